@@ -5,7 +5,9 @@ package boxcli
 
 import (
 	"fmt"
+	"time"
 
+	"github.com/AlecAivazis/survey/v2"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 
@@ -25,6 +27,30 @@ type addCmdFlags struct {
 	excludePlatforms []string
 	patchGlibc       bool
 	outputs          []string
+	dryRun           bool
+	file             string
+	stdin            bool
+	lockfile         string
+	nixpkgsCommit    string
+	noInstall        bool
+	buildVerbosity   string
+	buildLogLines    int
+	verbose          bool
+	skipVerify       bool
+	group            string
+	json             bool
+	markdown         bool
+	offline          bool
+	validateTimeout  time.Duration
+	deferValidation  bool
+	strictVersion    bool
+	onConflict       string
+	ifMissing        bool
+	buildEnv         map[string]string
+	reason           string
+	alternatives     map[string]string
+	gitCommit        bool
+	commitMessage    string
 }
 
 func addCmd() *cobra.Command {
@@ -35,7 +61,7 @@ func addCmd() *cobra.Command {
 		Short:   "Add a new package to your devbox",
 		PreRunE: ensureNixInstalled,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			if len(args) == 0 {
+			if len(args) == 0 && flags.file == "" && flags.lockfile == "" && !flags.stdin {
 				fmt.Fprintf(
 					cmd.ErrOrStderr(),
 					"Usage: %s\n\n%s\n",
@@ -71,26 +97,175 @@ func addCmd() *cobra.Command {
 	command.Flags().StringSliceVarP(
 		&flags.outputs, "outputs", "o", []string{},
 		"specify the outputs to select for the nix package")
+	command.Flags().BoolVar(
+		&flags.dryRun, "dry-run", false,
+		"validate the packages and show what would change without modifying devbox.json")
+	command.Flags().StringVarP(
+		&flags.file, "file", "f", "",
+		"add the packages listed in a file, one name@version per line")
+	command.Flags().BoolVar(
+		&flags.stdin, "stdin", false,
+		"also add the package names read from stdin, one per line")
+	command.Flags().StringVar(
+		&flags.lockfile, "from-lockfile", "",
+		"add the packages locked in another project's devbox.lock, pinned to their locked versions")
+	command.Flags().StringVar(
+		&flags.nixpkgsCommit, "nixpkgs-commit", "",
+		"pin the packages to this nixpkgs commit instead of resolving their version")
+	command.Flags().BoolVar(
+		&flags.noInstall, "no-install", false,
+		"only add the packages to devbox.json without installing them")
+	command.Flags().StringVar(
+		&flags.buildVerbosity, "build-output", "default",
+		"how much nix build output to show: default, verbose (stream build logs) or quiet (only on failure)")
+	command.Flags().IntVar(
+		&flags.buildLogLines, "build-log-lines", 0,
+		"how many lines at the end of a failed build's log to show (default 25, negative to not show the log)")
+	command.Flags().BoolVar(
+		&flags.verbose, "verbose", false,
+		"print details, such as the store paths added to and removed from the nix profile")
+	command.Flags().BoolVar(
+		&flags.skipVerify, "skip-verify", false,
+		"don't run the packages' verify commands after installing them")
+	command.Flags().StringVar(
+		&flags.group, "group", "",
+		"add the packages to a named group that can be installed with devbox install --group")
+	command.Flags().BoolVar(
+		&flags.json, "json", false,
+		"print the result, including plugin readmes, as a JSON object to stdout")
+	command.Flags().BoolVar(
+		&flags.markdown, "markdown", false,
+		"print plugin readmes in markdown format")
+	command.Flags().BoolVar(
+		&flags.offline, "offline", false,
+		"don't use the package search service; check packages against the local nixpkgs instead")
+	command.Flags().DurationVar(
+		&flags.validateTimeout, "validate-timeout", 0,
+		"how long to wait for the package search service before falling back to the legacy nixpkgs path (default 15s)")
+	command.Flags().BoolVar(
+		&flags.deferValidation, "defer-validation", false,
+		"add the packages to devbox.json without checking that they exist; they're validated by the next devbox install")
+	command.Flags().StringToStringVar(
+		&flags.buildEnv, "build-env", nil,
+		"set an environment variable, as KEY=VALUE, when building the packages")
+	command.Flags().StringVar(
+		&flags.onConflict, "on-conflict", "replace",
+		"what to do when devbox.json has another version of a package: replace, keep or prompt")
+	command.Flags().BoolVar(
+		&flags.ifMissing, "if-missing", false,
+		"only add packages that aren't in devbox.json yet, whatever their version")
+	command.Flags().BoolVar(
+		&flags.strictVersion, "strict-version", false,
+		"fail instead of warning when a package falls back to a nixpkgs version that doesn't match the requested version")
+	command.Flags().StringToStringVar(
+		&flags.alternatives, "on", nil,
+		"install a different package on each platform, as PLATFORM=PACKAGE, where PLATFORM is linux, darwin or a single platform")
+	command.Flags().StringVar(
+		&flags.reason, "reason", "",
+		"a note about why the packages were added, saved in devbox.json")
+	command.Flags().BoolVar(
+		&flags.gitCommit, "commit", false,
+		"commit devbox.json and devbox.lock to git after adding the packages")
+	command.Flags().StringVar(
+		&flags.commitMessage, "commit-message", devopt.DefaultCommitMessage,
+		"template for the --commit message, which can use {{.Action}} and {{.Packages}}")
 
 	return command
 }
 
 func addCmdFunc(cmd *cobra.Command, args []string, flags addCmdFlags) error {
 	box, err := devbox.Open(&devopt.Opts{
-		Dir:         flags.config.path,
-		Environment: flags.config.environment,
-		Stderr:      cmd.ErrOrStderr(),
+		Dir:            flags.config.path,
+		Environment:    flags.config.environment,
+		BuildVerbosity: flags.buildVerbosity,
+		BuildLogLines:  flags.buildLogLines,
+		Verbose:        flags.verbose,
+		SkipVerify:     flags.skipVerify,
+		Stderr:         cmd.ErrOrStderr(),
+		Stdin:          cmd.InOrStdin(),
 	})
 	if err != nil {
 		return errors.WithStack(err)
 	}
 
-	return box.Add(cmd.Context(), args, devopt.AddOpts{
-		AllowInsecure:    flags.allowInsecure,
-		DisablePlugin:    flags.disablePlugin,
-		Platforms:        flags.platforms,
-		ExcludePlatforms: flags.excludePlatforms,
-		PatchGlibc:       flags.patchGlibc,
-		Outputs:          flags.outputs,
-	})
+	conflictResolution, err := parseConflictResolution(flags.onConflict)
+	if err != nil {
+		return err
+	}
+
+	opts := devopt.AddOpts{
+		AllowInsecure:      flags.allowInsecure,
+		DisablePlugin:      flags.disablePlugin,
+		Platforms:          flags.platforms,
+		ExcludePlatforms:   flags.excludePlatforms,
+		PatchGlibc:         flags.patchGlibc,
+		Outputs:            flags.outputs,
+		BuildEnv:           flags.buildEnv,
+		Reason:             flags.reason,
+		Alternatives:       flags.alternatives,
+		DryRun:             flags.dryRun,
+		NixpkgsCommit:      flags.nixpkgsCommit,
+		SkipInstall:        flags.noInstall,
+		ReadFromStdin:      flags.stdin,
+		Group:              flags.group,
+		Offline:            flags.offline,
+		ValidateTimeout:    flags.validateTimeout,
+		DeferValidation:    flags.deferValidation,
+		StrictVersion:      flags.strictVersion,
+		ConflictResolution: conflictResolution,
+		ConflictPrompter:   surveyConflictPrompter{},
+		IfMissing:          flags.ifMissing,
+		GitCommit:          flags.gitCommit,
+		CommitMessage:      flags.commitMessage,
+		MarkdownReadme:     flags.markdown,
+	}
+	if flags.json {
+		opts.JSONOutput = cmd.OutOrStdout()
+	}
+	if flags.file != "" && flags.lockfile != "" {
+		return usererr.New("cannot specify both --file and --from-lockfile")
+	}
+	if flags.stdin && (flags.file != "" || flags.lockfile != "") {
+		return usererr.New("cannot specify --stdin with --file or --from-lockfile")
+	}
+	if flags.file != "" {
+		if len(args) > 0 {
+			return usererr.New("cannot specify both packages and --file")
+		}
+		return box.AddFromFile(cmd.Context(), flags.file, opts)
+	}
+	if flags.lockfile != "" {
+		if len(args) > 0 {
+			return usererr.New("cannot specify both packages and --from-lockfile")
+		}
+		return box.AddFromLockfile(cmd.Context(), flags.lockfile, opts)
+	}
+	return box.Add(cmd.Context(), args, opts)
+}
+
+func parseConflictResolution(s string) (devopt.ConflictResolution, error) {
+	switch s {
+	case "replace":
+		return devopt.ConflictReplace, nil
+	case "keep":
+		return devopt.ConflictKeep, nil
+	case "prompt":
+		return devopt.ConflictPrompt, nil
+	}
+	return 0, usererr.New("invalid --on-conflict value %q: must be replace, keep or prompt", s)
+}
+
+// surveyConflictPrompter asks the user in the terminal whether to replace a
+// package in devbox.json.
+type surveyConflictPrompter struct{}
+
+func (surveyConflictPrompter) ConfirmReplace(existing, added string) (bool, error) {
+	replace := false
+	prompt := &survey.Confirm{
+		Message: fmt.Sprintf("Replace %s with %s in devbox.json?", existing, added),
+	}
+	if err := survey.AskOne(prompt, &replace); err != nil {
+		return false, errors.WithStack(err)
+	}
+	return replace, nil
 }
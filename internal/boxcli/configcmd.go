@@ -0,0 +1,157 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package boxcli
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"go.jetpack.io/devbox/internal/devbox"
+	"go.jetpack.io/devbox/internal/devbox/devopt"
+)
+
+type aliasesCmdFlags struct {
+	config configFlags
+	json   bool
+}
+
+type copyPackagesCmdFlags struct {
+	config     configFlags
+	onConflict string
+	noInstall  bool
+}
+
+type setPlatformCmdFlags struct {
+	config           configFlags
+	excludePlatforms []string
+}
+
+func configCmd() *cobra.Command {
+	command := &cobra.Command{
+		Use:   "config",
+		Short: "View and edit the settings of the packages in devbox.json",
+	}
+	command.AddCommand(aliasesCmd())
+	command.AddCommand(copyPackagesCmd())
+	command.AddCommand(setPlatformCmd())
+	return command
+}
+
+func aliasesCmd() *cobra.Command {
+	flags := aliasesCmdFlags{}
+	command := &cobra.Command{
+		Use:   "aliases",
+		Short: "List the package aliases in devbox.json",
+		Long: "List the package aliases defined in the aliases field of devbox.json, and the " +
+			"packages they expand to when they're passed to devbox add.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			box, err := devbox.Open(&devopt.Opts{
+				Dir:         flags.config.path,
+				Environment: flags.config.environment,
+				Stderr:      cmd.ErrOrStderr(),
+			})
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			aliases := box.Aliases()
+			if flags.json {
+				enc := json.NewEncoder(cmd.OutOrStdout())
+				enc.SetIndent("", "  ")
+				return errors.WithStack(enc.Encode(aliases))
+			}
+			names := make([]string, 0, len(aliases))
+			for name := range aliases {
+				names = append(names, name)
+			}
+			slices.Sort(names)
+			for _, name := range names {
+				fmt.Fprintf(cmd.OutOrStdout(), "%s -> %s\n", name, aliases[name])
+			}
+			return nil
+		},
+	}
+
+	flags.config.register(command)
+	command.Flags().BoolVar(&flags.json, "json", false, "output the aliases as a JSON object")
+	return command
+}
+
+func copyPackagesCmd() *cobra.Command {
+	flags := copyPackagesCmdFlags{}
+	command := &cobra.Command{
+		Use:   "copy-packages <target-dir>",
+		Short: "Copy the packages in devbox.json to another project",
+		Long: "Add the packages in this project's devbox.json, with all of their settings, to " +
+			"the devbox.json of the project in <target-dir>, and install them there. " +
+			"--on-conflict decides what happens when the target already has a package with " +
+			"the same name but different settings, such as another version.",
+		Example: "  devbox config copy-packages ../other-project\n" +
+			"  devbox config copy-packages ../other-project --on-conflict keep --no-install",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			conflictResolution, err := parseConflictResolution(flags.onConflict)
+			if err != nil {
+				return err
+			}
+			box, err := devbox.Open(&devopt.Opts{
+				Dir:         flags.config.path,
+				Environment: flags.config.environment,
+				Stderr:      cmd.ErrOrStderr(),
+			})
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			return box.CopyPackagesTo(cmd.Context(), args[0], devopt.CopyPackagesOpts{
+				ConflictResolution: conflictResolution,
+				ConflictPrompter:   surveyConflictPrompter{},
+				SkipInstall:        flags.noInstall,
+			})
+		},
+	}
+
+	flags.config.register(command)
+	command.Flags().StringVar(
+		&flags.onConflict, "on-conflict", "replace",
+		"what to do when the target project has a package with different settings: replace, keep or prompt")
+	command.Flags().BoolVar(
+		&flags.noInstall, "no-install", false,
+		"only update the target's devbox.json, without installing the packages")
+	return command
+}
+
+func setPlatformCmd() *cobra.Command {
+	flags := setPlatformCmdFlags{}
+	command := &cobra.Command{
+		Use:   "set-platform <pkg>",
+		Short: "Set the platforms that a package is excluded from",
+		Long: "Set the platforms that a package is excluded from, replacing its current " +
+			"excluded_platforms. Run it without --exclude-platform to clear them. If the " +
+			"change doesn't affect the current platform, the project isn't installed again.",
+		Example: "  devbox config set-platform go --exclude-platform aarch64-darwin\n" +
+			"  devbox config set-platform go",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			box, err := devbox.Open(&devopt.Opts{
+				Dir:         flags.config.path,
+				Environment: flags.config.environment,
+				Stderr:      cmd.ErrOrStderr(),
+			})
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			return box.SetExcludedPlatforms(cmd.Context(), args[0], flags.excludePlatforms)
+		},
+	}
+
+	flags.config.register(command)
+	command.Flags().StringSliceVarP(
+		&flags.excludePlatforms, "exclude-platform", "e", []string{},
+		"exclude the package from this platform. Can be repeated.")
+	return command
+}
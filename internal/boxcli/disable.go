@@ -0,0 +1,63 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package boxcli
+
+import (
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"go.jetpack.io/devbox/internal/devbox"
+	"go.jetpack.io/devbox/internal/devbox/devopt"
+)
+
+type disableCmdFlags struct {
+	config configFlags
+}
+
+func disableCmd() *cobra.Command {
+	flags := disableCmdFlags{}
+	command := &cobra.Command{
+		Use:     "disable <pkg>...",
+		Short:   "Uninstall packages but keep them in devbox.json",
+		Long:    "Uninstall packages but keep them and their settings in devbox.json. Use `devbox enable` to install them again.",
+		Args:    cobra.MinimumNArgs(1),
+		PreRunE: ensureNixInstalled,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			box, err := openForDisable(cmd, flags)
+			if err != nil {
+				return err
+			}
+			return box.Disable(cmd.Context(), args...)
+		},
+	}
+	flags.config.register(command)
+	return command
+}
+
+func enableCmd() *cobra.Command {
+	flags := disableCmdFlags{}
+	command := &cobra.Command{
+		Use:     "enable <pkg>...",
+		Short:   "Install packages that were disabled with devbox disable",
+		Args:    cobra.MinimumNArgs(1),
+		PreRunE: ensureNixInstalled,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			box, err := openForDisable(cmd, flags)
+			if err != nil {
+				return err
+			}
+			return box.Enable(cmd.Context(), args...)
+		},
+	}
+	flags.config.register(command)
+	return command
+}
+
+func openForDisable(cmd *cobra.Command, flags disableCmdFlags) (*devbox.Devbox, error) {
+	box, err := devbox.Open(&devopt.Opts{
+		Dir:         flags.config.path,
+		Environment: flags.config.environment,
+		Stderr:      cmd.ErrOrStderr(),
+	})
+	return box, errors.WithStack(err)
+}
@@ -0,0 +1,69 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package boxcli
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"go.jetpack.io/devbox/internal/devbox"
+	"go.jetpack.io/devbox/internal/devbox/devopt"
+)
+
+type doctorCmdFlags struct {
+	config configFlags
+	json   bool
+}
+
+func doctorCmd() *cobra.Command {
+	flags := doctorCmdFlags{}
+	command := &cobra.Command{
+		Use:   "doctor",
+		Short: "Check that devbox.lock, the Nix store and the Nix profile match devbox.json",
+		Long: "Check that every package in devbox.json is locked in devbox.lock, that the locked " +
+			"store paths are in the Nix store, and that the project's Nix profile matches the " +
+			"environment. Problems are reported, but not fixed.",
+		Args:    cobra.NoArgs,
+		PreRunE: ensureNixInstalled,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return doctorCmdFunc(cmd, flags)
+		},
+	}
+
+	flags.config.register(command)
+	command.Flags().BoolVar(&flags.json, "json", false, "output the issues as a JSON array")
+	return command
+}
+
+func doctorCmdFunc(cmd *cobra.Command, flags doctorCmdFlags) error {
+	box, err := devbox.Open(&devopt.Opts{
+		Dir:         flags.config.path,
+		Environment: flags.config.environment,
+		Stderr:      cmd.ErrOrStderr(),
+	})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	issues, err := box.Doctor(cmd.Context())
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if flags.json {
+		enc := json.NewEncoder(cmd.OutOrStdout())
+		enc.SetIndent("", "  ")
+		return errors.WithStack(enc.Encode(issues))
+	}
+	if len(issues) == 0 {
+		fmt.Fprintln(cmd.OutOrStdout(), "No issues found.")
+		return nil
+	}
+	for _, issue := range issues {
+		fmt.Fprintln(cmd.OutOrStdout(), issue)
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package boxcli
+
+import (
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"go.jetpack.io/devbox/internal/devbox"
+	"go.jetpack.io/devbox/internal/devbox/devopt"
+)
+
+type freezeCmdFlags struct {
+	config configFlags
+}
+
+func freezeCmd() *cobra.Command {
+	flags := freezeCmdFlags{}
+	command := &cobra.Command{
+		Use:   "freeze",
+		Short: "Pin every package in devbox.json to its locked version",
+		Long: "Pin every floating package in devbox.json, such as go@latest, to the exact " +
+			"version it's locked to in devbox.lock. Packages that are already pinned to a version, " +
+			"flakes and packages with platform alternatives aren't changed. Packages without a " +
+			"version use the nixpkgs commit in devbox.json, so they're reported instead.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			box, err := devbox.Open(&devopt.Opts{
+				Dir:         flags.config.path,
+				Environment: flags.config.environment,
+				Stderr:      cmd.ErrOrStderr(),
+			})
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			return box.Freeze(cmd.Context())
+		},
+	}
+
+	flags.config.register(command)
+	return command
+}
@@ -6,6 +6,7 @@ package boxcli
 import (
 	"cmp"
 	"fmt"
+	"path/filepath"
 	"regexp"
 
 	"github.com/pkg/errors"
@@ -39,6 +40,11 @@ type GenerateReadmeCmdFlags struct {
 	template     string
 }
 
+type generateDotenvCmdFlags struct {
+	config      configFlags
+	includePath bool
+}
+
 type GenerateAliasCmdFlags struct {
 	config   configFlags
 	prefix   string
@@ -60,6 +66,7 @@ func generateCmd() *cobra.Command {
 	command.AddCommand(dockerfileCmd())
 	command.AddCommand(debugCmd())
 	command.AddCommand(direnvCmd())
+	command.AddCommand(dotenvCmd())
 	command.AddCommand(genReadmeCmd())
 	command.AddCommand(sshConfigCmd())
 	flags.config.register(command)
@@ -158,6 +165,44 @@ func direnvCmd() *cobra.Command {
 	return command
 }
 
+func dotenvCmd() *cobra.Command {
+	flags := &generateDotenvCmdFlags{}
+	command := &cobra.Command{
+		Use:   "dotenv [filename]",
+		Short: "Export the environment of this project to a .env file",
+		Long: "Export the environment of this project to a .env file, for tools that " +
+			"can't run `devbox shellenv`. The file is a snapshot, so run the command " +
+			"again after changing the project. Only variables set by Devbox or Nix are " +
+			"written, not the rest of your environment. Defaults to .env in the project directory.",
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			box, err := devbox.Open(&devopt.Opts{
+				Dir:         flags.config.path,
+				Environment: flags.config.environment,
+				Stderr:      cmd.ErrOrStderr(),
+			})
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			outPath := filepath.Join(box.ProjectDir(), ".env")
+			if len(args) > 0 {
+				outPath = args[0]
+			}
+			err = box.ExportDotenv(
+				cmd.Context(), outPath, devopt.ExportDotenvOpts{IncludePath: flags.includePath})
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			fmt.Fprintf(cmd.ErrOrStderr(), "Wrote the environment to %s.\n", outPath)
+			return nil
+		},
+	}
+	flags.config.register(command)
+	command.Flags().BoolVar(
+		&flags.includePath, "include-path", false, "Include PATH in the file")
+	return command
+}
+
 func sshConfigCmd() *cobra.Command {
 	flags := &generateCmdFlags{}
 	command := &cobra.Command{
@@ -4,6 +4,7 @@
 package boxcli
 
 import (
+	"encoding/json"
 	"fmt"
 
 	"github.com/pkg/errors"
@@ -16,6 +17,7 @@ import (
 type infoCmdFlags struct {
 	config   configFlags
 	markdown bool
+	json     bool
 }
 
 func infoCmd() *cobra.Command {
@@ -32,6 +34,9 @@ func infoCmd() *cobra.Command {
 
 	flags.config.register(command)
 	command.Flags().BoolVar(&flags.markdown, "markdown", false, "output in markdown format")
+	command.Flags().BoolVar(
+		&flags.json, "json", false,
+		"output the details of a package in devbox.json, such as its locked version, store paths and plugin, in JSON format")
 	return command
 }
 
@@ -45,6 +50,16 @@ func infoCmdFunc(cmd *cobra.Command, pkg string, flags infoCmdFlags) error {
 		return errors.WithStack(err)
 	}
 
+	if flags.json {
+		details, err := box.PackageInfo(cmd.Context(), pkg)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		enc := json.NewEncoder(cmd.OutOrStdout())
+		enc.SetIndent("", "  ")
+		return errors.WithStack(enc.Encode(details))
+	}
+
 	info, err := box.Info(cmd.Context(), pkg, flags.markdown)
 	if err != nil {
 		return errors.WithStack(err)
@@ -9,6 +9,7 @@ import (
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
 	"go.jetpack.io/devbox/internal/devbox"
 	"go.jetpack.io/devbox/internal/devbox/devopt"
 	"go.jetpack.io/devbox/internal/devpkg"
@@ -17,7 +18,19 @@ import (
 
 type installCmdFlags struct {
 	runCmdFlags
-	tidyLockfile bool
+	storeRoot      string
+	tidyLockfile   bool
+	buildVerbosity string
+	buildLogLines  int
+	groups         []string
+	only           []string
+	platform       string
+	pushToCache    string
+	keepGoing      bool
+	verbose        bool
+	skipVerify     bool
+	maxJobs        int
+	cores          int
 }
 
 func installCmd() *cobra.Command {
@@ -38,17 +51,83 @@ func installCmd() *cobra.Command {
 		"Fix missing store paths in the devbox.lock file.",
 		// Could potentially do more in the future.
 	)
+	command.Flags().StringVar(
+		&flags.storeRoot, "store", "",
+		"Root directory of a non-default Nix store to install packages into. Must be a local directory, not a store URL.",
+	)
+	command.Flags().StringVar(
+		&flags.buildVerbosity, "build-output", "default",
+		"How much nix build output to show: default, verbose (stream build logs) or quiet (only on failure).",
+	)
+	command.Flags().IntVar(
+		&flags.buildLogLines, "build-log-lines", 0,
+		"How many lines at the end of a failed build's log to show (default 25, negative to not show the log).",
+	)
+	command.Flags().StringSliceVar(
+		&flags.groups, "group", nil,
+		"Only install the packages in these groups and the packages without a group.",
+	)
+	command.Flags().StringSliceVar(
+		&flags.only, "only", nil,
+		"Only install these packages and the packages their plugins require. Other installed packages are kept.",
+	)
+	command.Flags().StringVar(
+		&flags.pushToCache, "push-to-cache", "",
+		"URI of a Nix binary cache to copy locally built packages to after installing them.",
+	)
+	command.Flags().BoolVar(
+		&flags.keepGoing, "keep-going", false,
+		"Keep installing the other packages when a package fails to install, and report the failures at the end.",
+	)
+	command.Flags().BoolVar(
+		&flags.verbose, "verbose", false,
+		"Print details, such as the store paths added to and removed from the Nix profile.",
+	)
+	command.Flags().BoolVar(
+		&flags.skipVerify, "skip-verify", false,
+		"Don't run the packages' verify commands after installing them.",
+	)
+	command.Flags().IntVar(
+		&flags.maxJobs, "max-jobs", 0,
+		"Number of nix builds to run in parallel. Overrides max_jobs in devbox.json. Defaults to nix's setting.",
+	)
+	command.Flags().IntVar(
+		&flags.cores, "cores", 0,
+		"Number of CPU cores each nix build may use, or 0 for all. Overrides cores in devbox.json. Defaults to nix's setting.",
+	)
+	command.Flags().StringVar(
+		&flags.platform, "platform", "",
+		"Only build the packages for this platform into the Nix store, without updating the environment. Useful to warm a binary cache for another platform.",
+	)
 
 	return command
 }
 
 func installCmdFunc(cmd *cobra.Command, flags installCmdFlags) error {
 	// Check the directory exists.
-	box, err := devbox.Open(&devopt.Opts{
-		Dir:         flags.config.path,
-		Environment: flags.config.environment,
-		Stderr:      cmd.ErrOrStderr(),
-	})
+	opts := &devopt.Opts{
+		Dir:            flags.config.path,
+		Environment:    flags.config.environment,
+		StoreRoot:      flags.storeRoot,
+		BuildVerbosity: flags.buildVerbosity,
+		BuildLogLines:  flags.buildLogLines,
+		Stderr:         cmd.ErrOrStderr(),
+		KeepGoing:      flags.keepGoing,
+		Verbose:        flags.verbose,
+		SkipVerify:     flags.skipVerify,
+	}
+	// Zero is a meaningful value for both flags, so they're only passed on
+	// when they're set.
+	if cmd.Flags().Changed("max-jobs") {
+		opts.MaxJobs = &flags.maxJobs
+	}
+	if cmd.Flags().Changed("cores") {
+		opts.Cores = &flags.cores
+	}
+	if flags.pushToCache != "" {
+		opts.PushToCache = &devopt.PushToCache{URI: flags.pushToCache}
+	}
+	box, err := devbox.Open(opts)
 	if err != nil {
 		return errors.WithStack(err)
 	}
@@ -56,7 +135,24 @@ func installCmdFunc(cmd *cobra.Command, flags installCmdFlags) error {
 	if flags.tidyLockfile {
 		ctx = ux.HideMessage(ctx, devpkg.MissingStorePathsWarning)
 	}
-	if err = box.Install(ctx); err != nil {
+	if flags.platform != "" {
+		if err = box.BuildForSystem(ctx, flags.platform); err != nil {
+			return errors.WithStack(err)
+		}
+		fmt.Fprintf(cmd.ErrOrStderr(), "Finished building packages for %s.\n", flags.platform)
+		return nil
+	}
+	if len(flags.groups) > 0 && len(flags.only) > 0 {
+		return usererr.New("The --group and --only flags can't be used together.")
+	}
+	if len(flags.groups) > 0 {
+		err = box.InstallGroups(ctx, flags.groups...)
+	} else if len(flags.only) > 0 {
+		err = box.InstallSubset(ctx, flags.only...)
+	} else {
+		err = box.Install(ctx)
+	}
+	if err != nil {
 		return errors.WithStack(err)
 	}
 	if flags.tidyLockfile {
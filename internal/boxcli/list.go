@@ -14,7 +14,8 @@ import (
 )
 
 type listCmdFlags struct {
-	config configFlags
+	config    configFlags
+	installed bool
 }
 
 func listCmd() *cobra.Command {
@@ -32,6 +33,9 @@ func listCmd() *cobra.Command {
 			if err != nil {
 				return errors.WithStack(err)
 			}
+			if flags.installed {
+				return listInstalled(cmd, box)
+			}
 			for _, p := range box.AllPackageNamesIncludingRemovedTriggerPackages() {
 				fmt.Fprintf(cmd.OutOrStdout(), "* %s\n", p)
 			}
@@ -39,5 +43,27 @@ func listCmd() *cobra.Command {
 		},
 	}
 	flags.config.register(cmd)
+	cmd.Flags().BoolVar(
+		&flags.installed, "installed", false,
+		"List the installed version of each package, and the packages that aren't installed yet.",
+	)
 	return cmd
 }
+
+func listInstalled(cmd *cobra.Command, box *devbox.Devbox) error {
+	installed, err := box.ListInstalled(cmd.Context())
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	for _, p := range installed {
+		switch {
+		case p.Pending:
+			fmt.Fprintf(cmd.OutOrStdout(), "* %s (pending)\n", p.Name)
+		case p.Version != "":
+			fmt.Fprintf(cmd.OutOrStdout(), "* %s - %s\n", p.Name, p.Version)
+		default:
+			fmt.Fprintf(cmd.OutOrStdout(), "* %s\n", p.Name)
+		}
+	}
+	return nil
+}
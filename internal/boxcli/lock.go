@@ -0,0 +1,75 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package boxcli
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"go.jetpack.io/devbox/internal/devbox"
+	"go.jetpack.io/devbox/internal/devbox/devopt"
+)
+
+type lockDiffCmdFlags struct {
+	config configFlags
+	json   bool
+}
+
+func lockCmd() *cobra.Command {
+	command := &cobra.Command{
+		Use:   "lock",
+		Short: "Inspect devbox.lock files",
+	}
+	command.AddCommand(lockDiffCmd())
+	return command
+}
+
+func lockDiffCmd() *cobra.Command {
+	flags := lockDiffCmdFlags{}
+	command := &cobra.Command{
+		Use:   "diff <old> <new>",
+		Short: "Show the package changes between two lockfiles",
+		Long: "Show the packages that were added, removed, changed version or were rebuilt " +
+			"with a different hash between two devbox.lock files, and the nixpkgs commit " +
+			"they're resolved to when it changed. It's easier to review than a diff of the " +
+			"lockfiles' JSON.",
+		Example: "  git show main:devbox.lock > /tmp/old.lock\n" +
+			"  devbox lock diff /tmp/old.lock devbox.lock",
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			box, err := devbox.Open(&devopt.Opts{
+				Dir:         flags.config.path,
+				Environment: flags.config.environment,
+				Stderr:      cmd.ErrOrStderr(),
+			})
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			changes, err := box.DiffLockfiles(args[0], args[1])
+			if err != nil {
+				return err
+			}
+			if flags.json {
+				enc := json.NewEncoder(cmd.OutOrStdout())
+				enc.SetIndent("", "  ")
+				return errors.WithStack(enc.Encode(changes))
+			}
+			if len(changes) == 0 {
+				fmt.Fprintln(cmd.OutOrStdout(), "The lockfiles lock the same packages.")
+				return nil
+			}
+			for _, change := range changes {
+				fmt.Fprintln(cmd.OutOrStdout(), change)
+			}
+			return nil
+		},
+	}
+
+	flags.config.register(command)
+	command.Flags().BoolVar(&flags.json, "json", false, "output the changes as a JSON array")
+	return command
+}
@@ -49,6 +49,8 @@ func SyncLockfiles(pkgs []string) error {
 					lockFile.Packages[key].Source = latestPkg.Source
 					lockFile.Packages[key].Version = latestPkg.Version
 					lockFile.Packages[key].Systems = latestPkg.Systems
+					lockFile.Packages[key].ResolvedOffline = latestPkg.ResolvedOffline
+					lockFile.Packages[key].Sha256 = latestPkg.Sha256
 					changed = true
 				}
 			}
@@ -0,0 +1,69 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package boxcli
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"go.jetpack.io/devbox/internal/devbox"
+	"go.jetpack.io/devbox/internal/devbox/devopt"
+)
+
+type outdatedCmdFlags struct {
+	config configFlags
+	json   bool
+}
+
+func outdatedCmd() *cobra.Command {
+	flags := outdatedCmdFlags{}
+	command := &cobra.Command{
+		Use:   "outdated",
+		Short: "List packages that have newer versions",
+		Long: "List the packages in devbox.json whose locked version is older than the newest " +
+			"version in the Devbox search index. Packages pinned to a version are marked as " +
+			"pinned, since `devbox update` doesn't change their version. Flakes and packages " +
+			"without a version are skipped.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return outdatedCmdFunc(cmd, flags)
+		},
+	}
+
+	flags.config.register(command)
+	command.Flags().BoolVar(&flags.json, "json", false, "output the packages as a JSON array")
+	return command
+}
+
+func outdatedCmdFunc(cmd *cobra.Command, flags outdatedCmdFlags) error {
+	box, err := devbox.Open(&devopt.Opts{
+		Dir:         flags.config.path,
+		Environment: flags.config.environment,
+		Stderr:      cmd.ErrOrStderr(),
+	})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	outdated, err := box.Outdated(cmd.Context())
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if flags.json {
+		enc := json.NewEncoder(cmd.OutOrStdout())
+		enc.SetIndent("", "  ")
+		return errors.WithStack(enc.Encode(outdated))
+	}
+	if len(outdated) == 0 {
+		fmt.Fprintln(cmd.OutOrStdout(), "All packages are up to date.")
+		return nil
+	}
+	for _, pkg := range outdated {
+		fmt.Fprintln(cmd.OutOrStdout(), pkg)
+	}
+	return nil
+}
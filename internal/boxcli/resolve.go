@@ -0,0 +1,47 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package boxcli
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"go.jetpack.io/devbox/internal/devbox"
+	"go.jetpack.io/devbox/internal/devbox/devopt"
+)
+
+type resolveCmdFlags struct {
+	config configFlags
+}
+
+func resolveCmd() *cobra.Command {
+	flags := resolveCmdFlags{}
+	command := &cobra.Command{
+		Use:   "resolve",
+		Short: "Lock the packages in devbox.json without installing them",
+		Long: "Lock every package in devbox.json to its resolved version in devbox.lock, " +
+			"without building or installing anything. Packages that are already locked " +
+			"aren't changed, so this works offline unless new packages need to be looked up.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			box, err := devbox.Open(&devopt.Opts{
+				Dir:         flags.config.path,
+				Environment: flags.config.environment,
+				Stderr:      cmd.ErrOrStderr(),
+			})
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			if err := box.ResolveLock(cmd.Context()); err != nil {
+				return errors.WithStack(err)
+			}
+			fmt.Fprintln(cmd.ErrOrStderr(), "Updated devbox.lock.")
+			return nil
+		},
+	}
+	flags.config.register(command)
+	return command
+}
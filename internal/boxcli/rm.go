@@ -11,14 +11,20 @@ import (
 )
 
 type removeCmdFlags struct {
-	config configFlags
+	config        configFlags
+	force         bool
+	gitCommit     bool
+	commitMessage string
+	verbose       bool
 }
 
 func removeCmd() *cobra.Command {
 	flags := removeCmdFlags{}
 	command := &cobra.Command{
-		Use:     "rm <pkg>...",
-		Short:   "Remove a package from your devbox",
+		Use:   "rm <pkg>...",
+		Short: "Remove a package from your devbox",
+		Long: "Remove packages from your devbox. A package can be a quoted glob pattern, " +
+			"such as 'python*', to remove every package whose name matches it.",
 		Args:    cobra.MinimumNArgs(1),
 		PreRunE: ensureNixInstalled,
 		RunE: func(cmd *cobra.Command, args []string) error {
@@ -27,6 +33,22 @@ func removeCmd() *cobra.Command {
 	}
 
 	flags.config.register(command)
+	command.Flags().BoolVarP(
+		&flags.force, "force", "f", false,
+		"also remove matching nix profile entries for packages that are not in devbox.json (best-effort)",
+	)
+	command.Flags().BoolVar(
+		&flags.gitCommit, "commit", false,
+		"commit devbox.json and devbox.lock to git after removing the packages",
+	)
+	command.Flags().StringVar(
+		&flags.commitMessage, "commit-message", devopt.DefaultCommitMessage,
+		"template for the --commit message, which can use {{.Action}} and {{.Packages}}",
+	)
+	command.Flags().BoolVar(
+		&flags.verbose, "verbose", false,
+		"print details, such as the store paths added to and removed from the nix profile",
+	)
 	return command
 }
 
@@ -34,11 +56,16 @@ func runRemoveCmd(cmd *cobra.Command, args []string, flags removeCmdFlags) error
 	box, err := devbox.Open(&devopt.Opts{
 		Dir:         flags.config.path,
 		Environment: flags.config.environment,
+		Verbose:     flags.verbose,
 		Stderr:      cmd.ErrOrStderr(),
 	})
 	if err != nil {
 		return errors.WithStack(err)
 	}
 
-	return box.Remove(cmd.Context(), args...)
+	return box.Remove(cmd.Context(), devopt.RemoveOpts{
+		Force:         flags.force,
+		GitCommit:     flags.gitCommit,
+		CommitMessage: flags.commitMessage,
+	}, args...)
 }
@@ -60,7 +60,12 @@ func RootCmd() *cobra.Command {
 		command.AddCommand(authCmd())
 	}
 	command.AddCommand(cacheCmd())
+	command.AddCommand(configCmd())
 	command.AddCommand(createCmd())
+	command.AddCommand(disableCmd())
+	command.AddCommand(doctorCmd())
+	command.AddCommand(enableCmd())
+	command.AddCommand(freezeCmd())
 	command.AddCommand(secretsCmd())
 	command.AddCommand(generateCmd())
 	command.AddCommand(globalCmd())
@@ -69,8 +74,11 @@ func RootCmd() *cobra.Command {
 	command.AddCommand(installCmd())
 	command.AddCommand(integrateCmd())
 	command.AddCommand(listCmd())
+	command.AddCommand(lockCmd())
 	command.AddCommand(logCmd())
+	command.AddCommand(outdatedCmd())
 	command.AddCommand(removeCmd())
+	command.AddCommand(resolveCmd())
 	command.AddCommand(runCmd(runFlagDefaults{}))
 	command.AddCommand(searchCmd())
 	command.AddCommand(servicesCmd())
@@ -79,6 +87,7 @@ func RootCmd() *cobra.Command {
 	command.AddCommand(shellEnvCmd(shellenvFlagDefaults{
 		recomputeEnv: true,
 	}))
+	command.AddCommand(storePathCmd())
 	command.AddCommand(updateCmd())
 	command.AddCommand(versionCmd())
 	// Preview commands
@@ -18,6 +18,7 @@ import (
 type shellCmdFlags struct {
 	envFlag
 	config     configFlags
+	groups     []string
 	omitNixEnv bool
 	printEnv   bool
 	pure       bool
@@ -53,6 +54,9 @@ func shellCmd(defaults shellFlagDefaults) *cobra.Command {
 		"shell environment will omit the env-vars from print-dev-env",
 	)
 	_ = command.Flags().MarkHidden("omit-nix-env")
+	command.Flags().StringSliceVar(
+		&flags.groups, "group", nil,
+		"only put the profiles of these package groups on the PATH. Requires group_profiles in devbox.json")
 
 	flags.config.register(command)
 	flags.envFlag.register(command)
@@ -92,6 +96,7 @@ func runShellCmd(cmd *cobra.Command, flags shellCmdFlags) error {
 	}
 
 	return box.Shell(cmd.Context(), devopt.EnvOptions{
+		Groups:     flags.groups,
 		OmitNixEnv: flags.omitNixEnv,
 		Pure:       flags.pure,
 	})
@@ -17,6 +17,7 @@ import (
 type shellEnvCmdFlags struct {
 	envFlag
 	config            configFlags
+	groups            []string
 	omitNixEnv        bool
 	install           bool
 	noRefreshAlias    bool
@@ -75,6 +76,9 @@ func shellEnvCmd(defaults shellenvFlagDefaults) *cobra.Command {
 		"shell environment will omit the env-vars from print-dev-env",
 	)
 	_ = command.Flags().MarkHidden("omit-nix-env")
+	command.Flags().StringSliceVar(
+		&flags.groups, "group", nil,
+		"only put the profiles of these package groups on the PATH. Requires group_profiles in devbox.json")
 
 	command.Flags().BoolVarP(
 		&flags.recomputeEnv, "recompute", "r", defaults.recomputeEnv,
@@ -118,6 +122,7 @@ func shellEnvFunc(
 	envStr, err := box.EnvExports(ctx, devopt.EnvExportsOpts{
 		DontRecomputeEnvironment: !flags.recomputeEnv,
 		EnvOptions: devopt.EnvOptions{
+			Groups:            flags.groups,
 			OmitNixEnv:        flags.omitNixEnv,
 			PreservePathStack: flags.preservePathStack,
 			Pure:              flags.pure,
@@ -0,0 +1,49 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package boxcli
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"go.jetpack.io/devbox/internal/devbox"
+	"go.jetpack.io/devbox/internal/devbox/devopt"
+)
+
+type storePathCmdFlags struct {
+	config configFlags
+}
+
+func storePathCmd() *cobra.Command {
+	flags := storePathCmdFlags{}
+	command := &cobra.Command{
+		Use:   "store-path <pkg>",
+		Short: "Print the Nix store path of an installed package",
+		Long: "Print the Nix store path that is installed for a package in devbox.json, so " +
+			"that scripts can refer to its files without parsing the output of devbox shellenv.",
+		Example: "  $(devbox store-path go)/bin/go version",
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			box, err := devbox.Open(&devopt.Opts{
+				Dir:         flags.config.path,
+				Environment: flags.config.environment,
+				Stderr:      cmd.ErrOrStderr(),
+			})
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			path, err := box.StorePathFor(cmd.Context(), args[0])
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), path)
+			return nil
+		},
+	}
+
+	flags.config.register(command)
+	return command
+}
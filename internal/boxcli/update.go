@@ -17,6 +17,9 @@ type updateCmdFlags struct {
 	config      configFlags
 	sync        bool
 	allProjects bool
+	security    bool
+	verbose     bool
+	skipVerify  bool
 }
 
 func updateCmd() *cobra.Command {
@@ -49,6 +52,24 @@ func updateCmd() *cobra.Command {
 		false,
 		"update all projects in the working directory, recursively.",
 	)
+	command.Flags().BoolVar(
+		&flags.security,
+		"security",
+		false,
+		"only update packages with auto_update set to \"security\" whose locked version has known vulnerabilities.",
+	)
+	command.Flags().BoolVar(
+		&flags.verbose,
+		"verbose",
+		false,
+		"print details, such as the store paths added to and removed from the nix profile.",
+	)
+	command.Flags().BoolVar(
+		&flags.skipVerify,
+		"skip-verify",
+		false,
+		"don't run the packages' verify commands after installing them.",
+	)
 	return command
 }
 
@@ -56,6 +77,9 @@ func updateCmdFunc(cmd *cobra.Command, args []string, flags *updateCmdFlags) err
 	if len(args) > 0 && flags.sync {
 		return usererr.New("cannot specify both a package and --sync")
 	}
+	if flags.security && (len(args) > 0 || flags.sync || flags.allProjects) {
+		return usererr.New("cannot use --security with packages, --sync-lock, or --all-projects")
+	}
 
 	if flags.allProjects {
 		return updateAllProjects(cmd, args)
@@ -68,12 +92,17 @@ func updateCmdFunc(cmd *cobra.Command, args []string, flags *updateCmdFlags) err
 	box, err := devbox.Open(&devopt.Opts{
 		Dir:         flags.config.path,
 		Environment: flags.config.environment,
+		Verbose:     flags.verbose,
+		SkipVerify:  flags.skipVerify,
 		Stderr:      cmd.ErrOrStderr(),
 	})
 	if err != nil {
 		return errors.WithStack(err)
 	}
 
+	if flags.security {
+		return box.ApplySecurityUpdates(cmd.Context())
+	}
 	return box.Update(cmd.Context(), devopt.UpdateOpts{
 		Pkgs: args,
 	})
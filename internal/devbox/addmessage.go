@@ -0,0 +1,196 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/pkg/errors"
+	"go.jetpack.io/devbox/internal/devbox/devopt"
+	"go.jetpack.io/devbox/internal/lock"
+	"go.jetpack.io/devbox/internal/nix"
+	"go.jetpack.io/devbox/internal/plugin"
+	"go.jetpack.io/devbox/internal/shellgen"
+	"go.jetpack.io/devbox/internal/ux"
+)
+
+// PostAddMessage is what Add reports once the packages are added. It's
+// rendered as text by default, or as JSON with AddOpts.JSONOutput.
+type PostAddMessage struct {
+	// Added are the names written to devbox.json.
+	Added []string `json:"added"`
+	// Unchanged are the requested packages that were already in devbox.json
+	// and weren't modified. Packages that only had options (such as
+	// platforms) set aren't considered unchanged.
+	Unchanged []string `json:"unchanged"`
+	// Readmes are the readmes of the plugins for the requested packages.
+	Readmes []PluginReadme `json:"readmes"`
+	// PlatformNotes explain why some of the packages aren't installed on
+	// the current platform.
+	PlatformNotes []string `json:"platform_notes"`
+	// GlibcNotes say whether patching glibc, requested with
+	// AddOpts.PatchGlibc, patched the packages or was skipped.
+	GlibcNotes []string `json:"glibc_notes"`
+}
+
+// PluginReadme is the readme of the plugin for a package.
+type PluginReadme struct {
+	Package string `json:"package"`
+	Readme  string `json:"readme"`
+}
+
+func (d *Devbox) postAddMessage(
+	ctx context.Context,
+	result AddResult,
+	opts devopt.AddOpts,
+) (*PostAddMessage, error) {
+	msg := &PostAddMessage{
+		Added:         result.Added,
+		Unchanged:     []string{},
+		Readmes:       []PluginReadme{},
+		PlatformNotes: []string{},
+		GlibcNotes:    []string{},
+	}
+	for _, input := range result.packages {
+		readme, err := plugin.Readme(ctx, input, d.projectDir, opts.MarkdownReadme)
+		if err != nil {
+			return nil, err
+		}
+		if readme != "" {
+			msg.Readmes = append(msg.Readmes, PluginReadme{Package: input.Raw, Readme: readme})
+		}
+	}
+
+	msg.Unchanged = append(msg.Unchanged, result.Unchanged...)
+
+	if len(opts.Platforms) > 0 || len(opts.ExcludePlatforms) > 0 {
+		requested := slices.Concat(result.Added, result.Updated, result.Unchanged)
+		for _, pkg := range d.TopLevelPackages() {
+			if !pkg.IsInstallable() && slices.Contains(requested, pkg.Raw) {
+				msg.PlatformNotes = append(msg.PlatformNotes, fmt.Sprintf(
+					"Package %q is not enabled on %s, so it wasn't installed", pkg.Raw, nix.System()))
+			}
+		}
+	}
+	if opts.PatchGlibc {
+		notes, err := d.glibcNotes(ctx, result, opts)
+		if err != nil {
+			return nil, err
+		}
+		msg.GlibcNotes = notes
+	}
+	return msg, nil
+}
+
+// glibcNotes explains what patch_glibc did for the requested packages. It's
+// only needed on Linux, so it's a no-op elsewhere. On Linux, each patched
+// package is built into the environment as a <name>-devbox-patched-glibc
+// store path, which is how a successful patch is told apart from one that
+// didn't take effect.
+func (d *Devbox) glibcNotes(ctx context.Context, result AddResult, opts devopt.AddOpts) ([]string, error) {
+	requested := slices.Concat(result.Added, result.Updated, result.Unchanged)
+	pkgs := []string{}
+	for _, pkg := range d.TopLevelPackages() {
+		if slices.Contains(requested, pkg.Raw) && pkg.IsInstallable() {
+			pkgs = append(pkgs, pkg.Raw)
+		}
+	}
+	if len(pkgs) == 0 {
+		return []string{}, nil
+	}
+
+	notes := []string{}
+	if !nix.SystemIsLinux() {
+		for _, pkg := range pkgs {
+			notes = append(notes, fmt.Sprintf(
+				"Skipped patching glibc for %q because %s doesn't need it. It's only applied on Linux",
+				pkg, nix.System()))
+		}
+		return notes, nil
+	}
+	if opts.SkipInstall {
+		for _, pkg := range pkgs {
+			notes = append(notes, fmt.Sprintf(
+				"Package %q will have its binaries patched to use a newer glibc when it's installed", pkg))
+		}
+		return notes, nil
+	}
+
+	// Outside a devbox shell, Add only installs the packages into the
+	// store, so the flake must be regenerated to have the patched builds.
+	if !d.IsEnvEnabled() {
+		if err := shellgen.GenerateForPrintEnv(ctx, d); err != nil {
+			return nil, err
+		}
+	}
+	env, err := d.execPrintDevEnv(ctx, d.IsEnvEnabled() /*usePrintDevEnvCache*/)
+	if err != nil {
+		return nil, err
+	}
+	buildInputs := strings.Fields(env["buildInputs"])
+	for _, pkg := range pkgs {
+		if isGlibcPatched(buildInputs, d.lockfile.Get(pkg)) {
+			notes = append(notes, fmt.Sprintf("Patched the binaries of %q to use a newer glibc", pkg))
+		} else {
+			notes = append(notes, fmt.Sprintf(
+				"Package %q has patch_glibc set, but the environment has no patched build of it. "+
+					"Run devbox install to apply it", pkg))
+		}
+	}
+	return notes, nil
+}
+
+// isGlibcPatched reports whether buildInputs has the devbox-patched-glibc
+// build of the package locked to entry. The build is named after the
+// package's default output. Without a locked store path, any patched build
+// counts.
+func isGlibcPatched(buildInputs []string, entry *lock.Package) bool {
+	suffix := "-devbox-patched-glibc"
+	if entry != nil && entry.Systems[nix.System()] != nil {
+		for _, out := range entry.Systems[nix.System()].Outputs {
+			// The store path is <hash>-<name>, where the hash is 32
+			// characters long.
+			if base := filepath.Base(out.Path); (out.Default || out.Name == "out") && len(base) > 33 {
+				suffix = "-" + base[33:] + suffix
+				break
+			}
+		}
+	}
+	return slices.ContainsFunc(buildInputs, func(p string) bool {
+		return strings.HasSuffix(p, suffix)
+	})
+}
+
+// WriteText writes the message for humans. This is the default output of Add.
+func (m *PostAddMessage) WriteText(w io.Writer) {
+	for _, readme := range m.Readmes {
+		fmt.Fprintf(w, "%s\n", readme.Readme)
+	}
+	for _, note := range m.PlatformNotes {
+		ux.Finfo(w, "%s\n", note)
+	}
+	for _, note := range m.GlibcNotes {
+		ux.Finfo(w, "%s\n", note)
+	}
+	if len(m.Unchanged) == 1 {
+		ux.Finfo(w, "Package %q was already in devbox.json and was not modified\n", m.Unchanged[0])
+	} else if len(m.Unchanged) > 1 {
+		ux.Finfo(w, "Packages %s were already in devbox.json and were not modified\n",
+			strings.Join(m.Unchanged, ", "),
+		)
+	}
+}
+
+// WriteJSON writes the message as a single JSON object.
+func (m *PostAddMessage) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return errors.WithStack(enc.Encode(m))
+}
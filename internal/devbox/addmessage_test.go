@@ -0,0 +1,91 @@
+package devbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.jetpack.io/devbox/internal/devbox/devopt"
+	"go.jetpack.io/devbox/internal/devpkg"
+	"go.jetpack.io/devbox/internal/lock"
+	"go.jetpack.io/devbox/internal/nix"
+)
+
+func TestPostAddMessage(t *testing.T) {
+	msg := &PostAddMessage{
+		Added:         []string{"postgresql@14"},
+		Unchanged:     []string{"go@1.22"},
+		Readmes:       []PluginReadme{{Package: "postgresql@14", Readme: "\npostgresql NOTES:\n"}},
+		PlatformNotes: []string{},
+	}
+
+	text := &bytes.Buffer{}
+	msg.WriteText(text)
+	require.True(t, strings.HasPrefix(text.String(), "\npostgresql NOTES:\n"))
+	require.Contains(t, text.String(), `Package "go@1.22" was already in devbox.json and was not modified`)
+
+	out := &bytes.Buffer{}
+	require.NoError(t, msg.WriteJSON(out))
+	got := &PostAddMessage{}
+	require.NoError(t, json.Unmarshal(out.Bytes(), got))
+	require.Equal(t, msg, got)
+}
+
+func TestPostAddMessageMarkdownReadme(t *testing.T) {
+	d := devboxForTesting(t)
+	nginx := devpkg.PackageFromStringWithDefaults("nginx@latest", d.lockfile)
+	result := AddResult{Added: []string{"nginx@latest"}, packages: []*devpkg.Package{nginx}}
+
+	msg, err := d.postAddMessage(context.Background(), result, devopt.AddOpts{})
+	require.NoError(t, err)
+	require.Len(t, msg.Readmes, 1)
+	require.Contains(t, msg.Readmes[0].Readme, "\nnginx NOTES:\n")
+
+	msg, err = d.postAddMessage(context.Background(), result, devopt.AddOpts{MarkdownReadme: true})
+	require.NoError(t, err)
+	require.Len(t, msg.Readmes, 1)
+	require.Contains(t, msg.Readmes[0].Readme, "\n### nginx NOTES:\n")
+}
+
+func TestPostAddMessageGlibcNotes(t *testing.T) {
+	d := devboxForTesting(t)
+	d.cfg.PackageMutator().Add("hello@2.12")
+	require.NoError(t, d.cfg.PackageMutator().SetPatchGLibc("hello@2.12", true))
+	result := AddResult{Added: []string{"hello@2.12"}}
+
+	msg, err := d.postAddMessage(context.Background(), result, devopt.AddOpts{})
+	require.NoError(t, err)
+	require.Empty(t, msg.GlibcNotes)
+
+	msg, err = d.postAddMessage(context.Background(), result, devopt.AddOpts{PatchGlibc: true, SkipInstall: true})
+	require.NoError(t, err)
+	require.Len(t, msg.GlibcNotes, 1)
+	if nix.SystemIsLinux() {
+		require.Contains(t, msg.GlibcNotes[0], "when it's installed")
+	} else {
+		require.Contains(t, msg.GlibcNotes[0], "Skipped patching glibc")
+	}
+}
+
+func TestIsGlibcPatched(t *testing.T) {
+	entry := func(path string) *lock.Package {
+		return &lock.Package{Systems: map[string]*lock.SystemInfo{
+			nix.System(): {Outputs: []lock.Output{{Name: "out", Path: path, Default: true}}},
+		}}
+	}
+	hello := entry("/nix/store/aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa-hello-2.12")
+	jq := entry("/nix/store/bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb-jq-1.7")
+	buildInputs := []string{
+		"/nix/store/cccccccccccccccccccccccccccccccc-hello-2.12-devbox-patched-glibc",
+		"/nix/store/bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb-jq-1.7",
+	}
+
+	require.True(t, isGlibcPatched(buildInputs, hello))
+	require.False(t, isGlibcPatched(buildInputs, jq))
+	// Without a locked store path, any patched build counts.
+	require.True(t, isGlibcPatched(buildInputs, nil))
+	require.False(t, isGlibcPatched(buildInputs[1:], nil))
+}
@@ -0,0 +1,73 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"context"
+	"log/slog"
+	"maps"
+	"strings"
+
+	"go.jetpack.io/devbox/internal/devbox/devopt"
+	"go.jetpack.io/devbox/internal/devpkg"
+	"go.jetpack.io/devbox/internal/nix"
+	"go.jetpack.io/devbox/internal/ux"
+)
+
+// Aliases returns the package aliases defined in devbox.json, keyed by alias.
+func (d *Devbox) Aliases() map[string]string {
+	aliases := maps.Clone(d.cfg.Root.Aliases)
+	if aliases == nil {
+		aliases = map[string]string{}
+	}
+	return aliases
+}
+
+// expandAliases replaces the aliases in names with the packages they stand
+// for, keeping any version and ^output suffix. The expansions are printed with
+// verbose output. An alias that has the same name as a real package is still
+// expanded, with a warning.
+func (d *Devbox) expandAliases(ctx context.Context, names []string, opts devopt.AddOpts) []string {
+	if len(d.cfg.Root.Aliases) == 0 {
+		return names
+	}
+	expanded := make([]string, 0, len(names))
+	for _, name := range names {
+		base, outputs := devpkg.SplitOutputs(name)
+		target, ok := d.cfg.Root.ExpandAlias(base)
+		if !ok {
+			expanded = append(expanded, name)
+			continue
+		}
+		if len(outputs) > 0 {
+			target += "^" + strings.Join(outputs, ",")
+		}
+		if d.verbose {
+			ux.Finfo(d.stderr, "Expanded alias %s to %s\n", name, target)
+		} else {
+			slog.Debug("expanded package alias", "alias", name, "package", target)
+		}
+		alias, _, _ := strings.Cut(base, "@")
+		if d.aliasShadowsPackage(ctx, alias, opts) {
+			d.warn(
+				WarningAliasShadowsPackage,
+				"The alias %s in devbox.json has the same name as a package. Adding %s instead of it.\n",
+				alias, target,
+			)
+		}
+		expanded = append(expanded, target)
+	}
+	return expanded
+}
+
+// aliasShadowsPackage reports whether there's a package named alias. It's
+// best-effort, so a failing lookup reports false.
+func (d *Devbox) aliasShadowsPackage(ctx context.Context, alias string, opts devopt.AddOpts) bool {
+	if opts.Offline {
+		_, err := nix.SearchOffline(d.lockfile.LegacyNixpkgsPath(alias))
+		return err == nil
+	}
+	ok, err := d.validateExistsWithTimeout(ctx, alias+"@latest", devopt.AddOpts{}, opts.ValidateTimeout)
+	return err == nil && ok
+}
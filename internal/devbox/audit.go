@@ -0,0 +1,127 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"encoding/json"
+	"os"
+	"os/user"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.jetpack.io/devbox/internal/devpkg"
+)
+
+// Statuses of the packages recorded in the audit log.
+const (
+	auditStatusAdded     = "added"
+	auditStatusUnchanged = "unchanged"
+	auditStatusUpdated   = "updated"
+	auditStatusRemoved   = "removed"
+)
+
+// auditEntry is a line of the audit log. See configfile.AuditLogConfig.
+type auditEntry struct {
+	Time          time.Time `json:"time"`
+	User          string    `json:"user"`
+	Status        string    `json:"status"`
+	Package       string    `json:"package"`
+	CanonicalName string    `json:"canonical_name,omitempty"`
+	Version       string    `json:"version,omitempty"`
+	StorePaths    []string  `json:"store_paths,omitempty"`
+	// Mode is how the project state was updated after the change, or
+	// empty if it wasn't (for example with devbox add --no-install).
+	Mode installMode `json:"mode,omitempty"`
+}
+
+func (d *Devbox) auditLogEnabled() bool {
+	return d.cfg.Root.AuditLogPath() != ""
+}
+
+// newAuditEntry describes a change to pkg. It must be called while pkg is
+// still in the lockfile, so removed packages are recorded before they're
+// removed.
+func (d *Devbox) newAuditEntry(status string, pkg *devpkg.Package, mode installMode) auditEntry {
+	entry := auditEntry{
+		Time:          time.Now().UTC(),
+		User:          currentUsername(),
+		Status:        status,
+		Package:       pkg.Raw,
+		CanonicalName: pkg.CanonicalName(),
+		Mode:          mode,
+	}
+	if locked := d.lockfile.Get(pkg.Raw); locked != nil {
+		entry.Version = locked.Version
+	}
+	// Store paths aren't known for every package (such as flakes), so
+	// they're best-effort.
+	entry.StorePaths, _ = pkg.GetResolvedStorePaths()
+	return entry
+}
+
+// writeAuditLog appends entries to the audit log, if it's enabled. The change
+// has already been made by the time it's logged, so failing to write the log
+// is only a warning.
+//
+// Inside collectAuditLog, the entries are held back instead, since the change
+// may still be rolled back.
+func (d *Devbox) writeAuditLog(entries []auditEntry) {
+	if !d.auditLogEnabled() || len(entries) == 0 {
+		return
+	}
+	if d.pendingAudit != nil {
+		*d.pendingAudit = append(*d.pendingAudit, entries...)
+		return
+	}
+	path := d.cfg.Root.AuditLogPath()
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(d.projectDir, path)
+	}
+	if err := appendJSONLines(path, entries); err != nil {
+		d.warn(WarningAuditLog, "Failed to write the audit log %s: %v\n", path, err)
+	}
+}
+
+// collectAuditLog runs fn and returns the audit log entries that were written
+// while it ran, such as by a nested Remove, without writing them.
+// The caller writes them once the whole operation has succeeded, so changes
+// that are rolled back aren't recorded.
+func (d *Devbox) collectAuditLog(fn func() error) ([]auditEntry, error) {
+	if d.pendingAudit != nil {
+		// An outer operation is already collecting the entries.
+		return nil, fn()
+	}
+	entries := []auditEntry{}
+	d.pendingAudit = &entries
+	defer func() { d.pendingAudit = nil }()
+	err := fn()
+	return entries, err
+}
+
+func appendJSONLines[T any](path string, values []T) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return errors.WithStack(err)
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer f.Close()
+
+	enc := json.NewEncoder(f)
+	for _, v := range values {
+		if err := enc.Encode(v); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	return errors.WithStack(f.Close())
+}
+
+func currentUsername() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return os.Getenv("USER")
+}
@@ -0,0 +1,80 @@
+package devbox
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.jetpack.io/devbox/internal/devconfig/configfile"
+)
+
+func TestWriteAuditLog(t *testing.T) {
+	devbox := devboxForTesting(t)
+	path := filepath.Join(devbox.projectDir, ".devbox", "audit.log")
+
+	entry := auditEntry{Status: auditStatusAdded, Package: "go@1.22", CanonicalName: "go", Mode: install}
+	devbox.writeAuditLog([]auditEntry{entry})
+	require.NoFileExists(t, path, "audit log should be opt-in")
+
+	devbox.cfg.Root.AuditLog = &configfile.AuditLogConfig{Enabled: true}
+	devbox.writeAuditLog([]auditEntry{entry})
+	devbox.writeAuditLog([]auditEntry{{Status: auditStatusRemoved, Package: "go@1.22", Mode: uninstall}})
+
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	got := []auditEntry{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e auditEntry
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &e))
+		got = append(got, e)
+	}
+	require.Len(t, got, 2)
+	require.Equal(t, entry, got[0])
+	require.Equal(t, auditStatusRemoved, got[1].Status)
+
+	devbox.cfg.Root.AuditLog.Path = "logs/changes.jsonl"
+	devbox.writeAuditLog([]auditEntry{entry})
+	require.FileExists(t, filepath.Join(devbox.projectDir, "logs", "changes.jsonl"))
+}
+
+func TestCollectAuditLogSkipsRolledBackChanges(t *testing.T) {
+	devbox := devboxForTesting(t)
+	devbox.cfg.Root.AuditLog = &configfile.AuditLogConfig{Enabled: true}
+	path := filepath.Join(devbox.projectDir, ".devbox", "audit.log")
+	removed := auditEntry{Status: auditStatusRemoved, Package: "go@1.21", Mode: uninstall}
+
+	// A removal made by a failed add is rolled back, so it isn't logged.
+	entries, err := devbox.collectAuditLog(func() error {
+		return devbox.withConfigRollback(func() error {
+			devbox.writeAuditLog([]auditEntry{removed})
+			return errors.New("add failed")
+		})
+	})
+	require.Error(t, err)
+	require.Equal(t, []auditEntry{removed}, entries)
+	require.NoFileExists(t, path)
+	// The rollback restored the config, which didn't enable the log.
+	devbox.cfg.Root.AuditLog = &configfile.AuditLogConfig{Enabled: true}
+
+	// Nested operations leave the entries to the outermost one.
+	entries, err = devbox.collectAuditLog(func() error {
+		nested, err := devbox.collectAuditLog(func() error {
+			devbox.writeAuditLog([]auditEntry{removed})
+			return nil
+		})
+		require.Empty(t, nested)
+		return err
+	})
+	require.NoError(t, err)
+	require.Equal(t, []auditEntry{removed}, entries)
+	require.NoFileExists(t, path)
+
+	devbox.writeAuditLog(entries)
+	require.FileExists(t, path)
+}
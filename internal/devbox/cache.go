@@ -12,6 +12,8 @@ import (
 	"go.jetpack.io/devbox/internal/devbox/providers/identity"
 	"go.jetpack.io/devbox/internal/devbox/providers/nixcache"
 	"go.jetpack.io/devbox/internal/devpkg"
+	"go.jetpack.io/devbox/internal/devpkg/pkgtype"
+	"go.jetpack.io/devbox/internal/netpolicy"
 	"go.jetpack.io/devbox/internal/nix"
 	"go.jetpack.io/devbox/internal/ux"
 	"go.jetpack.io/pkg/auth"
@@ -35,7 +37,7 @@ func (d *Devbox) UploadProjectToCache(
 		return err
 	}
 
-	packages := lo.Filter(d.InstallablePackages(), devpkg.IsNix)
+	packages := d.PackagesByType(pkgtype.Nix)
 	if err != nil || len(packages) == 0 {
 		return err
 	}
@@ -55,7 +57,7 @@ func (d *Devbox) UploadProjectToCache(
 			return err
 		}
 		for _, installable := range installables {
-			err := nix.CopyInstallableToCache(ctx, d.stderr, cacheURI, installable, creds.Env())
+			err := nix.CopyInstallableToCache(ctx, d.stderr, cacheURI, installable, creds.Env(), pkg.AllowInsecure)
 			if err != nil {
 				return err
 			}
@@ -65,6 +67,59 @@ func (d *Devbox) UploadProjectToCache(
 	return nil
 }
 
+// pushBuiltPackagesToCache copies the packages that were just installed to
+// the cache in d.pushToCache. Packages that are in the binary cache were
+// fetched from it rather than built, so they're skipped. Since the packages
+// are already installed, failures are reported as warnings.
+func (d *Devbox) pushBuiltPackagesToCache(ctx context.Context, packages []*devpkg.Package) {
+	defer debug.FunctionTimer().End()
+	storePaths := []string{}
+	for _, pkg := range packages {
+		inCache, err := pkg.IsInBinaryCache()
+		if err != nil {
+			d.warn(WarningCachePush, "Unable to check if package %s is in the binary cache: %v\n", pkg.Raw, err)
+			continue
+		}
+		if inCache {
+			continue
+		}
+		pkgStorePaths, err := pkg.GetStorePaths(ctx, d.stderr, d.storeRoot)
+		if err != nil {
+			d.warn(WarningCachePush, "Unable to push package %s to %s: %v\n", pkg.Raw, d.pushToCache.URI, err)
+			continue
+		}
+		storePaths = append(storePaths, pkgStorePaths...)
+	}
+	if err := d.pushStorePathsToCache(ctx, storePaths); err != nil {
+		d.warn(WarningCachePush, "Unable to push packages to %s: %v\n", d.pushToCache.URI, err)
+	}
+}
+
+// pushStorePathsToCache copies the closure of storePaths to the cache in
+// d.pushToCache, except for the paths that can be substituted from the
+// public binary cache. Those weren't built locally, and anyone using the
+// cache can get them from the public one.
+func (d *Devbox) pushStorePathsToCache(ctx context.Context, storePaths []string) error {
+	if len(storePaths) == 0 {
+		return nil
+	}
+	closure, err := nix.StorePathClosure(ctx, d.storeRoot, storePaths)
+	if err != nil {
+		return err
+	}
+	substitutable := map[string]bool{}
+	if netpolicy.Allows(defaultSubstituter) {
+		if substitutable, err = nix.StorePathsInCache(ctx, defaultSubstituter, closure); err != nil {
+			return err
+		}
+	}
+	toPush := lo.Filter(closure, func(path string, _ int) bool { return !substitutable[path] })
+	if len(toPush) == 0 {
+		return nil
+	}
+	return nix.CopyStorePathsToCache(ctx, d.stderr, d.storeRoot, d.pushToCache.URI, toPush, d.pushToCache.Env)
+}
+
 func UploadInstallableToCache(
 	ctx context.Context,
 	stderr io.Writer,
@@ -82,7 +137,7 @@ func UploadInstallableToCache(
 	if err != nil && !errors.Is(err, auth.ErrNotLoggedIn) {
 		return err
 	}
-	return nix.CopyInstallableToCache(ctx, stderr, cacheURI, installable, creds.Env())
+	return nix.CopyInstallableToCache(ctx, stderr, cacheURI, installable, creds.Env(), nil /*allowInsecure*/)
 }
 
 func getWriteCacheURI(
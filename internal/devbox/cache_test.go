@@ -0,0 +1,37 @@
+package devbox
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.jetpack.io/devbox/internal/devbox/devopt"
+	"go.jetpack.io/devbox/internal/nix"
+)
+
+func TestPushStorePathsToCacheSkipsSubstitutable(t *testing.T) {
+	log := filepath.Join(t.TempDir(), "copy")
+	// hello was built locally and depends on glibc, which is in the public
+	// binary cache, and on a locally built helper.
+	nix.FakeForTest(t, `case "$*" in
+*--recursive*) echo /nix/store/aaa-hello /nix/store/bbb-glibc /nix/store/ccc-helper ;;
+*path-info*) echo '{"/nix/store/aaa-hello":null,"/nix/store/bbb-glibc":{},"/nix/store/ccc-helper":null}' ;;
+*copy*) echo "$*" > `+log+` ;;
+esac
+`)
+
+	d := devboxForTesting(t)
+	d.stderr = io.Discard
+	d.pushToCache = &devopt.PushToCache{URI: "s3://my-cache"}
+	require.NoError(t, d.pushStorePathsToCache(context.Background(), []string{"/nix/store/aaa-hello"}))
+
+	data, err := os.ReadFile(log)
+	require.NoError(t, err)
+	_, paths, ok := strings.Cut(strings.TrimSpace(string(data)), "--no-recursive ")
+	require.True(t, ok, "nix copy %s", data)
+	require.Equal(t, "/nix/store/aaa-hello /nix/store/ccc-helper", paths)
+}
@@ -0,0 +1,117 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"go.jetpack.io/devbox/internal/devpkg"
+	"go.jetpack.io/devbox/internal/lock"
+)
+
+// installCheckpoint records the packages that ensureStateIsUpToDate built or
+// fetched into the Nix store. The lockfile is only saved once every step
+// succeeds, so without the checkpoint a failure later on (such as while
+// syncing the Nix profile) loses the resolved store paths, and the next run
+// has to resolve and check every package again.
+//
+// The checkpoint is removed once the lockfile is saved.
+type installCheckpoint struct {
+	// StoreRoot is the Nix store the packages were installed to. The
+	// checkpoint is ignored for other stores.
+	StoreRoot string `json:"store_root,omitempty"`
+
+	// Packages maps the installed packages to their lockfile entries.
+	Packages map[string]*lock.Package `json:"packages"`
+}
+
+func (d *Devbox) installCheckpointPath() string {
+	return filepath.Join(d.projectDir, ".devbox", "install-checkpoint.json")
+}
+
+// loadInstallCheckpoint returns the checkpoint for the project's store. A
+// missing or unreadable checkpoint is treated as empty, since it only saves
+// work.
+func (d *Devbox) loadInstallCheckpoint() *installCheckpoint {
+	empty := &installCheckpoint{StoreRoot: d.storeRoot, Packages: map[string]*lock.Package{}}
+	data, err := os.ReadFile(d.installCheckpointPath())
+	if err != nil {
+		return empty
+	}
+	checkpoint := &installCheckpoint{}
+	if err := json.Unmarshal(data, checkpoint); err != nil {
+		slog.Debug("ignoring invalid install checkpoint", "err", err)
+		return empty
+	}
+	if checkpoint.StoreRoot != d.storeRoot || checkpoint.Packages == nil {
+		return empty
+	}
+	return checkpoint
+}
+
+// checkpointStoredPackages adds pkgs, which are now in the Nix store, to the
+// install checkpoint. Packages without a lockfile entry, such as flakes,
+// aren't recorded.
+func (d *Devbox) checkpointStoredPackages(pkgs []*devpkg.Package) error {
+	checkpoint := d.loadInstallCheckpoint()
+	for _, pkg := range pkgs {
+		if entry := d.lockfile.Get(pkg.Raw); entry != nil {
+			checkpoint.Packages[pkg.Raw] = entry
+		}
+	}
+	data, err := json.MarshalIndent(checkpoint, "", "  ")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	path := d.installCheckpointPath()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(os.WriteFile(path, data, 0o644))
+}
+
+// restoreCheckpointedPackages returns the packages in pkgs that are in the
+// install checkpoint. Their lockfile entries are restored if the lockfile
+// doesn't have them, which is the case when the previous run failed before
+// saving it, so they don't need to be resolved again. A checkpointed package
+// whose lockfile entry has since changed isn't returned, so it's installed
+// again.
+//
+// The store paths may have been garbage collected since, so callers must still
+// check that the packages are in the store.
+func (d *Devbox) restoreCheckpointedPackages(pkgs []*devpkg.Package) map[*devpkg.Package]bool {
+	checkpointed := map[*devpkg.Package]bool{}
+	checkpoint := d.loadInstallCheckpoint()
+	if len(checkpoint.Packages) == 0 {
+		return checkpointed
+	}
+	for _, pkg := range pkgs {
+		stored := checkpoint.Packages[pkg.Raw]
+		if stored == nil {
+			continue
+		}
+		entry := d.lockfile.Get(pkg.Raw)
+		if entry == nil {
+			d.lockfile.Packages[pkg.Raw] = stored
+		} else if entry.Resolved != stored.Resolved {
+			continue
+		}
+		checkpointed[pkg] = true
+	}
+	return checkpointed
+}
+
+// clearInstallCheckpoint removes the install checkpoint after the lockfile has
+// been saved.
+func (d *Devbox) clearInstallCheckpoint() error {
+	err := os.Remove(d.installCheckpointPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return errors.WithStack(err)
+}
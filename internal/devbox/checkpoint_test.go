@@ -0,0 +1,76 @@
+package devbox
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.jetpack.io/devbox/internal/devbox/devopt"
+	"go.jetpack.io/devbox/internal/devpkg"
+	"go.jetpack.io/devbox/internal/lock"
+	"go.jetpack.io/devbox/internal/nix"
+)
+
+func TestInstallCheckpointAfterFailedSync(t *testing.T) {
+	d := devboxForTesting(t)
+	entry := &lock.Package{Resolved: "github:NixOS/nixpkgs/abc#hello", Version: "2.12"}
+	d.lockfile.Packages["hello@2.12"] = entry
+	hello := devpkg.PackageFromStringWithDefaults("hello@2.12", d.lockfile)
+	require.NoError(t, d.checkpointStoredPackages([]*devpkg.Package{hello}))
+
+	// Simulate the profile sync failing: the lockfile is never saved, so
+	// the next run starts from the lockfile on disk.
+	d, err := Open(&devopt.Opts{Dir: d.projectDir, Stderr: os.Stderr})
+	require.NoError(t, err)
+	require.Nil(t, d.lockfile.Get("hello@2.12"))
+
+	hello = devpkg.PackageFromStringWithDefaults("hello@2.12", d.lockfile)
+	jq := devpkg.PackageFromStringWithDefaults("jq@1.7", d.lockfile)
+	checkpointed := d.restoreCheckpointedPackages([]*devpkg.Package{hello, jq})
+	require.Equal(t, map[*devpkg.Package]bool{hello: true}, checkpointed)
+	require.Equal(t, entry, d.lockfile.Get("hello@2.12"))
+
+	// A package that resolves differently now is installed again.
+	d.lockfile.Packages["hello@2.12"] = &lock.Package{Resolved: "github:NixOS/nixpkgs/def#hello"}
+	checkpointed = d.restoreCheckpointedPackages([]*devpkg.Package{hello})
+	require.Empty(t, checkpointed)
+
+	require.NoError(t, d.clearInstallCheckpoint())
+	require.Empty(t, d.loadInstallCheckpoint().Packages)
+	require.NoError(t, d.clearInstallCheckpoint())
+}
+
+func TestCheckpointedPackageMissingFromStore(t *testing.T) {
+	storePath := "/nix/store/aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa-hello-2.12"
+	// A fake nix that reports the checkpointed package's store path as
+	// missing, such as after it was garbage collected.
+	nix.FakeForTest(t, `case "$*" in
+  *--version*) echo "nix (Nix) 2.24.0";;
+  *path-info*) echo '{"`+storePath+`": null}';;
+  *) exit 1;;
+esac
+`)
+
+	d := devboxForTesting(t)
+	d.cfg.PackageMutator().Add("hello@2.12")
+	entry := &lock.Package{
+		Resolved: "github:NixOS/nixpkgs/abc#hello",
+		Version:  "2.12",
+		Systems: map[string]*lock.SystemInfo{
+			nix.System(): {Outputs: []lock.Output{{Name: "out", Path: storePath, Default: true}}},
+		},
+	}
+	d.lockfile.Packages["hello@2.12"] = entry
+	hello := devpkg.PackageFromStringWithDefaults("hello@2.12", d.lockfile)
+	require.NoError(t, d.checkpointStoredPackages([]*devpkg.Package{hello}))
+
+	pkgs := []*devpkg.Package{hello}
+	checkpointed := d.restoreCheckpointedPackages(pkgs)
+	locked, unlocked, err := lockedStorePathsToCheck(pkgs, checkpointed)
+	require.NoError(t, err)
+	require.Empty(t, unlocked)
+	notInStore, err := d.packagesNotInStore(context.Background(), locked)
+	require.NoError(t, err)
+	require.Equal(t, pkgs, notInStore)
+}
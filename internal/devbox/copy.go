@@ -0,0 +1,86 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"context"
+	"reflect"
+	"runtime/trace"
+	"slices"
+	"strings"
+
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+	"go.jetpack.io/devbox/internal/devbox/devopt"
+	"go.jetpack.io/devbox/internal/devconfig/configfile"
+	"go.jetpack.io/devbox/internal/ux"
+)
+
+// CopyPackagesTo adds the packages in this project's devbox.json to the
+// devbox.json of the project in targetDir, with all of their settings, such as
+// their platforms, allow_insecure and patch_glibc. It then installs them in the
+// target project, unless opts.SkipInstall is set.
+//
+// A package that the target already has with the same settings is left
+// alone. If the target has a package with the same name but different
+// settings, such as another version, opts.ConflictResolution decides which
+// one is kept. Packages that are only in the target are kept.
+func (d *Devbox) CopyPackagesTo(ctx context.Context, targetDir string, opts devopt.CopyPackagesOpts) error {
+	ctx, task := trace.NewTask(ctx, "devboxCopyPackagesTo")
+	defer task.End()
+
+	// The target is changed on behalf of this project, so it uses the same
+	// network policy.
+	target, err := Open(&devopt.Opts{
+		Dir:           targetDir,
+		Stderr:        d.stderr,
+		NetworkPolicy: string(d.network.Policy()),
+		AllowedHosts:  d.network.Hosts(),
+	})
+	if err != nil {
+		return err
+	}
+	if target.projectDir == d.projectDir {
+		return usererr.New("Can't copy the packages of a project to itself.")
+	}
+
+	conflictOpts := devopt.AddOpts{
+		ConflictResolution: opts.ConflictResolution,
+		ConflictPrompter:   opts.ConflictPrompter,
+	}
+	targetPkgs := target.cfg.Root.ConfiguredPackages()
+	copied := []string{}
+	for _, pkg := range d.cfg.Root.ConfiguredPackages() {
+		i := slices.IndexFunc(targetPkgs, func(p configfile.Package) bool { return p.Name == pkg.Name })
+		if i != -1 {
+			existing := targetPkgs[i]
+			if reflect.DeepEqual(existing, pkg) {
+				continue
+			}
+			replace, err := shouldReplaceConflict(conflictOpts, existing.VersionedName(), pkg.VersionedName())
+			if err != nil {
+				return err
+			}
+			if !replace {
+				ux.Finfo(d.stderr, "Keeping package %q in %s\n", existing.VersionedName(), target.projectDir)
+				continue
+			}
+		}
+		if err := target.cfg.PackageMutator().Put(pkg); err != nil {
+			return err
+		}
+		copied = append(copied, pkg.VersionedName())
+	}
+	if len(copied) == 0 {
+		ux.Finfo(d.stderr, "%s already has all of the packages\n", target.projectDir)
+		return nil
+	}
+	ux.Finfo(d.stderr, "Copying packages to %s: %s\n", target.projectDir, strings.Join(copied, ", "))
+
+	if !opts.SkipInstall {
+		if err := target.ensureStateIsUpToDate(ctx, update); err != nil {
+			return err
+		}
+	}
+	return target.saveCfg()
+}
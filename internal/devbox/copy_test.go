@@ -0,0 +1,58 @@
+package devbox
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.jetpack.io/devbox/internal/devbox/devopt"
+	"go.jetpack.io/devbox/internal/devconfig/configfile"
+)
+
+func TestCopyPackagesTo(t *testing.T) {
+	src := devboxForTesting(t)
+	src.cfg.PackageMutator().Add("hello@2.12")
+	src.cfg.PackageMutator().Add("jq@1.7")
+	require.NoError(t, src.cfg.PackageMutator().SetExcludedPlatforms(io.Discard, "jq@1.7", []string{"aarch64-darwin"}))
+	src.stderr = io.Discard
+
+	for _, tc := range []struct {
+		resolution devopt.ConflictResolution
+		wantJq     string
+	}{
+		{devopt.ConflictReplace, "1.7"},
+		{devopt.ConflictKeep, "1.6"},
+	} {
+		target := devboxForTesting(t)
+		target.cfg.PackageMutator().Add("jq@1.6")
+		target.cfg.PackageMutator().Add("cowsay@latest")
+		require.NoError(t, target.saveCfg())
+
+		err := src.CopyPackagesTo(context.Background(), target.projectDir, devopt.CopyPackagesOpts{
+			ConflictResolution: tc.resolution,
+			SkipInstall:        true,
+		})
+		require.NoError(t, err)
+
+		got, err := Open(&devopt.Opts{Dir: target.projectDir, Stderr: io.Discard})
+		require.NoError(t, err)
+		pkgs := map[string]configfile.Package{}
+		for _, pkg := range got.cfg.Root.ConfiguredPackages() {
+			pkgs[pkg.Name] = pkg
+		}
+		require.Len(t, pkgs, 3)
+		require.Equal(t, "2.12", pkgs["hello"].Version)
+		require.Equal(t, "latest", pkgs["cowsay"].Version)
+		require.Equal(t, tc.wantJq, pkgs["jq"].Version)
+		if tc.resolution == devopt.ConflictReplace {
+			require.Equal(t, []string{"aarch64-darwin"}, pkgs["jq"].ExcludedPlatforms)
+		}
+	}
+}
+
+func TestCopyPackagesToSelf(t *testing.T) {
+	d := devboxForTesting(t)
+	err := d.CopyPackagesTo(context.Background(), d.projectDir, devopt.CopyPackagesOpts{SkipInstall: true})
+	require.Error(t, err)
+}
@@ -6,6 +6,7 @@ package devbox
 
 import (
 	"bytes"
+	"cmp"
 	"context"
 	"fmt"
 	"io"
@@ -37,7 +38,9 @@ import (
 	"go.jetpack.io/devbox/internal/envir"
 	"go.jetpack.io/devbox/internal/fileutil"
 	"go.jetpack.io/devbox/internal/lock"
+	"go.jetpack.io/devbox/internal/netpolicy"
 	"go.jetpack.io/devbox/internal/nix"
+	"go.jetpack.io/devbox/internal/nix/nixprofile"
 	"go.jetpack.io/devbox/internal/plugin"
 	"go.jetpack.io/devbox/internal/redact"
 	"go.jetpack.io/devbox/internal/searcher"
@@ -64,6 +67,70 @@ type Devbox struct {
 	projectDir               string
 	pluginManager            *plugin.Manager
 	customProcessComposeFile string
+	// storeRoot is the absolute path of the root directory of a
+	// non-default Nix store. Every nix command that installs, lists or
+	// evaluates the project's packages uses it. Empty means the default
+	// store.
+	storeRoot string
+	// buildVerbosity controls the output of the nix builds that install
+	// packages into the store.
+	buildVerbosity nix.BuildVerbosity
+	// buildLogLines is how many lines of a failed build's log to include in
+	// the error. See devopt.Opts.BuildLogLines.
+	buildLogLines int
+	// maxJobs and cores limit the parallelism of nix builds. Nil uses nix's
+	// settings. See devopt.Opts.MaxJobs and devopt.Opts.Cores.
+	maxJobs *int
+	cores   *int
+	// profileItems caches the items in the project's nix profile. See
+	// profileListItems.
+	profileItems []*nixprofile.NixProfileListItem
+	// lastProfileDiff is the change made by the last profile sync. See
+	// LastProfileDiff.
+	lastProfileDiff *ProfileDiff
+	// verbose prints details of package operations, such as the changes to
+	// the Nix profile.
+	verbose  bool
+	progress devopt.ProgressReporter
+	warnings devopt.WarningReporter
+	// installGroups limits InstallablePackages to the packages in these
+	// groups, plus the packages without a group. Nil means all packages.
+	installGroups []string
+	// installSubset limits InstallablePackages to these packages, keyed by
+	// their raw name. Nil means all packages. See InstallSubset.
+	installSubset map[string]bool
+	// network is the project's network policy. See networkRules.
+	network *netpolicy.Rules
+	// deferLazy leaves lazy packages out of InstallablePackages, so that an
+	// install doesn't build them before the environment is first used. See
+	// deferLazyPackages.
+	deferLazy bool
+	// buildSystem is the nix system to build packages for when it isn't
+	// the current system. See BuildForSystem.
+	buildSystem string
+	// pushToCache is the cache that locally built packages are copied to
+	// after they're installed, if any.
+	pushToCache *devopt.PushToCache
+	// keepGoing installs as many packages as possible instead of stopping
+	// at the first package that fails to install.
+	keepGoing bool
+	// failures records the packages that failed to install while
+	// ensureStateIsUpToDate runs with keepGoing. It's nil otherwise.
+	failures *packageFailures
+	// skipVerify skips the packages' verify commands. See verifyPackages.
+	skipVerify bool
+	// stdin is where AddOpts.ReadFromStdin reads package names from.
+	stdin io.Reader
+	// envFromNames are the names of the variables that were read from
+	// env_from (Jetify Cloud secrets or a .env file) the last time the
+	// environment was computed. See configEnvs.
+	envFromNames map[string]bool
+	// warnedEnvConflicts are the names of the variables whose plugin env
+	// conflicts were already reported. See configEnvs.
+	warnedEnvConflicts map[string]bool
+	// pendingAudit holds the audit log entries of an operation that may
+	// still be rolled back. It's nil otherwise. See collectAuditLog.
+	pendingAudit *[]auditEntry
 
 	// This is needed because of the --quiet flag.
 	stderr io.Writer
@@ -101,6 +168,44 @@ func Open(opts *devopt.Opts) (*Devbox, error) {
 		return nil, err
 	}
 
+	buildVerbosity, err := nix.ParseBuildVerbosity(opts.BuildVerbosity)
+	if err != nil {
+		return nil, usererr.WithUserMessage(err, "Invalid build verbosity.")
+	}
+
+	maxJobs, cores := cmp.Or(opts.MaxJobs, cfg.Root.MaxJobs), cmp.Or(opts.Cores, cfg.Root.Cores)
+	if (maxJobs != nil && *maxJobs < 0) || (cores != nil && *cores < 0) {
+		return nil, usererr.New("max_jobs and cores can't be negative.")
+	}
+
+	if err := netpolicy.SetProxy(cmp.Or(opts.Proxy, cfg.Root.Proxy)); err != nil {
+		return nil, err
+	}
+	network, err := networkRules(context.TODO(), opts)
+	if err != nil {
+		return nil, err
+	}
+
+	storeRoot := opts.StoreRoot
+	if strings.Contains(storeRoot, "://") {
+		return nil, usererr.New(
+			"The Nix store root must be a local directory, not a store URL such as %s.", storeRoot)
+	}
+	if storeRoot != "" {
+		if storeRoot, err = filepath.Abs(storeRoot); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+
+	var progress devopt.ProgressReporter = logProgress{}
+	if opts.Progress != nil {
+		progress = opts.Progress
+	}
+	var warnings devopt.WarningReporter = stderrWarnings{opts.Stderr}
+	if opts.Warnings != nil {
+		warnings = opts.Warnings
+	}
+
 	box := &Devbox{
 		cfg:                      cfg,
 		env:                      opts.Env,
@@ -108,9 +213,30 @@ func Open(opts *devopt.Opts) (*Devbox, error) {
 		nix:                      &nix.Nix{},
 		projectDir:               filepath.Dir(cfg.Root.AbsRootPath),
 		pluginManager:            plugin.NewManager(),
+		progress:                 progress,
+		warnings:                 warnings,
+		buildVerbosity:           buildVerbosity,
+		buildLogLines:            cmp.Or(opts.BuildLogLines, defaultBuildLogLines),
+		maxJobs:                  maxJobs,
+		cores:                    cores,
 		stderr:                   opts.Stderr,
+		stdin:                    opts.Stdin,
 		customProcessComposeFile: opts.CustomProcessComposeFile,
-	}
+		storeRoot:                storeRoot,
+		pushToCache:              opts.PushToCache,
+		keepGoing:                opts.KeepGoing,
+		verbose:                  opts.Verbose,
+		skipVerify:               opts.SkipVerify,
+		network:                  network,
+		warnedEnvConflicts:       map[string]bool{},
+	}
+	// Requests that other packages make with netpolicy.Client are checked
+	// against the rules of every open project, so report the ones that this
+	// project's rules reject.
+	network.OnDeny = func(rawURL string) {
+		box.warn(WarningNetworkDenied, "The strict network policy blocked a request to %s.\n", rawURL)
+	}
+	netpolicy.Enforce(network)
 
 	lock, err := lock.GetFile(box)
 	if err != nil {
@@ -136,6 +262,7 @@ func Open(opts *devopt.Opts) (*Devbox, error) {
 	box.pluginManager.ApplyOptions(
 		plugin.WithDevbox(box),
 		plugin.WithLockfile(lock),
+		plugin.WithCreateFailurePolicy(cfg.Root.PluginFailurePolicy, box.stderr),
 	)
 	box.lockfile = lock
 
@@ -179,6 +306,9 @@ func (d *Devbox) ConfigHash() (string, error) {
 
 	buf := bytes.Buffer{}
 	buf.WriteString(h)
+	// The environment and profile computed against one store don't carry
+	// over to another.
+	buf.WriteString(d.storeRoot)
 	for _, pkg := range d.AllPackages() {
 		buf.WriteString(pkg.Hash())
 	}
@@ -306,7 +436,113 @@ func (d *Devbox) Install(ctx context.Context) error {
 	ctx, task := trace.NewTask(ctx, "devboxInstall")
 	defer task.End()
 
-	return d.ensureStateIsUpToDate(ctx, ensure)
+	d.lockfile.RetryOfflineResolutions()
+	defer d.deferLazyPackages()()
+	if err := d.ensureStateIsUpToDate(ctx, ensure); err != nil {
+		return err
+	}
+	return d.invalidateStateIfLazyDeferred()
+}
+
+// deferLazyPackages leaves lazy packages out of the generated flake and the
+// Nix profile until the returned function is called, so that an install
+// doesn't build them. Lazy packages that are already in the profile are kept.
+func (d *Devbox) deferLazyPackages() func() {
+	d.deferLazy = lo.SomeBy(d.AllPackages(), func(pkg *devpkg.Package) bool { return pkg.Lazy })
+	return func() { d.deferLazy = false }
+}
+
+// invalidateStateIfLazyDeferred invalidates the state hash after an install
+// that left out lazy packages, so that the next command that computes the
+// environment, such as devbox shell, adds them.
+func (d *Devbox) invalidateStateIfLazyDeferred() error {
+	if !d.deferLazy {
+		return nil
+	}
+	return errors.WithStack(lock.InvalidateStateHashFile(d.projectDir))
+}
+
+// InstallGroups is like Install, but only installs the packages in the given
+// groups and the packages that don't belong to a group. A package in several
+// groups is installed if any of them is requested. Because the resulting state
+// doesn't include every package, the state hash is invalidated so that the
+// next command installs the rest.
+func (d *Devbox) InstallGroups(ctx context.Context, groups ...string) error {
+	ctx, task := trace.NewTask(ctx, "devboxInstallGroups")
+	defer task.End()
+
+	known := map[string]bool{}
+	for _, pkg := range d.cfg.Root.TopLevelPackages() {
+		for _, g := range pkg.Groups {
+			known[g] = true
+		}
+	}
+	for _, g := range groups {
+		if !known[g] {
+			return usererr.New("No packages belong to group %q.", g)
+		}
+	}
+
+	d.installGroups = groups
+	defer func() { d.installGroups = nil }()
+	defer d.deferLazyPackages()()
+	d.lockfile.RetryOfflineResolutions()
+	if err := d.ensureStateIsUpToDate(ctx, install); err != nil {
+		return err
+	}
+	return errors.WithStack(lock.InvalidateStateHashFile(d.projectDir))
+}
+
+// InstallSubset is like Install, but only installs the named packages and the
+// packages required by their built-in plugins. The other packages stay in the
+// config, and are left in the Nix profile if they're already installed.
+// Packages that are no longer in the config are still removed from it. Like
+// InstallGroups, it invalidates the state hash so that the next command
+// installs the rest.
+func (d *Devbox) InstallSubset(ctx context.Context, names ...string) error {
+	ctx, task := trace.NewTask(ctx, "devboxInstallSubset")
+	defer task.End()
+
+	subset := map[string]bool{}
+	for _, name := range names {
+		pkg, err := d.findPackageByName(name)
+		if err != nil {
+			return err
+		}
+		subset[pkg.Raw] = true
+	}
+	for _, pkg := range d.AllPackages() {
+		for _, origin := range d.cfg.PackageOrigins(pkg.Raw) {
+			if len(origin.Plugins) == 0 {
+				continue
+			}
+			// Built-in plugins are sourced from the package that enables them.
+			if trigger, ok := origin.Plugins[0].(*devpkg.Package); ok && subset[trigger.Raw] {
+				subset[pkg.Raw] = true
+			}
+		}
+	}
+
+	d.installSubset = subset
+	defer func() { d.installSubset = nil }()
+	d.lockfile.RetryOfflineResolutions()
+	if err := d.ensureStateIsUpToDate(ctx, install); err != nil {
+		return err
+	}
+	return errors.WithStack(lock.InvalidateStateHashFile(d.projectDir))
+}
+
+// isSubsetInstall reports whether InstallGroups or InstallSubset is
+// restricting the packages to install.
+func (d *Devbox) isSubsetInstall() bool {
+	return d.installGroups != nil || d.installSubset != nil
+}
+
+// isPartialInstall reports whether InstallGroups or InstallSubset is
+// restricting the packages to install, lazy packages are deferred, or some
+// packages failed to install with keepGoing.
+func (d *Devbox) isPartialInstall() bool {
+	return d.isSubsetInstall() || d.deferLazy || d.failures.any()
 }
 
 func (d *Devbox) ListScripts() []string {
@@ -332,7 +568,7 @@ func (d *Devbox) EnvExports(ctx context.Context, opts devopt.EnvExportsOpts) (st
 
 	if opts.DontRecomputeEnvironment {
 		upToDate, _ := d.lockfile.IsUpToDateAndInstalled(isFishShell())
-		if !upToDate {
+		if !upToDate && !d.refreshWarningSuppressed() {
 			ux.FHidableWarning(
 				ctx,
 				d.stderr,
@@ -375,6 +611,34 @@ func (d *Devbox) EnvVars(ctx context.Context) ([]string, error) {
 	return envir.MapToPairs(envs), nil
 }
 
+// ExportDotenv writes the project's environment to path as a .env file, with a
+// KEY="value" line for each variable. Unlike EnvExports, the file is a static
+// snapshot for tools that can't run devbox shellenv, so it has to be exported
+// again when the project changes.
+//
+// Only the variables that Devbox or Nix set are written, so that the host's
+// environment, which may have credentials, doesn't end up in the project. The
+// file is only readable by the user.
+func (d *Devbox) ExportDotenv(ctx context.Context, path string, opts devopt.ExportDotenvOpts) error {
+	ctx, task := trace.NewTask(ctx, "devboxExportDotenv")
+	defer task.End()
+
+	envs, err := d.ensureStateIsUpToDateAndComputeEnv(ctx, devopt.EnvOptions{})
+	if err != nil {
+		return err
+	}
+	envs = setByDevbox(envs, envir.PairsToMap(os.Environ()))
+	delete(envs, "buildInputs")
+	if !opts.IncludePath {
+		delete(envs, "PATH")
+	}
+	if err := os.WriteFile(path, []byte(dotenvify(envs)), 0o600); err != nil {
+		return errors.WithStack(err)
+	}
+	// WriteFile keeps the permissions of an existing file.
+	return errors.WithStack(os.Chmod(path, 0o600))
+}
+
 func (d *Devbox) shellEnvHashKey() string {
 	// Don't make this a const so we don't use it by itself accidentally
 	return "__DEVBOX_SHELLENV_HASH_" + d.ProjectDirHash()
@@ -579,6 +843,30 @@ func (d *Devbox) Services() (services.Services, error) {
 }
 
 func (d *Devbox) execPrintDevEnv(ctx context.Context, usePrintDevEnvCache bool) (map[string]string, error) {
+	return d.execPrintDevEnvForShell(ctx, "", usePrintDevEnvCache)
+}
+
+// ungroupedShell is the name of the flake's dev shell with only the packages
+// that don't belong to a group, which is generated with group_profiles.
+const ungroupedShell = "ungrouped"
+
+// execPrintDevEnvForShell is like execPrintDevEnv, but for the flake's dev
+// shell with the given name. Each shell's environment is cached separately.
+func (d *Devbox) execPrintDevEnvForShell(
+	ctx context.Context,
+	shell string,
+	usePrintDevEnvCache bool,
+) (map[string]string, error) {
+	cachePath := d.nixPrintDevEnvCachePath()
+	if shell != "" {
+		cachePath += "-" + shell
+	}
+	// Packages are evaluated with their build_env, the same as when they're
+	// built in installNixPackagesToStore.
+	buildEnv, err := d.flakeBuildEnv()
+	if err != nil {
+		return nil, err
+	}
 	var spinny *spinner.Spinner
 	if !usePrintDevEnvCache {
 		spinny = spinner.New(spinner.CharSets[11], 100*time.Millisecond, spinner.WithWriter(d.stderr))
@@ -589,8 +877,11 @@ func (d *Devbox) execPrintDevEnv(ctx context.Context, usePrintDevEnvCache bool)
 
 	vaf, err := d.nix.PrintDevEnv(ctx, &nix.PrintDevEnvArgs{
 		FlakeDir:             d.flakeDir(),
-		PrintDevEnvCachePath: d.nixPrintDevEnvCachePath(),
+		PrintDevEnvCachePath: cachePath,
 		UsePrintDevEnvCache:  usePrintDevEnvCache,
+		Shell:                shell,
+		Env:                  buildEnv,
+		Store:                d.storeRoot,
 	})
 	if spinny != nil {
 		spinny.Stop()
@@ -688,7 +979,14 @@ func (d *Devbox) computeEnv(
 	maps.Copy(originalEnv, env)
 
 	if !envOpts.OmitNixEnv {
-		nixEnv, err := d.execPrintDevEnv(ctx, usePrintDevEnvCache)
+		// When only some groups are active, the environment comes from the
+		// shell without grouped packages, and the active groups are added
+		// through their profiles below.
+		shell := ""
+		if envOpts.Groups != nil && d.cfg.Root.GroupProfiles {
+			shell = ungroupedShell
+		}
+		nixEnv, err := d.execPrintDevEnvForShell(ctx, shell, usePrintDevEnvCache)
 		if err != nil {
 			return nil, err
 		}
@@ -699,9 +997,14 @@ func (d *Devbox) computeEnv(
 	}
 	slog.Debug("nix environment PATH", "path", env["PATH"])
 
+	// With group_profiles, the profiles of the active groups come after the
+	// default profile.
+	groupBinPaths, err := d.groupProfileBinPaths(envOpts.Groups)
+	if err != nil {
+		return nil, err
+	}
 	env["PATH"] = envpath.JoinPathLists(
-		nix.ProfileBinPath(d.projectDir),
-		env["PATH"],
+		slices.Concat([]string{nix.ProfileBinPath(d.projectDir)}, groupBinPaths, []string{env["PATH"]})...,
 	)
 
 	wd, err := os.Getwd()
@@ -714,6 +1017,11 @@ func (d *Devbox) computeEnv(
 	env["DEVBOX_WD"] = wd
 	env["DEVBOX_CONFIG_DIR"] = d.projectDir + "/devbox.d"
 	env["DEVBOX_PACKAGES_DIR"] = d.projectDir + "/" + nix.ProfilePath
+	if len(groupBinPaths) > 0 {
+		// The profiles of the active groups, like DEVBOX_PACKAGES_DIR.
+		env["DEVBOX_GROUP_PACKAGES_DIRS"] = envpath.JoinPathLists(
+			lo.Map(groupBinPaths, func(p string, _ int) string { return filepath.Dir(p) })...)
+	}
 
 	// Include env variables in devbox.json
 	configEnv, err := d.configEnvs(ctx, env)
@@ -824,6 +1132,21 @@ func (d *Devbox) ensureStateIsUpToDateAndComputeEnv(
 	return d.computeEnv(ctx, true /*usePrintDevEnvCache*/, envOpts)
 }
 
+// BuildInputs returns the store paths of the packages in the environment, which
+// are the paths that Devbox puts on the PATH. Like shellenv with
+// DontRecomputeEnvironment, it uses the last computed environment, so call
+// Install first for it to include changes to devbox.json.
+func (d *Devbox) BuildInputs(ctx context.Context) ([]string, error) {
+	ctx, task := trace.NewTask(ctx, "devboxBuildInputs")
+	defer task.End()
+
+	env, err := d.computeEnv(ctx, true /*usePrintDevEnvCache*/, devopt.EnvOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return parseBuildInputs(env["buildInputs"]), nil
+}
+
 func (d *Devbox) nixPrintDevEnvCachePath() string {
 	return filepath.Join(d.projectDir, ".devbox/.nix-print-dev-env-cache")
 }
@@ -864,7 +1187,19 @@ func (d *Devbox) TopLevelPackages() []*devpkg.Package {
 // InstallablePackages returns the packages that are to be installed
 func (d *Devbox) InstallablePackages() []*devpkg.Package {
 	return lo.Filter(d.AllPackages(), func(pkg *devpkg.Package, _ int) bool {
-		return pkg.IsInstallable()
+		return pkg.IsInstallable() && !pkg.Disabled &&
+			(d.installGroups == nil || pkg.InAnyGroup(d.installGroups)) &&
+			(d.installSubset == nil || d.installSubset[pkg.Raw]) &&
+			!(d.deferLazy && pkg.Lazy) &&
+			!d.failures.has(pkg)
+	})
+}
+
+// PackagesByType returns the installable packages of type t, such as the runx
+// packages that the project uses.
+func (d *Devbox) PackagesByType(t pkgtype.Type) []*devpkg.Package {
+	return lo.Filter(d.InstallablePackages(), func(pkg *devpkg.Package, _ int) bool {
+		return pkg.IsType(t)
 	})
 }
 
@@ -881,24 +1216,33 @@ func (d *Devbox) findPackageByName(name string) (*devpkg.Package, error) {
 	if name == "" {
 		return nil, errors.New("package name cannot be empty")
 	}
-	results := map[*devpkg.Package]bool{}
-	for _, pkg := range d.TopLevelPackages() {
-		if pkg.Raw == name || pkg.CanonicalName() == name {
-			results[pkg] = true
-		}
-	}
+	results := d.topLevelPackagesByName()[name]
 	if len(results) > 1 {
 		return nil, usererr.New(
 			"found multiple packages with name %s: %s. Please specify version",
 			name,
-			lo.Keys(results),
+			results,
 		)
 	}
 	if len(results) == 0 {
 		return nil, usererr.WithUserMessage(
 			searcher.ErrNotFound, "no package found with name %s", name)
 	}
-	return lo.Keys(results)[0], nil
+	return results[0], nil
+}
+
+// topLevelPackagesByName indexes the top level packages by their raw name
+// (e.g. "go@1.21") and by their canonical name (e.g. "go"). A canonical name
+// maps to several packages if more than one version is in the config.
+func (d *Devbox) topLevelPackagesByName() map[string][]*devpkg.Package {
+	index := map[string][]*devpkg.Package{}
+	for _, pkg := range d.TopLevelPackages() {
+		index[pkg.Raw] = append(index[pkg.Raw], pkg)
+		if name := pkg.CanonicalName(); name != "" && name != pkg.Raw {
+			index[name] = append(index[name], pkg)
+		}
+	}
+	return index
 }
 
 func (d *Devbox) checkOldEnvrc() error {
@@ -942,6 +1286,7 @@ func (d *Devbox) configEnvs(
 ) (map[string]string, error) {
 	defer debug.FunctionTimer().End()
 	env := map[string]string{}
+	d.envFromNames = map[string]bool{}
 	if d.cfg.IsEnvsecEnabled() {
 		secrets, err := d.Secrets(ctx)
 		// TODO: replace this with error.Is check once envsec exports it.
@@ -964,6 +1309,7 @@ func (d *Devbox) configEnvs(
 			} else {
 				for _, secret := range cloudSecrets {
 					env[secret.Name] = secret.Value
+					d.envFromNames[secret.Name] = true
 				}
 			}
 		}
@@ -981,6 +1327,7 @@ func (d *Devbox) configEnvs(
 		}
 		for k, v := range parsedEnvs {
 			env[k] = v
+			d.envFromNames[k] = true
 		}
 	} else if d.cfg.Root.EnvFrom != "" {
 		return nil, usererr.New(
@@ -989,6 +1336,22 @@ func (d *Devbox) configEnvs(
 			"jetpack-cloud",
 		)
 	}
+	for _, conflict := range d.cfg.EnvConflicts() {
+		// The environment is computed several times in a command, so
+		// only warn about each conflict the first time.
+		if d.warnedEnvConflicts[conflict.Name] {
+			continue
+		}
+		d.warnedEnvConflicts[conflict.Name] = true
+		d.warn(
+			WarningPluginEnvConflict,
+			"Plugins %s set %s to different values. Using the value from %s. "+
+				"Set `plugin_env_priority` or `env` in devbox.json to choose a value.\n",
+			strings.Join(conflict.Plugins, ", "),
+			conflict.Name,
+			conflict.Plugins[len(conflict.Plugins)-1],
+		)
+	}
 	for k, v := range d.cfg.Env() {
 		env[k] = v
 	}
@@ -1108,6 +1471,7 @@ func (d *Devbox) RunXPaths(ctx context.Context) (string, error) {
 		return "", err
 	}
 
+	installs := map[string][]string{}
 	for _, pkg := range d.InstallablePackages() {
 		if !pkg.IsRunX() {
 			continue
@@ -1116,10 +1480,14 @@ func (d *Devbox) RunXPaths(ctx context.Context) (string, error) {
 		if err != nil {
 			return "", err
 		}
+		if err := pkgtype.CheckRunXNetworkPolicy(); err != nil {
+			return "", err
+		}
 		paths, err := pkgtype.RunXClient().Install(ctx, lockedPkg.Resolved)
 		if err != nil {
 			return "", err
 		}
+		installs[lockedPkg.Resolved] = paths
 		for _, path := range paths {
 			// create symlink to all files in p
 			files, err := os.ReadDir(path)
@@ -1135,6 +1503,9 @@ func (d *Devbox) RunXPaths(ctx context.Context) (string, error) {
 			}
 		}
 	}
+	if err := d.saveRunXInstalls(installs); err != nil {
+		return "", err
+	}
 	return runxBinPath, nil
 }
 
@@ -6,18 +6,22 @@ package devbox
 import (
 	"context"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
 
 	"github.com/bmatcuk/doublestar/v4"
+	"github.com/samber/lo"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.jetpack.io/devbox/internal/devbox/envpath"
 
 	"go.jetpack.io/devbox/internal/devbox/devopt"
 	"go.jetpack.io/devbox/internal/devconfig"
+	"go.jetpack.io/devbox/internal/devpkg"
+	"go.jetpack.io/devbox/internal/devpkg/pkgtype"
 	"go.jetpack.io/devbox/internal/envir"
 	"go.jetpack.io/devbox/internal/nix"
 )
@@ -132,3 +136,113 @@ func devboxForTesting(t *testing.T) *Devbox {
 
 	return d
 }
+
+func TestOpenStoreRoot(t *testing.T) {
+	dir := devboxForTesting(t).projectDir
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+
+	// Relative roots are relative to the working directory.
+	d, err := Open(&devopt.Opts{Dir: dir, StoreRoot: "ci-store", Stderr: os.Stderr})
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(wd, "ci-store"), d.storeRoot)
+
+	_, err = Open(&devopt.Opts{Dir: dir, StoreRoot: "ssh://builder", Stderr: os.Stderr})
+	require.Error(t, err)
+}
+
+func TestInstallSubsetUnknownPackage(t *testing.T) {
+	d := devboxForTesting(t)
+	err := d.InstallSubset(context.Background(), "hello")
+	require.Error(t, err)
+	require.Nil(t, d.installSubset)
+}
+
+func TestPackagesByType(t *testing.T) {
+	d := devboxForTesting(t)
+	for _, pkg := range []string{"hello@latest", "runx:golangci/golangci-lint@latest", "github:NixOS/nixpkgs#cowsay"} {
+		d.cfg.PackageMutator().Add(pkg)
+	}
+
+	raw := func(pkgs []*devpkg.Package) []string {
+		return lo.Map(pkgs, func(p *devpkg.Package, _ int) string { return p.Raw })
+	}
+	require.ElementsMatch(t, []string{"hello@latest", "github:NixOS/nixpkgs#cowsay"}, raw(d.PackagesByType(pkgtype.Nix)))
+	require.Equal(t, []string{"github:NixOS/nixpkgs#cowsay"}, raw(d.PackagesByType(pkgtype.Flake)))
+	require.Equal(t, []string{"runx:golangci/golangci-lint@latest"}, raw(d.PackagesByType(pkgtype.RunX)))
+}
+
+func TestParseBuildInputs(t *testing.T) {
+	require.Empty(t, parseBuildInputs(""))
+	require.Equal(t,
+		[]string{"/nix/store/abc-go-1.22", "/nix/store/def-jq-1.7"},
+		parseBuildInputs("/nix/store/abc-go-1.22 /nix/store/def-jq-1.7"),
+	)
+}
+
+func TestProfileDiffWriteText(t *testing.T) {
+	d := devboxForTesting(t)
+	require.Nil(t, d.LastProfileDiff())
+
+	var b strings.Builder
+	(&ProfileDiff{}).WriteText(&b)
+	require.Equal(t, "Nix profile unchanged\n", b.String())
+
+	b.Reset()
+	diff := &ProfileDiff{
+		Added:   []string{"/nix/store/abc-go-1.22"},
+		Removed: []string{"/nix/store/def-go-1.21"},
+	}
+	diff.WriteText(&b)
+	require.Equal(t, "Nix profile changes:\n  + /nix/store/abc-go-1.22\n  - /nix/store/def-go-1.21\n", b.String())
+}
+
+func TestInstallablePackagesForSubsetInstall(t *testing.T) {
+	d := devboxForTesting(t)
+	d.cfg.PackageMutator().Add("go@1.22")
+	d.cfg.PackageMutator().Add("nodejs@20")
+	d.cfg.PackageMutator().Add("git@latest")
+	require.NoError(t, d.cfg.PackageMutator().AddGroup(io.Discard, "go@1.22", "backend"))
+	require.NoError(t, d.cfg.PackageMutator().AddGroup(io.Discard, "nodejs@20", "frontend"))
+	raws := func() []string {
+		return lo.Map(d.InstallablePackages(), func(p *devpkg.Package, _ int) string { return p.Raw })
+	}
+
+	require.False(t, d.isSubsetInstall())
+	require.ElementsMatch(t, []string{"go@1.22", "nodejs@20", "git@latest"}, raws())
+
+	d.installGroups = []string{"backend"}
+	require.True(t, d.isSubsetInstall())
+	require.ElementsMatch(t, []string{"go@1.22", "git@latest"}, raws())
+
+	d.installGroups = nil
+	d.installSubset = map[string]bool{"nodejs@20": true}
+	require.True(t, d.isSubsetInstall())
+	require.Equal(t, []string{"nodejs@20"}, raws())
+}
+
+func TestDeferLazyPackages(t *testing.T) {
+	path := t.TempDir()
+	err := os.WriteFile(filepath.Join(path, "devbox.json"), []byte(`{
+		"packages": {
+			"go": "1.22",
+			"texlive.combined.scheme-full": {"version": "latest", "lazy": true}
+		}
+	}`), 0o644)
+	require.NoError(t, err)
+	d, err := Open(&devopt.Opts{Dir: path, Stderr: os.Stderr})
+	require.NoError(t, err)
+	raws := func() []string {
+		return lo.Map(d.InstallablePackages(), func(p *devpkg.Package, _ int) string { return p.Raw })
+	}
+
+	require.ElementsMatch(t, []string{"go@1.22", "texlive.combined.scheme-full@latest"}, raws())
+
+	restore := d.deferLazyPackages()
+	require.True(t, d.isPartialInstall())
+	require.Equal(t, []string{"go@1.22"}, raws())
+
+	restore()
+	require.False(t, d.isPartialInstall())
+	require.Len(t, raws(), 2)
+}
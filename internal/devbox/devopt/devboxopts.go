@@ -2,6 +2,7 @@ package devopt
 
 import (
 	"io"
+	"time"
 )
 
 // Naming Convention:
@@ -14,7 +15,93 @@ type Opts struct {
 	Environment              string
 	IgnoreWarnings           bool
 	CustomProcessComposeFile string
-	Stderr                   io.Writer
+	// StoreRoot is the root directory of a non-default Nix store to install
+	// packages into. It must be a local directory. Store URLs, such as
+	// daemon or ssh://host, aren't supported. Defaults to the system store.
+	StoreRoot string
+	// BuildVerbosity is how much nix build output to show when installing
+	// packages: "default", "verbose" or "quiet".
+	BuildVerbosity string
+	// BuildLogLines is how many lines at the end of a failed derivation's
+	// build log to include in the install error. Zero means a default of 25
+	// lines, and a negative number leaves out the log.
+	BuildLogLines int
+	// MaxJobs is the number of nix builds that may run in parallel when
+	// installing packages. Nil defaults to the max_jobs field in
+	// devbox.json, and then to nix's own setting.
+	MaxJobs *int
+	// Cores is the number of CPU cores that each nix build may use, where
+	// zero means all of them. Nil defaults to the cores field in
+	// devbox.json, and then to nix's own setting.
+	Cores *int
+	// Verbose prints details of package operations, such as the store paths
+	// added to and removed from the Nix profile.
+	Verbose bool
+	Stderr  io.Writer
+	// Stdin is where AddOpts.ReadFromStdin reads package names from.
+	Stdin io.Reader
+	// NetworkPolicy is "strict" to only allow connections to the search
+	// endpoint, the substituters in the Nix configuration and AllowedHosts.
+	// Defaults to the DEVBOX_NETWORK_POLICY environment variable.
+	NetworkPolicy string
+	// AllowedHosts are extra hosts that the strict network policy allows, in
+	// addition to the ones in DEVBOX_NETWORK_ALLOWED_HOSTS.
+	AllowedHosts []string
+	// Proxy is the URL of an HTTP proxy to use when the HTTP_PROXY and
+	// HTTPS_PROXY environment variables aren't set. Defaults to the proxy
+	// field in devbox.json.
+	Proxy string
+	// Progress receives an event as each step of installing packages and
+	// updating the project state starts and finishes. Defaults to logging
+	// the steps at debug level.
+	Progress ProgressReporter
+	// Warnings receives the warnings of package operations, such as Add,
+	// Remove and installing packages. Defaults to printing them to Stderr.
+	Warnings WarningReporter
+	// PushToCache, if set, copies the packages that install builds locally
+	// to a Nix binary cache.
+	PushToCache *PushToCache
+	// KeepGoing continues installing the other packages when some packages
+	// fail to install, and returns the failures together at the end.
+	KeepGoing bool
+	// SkipVerify skips running the verify commands of packages after
+	// installing them.
+	SkipVerify bool
+}
+
+// PushToCache is a Nix binary cache that locally built packages are copied to
+// after they're installed.
+type PushToCache struct {
+	// URI is the cache to copy to, such as s3://my-cache?region=us-east-1 or
+	// https://my-cache.example.com.
+	URI string
+	// Env is extra environment variables for nix copy, such as the cache's
+	// credentials.
+	Env []string
+}
+
+// ProgressReporter is notified as Devbox works through the steps of a
+// long-running operation, so that integrations can show progress.
+type ProgressReporter interface {
+	// Step is called when the named step starts.
+	Step(name string)
+	// Done is called when the named step finishes successfully.
+	Done(name string)
+}
+
+// WarningReporter is notified of the warnings that Devbox finds while it
+// works, so that integrations can filter or show them their own way.
+type WarningReporter interface {
+	Warn(w Warning)
+}
+
+// Warning is a problem that doesn't stop a Devbox operation.
+type Warning struct {
+	// Code identifies the kind of warning, such as "package-not-found", so
+	// that reporters don't have to match the message.
+	Code string
+	// Message describes the warning, without a trailing newline.
+	Message string
 }
 
 type ProcessComposeOpts struct {
@@ -47,12 +134,136 @@ type Credentials struct {
 }
 
 type AddOpts struct {
+	// Group is a named group to add the packages to. See
+	// Devbox.InstallGroups.
+	Group            string
 	AllowInsecure    []string
 	Platforms        []string
 	ExcludePlatforms []string
 	DisablePlugin    bool
 	PatchGlibc       bool
 	Outputs          []string
+	// BuildEnv are environment variables to set when Nix evaluates and
+	// builds the added packages. They're saved in devbox.json.
+	BuildEnv map[string]string
+	// Reason is a note about why the packages were added. It's saved in
+	// devbox.json as the packages' reason field.
+	Reason string
+	// Alternatives adds a single package that installs a different package
+	// on each platform, such as {"linux": "gcc", "darwin": "clang"}. The
+	// keys are "linux", "darwin" or a single platform.
+	Alternatives map[string]string
+	// Offline skips the search endpoint, which can't be reached without
+	// network access. Packages are checked against the project's nixpkgs
+	// commit in the local Nix store instead, and are only added to
+	// devbox.json if that isn't possible.
+	Offline bool
+	// ConflictResolution is what to do when devbox.json already has a
+	// package with the same canonical name as an added package. Defaults to
+	// ConflictReplace.
+	ConflictResolution ConflictResolution
+	// ConflictPrompter decides conflicts when ConflictResolution is
+	// ConflictPrompt.
+	ConflictPrompter ConflictPrompter
+	// IfMissing only adds packages that devbox.json doesn't have a package
+	// with the same canonical name for, whatever its version. Packages that
+	// are already there are left as they are, including their options, so
+	// the add can be repeated safely. It overrides ConflictResolution.
+	IfMissing bool
+	// ValidateTimeout limits how long each package is validated against the
+	// search endpoint before falling back to the legacy nixpkgs path. Zero
+	// means a default of 15 seconds.
+	ValidateTimeout time.Duration
+	// StrictVersion fails the add, instead of warning, when a package falls
+	// back to the project's nixpkgs and the version there doesn't match the
+	// requested version.
+	StrictVersion bool
+	// JSONOutput, if set, receives the post-add message as a JSON object
+	// instead of the default text on stderr. See devbox.PostAddMessage.
+	JSONOutput io.Writer
+	// MarkdownReadme returns the readmes of the added packages' plugins in
+	// markdown instead of plain text, for consumers that render markdown.
+	MarkdownReadme bool
+	// SourcePreference orders the sources to use for ambiguous package names.
+	// The first source that has the package is used. See
+	// pkgtype.SourceCandidates.
+	SourcePreference []string
+	// NixpkgsCommit pins the added packages to this nixpkgs commit instead
+	// of resolving them with the search index.
+	NixpkgsCommit string
+	// SkipInstall writes the packages to devbox.json without installing
+	// them. They are installed the next time the environment is used.
+	SkipInstall bool
+	// ReadFromStdin adds the package names read from Opts.Stdin, one per
+	// line, after the ones passed to Add. Blank lines and lines starting
+	// with # are ignored.
+	ReadFromStdin bool
+	// DeferValidation writes the packages to devbox.json without checking
+	// that they exist, with the search endpoint or otherwise, and without
+	// installing them. They're resolved and validated when they're
+	// installed. Packages only in the legacy nixpkgs path, without a
+	// version in the search index, fail then instead of falling back. It
+	// implies SkipInstall.
+	DeferValidation bool
+	// DryRun resolves and validates the packages and reports what would
+	// change, without modifying devbox.json or installing anything.
+	DryRun bool
+	// GitCommit commits devbox.json and devbox.lock to git after they're
+	// saved. It does nothing, with a warning, if the project isn't in a git
+	// repository.
+	GitCommit bool
+	// CommitMessage is the text/template for the GitCommit message. It can
+	// use {{.Action}} and {{.Packages}}. Defaults to
+	// DefaultCommitMessage.
+	CommitMessage string
+}
+
+// DefaultCommitMessage is the default template for the commit message of
+// AddOpts.GitCommit and RemoveOpts.GitCommit.
+const DefaultCommitMessage = "devbox: {{.Action}} {{.Packages}}"
+
+// ConflictResolution is how Devbox.Add handles an added package that has the
+// same canonical name as a package in devbox.json, such as adding go@1.22 when
+// go@1.21 is already there.
+type ConflictResolution int
+
+const (
+	// ConflictReplace removes the existing package and adds the new one.
+	ConflictReplace ConflictResolution = iota
+	// ConflictKeep keeps the existing package and skips the new one.
+	ConflictKeep
+	// ConflictPrompt asks the AddOpts.ConflictPrompter which to keep.
+	ConflictPrompt
+)
+
+// ConflictPrompter asks the user how to resolve a conflicting add.
+type ConflictPrompter interface {
+	// ConfirmReplace returns true if existing should be replaced with added.
+	ConfirmReplace(existing, added string) (bool, error)
+}
+
+type RemoveOpts struct {
+	// Force removes nix profile entries that match packages which are not in
+	// devbox.json. See Devbox.Remove.
+	Force bool
+	// GitCommit and CommitMessage are like the AddOpts fields of the same
+	// name.
+	GitCommit     bool
+	CommitMessage string
+}
+
+// CopyPackagesOpts are the options of Devbox.CopyPackagesTo.
+type CopyPackagesOpts struct {
+	// ConflictResolution is what to do when the target project already has
+	// a package with the same name and different settings, such as another
+	// version. Defaults to ConflictReplace.
+	ConflictResolution ConflictResolution
+	// ConflictPrompter decides conflicts when ConflictResolution is
+	// ConflictPrompt.
+	ConflictPrompter ConflictPrompter
+	// SkipInstall writes the packages to the target's devbox.json without
+	// installing them.
+	SkipInstall bool
 }
 
 type UpdateOpts struct {
@@ -67,6 +278,13 @@ type EnvExportsOpts struct {
 	RunHooks                 bool
 }
 
+// ExportDotenvOpts configures Devbox.ExportDotenv.
+type ExportDotenvOpts struct {
+	// IncludePath writes PATH to the file. It's left out by default, since
+	// the tools that read .env files usually set up their own PATH.
+	IncludePath bool
+}
+
 // EnvOptions configure the Devbox Environment in the `computeEnv` function.
 // - These options are commonly set by flags in some Devbox commands
 // like `shellenv`, `shell` and `run`.
@@ -75,4 +293,8 @@ type EnvOptions struct {
 	OmitNixEnv        bool
 	PreservePathStack bool
 	Pure              bool
+	// Groups are the package groups whose profiles are put on the PATH when
+	// group_profiles is set in devbox.json. Nil means all groups. The
+	// packages without a group are always on the PATH.
+	Groups []string
 }
@@ -0,0 +1,158 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"runtime/trace"
+	"slices"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/samber/lo"
+	"go.jetpack.io/devbox/internal/devpkg"
+	"go.jetpack.io/devbox/internal/devpkg/pkgtype"
+	"go.jetpack.io/devbox/internal/fileutil"
+	"go.jetpack.io/devbox/internal/nix"
+)
+
+// Issue is an inconsistency in a project's state found by Doctor.
+type Issue struct {
+	// Package is the package or store path that the issue is about, if
+	// any.
+	Package string `json:"package,omitempty"`
+	// Problem describes what's wrong.
+	Problem string `json:"problem"`
+	// Fix suggests how to fix it.
+	Fix string `json:"fix,omitempty"`
+}
+
+func (i Issue) String() string {
+	s := i.Problem
+	if i.Package != "" {
+		s = i.Package + ": " + s
+	}
+	if i.Fix != "" {
+		s += " (fix: " + i.Fix + ")"
+	}
+	return s
+}
+
+// Doctor checks that the project's config, lockfile, Nix store and Nix profile
+// agree with each other, and reports the discrepancies. It checks that:
+//
+//   - every installable package is locked in devbox.lock,
+//   - every locked package has store paths for the current system, and they're
+//     in the Nix store,
+//   - the Nix profile has exactly the packages in the environment's
+//     buildInputs, so there are no missing or orphaned profile entries.
+//
+// Unlike Install, it doesn't fix or build anything or query the search
+// service, so the profile is checked against the environment that was last
+// computed. It returns an error only if a check couldn't run.
+func (d *Devbox) Doctor(ctx context.Context) ([]Issue, error) {
+	defer trace.StartRegion(ctx, "devboxDoctor").End()
+
+	issues, err := d.lockfileIssues(ctx)
+	if err != nil {
+		return nil, err
+	}
+	profileIssues, err := d.profileIssues()
+	if err != nil {
+		return nil, err
+	}
+	return append(issues, profileIssues...), nil
+}
+
+// lockfileIssues checks the installable packages against the lockfile and
+// the Nix store.
+func (d *Devbox) lockfileIssues(ctx context.Context) ([]Issue, error) {
+	issues := []Issue{}
+	lockedStorePaths := map[*devpkg.Package][]string{}
+	for _, pkg := range d.PackagesByType(pkgtype.Nix) {
+		// Flakes aren't locked to store paths.
+		if !pkg.IsDevboxPackage {
+			continue
+		}
+		if d.lockfile.Get(pkg.Raw) == nil {
+			issues = append(issues, Issue{
+				Package: pkg.Raw,
+				Problem: "isn't locked in devbox.lock",
+				Fix:     "devbox install",
+			})
+			continue
+		}
+		storePaths, err := pkg.GetResolvedStorePaths()
+		if err != nil {
+			return nil, err
+		}
+		if len(storePaths) == 0 {
+			issues = append(issues, Issue{
+				Package: pkg.Raw,
+				Problem: fmt.Sprintf("has no store paths for %s in devbox.lock", nix.System()),
+				Fix:     "devbox install --tidy-lockfile",
+			})
+			continue
+		}
+		lockedStorePaths[pkg] = storePaths
+	}
+
+	notInStore, err := d.packagesNotInStore(ctx, lockedStorePaths)
+	if err != nil {
+		return nil, err
+	}
+	slices.SortFunc(notInStore, func(a, b *devpkg.Package) int { return strings.Compare(a.Raw, b.Raw) })
+	for _, pkg := range notInStore {
+		issues = append(issues, Issue{
+			Package: pkg.Raw,
+			Problem: "is locked to store paths that aren't in the Nix store",
+			Fix:     "devbox install",
+		})
+	}
+	return issues, nil
+}
+
+// profileIssues compares the project's Nix profile with the buildInputs of
+// the environment, like syncNixProfileFromFlake does. It reads the environment
+// from the print-dev-env cache, since computing it could build packages.
+func (d *Devbox) profileIssues() ([]Issue, error) {
+	cached, err := nix.ReadPrintDevEnvCache(d.nixPrintDevEnvCachePath())
+	if errors.Is(err, fs.ErrNotExist) || !fileutil.Exists(d.flakeDir()) {
+		return []Issue{{
+			Problem: "the environment hasn't been generated, so the Nix profile can't be checked",
+			Fix:     "devbox install",
+		}}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	buildInputs, _ := cached.Variables["buildInputs"].Value.(string)
+	want := strings.Fields(buildInputs)
+
+	got, err := d.installedProfileStorePaths()
+	if err != nil {
+		return nil, err
+	}
+
+	orphaned, missing := lo.Difference(got, want)
+	slices.Sort(orphaned)
+	slices.Sort(missing)
+	issues := []Issue{}
+	for _, p := range missing {
+		issues = append(issues, Issue{
+			Package: p,
+			Problem: "is in the environment but not in the Nix profile",
+			Fix:     "devbox install",
+		})
+	}
+	for _, p := range orphaned {
+		issues = append(issues, Issue{
+			Package: p,
+			Problem: "is in the Nix profile but not in the environment",
+			Fix:     "devbox install",
+		})
+	}
+	return issues, nil
+}
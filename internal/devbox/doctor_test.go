@@ -0,0 +1,47 @@
+package devbox
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.jetpack.io/devbox/internal/nix"
+)
+
+func TestDoctorWithoutEnvironment(t *testing.T) {
+	d := devboxForTesting(t)
+	issues, err := d.Doctor(context.Background())
+	require.NoError(t, err)
+	require.Len(t, issues, 1)
+	require.Equal(t,
+		"the environment hasn't been generated, so the Nix profile can't be checked (fix: devbox install)",
+		issues[0].String())
+}
+
+func TestDoctorUsesCachedEnvironment(t *testing.T) {
+	d := devboxForTesting(t)
+	// Computing the environment could build packages, so nix must not run.
+	nix.FakeForTest(t, "exit 1\n")
+
+	require.NoError(t, os.MkdirAll(d.flakeDir(), 0o755))
+	env, err := json.Marshal(nix.PrintDevEnvOut{Variables: map[string]nix.Variable{
+		"buildInputs": {Type: "exported", Value: "/nix/store/abc-go-1.22"},
+	}})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(d.nixPrintDevEnvCachePath(), env, 0o644))
+
+	issues, err := d.profileIssues()
+	require.NoError(t, err)
+	require.Equal(t, []Issue{{
+		Package: "/nix/store/abc-go-1.22",
+		Problem: "is in the environment but not in the Nix profile",
+		Fix:     "devbox install",
+	}}, issues)
+}
+
+func TestIssueString(t *testing.T) {
+	issue := Issue{Package: "go@1.22", Problem: "isn't locked in devbox.lock", Fix: "devbox install"}
+	require.Equal(t, "go@1.22: isn't locked in devbox.lock (fix: devbox install)", issue.String())
+}
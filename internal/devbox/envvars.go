@@ -8,6 +8,7 @@ import (
 	"slices"
 	"strings"
 
+	"github.com/samber/lo"
 	"go.jetpack.io/devbox/internal/devbox/envpath"
 	"go.jetpack.io/devbox/internal/envir"
 )
@@ -47,6 +48,47 @@ func exportify(vars map[string]string) string {
 	return strings.TrimSpace(strb.String())
 }
 
+// setByDevbox returns the variables in env that Devbox or Nix set, leaving out
+// the ones inherited unchanged from hostEnv.
+func setByDevbox(env, hostEnv map[string]string) map[string]string {
+	return lo.OmitBy(env, func(k, v string) bool {
+		hostValue, ok := hostEnv[k]
+		return ok && hostValue == v
+	})
+}
+
+// dotenvify formats vars as the lines of a .env file. Each line is of the form
+// `key="value"`, with quotes, backslashes and dollar signs escaped and
+// newlines written as \n, so that values are read back literally.
+func dotenvify(vars map[string]string) string {
+	keys := make([]string, 0, len(vars))
+	for k := range vars {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys) // for reproducibility
+
+	strb := strings.Builder{}
+	for _, k := range keys {
+		strb.WriteString(k)
+		strb.WriteString(`="`)
+		for _, r := range vars[k] {
+			switch r {
+			case '\n':
+				strb.WriteString(`\n`)
+			case '\r':
+				strb.WriteString(`\r`)
+			case '"', '\\', '$':
+				strb.WriteRune('\\')
+				strb.WriteRune(r)
+			default:
+				strb.WriteRune(r)
+			}
+		}
+		strb.WriteString("\"\n")
+	}
+	return strb.String()
+}
+
 // addEnvIfNotPreviouslySetByDevbox adds the key-value pairs from new to existing,
 // but only if the key was not previously set by devbox
 // Caveat, this won't mark the values as set by devbox automatically. Instead,
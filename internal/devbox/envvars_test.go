@@ -0,0 +1,55 @@
+package devbox
+
+import (
+	"testing"
+
+	"github.com/joho/godotenv"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDotenvify(t *testing.T) {
+	vars := map[string]string{
+		"SIMPLE":    "value",
+		"SPACES":    "a value with spaces",
+		"MULTILINE": "line one\nline two",
+		"QUOTES":    `say "hi" \n not a newline`,
+		"DOLLAR":    "$HOME and ${USER}",
+		"NUMBER":    "007",
+		"EMPTY":     "",
+	}
+	got := dotenvify(vars)
+	require.Equal(t, `DOLLAR="\$HOME and \${USER}"
+EMPTY=""
+MULTILINE="line one\nline two"
+NUMBER="007"
+QUOTES="say \"hi\" \\n not a newline"
+SIMPLE="value"
+SPACES="a value with spaces"
+`, got)
+
+	// The file reads back as the same values.
+	parsed, err := godotenv.Unmarshal(got)
+	require.NoError(t, err)
+	require.Equal(t, vars, parsed)
+}
+
+func TestSetByDevbox(t *testing.T) {
+	host := map[string]string{
+		"HOME":      "/home/me",
+		"API_TOKEN": "secret",
+		"PATH":      "/usr/bin",
+		"GOPATH":    "/home/me/go",
+	}
+	env := map[string]string{
+		"HOME":                "/home/me",
+		"API_TOKEN":           "secret",
+		"PATH":                "/project/.devbox/nix/profile/default/bin:/usr/bin",
+		"GOPATH":              "/project/.devbox/go",
+		"DEVBOX_PROJECT_ROOT": "/project",
+	}
+	require.Equal(t, map[string]string{
+		"PATH":                "/project/.devbox/nix/profile/default/bin:/usr/bin",
+		"GOPATH":              "/project/.devbox/go",
+		"DEVBOX_PROJECT_ROOT": "/project",
+	}, setByDevbox(env, host))
+}
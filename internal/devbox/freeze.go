@@ -0,0 +1,75 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"context"
+	"runtime/trace"
+
+	"go.jetpack.io/devbox/internal/devpkg/pkgtype"
+	"go.jetpack.io/devbox/internal/lock"
+	"go.jetpack.io/devbox/internal/ux"
+)
+
+// Freeze pins every floating package in devbox.json, such as go@latest,
+// to the exact version it's locked to in devbox.lock, so that the config
+// itself records the versions in use. Packages that are already pinned to a
+// version, flakes and packages with platform alternatives are left as they
+// are. Packages that aren't locked yet, and packages without a version that
+// use the project's nixpkgs commit, can't be pinned, so they're reported and
+// skipped.
+//
+// Freeze doesn't resolve or install anything: the locked packages are the
+// same, only their names in devbox.json and devbox.lock change.
+func (d *Devbox) Freeze(ctx context.Context) error {
+	defer trace.StartRegion(ctx, "devboxFreeze").End()
+
+	frozen := 0
+	for _, cfgPkg := range d.cfg.Root.ConfiguredPackages() {
+		versionedName := cfgPkg.VersionedName()
+		if pkgtype.IsFlake(versionedName) || len(cfgPkg.Alternatives) > 0 {
+			continue
+		}
+		if cfgPkg.Version != "" && cfgPkg.Version != "latest" {
+			continue
+		}
+		if cfgPkg.Version == "" && lock.IsLegacyPackage(versionedName) {
+			d.warn(
+				WarningNotVersioned,
+				"Package %s has no version and uses the nixpkgs commit in devbox.json, so it wasn't pinned. Run `devbox add %s@latest` to version it.\n",
+				versionedName, versionedName,
+			)
+			continue
+		}
+		entry := d.lockfile.Get(versionedName)
+		if entry == nil || entry.Version == "" {
+			d.warn(
+				WarningNotLocked,
+				"Package %s isn't locked to a version, so it wasn't pinned. Run `devbox install` to lock it.\n",
+				versionedName,
+			)
+			continue
+		}
+
+		if err := d.cfg.PackageMutator().SetVersion(versionedName, entry.Version); err != nil {
+			return err
+		}
+		pinned := cfgPkg.Name + "@" + entry.Version
+		if d.lockfile.Get(pinned) == nil {
+			d.lockfile.Packages[pinned] = entry
+		}
+		delete(d.lockfile.Packages, versionedName)
+		ux.Finfo(d.stderr, "Pinned %s to %s\n", versionedName, pinned)
+		frozen++
+	}
+
+	if frozen == 0 {
+		ux.Finfo(d.stderr, "All packages are already pinned.\n")
+		return nil
+	}
+	if err := d.saveCfg(); err != nil {
+		return err
+	}
+	return d.lockfile.Save()
+}
@@ -0,0 +1,38 @@
+package devbox
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.jetpack.io/devbox/internal/lock"
+)
+
+func TestFreeze(t *testing.T) {
+	d := devboxForTesting(t)
+	reporter := &recordingWarnings{}
+	d.warnings = reporter
+	for _, pkg := range []string{"go@latest", "jq@1.7", "hello", "github:nixos/nixpkgs#ripgrep", "ruff@latest", "cc"} {
+		d.cfg.PackageMutator().Add(pkg)
+	}
+	// Alternatives are left as they are, even if they're locked.
+	require.NoError(t, d.cfg.PackageMutator().SetAlternatives("cc", map[string]string{"linux": "gcc@latest"}))
+	d.lockfile.Packages["gcc@latest"] = &lock.Package{Resolved: "github:NixOS/nixpkgs/abc#gcc", Version: "13.2.0"}
+	goEntry := &lock.Package{Resolved: "github:NixOS/nixpkgs/abc#go", Version: "1.22.5"}
+	d.lockfile.Packages["go@latest"] = goEntry
+	d.lockfile.Packages["jq@1.7"] = &lock.Package{Resolved: "github:NixOS/nixpkgs/abc#jq", Version: "1.7.1"}
+
+	require.NoError(t, d.Freeze(context.Background()))
+	names := []string{}
+	for _, pkg := range d.cfg.Root.ConfiguredPackages() {
+		names = append(names, pkg.VersionedName())
+	}
+	require.Equal(t, []string{"go@1.22.5", "jq@1.7", "hello", "github:nixos/nixpkgs#ripgrep", "ruff@latest", "cc"}, names)
+	require.Equal(t, goEntry, d.lockfile.Get("go@1.22.5"))
+	require.Nil(t, d.lockfile.Get("go@latest"))
+
+	// hello has no version to pin, and ruff isn't locked yet.
+	require.Len(t, reporter.warnings, 2)
+	require.Equal(t, WarningNotVersioned, reporter.warnings[0].Code)
+	require.Equal(t, WarningNotLocked, reporter.warnings[1].Code)
+}
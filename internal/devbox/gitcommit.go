@@ -0,0 +1,99 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"bytes"
+	"context"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/pkg/errors"
+	"github.com/samber/lo"
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+	"go.jetpack.io/devbox/internal/devbox/devopt"
+	"go.jetpack.io/devbox/internal/devconfig/configfile"
+	"go.jetpack.io/devbox/internal/devpkg"
+	"go.jetpack.io/devbox/internal/fileutil"
+	"go.jetpack.io/devbox/internal/ux"
+)
+
+// commitMessageData is the data for the commit message template.
+type commitMessageData struct {
+	// Action is what the command did, such as "add" or "remove".
+	Action string
+	// Packages are the changed packages, separated by spaces.
+	Packages string
+}
+
+// commitConfigChanges commits devbox.json and devbox.lock to git with a
+// message rendered from tmpl. Other staged changes aren't committed. It does
+// nothing if there's nothing to commit, and only warns if the project isn't in
+// a git repository.
+func (d *Devbox) commitConfigChanges(ctx context.Context, tmpl, action string, packages []string) error {
+	if tmpl == "" {
+		tmpl = devopt.DefaultCommitMessage
+	}
+	t, err := template.New("commit").Parse(tmpl)
+	if err != nil {
+		return usererr.WithUserMessage(err, "Invalid commit message template %q.", tmpl)
+	}
+	var msg bytes.Buffer
+	err = t.Execute(&msg, commitMessageData{Action: action, Packages: strings.Join(packages, " ")})
+	if err != nil {
+		return usererr.WithUserMessage(err, "Invalid commit message template %q.", tmpl)
+	}
+
+	if _, err := d.git(ctx, "rev-parse", "--is-inside-work-tree"); err != nil {
+		d.warn(WarningNotGitRepository, "%s isn't in a git repository, so the changes weren't committed.\n", d.projectDir)
+		return nil
+	}
+
+	files := []string{}
+	for _, name := range []string{configfile.DefaultName, "devbox.lock"} {
+		if fileutil.Exists(filepath.Join(d.projectDir, name)) {
+			files = append(files, name)
+		}
+	}
+	if _, err := d.git(ctx, append([]string{"add", "--"}, files...)...); err != nil {
+		return err
+	}
+	diffArgs := append([]string{"diff", "--cached", "--quiet", "--"}, files...)
+	if _, err := d.git(ctx, diffArgs...); err == nil {
+		return nil // nothing to commit
+	}
+	args := append([]string{"commit", "--message", strings.TrimSpace(msg.String()), "--"}, files...)
+	if _, err := d.git(ctx, args...); err != nil {
+		return err
+	}
+	ux.Finfo(d.stderr, "Committed %s to git\n", strings.Join(files, " and "))
+	return nil
+}
+
+// lockedVersionNames returns the packages with the versions they're locked to
+// in devbox.lock, such as ripgrep@14.1.0 for ripgrep@latest, for use in a
+// commit message. Packages that aren't locked to a version are returned as
+// they are.
+func (d *Devbox) lockedVersionNames(packages []string) []string {
+	return lo.Map(packages, func(raw string, _ int) string {
+		entry := d.lockfile.Packages[raw]
+		if entry == nil || entry.Version == "" {
+			return raw
+		}
+		return devpkg.PackageFromStringWithDefaults(raw, d.lockfile).CanonicalName() + "@" + entry.Version
+	})
+}
+
+// git runs a git command in the project directory and returns its output.
+func (d *Devbox) git(ctx context.Context, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = d.projectDir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return out, errors.Errorf("git %s: %v: %s", args[0], err, bytes.TrimSpace(out))
+	}
+	return out, nil
+}
@@ -0,0 +1,50 @@
+package devbox
+
+import (
+	"context"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.jetpack.io/devbox/internal/lock"
+)
+
+func TestCommitConfigChanges(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git isn't installed")
+	}
+	ctx := context.Background()
+	d := devboxForTesting(t)
+
+	// Not a git repository, so there's nothing to commit.
+	require.NoError(t, d.commitConfigChanges(ctx, "", "add", []string{"hello@2.12"}))
+
+	for _, args := range [][]string{
+		{"init", "--quiet"},
+		{"config", "user.name", "Devbox Test"},
+		{"config", "user.email", "test@example.com"},
+	} {
+		_, err := d.git(ctx, args...)
+		require.NoError(t, err)
+	}
+	require.NoError(t, d.commitConfigChanges(ctx, "", "add", []string{"hello@2.12", "jq@1.7"}))
+	out, err := d.git(ctx, "log", "--format=%s")
+	require.NoError(t, err)
+	require.Equal(t, "devbox: add hello@2.12 jq@1.7", strings.TrimSpace(string(out)))
+
+	// Committing again with no changes is a no-op.
+	require.NoError(t, d.commitConfigChanges(ctx, "chore: {{.Action}}", "remove", nil))
+	out, err = d.git(ctx, "rev-list", "--count", "HEAD")
+	require.NoError(t, err)
+	require.Equal(t, "1", strings.TrimSpace(string(out)))
+}
+
+func TestLockedVersionNames(t *testing.T) {
+	d := devboxForTesting(t)
+	d.lockfile.Packages["ripgrep@latest"] = &lock.Package{Version: "14.1.0"}
+	d.lockfile.Packages["jq@1.7"] = &lock.Package{}
+
+	got := d.lockedVersionNames([]string{"ripgrep@latest", "jq@1.7", "hello@2.12"})
+	require.Equal(t, []string{"ripgrep@14.1.0", "jq@1.7", "hello@2.12"}, got)
+}
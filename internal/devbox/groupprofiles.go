@@ -0,0 +1,198 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+
+	"github.com/pkg/errors"
+	"github.com/samber/lo"
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+	"go.jetpack.io/devbox/internal/fileutil"
+	"go.jetpack.io/devbox/internal/nix"
+)
+
+// groupProfilesDir is the directory, relative to the project, of the Nix
+// profiles of package groups when group_profiles is set in devbox.json. Each
+// group's profile is named after the group.
+const groupProfilesDir = ".devbox/nix/profile/groups"
+
+// profileGenerationLink matches the generation links that nix creates next to
+// a profile, such as "dev-1-link" for the profile "dev".
+var profileGenerationLink = regexp.MustCompile(`-\d+-link$`)
+
+// groupProfilePath returns the absolute path of group's Nix profile.
+func (d *Devbox) groupProfilePath(group string) (string, error) {
+	if group == "." || group == ".." {
+		return "", usererr.New("%q can't be used as a group name with group_profiles.", group)
+	}
+	return filepath.Join(d.projectDir, groupProfilesDir, url.PathEscape(group)), nil
+}
+
+// existingGroupProfiles returns the groups that have a Nix profile in the
+// project, including groups that no longer have any packages.
+func (d *Devbox) existingGroupProfiles() ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(d.projectDir, groupProfilesDir))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	groups := []string{}
+	for _, entry := range entries {
+		if profileGenerationLink.MatchString(entry.Name()) {
+			continue
+		}
+		group, err := url.PathUnescape(entry.Name())
+		if err != nil {
+			continue
+		}
+		groups = append(groups, group)
+	}
+	return groups, nil
+}
+
+// projectProfilePaths returns the paths of all of the project's Nix profiles:
+// the default profile and the profiles of groups. Some of them might not
+// exist yet.
+func (d *Devbox) projectProfilePaths() ([]string, error) {
+	profilePaths := []string{filepath.Join(d.projectDir, nix.ProfilePath)}
+	groups, err := d.existingGroupProfiles()
+	if err != nil {
+		return nil, err
+	}
+	for _, group := range groups {
+		profilePath, err := d.groupProfilePath(group)
+		if err != nil {
+			return nil, err
+		}
+		profilePaths = append(profilePaths, profilePath)
+	}
+	return profilePaths, nil
+}
+
+// installedProfileStorePaths returns the store paths in all of the project's
+// Nix profiles: the default profile and the profiles of groups.
+func (d *Devbox) installedProfileStorePaths() ([]string, error) {
+	profilePaths, err := d.projectProfilePaths()
+	if err != nil {
+		return nil, err
+	}
+
+	storePaths := []string{}
+	for _, profilePath := range profilePaths {
+		if !fileutil.Exists(profilePath) {
+			continue
+		}
+		items, err := d.profileListItems(profilePath)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range items {
+			storePaths = append(storePaths, item.StorePaths()...)
+		}
+	}
+	return lo.Uniq(storePaths), nil
+}
+
+// configGroups returns the groups of the packages in devbox.json, sorted.
+func (d *Devbox) configGroups() []string {
+	groups := []string{}
+	for _, pkg := range d.cfg.Root.TopLevelPackages() {
+		groups = append(groups, pkg.Groups...)
+	}
+	groups = lo.Uniq(groups)
+	slices.Sort(groups)
+	return groups
+}
+
+// profileStorePaths splits the store paths that the project's Nix profiles
+// should have by profile, keyed by group. The default profile's key is "".
+// Without group_profiles, every store path is in the default profile.
+//
+// A store path is in the profile of every group of the packages it belongs
+// to, unless it also belongs to a package without a group. Store paths that
+// don't belong to a package in devbox.json, such as those of plugins, are in
+// the default profile.
+func (d *Devbox) profileStorePaths(want []string) (map[string][]string, error) {
+	byProfile := map[string][]string{"": {}}
+	if !d.cfg.Root.GroupProfiles {
+		byProfile[""] = want
+		return byProfile, nil
+	}
+
+	ungrouped := map[string]bool{}
+	groupsByPath := map[string][]string{}
+	for _, pkg := range d.InstallablePackages() {
+		// Packages without locked store paths, such as flakes, are
+		// matched by name instead, the same as in ListInstalled.
+		var locked []string
+		if pkg.IsDevboxPackage && d.lockfile.Get(pkg.Raw) != nil {
+			var err error
+			if locked, err = pkg.GetResolvedStorePaths(); err != nil {
+				return nil, err
+			}
+		}
+		name := pkg.CanonicalName()
+		if pkg.IsDevboxPackage {
+			name = pkg.Versioned()
+		}
+		for _, path := range matchProfilePaths(name, locked, want) {
+			if len(pkg.Groups) == 0 {
+				ungrouped[path] = true
+			} else {
+				groupsByPath[path] = append(groupsByPath[path], pkg.Groups...)
+			}
+		}
+	}
+
+	for _, path := range want {
+		groups := groupsByPath[path]
+		if ungrouped[path] || len(groups) == 0 {
+			byProfile[""] = append(byProfile[""], path)
+			continue
+		}
+		for _, group := range lo.Uniq(groups) {
+			byProfile[group] = append(byProfile[group], path)
+		}
+	}
+	return byProfile, nil
+}
+
+// groupProfileBinPaths returns the bin directories of the profiles of the
+// active groups, which are all groups if active is nil. It returns nothing
+// without group_profiles, since then every package is in the default profile.
+func (d *Devbox) groupProfileBinPaths(active []string) ([]string, error) {
+	if !d.cfg.Root.GroupProfiles {
+		if len(active) > 0 {
+			return nil, usererr.New(
+				"Activating package groups requires \"group_profiles\": true in devbox.json.")
+		}
+		return nil, nil
+	}
+	groups := d.configGroups()
+	if active != nil {
+		for _, g := range active {
+			if !slices.Contains(groups, g) {
+				return nil, usererr.New("No packages belong to group %q.", g)
+			}
+		}
+		groups = active
+	}
+	paths := make([]string, 0, len(groups))
+	for _, group := range groups {
+		profilePath, err := d.groupProfilePath(group)
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, filepath.Join(profilePath, "bin"))
+	}
+	return paths, nil
+}
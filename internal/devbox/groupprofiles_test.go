@@ -0,0 +1,71 @@
+package devbox
+
+import (
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProfileStorePaths(t *testing.T) {
+	d := devboxForTesting(t)
+	d.stderr = io.Discard
+	d.cfg.PackageMutator().Add("go@1.22")
+	d.cfg.PackageMutator().Add("nodejs@20")
+	d.cfg.PackageMutator().Add("git@latest")
+	require.NoError(t, d.cfg.PackageMutator().AddGroup(io.Discard, "go@1.22", "backend"))
+	require.NoError(t, d.cfg.PackageMutator().AddGroup(io.Discard, "nodejs@20", "frontend"))
+	require.NoError(t, d.cfg.PackageMutator().AddGroup(io.Discard, "nodejs@20", "e2e"))
+
+	goPath := "/nix/store/00000000000000000000000000000000-go-1.22.5"
+	nodePath := "/nix/store/11111111111111111111111111111111-nodejs-20.11.0"
+	gitPath := "/nix/store/22222222222222222222222222222222-git-2.44.0"
+	want := []string{goPath, nodePath, gitPath}
+
+	byProfile, err := d.profileStorePaths(want)
+	require.NoError(t, err)
+	require.Equal(t, map[string][]string{"": want}, byProfile)
+
+	d.cfg.Root.GroupProfiles = true
+	byProfile, err = d.profileStorePaths(want)
+	require.NoError(t, err)
+	require.Equal(t, map[string][]string{
+		"":         {gitPath},
+		"backend":  {goPath},
+		"frontend": {nodePath},
+		"e2e":      {nodePath},
+	}, byProfile)
+}
+
+func TestGroupProfileBinPaths(t *testing.T) {
+	d := devboxForTesting(t)
+	d.cfg.PackageMutator().Add("go@1.22")
+	require.NoError(t, d.cfg.PackageMutator().AddGroup(io.Discard, "go@1.22", "backend"))
+
+	paths, err := d.groupProfileBinPaths(nil)
+	require.NoError(t, err)
+	require.Empty(t, paths)
+	_, err = d.groupProfileBinPaths([]string{"backend"})
+	require.Error(t, err)
+
+	d.cfg.Root.GroupProfiles = true
+	paths, err = d.groupProfileBinPaths(nil)
+	require.NoError(t, err)
+	require.Equal(t, []string{filepath.Join(d.projectDir, groupProfilesDir, "backend", "bin")}, paths)
+	paths, err = d.groupProfileBinPaths([]string{})
+	require.NoError(t, err)
+	require.Empty(t, paths)
+	_, err = d.groupProfileBinPaths([]string{"frontend"})
+	require.Error(t, err)
+}
+
+func TestGroupProfilePath(t *testing.T) {
+	d := devboxForTesting(t)
+	_, err := d.groupProfilePath("..")
+	require.Error(t, err)
+
+	path, err := d.groupProfilePath("a/b")
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(d.projectDir, groupProfilesDir, "a%2Fb"), path)
+}
@@ -0,0 +1,166 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime/trace"
+
+	"github.com/pkg/errors"
+	"github.com/samber/lo"
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+	"go.jetpack.io/devbox/internal/fileutil"
+	"go.jetpack.io/devbox/internal/lock"
+	"go.jetpack.io/devbox/internal/nix"
+)
+
+// InstalledPackage is a package in the project and what's installed for it.
+// See Devbox.ListInstalled.
+type InstalledPackage struct {
+	// Name is the package's name in the config, such as "go@1.22".
+	Name string `json:"name"`
+
+	// Version is the installed version, or the locked version if the
+	// version can't be read from the store paths.
+	Version string `json:"version,omitempty"`
+
+	// StorePaths are the package's store paths in the project's Nix
+	// profile.
+	StorePaths []string `json:"store_paths,omitempty"`
+
+	// Pending is true if the package is in the config but isn't installed
+	// yet.
+	Pending bool `json:"pending"`
+}
+
+// ListInstalled returns the installable packages along with their versions and
+// store paths in the project's Nix profile. Packages that aren't in the profile
+// are marked as pending. Runx packages aren't installed to the profile, so
+// they're pending until runx has installed their locked version.
+//
+// Unlike AllPackageNamesIncludingRemovedTriggerPackages, which lists the
+// config, ListInstalled reports what's actually installed. It doesn't install
+// or resolve anything.
+func (d *Devbox) ListInstalled(ctx context.Context) ([]InstalledPackage, error) {
+	defer trace.StartRegion(ctx, "devboxListInstalled").End()
+
+	profilePaths, err := d.installedProfileStorePaths()
+	if err != nil {
+		return nil, err
+	}
+
+	installed := []InstalledPackage{}
+	for _, pkg := range d.InstallablePackages() {
+		entry := d.lockfile.Get(pkg.Raw)
+		p := InstalledPackage{Name: pkg.Raw}
+		if entry != nil {
+			p.Version = entry.Version
+		}
+		if pkg.IsRunX() {
+			p.Pending = !d.isRunXInstalled(entry)
+			installed = append(installed, p)
+			continue
+		}
+
+		// Packages without locked store paths, such as flakes, are
+		// matched by name instead.
+		var locked []string
+		if entry != nil && pkg.IsDevboxPackage {
+			var err error
+			if locked, err = pkg.GetResolvedStorePaths(); err != nil {
+				return nil, err
+			}
+		}
+		name := pkg.CanonicalName()
+		if pkg.IsDevboxPackage {
+			name = pkg.Versioned()
+		}
+		p.StorePaths = matchProfilePaths(name, locked, profilePaths)
+		p.Pending = len(p.StorePaths) == 0
+		if !p.Pending {
+			if v := nix.NewStorePathParts(p.StorePaths[0]).Version; v != "" {
+				p.Version = v
+			}
+		}
+		installed = append(installed, p)
+	}
+	return installed, nil
+}
+
+func (d *Devbox) runxInstallsPath() string {
+	return filepath.Join(d.projectDir, ".devbox", "virtenv", "runx", "installs.json")
+}
+
+// saveRunXInstalls records the directories that runx installed each locked
+// runx package to, keyed by the package's resolved reference.
+func (d *Devbox) saveRunXInstalls(installs map[string][]string) error {
+	data, err := json.MarshalIndent(installs, "", "  ")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(os.WriteFile(d.runxInstallsPath(), data, 0o644))
+}
+
+// isRunXInstalled returns true if runx has installed the locked runx package
+// and its install directories still exist.
+func (d *Devbox) isRunXInstalled(entry *lock.Package) bool {
+	if entry == nil {
+		return false
+	}
+	data, err := os.ReadFile(d.runxInstallsPath())
+	if err != nil {
+		return false
+	}
+	installs := map[string][]string{}
+	if err := json.Unmarshal(data, &installs); err != nil {
+		return false
+	}
+	dirs, ok := installs[entry.Resolved]
+	return ok && lo.EveryBy(dirs, fileutil.IsDir)
+}
+
+// matchProfilePaths returns the profile store paths that belong to the
+// package with the given versioned name. If the package has locked store
+// paths, only those match. Otherwise, store paths match by name and version.
+func matchProfilePaths(name string, locked, profilePaths []string) []string {
+	if len(locked) > 0 {
+		return lo.Filter(locked, func(p string, _ int) bool { return lo.Contains(profilePaths, p) })
+	}
+	return lo.Filter(profilePaths, func(p string, _ int) bool { return storePathMatchesName(p, name) })
+}
+
+// StorePathFor returns the store path in the project's Nix profile of the
+// package in devbox.json with the given name, such as "go" or "go@1.22", so that
+// scripts can refer to its files. If the package has more than one output
+// installed, it returns the first one's path.
+//
+// It returns an error wrapping searcher.ErrNotFound if devbox.json doesn't have
+// the package, and one wrapping nix.ErrPackageNotInstalled if it does but the
+// package isn't installed, such as before devbox install or on a platform that
+// the package is excluded from.
+func (d *Devbox) StorePathFor(ctx context.Context, name string) (string, error) {
+	defer trace.StartRegion(ctx, "devboxStorePathFor").End()
+
+	pkg, err := d.findPackageByName(name)
+	if err != nil {
+		return "", err
+	}
+	installed, err := d.ListInstalled(ctx)
+	if err != nil {
+		return "", err
+	}
+	for _, p := range installed {
+		if p.Name == pkg.Raw && len(p.StorePaths) > 0 {
+			return p.StorePaths[0], nil
+		}
+	}
+	return "", usererr.WithUserMessage(
+		nix.ErrPackageNotInstalled,
+		"Package %s is in devbox.json but isn't installed in the Nix profile. Run devbox install to install it.",
+		pkg.Raw,
+	)
+}
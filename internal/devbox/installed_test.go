@@ -0,0 +1,70 @@
+package devbox
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.jetpack.io/devbox/internal/lock"
+	"go.jetpack.io/devbox/internal/nix"
+	"go.jetpack.io/devbox/internal/searcher"
+)
+
+func TestMatchProfilePaths(t *testing.T) {
+	goPath := "/nix/store/0a2xcqgwdgwhsy2b5idgxlfbjxgyv0x6-go-1.21.6"
+	jqBin := "/nix/store/1b2xcqgwdgwhsy2b5idgxlfbjxgyv0x6-jq-1.7.1-bin"
+	jqMan := "/nix/store/2c2xcqgwdgwhsy2b5idgxlfbjxgyv0x6-jq-1.7.1-man"
+	profile := []string{goPath, jqBin}
+
+	// Locked store paths are matched exactly.
+	require.Equal(t, []string{jqBin}, matchProfilePaths("jq@latest", []string{jqBin, jqMan}, profile))
+	require.Empty(t, matchProfilePaths("go@1.21", []string{"/nix/store/3d2xcqgwdgwhsy2b5idgxlfbjxgyv0x6-go-1.21.5"}, profile))
+
+	// Without locked store paths, they're matched by name.
+	require.Equal(t, []string{goPath}, matchProfilePaths("go@1.21", nil, profile))
+	require.Empty(t, matchProfilePaths("hello", nil, profile))
+}
+
+func TestStorePathForMissingPackage(t *testing.T) {
+	d := devboxForTesting(t)
+	d.cfg.PackageMutator().Add("hello@2.12")
+	ctx := context.Background()
+
+	_, err := d.StorePathFor(ctx, "go")
+	require.ErrorIs(t, err, searcher.ErrNotFound)
+
+	// hello is in devbox.json, but nothing is installed.
+	_, err = d.StorePathFor(ctx, "hello")
+	require.ErrorIs(t, err, nix.ErrPackageNotInstalled)
+}
+
+func TestListInstalledRunXPending(t *testing.T) {
+	d := devboxForTesting(t)
+	raw := "runx:golangci/golangci-lint@v1.59.1"
+	d.cfg.PackageMutator().Add(raw)
+	d.lockfile.Packages[raw] = &lock.Package{Resolved: "golangci/golangci-lint@v1.59.1", Version: "v1.59.1"}
+	ctx := context.Background()
+
+	// Locked, but runx hasn't installed it.
+	installed, err := d.ListInstalled(ctx)
+	require.NoError(t, err)
+	require.Len(t, installed, 1)
+	require.True(t, installed[0].Pending)
+
+	installDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Dir(d.runxInstallsPath()), 0o755))
+	require.NoError(t, d.saveRunXInstalls(map[string][]string{
+		"golangci/golangci-lint@v1.59.1": {installDir},
+	}))
+	installed, err = d.ListInstalled(ctx)
+	require.NoError(t, err)
+	require.False(t, installed[0].Pending)
+
+	// The install directory was deleted, such as by clearing the cache.
+	require.NoError(t, os.RemoveAll(installDir))
+	installed, err = d.ListInstalled(ctx)
+	require.NoError(t, err)
+	require.True(t, installed[0].Pending)
+}
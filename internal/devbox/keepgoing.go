@@ -0,0 +1,103 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	stderrors "errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+	"go.jetpack.io/devbox/internal/devpkg"
+	"go.jetpack.io/devbox/internal/lock"
+)
+
+// packageFailures records the packages that failed to install when installing
+// with KeepGoing. The failed packages are left out of the environment and keep
+// their previous lockfile entries, so that the rest of the packages are
+// installed as usual.
+type packageFailures struct {
+	// errs maps the raw name of each failed package to its install error.
+	errs map[string]error
+	// locked is the lockfile's packages from before the install, which the
+	// failed packages' entries are restored from.
+	locked map[string]*lock.Package
+}
+
+func newPackageFailures(lockfile *lock.File) *packageFailures {
+	return &packageFailures{
+		errs:   map[string]error{},
+		locked: maps.Clone(lockfile.Packages),
+	}
+}
+
+// add records that pkg failed to install. Only the first error for a package
+// is kept.
+func (f *packageFailures) add(pkg *devpkg.Package, err error) {
+	if _, ok := f.errs[pkg.Raw]; !ok {
+		f.errs[pkg.Raw] = err
+	}
+}
+
+// has reports whether pkg failed to install. It's safe to call on a nil
+// packageFailures.
+func (f *packageFailures) has(pkg *devpkg.Package) bool {
+	if f == nil {
+		return false
+	}
+	_, ok := f.errs[pkg.Raw]
+	return ok
+}
+
+// any reports whether any package failed to install. It's safe to call on a
+// nil packageFailures.
+func (f *packageFailures) any() bool {
+	return f != nil && len(f.errs) > 0
+}
+
+// restoreLockfile undoes any changes to the lockfile entries of the failed
+// packages, such as ones made when they were resolved.
+func (f *packageFailures) restoreLockfile(lockfile *lock.File) {
+	for name := range f.errs {
+		if entry, ok := f.locked[name]; ok {
+			lockfile.Packages[name] = entry
+		} else {
+			delete(lockfile.Packages, name)
+		}
+	}
+}
+
+// err returns an error that summarizes the failed packages, or nil if every
+// package was installed.
+func (f *packageFailures) err() error {
+	if !f.any() {
+		return nil
+	}
+	names := make([]string, 0, len(f.errs))
+	for name := range f.errs {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	errs := make([]error, 0, len(names))
+	for _, name := range names {
+		errs = append(errs, fmt.Errorf("%s: %w", name, f.errs[name]))
+	}
+	return usererr.WithUserMessage(
+		stderrors.Join(errs...),
+		"Failed to install %d package(s): %s. The other packages were installed.",
+		len(names), strings.Join(names, ", "),
+	)
+}
+
+// keepGoingOnError records err as pkg's install error and returns nil when
+// installing with KeepGoing. Otherwise, it returns err.
+func (d *Devbox) keepGoingOnError(pkg *devpkg.Package, err error) error {
+	if err == nil || d.failures == nil {
+		return err
+	}
+	d.failures.add(pkg, err)
+	return nil
+}
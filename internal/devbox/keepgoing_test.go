@@ -0,0 +1,43 @@
+package devbox
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.jetpack.io/devbox/internal/devpkg"
+	"go.jetpack.io/devbox/internal/lock"
+)
+
+func TestPackageFailures(t *testing.T) {
+	hello := &lock.Package{Resolved: "github:NixOS/nixpkgs/abc#hello"}
+	lockfile := &lock.File{Packages: map[string]*lock.Package{"hello@latest": hello}}
+	failures := newPackageFailures(lockfile)
+	require.False(t, failures.any())
+	require.NoError(t, failures.err())
+
+	// Resolving during the install changes and adds entries.
+	lockfile.Packages["hello@latest"] = &lock.Package{Resolved: "github:NixOS/nixpkgs/def#hello"}
+	lockfile.Packages["broken@1.0"] = &lock.Package{Resolved: "github:NixOS/nixpkgs/def#broken"}
+	lockfile.Packages["jq@1.7"] = &lock.Package{Resolved: "github:NixOS/nixpkgs/def#jq"}
+	jq := lockfile.Packages["jq@1.7"]
+
+	failures.add(devpkg.PackageFromStringWithDefaults("hello@latest", lockfile), errors.New("build failed"))
+	failures.add(devpkg.PackageFromStringWithDefaults("broken@1.0", lockfile), errors.New("not found"))
+	failures.add(devpkg.PackageFromStringWithDefaults("broken@1.0", lockfile), errors.New("ignored"))
+	require.True(t, failures.has(devpkg.PackageFromStringWithDefaults("broken@1.0", lockfile)))
+	require.False(t, failures.has(devpkg.PackageFromStringWithDefaults("jq@1.7", lockfile)))
+
+	// Only the failed packages' entries are restored.
+	failures.restoreLockfile(lockfile)
+	require.Equal(t, map[string]*lock.Package{"hello@latest": hello, "jq@1.7": jq}, lockfile.Packages)
+
+	err := failures.err()
+	require.ErrorContains(t, err, "Failed to install 2 package(s): broken@1.0, hello@latest.")
+	require.ErrorContains(t, err, "broken@1.0: not found")
+	require.NotContains(t, err.Error(), "ignored")
+
+	var nilFailures *packageFailures
+	require.False(t, nilFailures.any())
+	require.NoError(t, nilFailures.err())
+}
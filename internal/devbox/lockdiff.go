@@ -0,0 +1,164 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"fmt"
+	"io/fs"
+	"maps"
+	"slices"
+
+	"github.com/pkg/errors"
+	"github.com/samber/lo"
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+	"go.jetpack.io/devbox/internal/lock"
+	"go.jetpack.io/devbox/nix/flake"
+)
+
+// LockChangeKind is how a package's lockfile entry changed between two
+// lockfiles.
+type LockChangeKind string
+
+const (
+	LockPackageAdded   LockChangeKind = "added"
+	LockPackageRemoved LockChangeKind = "removed"
+	LockVersionChanged LockChangeKind = "version-changed"
+	// LockHashChanged is a package locked to the same version but a
+	// different reference or store paths, such as after a nixpkgs update
+	// that rebuilt it with new dependencies.
+	LockHashChanged LockChangeKind = "hash-changed"
+)
+
+// LockChange is a package whose entry differs between two lockfiles.
+type LockChange struct {
+	// Package is the lockfile key, such as "go@latest".
+	Package string         `json:"package"`
+	Kind    LockChangeKind `json:"kind"`
+	// OldVersion and NewVersion are the locked versions. Only NewVersion is
+	// set for an added package, and only OldVersion for a removed one.
+	OldVersion string `json:"old_version,omitempty"`
+	NewVersion string `json:"new_version,omitempty"`
+	// OldNixpkgs and NewNixpkgs are the nixpkgs commits that the package is
+	// resolved to. They're only set if they differ.
+	OldNixpkgs string `json:"old_nixpkgs,omitempty"`
+	NewNixpkgs string `json:"new_nixpkgs,omitempty"`
+}
+
+func (c LockChange) String() string {
+	var s string
+	switch c.Kind {
+	case LockPackageAdded:
+		s = fmt.Sprintf("+ %s %s", c.Package, c.NewVersion)
+	case LockPackageRemoved:
+		s = fmt.Sprintf("- %s %s", c.Package, c.OldVersion)
+	case LockVersionChanged:
+		s = fmt.Sprintf("~ %s: %s -> %s", c.Package, c.OldVersion, c.NewVersion)
+	default:
+		s = fmt.Sprintf("~ %s: %s (rebuilt)", c.Package, c.NewVersion)
+	}
+	if c.OldNixpkgs != "" || c.NewNixpkgs != "" {
+		s += fmt.Sprintf(" (nixpkgs %s -> %s)", shortCommit(c.OldNixpkgs), shortCommit(c.NewNixpkgs))
+	}
+	return s
+}
+
+// DiffLockfiles returns the packages that were added, removed or locked
+// differently in the lockfile at newPath compared to the one at oldPath,
+// sorted by package. It's meant for reviewing dependency changes, such as in
+// a pull request, and doesn't read or change this project's lockfile.
+func (d *Devbox) DiffLockfiles(oldPath, newPath string) ([]LockChange, error) {
+	oldPkgs, err := readLockfileForDiff(oldPath)
+	if err != nil {
+		return nil, err
+	}
+	newPkgs, err := readLockfileForDiff(newPath)
+	if err != nil {
+		return nil, err
+	}
+	return diffLockPackages(oldPkgs, newPkgs), nil
+}
+
+func readLockfileForDiff(path string) (map[string]*lock.Package, error) {
+	pkgs, err := lock.ReadPackages(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, usererr.New("Lockfile %s does not exist.", path)
+	}
+	return pkgs, errors.Wrapf(err, "reading lockfile %s", path)
+}
+
+func diffLockPackages(oldPkgs, newPkgs map[string]*lock.Package) []LockChange {
+	keys := lo.Union(lo.Keys(oldPkgs), lo.Keys(newPkgs))
+	slices.Sort(keys)
+
+	changes := []LockChange{}
+	for _, key := range keys {
+		oldPkg, newPkg := oldPkgs[key], newPkgs[key]
+		change := LockChange{Package: key}
+		switch {
+		case oldPkg == nil:
+			change.Kind = LockPackageAdded
+			change.NewVersion = newPkg.Version
+		case newPkg == nil:
+			change.Kind = LockPackageRemoved
+			change.OldVersion = oldPkg.Version
+		case oldPkg.Version != newPkg.Version:
+			change.Kind = LockVersionChanged
+			change.OldVersion, change.NewVersion = oldPkg.Version, newPkg.Version
+		case lockedArtifactsDiffer(oldPkg, newPkg):
+			change.Kind = LockHashChanged
+			change.OldVersion, change.NewVersion = oldPkg.Version, newPkg.Version
+		default:
+			continue
+		}
+		if oldPkg != nil && newPkg != nil {
+			oldCommit, newCommit := resolvedNixpkgsCommit(oldPkg), resolvedNixpkgsCommit(newPkg)
+			if oldCommit != newCommit {
+				change.OldNixpkgs, change.NewNixpkgs = oldCommit, newCommit
+			}
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+// lockedArtifactsDiffer reports whether two entries with the same version
+// install different things.
+func lockedArtifactsDiffer(a, b *lock.Package) bool {
+	if a.Resolved != b.Resolved || a.Sha256 != b.Sha256 {
+		return true
+	}
+	systems := func(p *lock.Package) map[string][]string {
+		paths := map[string][]string{}
+		for system, info := range p.Systems {
+			if info == nil {
+				continue
+			}
+			for _, out := range info.Outputs {
+				paths[system] = append(paths[system], out.Path)
+			}
+			if info.StorePath != "" {
+				paths[system] = append(paths[system], info.StorePath)
+			}
+		}
+		return paths
+	}
+	return !maps.EqualFunc(systems(a), systems(b), slices.Equal)
+}
+
+// resolvedNixpkgsCommit returns the nixpkgs commit that pkg is resolved to,
+// or "" if it isn't resolved to nixpkgs.
+func resolvedNixpkgsCommit(pkg *lock.Package) string {
+	installable, err := flake.ParseInstallable(pkg.Resolved)
+	if err != nil || installable.Ref.Owner != "NixOS" || installable.Ref.Repo != "nixpkgs" {
+		return ""
+	}
+	return installable.Ref.Rev
+}
+
+func shortCommit(commit string) string {
+	if commit == "" {
+		return "none"
+	}
+	return commit[:min(len(commit), 7)]
+}
@@ -0,0 +1,50 @@
+package devbox
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffLockfiles(t *testing.T) {
+	dir := t.TempDir()
+	oldPath := filepath.Join(dir, "old.lock")
+	newPath := filepath.Join(dir, "new.lock")
+	require.NoError(t, os.WriteFile(oldPath, []byte(`{
+  "lockfile_version": "1",
+  "packages": {
+    "go@latest": {"resolved": "github:NixOS/nixpkgs/aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa#go", "version": "1.22.3"},
+    "hello@latest": {"resolved": "github:NixOS/nixpkgs/aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa#hello", "version": "2.12.1"},
+    "ripgrep@14": {"resolved": "github:NixOS/nixpkgs/aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa#ripgrep", "version": "14.1.0"},
+    "fd@latest": {"resolved": "github:NixOS/nixpkgs/aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa#fd", "version": "9.0.0"}
+  }
+}`), 0o644))
+	require.NoError(t, os.WriteFile(newPath, []byte(`{
+  "lockfile_version": "1",
+  "packages": {
+    "go@latest": {"resolved": "github:NixOS/nixpkgs/bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb#go", "version": "1.22.4"},
+    "jq@latest": {"resolved": "github:NixOS/nixpkgs/bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb#jq", "version": "1.7.1"},
+    "ripgrep@14": {"resolved": "github:NixOS/nixpkgs/bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb#ripgrep", "version": "14.1.0"},
+    "fd@latest": {"resolved": "github:NixOS/nixpkgs/aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa#fd", "version": "9.0.0"}
+  }
+}`), 0o644))
+
+	d := devboxForTesting(t)
+	changes, err := d.DiffLockfiles(oldPath, newPath)
+	require.NoError(t, err)
+
+	aaa := "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+	bbb := "bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"
+	require.Equal(t, []LockChange{
+		{Package: "go@latest", Kind: LockVersionChanged, OldVersion: "1.22.3", NewVersion: "1.22.4", OldNixpkgs: aaa, NewNixpkgs: bbb},
+		{Package: "hello@latest", Kind: LockPackageRemoved, OldVersion: "2.12.1"},
+		{Package: "jq@latest", Kind: LockPackageAdded, NewVersion: "1.7.1"},
+		{Package: "ripgrep@14", Kind: LockHashChanged, OldVersion: "14.1.0", NewVersion: "14.1.0", OldNixpkgs: aaa, NewNixpkgs: bbb},
+	}, changes)
+	require.Equal(t, "~ go@latest: 1.22.3 -> 1.22.4 (nixpkgs aaaaaaa -> bbbbbbb)", changes[0].String())
+
+	_, err = d.DiffLockfiles(filepath.Join(dir, "missing.lock"), newPath)
+	require.Error(t, err)
+}
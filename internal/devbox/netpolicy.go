@@ -0,0 +1,104 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"cmp"
+	"context"
+	"log/slog"
+	"net/url"
+	"slices"
+
+	"go.jetpack.io/devbox/internal/devbox/devopt"
+	"go.jetpack.io/devbox/internal/devpkg"
+	"go.jetpack.io/devbox/internal/netpolicy"
+	"go.jetpack.io/devbox/internal/nix"
+	"go.jetpack.io/devbox/internal/searcher"
+	"go.jetpack.io/devbox/nix/flake"
+)
+
+// defaultSubstituter is the substituter that Nix uses when its configuration
+// can't be read.
+const defaultSubstituter = "https://cache.nixos.org"
+
+// flakeRegistryHost is where Nix downloads the global flake registry from to
+// resolve indirect flake references, such as nixpkgs.
+const flakeRegistryHost = "https://channels.nixos.org"
+
+// networkRules returns the network policy in opts, or the one in the
+// DEVBOX_NETWORK_POLICY environment variable. The strict policy allows the
+// search endpoint, the substituters in the Nix configuration, and the extra
+// hosts in opts and DEVBOX_NETWORK_ALLOWED_HOSTS.
+func networkRules(ctx context.Context, opts *devopt.Opts) (*netpolicy.Rules, error) {
+	policy := netpolicy.FromEnv()
+	if opts.NetworkPolicy != "" {
+		var err error
+		if policy, err = netpolicy.Parse(opts.NetworkPolicy); err != nil {
+			return nil, err
+		}
+	}
+	if policy != netpolicy.Strict {
+		return netpolicy.NewRules(policy), nil
+	}
+
+	hosts := slices.Concat(
+		[]string{searcher.Host()},
+		opts.AllowedHosts,
+		netpolicy.AllowedHostsFromEnv(),
+	)
+	cfg, err := nix.CurrentConfig(ctx)
+	if err != nil {
+		slog.Debug("unable to read nix substituters, allowing the default", "err", err)
+		hosts = append(hosts, defaultSubstituter)
+	} else {
+		hosts = append(hosts, cfg.Substituters.Value...)
+	}
+	return netpolicy.NewRules(netpolicy.Strict, hosts...), nil
+}
+
+// checkBuildNetworkPolicy returns an error if building pkgs would fetch a
+// flake from a host that the network policy doesn't allow. nix build makes its
+// own requests, so they can't go through netpolicy.Client. The store paths
+// that nix build and nix profile install download come from the substituters
+// in the Nix configuration, which the strict policy allows.
+func (d *Devbox) checkBuildNetworkPolicy(pkgs []*devpkg.Package) error {
+	if !netpolicy.IsStrict() {
+		return nil
+	}
+	for _, pkg := range pkgs {
+		if pkg.IsDevboxPackage {
+			if err := nix.CheckNixpkgsNetworkPolicy(pkg.HashFromNixPkgsURL()); err != nil {
+				return err
+			}
+			continue
+		}
+		installable, err := pkg.FlakeInstallable()
+		if err != nil {
+			continue // reported when the flake is built
+		}
+		if host := flakeRefHost(installable.Ref); host != "" {
+			if err := netpolicy.Check(host); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// flakeRefHost returns the URL of the host that Nix fetches ref from, or an
+// empty string if it's local.
+func flakeRefHost(ref flake.Ref) string {
+	switch ref.Type {
+	case flake.TypeGitHub:
+		return "https://" + cmp.Or(ref.Host, "github.com")
+	case flake.TypeIndirect:
+		return flakeRegistryHost
+	case flake.TypePath:
+		return ""
+	}
+	if u, err := url.Parse(ref.URL); err != nil || u.Host == "" {
+		return "" // a local file or repository
+	}
+	return ref.URL
+}
@@ -0,0 +1,25 @@
+package devbox
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.jetpack.io/devbox/nix/flake"
+)
+
+func TestFlakeRefHost(t *testing.T) {
+	cases := map[string]string{
+		"github:numtide/flake-utils":                "https://github.com",
+		"github:acme/tools?host=github.example.com": "https://github.example.com",
+		"nixpkgs":                                       flakeRegistryHost,
+		"path:./my-flake":                               "",
+		"git+file:///home/user/flake":                   "",
+		"https://example.com/flake.tar.gz":              "https://example.com/flake.tar.gz",
+		"git+https://git.example.com/tools.git?ref=dev": "https://git.example.com/tools.git",
+	}
+	for raw, want := range cases {
+		ref, err := flake.ParseRef(raw)
+		require.NoError(t, err, raw)
+		require.Equal(t, want, flakeRefHost(ref), raw)
+	}
+}
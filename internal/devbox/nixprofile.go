@@ -4,45 +4,148 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"log/slog"
+	"path/filepath"
+	"slices"
 	"strings"
 
 	"github.com/samber/lo"
 	"go.jetpack.io/devbox/internal/debug"
+	"go.jetpack.io/devbox/internal/devpkg"
+	"go.jetpack.io/devbox/internal/lock"
 	"go.jetpack.io/devbox/internal/nix"
 	"go.jetpack.io/devbox/internal/nix/nixprofile"
 )
 
+// parseBuildInputs splits the buildInputs variable of the environment into
+// store paths.
+func parseBuildInputs(buildInputs string) []string {
+	if buildInputs == "" {
+		// env["buildInputs"] can be empty string if there are no packages in the project
+		// if buildInputs is empty, then we don't want an array with a single "" entry
+		return []string{}
+	}
+	return strings.Split(buildInputs, " ")
+}
+
+// ProfileDiff is how the project's Nix profile changed when it was synced with
+// the environment. See Devbox.LastProfileDiff.
+type ProfileDiff struct {
+	// Added are the store paths that were installed in the profile.
+	Added []string `json:"added"`
+	// Removed are the store paths that were removed from the profile.
+	Removed []string `json:"removed"`
+}
+
+// IsEmpty reports if the profile didn't change.
+func (p *ProfileDiff) IsEmpty() bool {
+	return len(p.Added) == 0 && len(p.Removed) == 0
+}
+
+// WriteText writes the diff for humans, one store path per line.
+func (p *ProfileDiff) WriteText(w io.Writer) {
+	if p.IsEmpty() {
+		fmt.Fprintln(w, "Nix profile unchanged")
+		return
+	}
+	fmt.Fprintln(w, "Nix profile changes:")
+	for _, path := range p.Added {
+		fmt.Fprintf(w, "  + %s\n", path)
+	}
+	for _, path := range p.Removed {
+		fmt.Fprintf(w, "  - %s\n", path)
+	}
+}
+
+// LastProfileDiff returns the changes that the last install or package
+// operation made to the project's Nix profile, so that automation can check
+// that the expected packages were installed or removed. It returns nil if the
+// profile hasn't been synced since the project was opened.
+func (d *Devbox) LastProfileDiff() *ProfileDiff {
+	return d.lastProfileDiff
+}
+
 // syncNixProfileFromFlake ensures the nix profile has the packages from the buildInputs
 // from the devshell of the generated flake.
 //
 // It also removes any packages from the nix profile that are no longer in the buildInputs.
+// With group_profiles, the packages of each group are synced to the group's
+// own profile instead. See profileStorePaths.
 func (d *Devbox) syncNixProfileFromFlake(ctx context.Context) error {
 	defer debug.FunctionTimer().End()
 	// Get the buildInputs from the generated flake
+	done := d.startStep(ProgressStepComputeEnv)
 	env, err := d.execPrintDevEnv(ctx, false /*usePrintDevEnvCache*/)
 	if err != nil {
 		return err
 	}
-	buildInputs := env["buildInputs"]
+	// With group_profiles, refresh the cached environment of the shell
+	// without grouped packages too. See computeEnv.
+	if d.cfg.Root.GroupProfiles {
+		if _, err := d.execPrintDevEnvForShell(ctx, ungroupedShell, false /*usePrintDevEnvCache*/); err != nil {
+			return err
+		}
+	}
+	done()
 
+	done = d.startStep(ProgressStepSyncProfile)
 	// Get the store-paths of the packages we want installed in the nix profile
-	wantStorePaths := []string{}
-	if buildInputs != "" {
-		// env["buildInputs"] can be empty string if there are no packages in the project
-		// if buildInputs is empty, then we don't want wantStorePaths to be an array with a single "" entry
-		wantStorePaths = strings.Split(buildInputs, " ")
+	wantStorePaths := parseBuildInputs(env["buildInputs"])
+	wantByProfile, err := d.profileStorePaths(wantStorePaths)
+	if err != nil {
+		return err
 	}
-
-	profilePath, err := d.profilePath()
+	// Profiles of groups that no longer have packages in them, or that were
+	// made before group_profiles was turned off, are emptied.
+	existing, err := d.existingGroupProfiles()
 	if err != nil {
 		return err
 	}
+	for _, group := range existing {
+		if _, ok := wantByProfile[group]; !ok {
+			wantByProfile[group] = nil
+		}
+	}
+
+	diff := &ProfileDiff{Added: []string{}, Removed: []string{}}
+	groups := lo.Keys(wantByProfile)
+	slices.Sort(groups)
+	for _, group := range groups {
+		profilePath, err := d.profilePath(group)
+		if err != nil {
+			return err
+		}
+		add, remove, err := d.syncNixProfile(ctx, profilePath, wantByProfile[group])
+		if err != nil {
+			return err
+		}
+		diff.Added = append(diff.Added, add...)
+		diff.Removed = append(diff.Removed, remove...)
+	}
 
+	slices.Sort(diff.Added)
+	slices.Sort(diff.Removed)
+	d.lastProfileDiff = diff
+	if d.verbose {
+		diff.WriteText(d.stderr)
+	}
+	done()
+	return nil
+}
+
+// syncNixProfile installs and removes packages in the nix profile at
+// profilePath so that it has the wantStorePaths. It returns the store paths
+// that were added and removed.
+func (d *Devbox) syncNixProfile(
+	ctx context.Context,
+	profilePath string,
+	wantStorePaths []string,
+) (add, remove []string, err error) {
 	// Get the store-paths of the packages currently installed in the nix profile
-	items, err := nixprofile.ProfileListItems(d.stderr, profilePath)
+	items, err := d.profileListItems(profilePath)
 	if err != nil {
-		return fmt.Errorf("nix profile list: %v", err)
+		return nil, nil, fmt.Errorf("nix profile list: %v", err)
 	}
 	gotStorePaths := make([]string, 0, len(items))
 	for _, item := range items {
@@ -50,23 +153,34 @@ func (d *Devbox) syncNixProfileFromFlake(ctx context.Context) error {
 	}
 
 	// Diff the store paths and install/remove packages as needed
-	remove, add := lo.Difference(gotStorePaths, wantStorePaths)
+	remove, add = lo.Difference(gotStorePaths, wantStorePaths)
+	if len(remove) > 0 || len(add) > 0 {
+		d.profileItems = nil
+	}
+	// A partial install leaves out packages that may already be installed,
+	// so only remove the packages that are no longer in the config.
+	if d.isPartialInstall() {
+		if remove, err = d.removedPackageStorePaths(remove); err != nil {
+			return nil, nil, err
+		}
+	}
 	if len(remove) > 0 {
 		packagesToRemove := make([]string, 0, len(remove))
 		for _, p := range remove {
 			storePath := nix.NewStorePathParts(p)
 			packagesToRemove = append(packagesToRemove, fmt.Sprintf("%s@%s", storePath.Name, storePath.Version))
 		}
-		slog.Debug("removing packages from nix profile", "pkgs", strings.Join(packagesToRemove, ", "))
+		slog.Debug("removing packages from nix profile", "profile", profilePath, "pkgs", strings.Join(packagesToRemove, ", "))
 
-		if err := nix.ProfileRemove(profilePath, remove...); err != nil {
-			return err
+		if err := nix.ProfileRemove(d.storeRoot, profilePath, remove...); err != nil {
+			return nil, nil, err
 		}
 	}
 	if len(add) > 0 {
 		if err = nix.ProfileInstall(ctx, &nix.ProfileInstallArgs{
 			Installables: add,
 			ProfilePath:  profilePath,
+			Store:        d.storeRoot,
 			Writer:       d.stderr,
 		}); errors.Is(err, nix.ErrPriorityConflict) {
 			// We need to install the packages one by one because there was possibly a priority conflict
@@ -75,14 +189,138 @@ func (d *Devbox) syncNixProfileFromFlake(ctx context.Context) error {
 				if err = nix.ProfileInstall(ctx, &nix.ProfileInstallArgs{
 					Installables: []string{addPath},
 					ProfilePath:  profilePath,
+					Store:        d.storeRoot,
 					Writer:       d.stderr,
 				}); err != nil {
-					return fmt.Errorf("error installing package in nix profile %s: %w", addPath, err)
+					return nil, nil, fmt.Errorf("error installing package in nix profile %s: %w", addPath, err)
 				}
 			}
 		} else if err != nil {
-			return fmt.Errorf("error installing packages in nix profile %s: %w", add, err)
+			return nil, nil, fmt.Errorf("error installing packages in nix profile %s: %w", add, err)
 		}
 	}
-	return nil
+	return slices.Clone(add), slices.Clone(remove), nil
+}
+
+// removeOrphansFromProfile removes the nix profile entries whose store paths
+// look like they belong to one of names, which are packages that are no longer
+// in devbox.json. A name matches a store path if it has the same package name
+// and, when the name has a version (e.g. "go@1.21"), the version starts with
+// it. Entries with store paths that are locked for a package still in the
+// config are never removed.
+//
+// This is best-effort: store paths don't always use the package's attribute
+// name, so some orphans may not be found. It returns the store paths that were
+// removed.
+func (d *Devbox) removeOrphansFromProfile(names []string) ([]string, error) {
+	profilePath, err := d.profilePath("")
+	if err != nil {
+		return nil, err
+	}
+	items, err := d.profileListItems(profilePath)
+	if err != nil {
+		return nil, fmt.Errorf("nix profile list: %v", err)
+	}
+
+	locked := map[string]bool{}
+	for _, pkg := range d.InstallablePackages() {
+		storePaths, err := pkg.GetResolvedStorePaths()
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range storePaths {
+			locked[p] = true
+		}
+	}
+
+	remove := []string{}
+	for _, item := range items {
+		storePaths := item.StorePaths()
+		if len(storePaths) == 0 || lo.SomeBy(storePaths, func(p string) bool { return locked[p] }) {
+			continue
+		}
+		if lo.SomeBy(names, func(name string) bool { return storePathMatchesName(storePaths[0], name) }) {
+			remove = append(remove, storePaths...)
+		}
+	}
+	if len(remove) == 0 {
+		return nil, nil
+	}
+	d.profileItems = nil
+	if err := nix.ProfileRemove(d.storeRoot, profilePath, remove...); err != nil {
+		return nil, err
+	}
+	return remove, nil
+}
+
+// removedPackageStorePaths returns the store paths in paths that belong to
+// packages in devbox.lock that are no longer in the config. Store paths that are
+// locked for a package still in the config are never returned.
+func (d *Devbox) removedPackageStorePaths(paths []string) ([]string, error) {
+	configured := map[string]bool{}
+	locked := map[string]bool{}
+	for _, pkg := range d.AllPackages() {
+		configured[pkg.Raw] = true
+		for _, p := range lockedSystemStorePaths(d.lockfile.Get(pkg.Raw)) {
+			locked[p] = true
+		}
+	}
+	candidates := lo.Filter(paths, func(p string, _ int) bool { return !locked[p] })
+
+	removed := []string{}
+	for name, entry := range d.lockfile.Packages {
+		if configured[name] {
+			continue
+		}
+		// Flakes can't be matched to their store paths by name, so they're
+		// left for a full install to remove.
+		pkg := devpkg.PackageFromStringWithDefaults(name, d.lockfile)
+		if !pkg.IsDevboxPackage {
+			continue
+		}
+		removed = append(removed,
+			matchProfilePaths(pkg.Versioned(), lockedSystemStorePaths(entry), candidates)...)
+	}
+	return lo.Uniq(removed), nil
+}
+
+// lockedSystemStorePaths returns the store paths of all of entry's outputs on
+// the current system.
+func lockedSystemStorePaths(entry *lock.Package) []string {
+	if entry == nil || entry.Systems[nix.System()] == nil {
+		return nil
+	}
+	return lo.Map(entry.Systems[nix.System()].Outputs, func(o lock.Output, _ int) string { return o.Path })
+}
+
+func storePathMatchesName(storePath, name string) bool {
+	base := filepath.Base(storePath)
+	if len(base) < 34 {
+		return false
+	}
+	parts := nix.NewStorePathParts(base)
+	pkgName, version, _ := strings.Cut(name, "@")
+	if parts.Name != pkgName {
+		return false
+	}
+	return version == "" || version == "latest" || strings.HasPrefix(parts.Version, version)
+}
+
+// profileListItems returns the items in the nix profile at profilePath. The
+// items of the project's default profile are cached until the profile is
+// changed, so that a command that syncs the profile several times (such as an
+// add followed by a remove) only lists it once. Group profiles aren't cached.
+func (d *Devbox) profileListItems(profilePath string) ([]*nixprofile.NixProfileListItem, error) {
+	if profilePath != filepath.Join(d.projectDir, nix.ProfilePath) {
+		return nixprofile.ProfileListItems(d.stderr, d.storeRoot, profilePath)
+	}
+	if d.profileItems != nil {
+		return d.profileItems, nil
+	}
+	items, err := nixprofile.ProfileListItems(d.stderr, d.storeRoot, profilePath)
+	if err != nil {
+		return nil, err
+	}
+	d.profileItems = items
+	return items, nil
 }
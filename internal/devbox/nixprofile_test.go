@@ -0,0 +1,115 @@
+package devbox
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.jetpack.io/devbox/internal/lock"
+	"go.jetpack.io/devbox/internal/nix"
+)
+
+func lockedTo(storePath string) *lock.Package {
+	return &lock.Package{
+		Resolved: "github:NixOS/nixpkgs/abc#pkg",
+		Systems: map[string]*lock.SystemInfo{
+			nix.System(): {Outputs: []lock.Output{{Name: "out", Path: storePath, Default: true}}},
+		},
+	}
+}
+
+func TestRemovedPackageStorePaths(t *testing.T) {
+	d := devboxForTesting(t)
+	d.cfg.PackageMutator().Add("go@1.22")
+	d.cfg.PackageMutator().Add("nodejs@20")
+
+	goPath := "/nix/store/00000000000000000000000000000000-go-1.22.5"
+	nodePath := "/nix/store/11111111111111111111111111111111-nodejs-20.11.0"
+	jqPath := "/nix/store/22222222222222222222222222222222-jq-1.7.1"
+	ripgrepPath := "/nix/store/33333333333333333333333333333333-ripgrep-14.1.0"
+	d.lockfile.Packages["go@1.22"] = lockedTo(goPath)
+	// jq was removed from devbox.json, but is still in devbox.lock.
+	d.lockfile.Packages["jq@1.7"] = lockedTo(jqPath)
+	// ripgrep was removed too, and has no locked store paths.
+	d.lockfile.Packages["ripgrep@14"] = &lock.Package{Resolved: "github:NixOS/nixpkgs/abc#ripgrep"}
+
+	// A subset install of nodejs wants only nodePath, so the others are
+	// candidates for removal. Only the removed packages' paths are removed.
+	removed, err := d.removedPackageStorePaths([]string{goPath, jqPath, ripgrepPath})
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{jqPath, ripgrepPath}, removed)
+
+	removed, err = d.removedPackageStorePaths([]string{goPath, nodePath})
+	require.NoError(t, err)
+	require.Empty(t, removed)
+}
+
+func TestProfileListItemsCache(t *testing.T) {
+	// A fake nix that logs each time a profile is listed.
+	calls := filepath.Join(t.TempDir(), "calls")
+	nix.FakeForTest(t, `case "$*" in
+  *"profile list"*)
+    echo "$*" >> `+calls+`
+    echo '{"elements": {"hello": {"storePaths": ["/nix/store/aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa-hello-2.12"]}}, "version": 3}';;
+  *--version*) echo "nix (Nix) 2.24.0";;
+  *"profile remove"*) ;;
+  *) exit 1;;
+esac
+`)
+	listCalls := func() int {
+		data, err := os.ReadFile(calls)
+		if errors.Is(err, fs.ErrNotExist) {
+			return 0
+		}
+		require.NoError(t, err)
+		return strings.Count(string(data), "\n")
+	}
+
+	d := devboxForTesting(t)
+	profilePath := filepath.Join(d.projectDir, nix.ProfilePath)
+	for range 2 {
+		items, err := d.profileListItems(profilePath)
+		require.NoError(t, err)
+		require.Len(t, items, 1)
+	}
+	require.Equal(t, 1, listCalls(), "the project's profile should only be listed once")
+
+	// Group profiles aren't cached.
+	groupProfile := filepath.Join(d.projectDir, ".devbox", "nix", "profile", "build")
+	for range 2 {
+		_, err := d.profileListItems(groupProfile)
+		require.NoError(t, err)
+	}
+	require.Equal(t, 3, listCalls())
+
+	// Syncing the profile only clears the cache if it changes the profile.
+	hello := "/nix/store/aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa-hello-2.12"
+	_, _, err := d.syncNixProfile(context.Background(), profilePath, []string{hello})
+	require.NoError(t, err)
+	_, err = d.profileListItems(profilePath)
+	require.NoError(t, err)
+	require.Equal(t, 3, listCalls())
+	_, removed, err := d.syncNixProfile(context.Background(), profilePath, nil)
+	require.NoError(t, err)
+	require.Equal(t, []string{hello}, removed)
+	_, err = d.profileListItems(profilePath)
+	require.NoError(t, err)
+	require.Equal(t, 4, listCalls())
+
+	// Resetting a pre-flakes profile clears the cache.
+	legacy := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(legacy, "manifest.nix"), nil, 0o644))
+	require.NoError(t, os.MkdirAll(filepath.Dir(profilePath), 0o755))
+	require.NoError(t, os.Symlink(legacy, profilePath))
+	_, err = d.profilePath("")
+	require.NoError(t, err)
+	require.NoFileExists(t, profilePath)
+	_, err = d.profileListItems(profilePath)
+	require.NoError(t, err)
+	require.Equal(t, 5, listCalls())
+}
@@ -0,0 +1,99 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"context"
+	"fmt"
+	"runtime/trace"
+
+	"github.com/pkg/errors"
+	"golang.org/x/mod/semver"
+
+	"go.jetpack.io/devbox/internal/nix"
+	"go.jetpack.io/devbox/internal/searcher"
+)
+
+// OutdatedPackage is a package in devbox.json that has a newer version in the
+// search index than the version it's locked to.
+type OutdatedPackage struct {
+	// Package is the package's versioned name in devbox.json.
+	Package string `json:"package"`
+	// Current is the locked version of the package.
+	Current string `json:"current"`
+	// Latest is the newest version in the search index.
+	Latest string `json:"latest"`
+	// Pinned is true if devbox.json pins the package to a version other
+	// than "latest", so Update won't install the newer version. Use
+	// Upgrade to change the pinned version.
+	Pinned bool `json:"pinned"`
+}
+
+func (p OutdatedPackage) String() string {
+	s := fmt.Sprintf("%s: %s -> %s", p.Package, p.Current, p.Latest)
+	if p.Pinned {
+		s += " (pinned)"
+	}
+	return s
+}
+
+// Outdated returns the packages in devbox.json whose locked version is older
+// than the newest version in the search index. Flakes, local flakes, runx
+// packages and legacy (unversioned) packages don't have versions in the search
+// index to compare with, so they're skipped, and so are packages that aren't
+// in the search index. It doesn't change devbox.json or devbox.lock.
+func (d *Devbox) Outdated(ctx context.Context) ([]OutdatedPackage, error) {
+	defer trace.StartRegion(ctx, "devboxOutdated").End()
+
+	outdated := []OutdatedPackage{}
+	for _, pkg := range d.TopLevelPackages() {
+		if !pkg.IsDevboxPackage || pkg.IsRunX() {
+			continue
+		}
+		name, version, isVersioned := searcher.ParseVersionedPackage(pkg.Raw)
+		if !isVersioned {
+			continue
+		}
+
+		current := version
+		if locked := d.lockfile.Get(pkg.Raw); locked != nil && locked.Version != "" {
+			current = locked.Version
+		}
+		if current == "latest" {
+			// Not locked yet, so installing it gets the newest version.
+			continue
+		}
+
+		latest, err := d.lockfile.FetchResolvedPackage(name + "@latest")
+		if errors.Is(err, nix.ErrPackageNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if latest == nil || !isNewerVersion(latest.Version, current) {
+			continue
+		}
+		outdated = append(outdated, OutdatedPackage{
+			Package: pkg.Raw,
+			Current: current,
+			Latest:  latest.Version,
+			Pinned:  version != "latest",
+		})
+	}
+	return outdated, nil
+}
+
+// isNewerVersion reports whether latest is a newer version than current.
+// Versions that aren't semantic versions can't be ordered, so any version
+// other than current is considered newer.
+func isNewerVersion(latest, current string) bool {
+	if latest == "" || latest == current {
+		return false
+	}
+	if semver.IsValid("v"+latest) && semver.IsValid("v"+current) {
+		return semver.Compare("v"+latest, "v"+current) > 0
+	}
+	return true
+}
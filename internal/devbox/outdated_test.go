@@ -0,0 +1,37 @@
+package devbox
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsNewerVersion(t *testing.T) {
+	testCases := []struct {
+		latest, current string
+		want            bool
+	}{
+		{"1.22.5", "1.21.0", true},
+		{"1.21.0", "1.21.0", false},
+		{"1.21.0", "1.22.5", false},
+		{"2024-01-01", "2023-06-01", true},
+		{"", "1.0.0", false},
+	}
+	for _, tc := range testCases {
+		require.Equal(t, tc.want, isNewerVersion(tc.latest, tc.current), "isNewerVersion(%q, %q)", tc.latest, tc.current)
+	}
+}
+
+func TestOutdatedSkipsUncomparablePackages(t *testing.T) {
+	d := devboxForTesting(t)
+	for _, pkg := range []string{"github:nixos/nixpkgs#hello", "path:./mypkg", "runx:golangci/golangci-lint@latest", "go@latest"} {
+		d.cfg.PackageMutator().Add(pkg)
+	}
+
+	// None of the packages need the search service: flakes and runx
+	// packages are skipped, and go@latest isn't locked yet.
+	outdated, err := d.Outdated(context.Background())
+	require.NoError(t, err)
+	require.Empty(t, outdated)
+}
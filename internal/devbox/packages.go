@@ -4,34 +4,52 @@
 package devbox
 
 import (
+	"bufio"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	stderrors "errors"
 	"fmt"
 	"io"
 	"io/fs"
 	"log/slog"
+	"maps"
+	"net"
 	"os"
+	"path"
 	"path/filepath"
+	"regexp"
+	"runtime"
 	"runtime/trace"
 	"slices"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
+	"unicode"
 
+	"github.com/mattn/go-isatty"
 	"github.com/pkg/errors"
 	"github.com/samber/lo"
+	"go.jetpack.io/devbox/internal/conf"
 	"go.jetpack.io/devbox/internal/devbox/devopt"
 	"go.jetpack.io/devbox/internal/devbox/providers/nixcache"
 	"go.jetpack.io/devbox/internal/devconfig"
 	"go.jetpack.io/devbox/internal/devconfig/configfile"
 	"go.jetpack.io/devbox/internal/devpkg"
 	"go.jetpack.io/devbox/internal/devpkg/pkgtype"
+	"go.jetpack.io/devbox/internal/envir"
 	"go.jetpack.io/devbox/internal/lock"
+	"go.jetpack.io/devbox/internal/searcher"
 	"go.jetpack.io/devbox/internal/setup"
 	"go.jetpack.io/devbox/internal/shellgen"
 	"go.jetpack.io/devbox/internal/telemetry"
 	"go.jetpack.io/pkg/auth"
+	"golang.org/x/sync/errgroup"
 
 	"go.jetpack.io/devbox/internal/boxcli/usererr"
 	"go.jetpack.io/devbox/internal/debug"
+	"go.jetpack.io/devbox/internal/netpolicy"
 	"go.jetpack.io/devbox/internal/nix"
 	"go.jetpack.io/devbox/internal/plugin"
 	"go.jetpack.io/devbox/internal/ux"
@@ -42,90 +60,997 @@ const StateOutOfDateMessage = "Your devbox environment may be out of date. Run %
 // packages.go has functions for adding, removing and getting info about nix
 // packages
 
+// AddResult describes what Devbox.AddWithResult did to devbox.json. In a
+// dry run it describes what would have been done.
+type AddResult struct {
+	// Added are the names written to devbox.json, which may be versioned
+	// forms of the requested names.
+	Added []string
+	// Replaced are the names of existing packages that were removed
+	// because a package with the same canonical name was added.
+	Replaced []string
+	// Unchanged are the requested packages that were already in devbox.json.
+	// With AddOpts.IfMissing, they're the existing packages that had the
+	// same canonical name as a requested package.
+	Unchanged []string
+	// Updated are the requested packages that were already in devbox.json,
+	// but whose options (such as platforms) were changed by the add.
+	Updated []string
+	// FellBackToLegacy are the added packages that couldn't be found in
+	// the search index and were added as legacy (unversioned) nixpkgs
+	// packages instead. They're also included in Added.
+	FellBackToLegacy []string
+	// Kept are the existing packages that were kept, instead of being
+	// replaced, because of AddOpts.ConflictResolution. The requested
+	// packages they conflicted with weren't added.
+	Kept []string
+	// Unverified are the added packages that couldn't be checked in an
+	// offline add. They're also included in Added.
+	Unverified []string
+
+	// packages are the requested packages, used for the post-add message.
+	packages []*devpkg.Package
+	// replaced are the packages in Replaced, and replacedAudit their audit
+	// log entries, recorded before they were removed from the lockfile.
+	replaced      []*devpkg.Package
+	replacedAudit []auditEntry
+}
+
 // Add adds the `pkgs` to the config (i.e. devbox.json) and nix profile for this
 // devbox project
 func (d *Devbox) Add(ctx context.Context, pkgsNames []string, opts devopt.AddOpts) error {
+	opts.SkipInstall = opts.SkipInstall || opts.DeferValidation
+	result, err := d.AddWithResult(ctx, pkgsNames, opts)
+	if err != nil || opts.DryRun {
+		return err
+	}
+	// The packages are already saved to devbox.json, so a failing hook
+	// doesn't undo the add. Its error is returned after the usual messages.
+	var hookErr error
+	if !opts.SkipInstall && len(result.Unverified) == 0 {
+		hookErr = d.runAfterAddHook(ctx, result)
+	}
+	msg, err := d.postAddMessage(ctx, result, opts)
+	if err != nil {
+		return err
+	}
+	if opts.JSONOutput != nil {
+		if err := msg.WriteJSON(opts.JSONOutput); err != nil {
+			return err
+		}
+	} else {
+		msg.WriteText(d.stderr)
+	}
+	return hookErr
+}
+
+// runAfterAddHook runs the shell.after_add hook from devbox.json, if any, in
+// the Devbox environment. The added and replaced packages are passed in the
+// DEVBOX_ADDED_PACKAGES and DEVBOX_REPLACED_PACKAGES variables.
+func (d *Devbox) runAfterAddHook(ctx context.Context, result AddResult) error {
+	hook := d.cfg.Root.AfterAddHook().String()
+	if strings.TrimSpace(hook) == "" || len(result.Added)+len(result.Replaced) == 0 {
+		return nil
+	}
+	env, err := d.computeEnv(ctx, true /*usePrintDevEnvCache*/, devopt.EnvOptions{})
+	if err != nil {
+		return err
+	}
+	env["DEVBOX_ADDED_PACKAGES"] = strings.Join(result.Added, " ")
+	env["DEVBOX_REPLACED_PACKAGES"] = strings.Join(result.Replaced, " ")
+
+	ux.Finfo(d.stderr, "Running after_add hook\n")
+	if err := nix.RunScript(d.projectDir, hook, env); err != nil {
+		return usererr.WithUserMessage(
+			err, "The packages were added to devbox.json, but the after_add hook failed.")
+	}
+	return nil
+}
+
+// shouldReplaceConflict returns true if the existing package should be
+// replaced by the added package with the same canonical name.
+func shouldReplaceConflict(opts devopt.AddOpts, existing, added string) (bool, error) {
+	switch opts.ConflictResolution {
+	case devopt.ConflictKeep:
+		return false, nil
+	case devopt.ConflictPrompt:
+		if opts.ConflictPrompter == nil {
+			return false, errors.New("ConflictPrompt requires a ConflictPrompter")
+		}
+		return opts.ConflictPrompter.ConfirmReplace(existing, added)
+	default:
+		return true, nil
+	}
+}
+
+// AddWithResult is like Add, but returns a summary of the changes instead of
+// printing plugin readmes and the list of unchanged packages.
+//
+// If it fails, devbox.json and devbox.lock are restored to how they were
+// before, even if packages were already replaced or resolved. The git commit
+// for devopt.AddOpts.GitCommit is made after that point, so a failed commit
+// doesn't undo the added packages. The audit log is only written once both
+// have succeeded.
+func (d *Devbox) AddWithResult(ctx context.Context, pkgsNames []string, opts devopt.AddOpts) (AddResult, error) {
 	ctx, task := trace.NewTask(ctx, "devboxAdd")
 	defer task.End()
 
-	// Track which packages had no changes so we can report that to the user.
-	unchangedPackageNames := []string{}
+	if opts.DryRun {
+		return d.addWithResult(ctx, pkgsNames, opts)
+	}
+	var result AddResult
+	auditEntries, err := d.collectAuditLog(func() error {
+		return d.withConfigRollback(func() error {
+			var err error
+			result, err = d.addWithResult(ctx, pkgsNames, opts)
+			return err
+		})
+	})
+	if err != nil {
+		return result, err
+	}
+	if opts.GitCommit {
+		changed := d.lockedVersionNames(slices.Concat(result.Added, result.Updated))
+		if err := d.commitConfigChanges(ctx, opts.CommitMessage, "add", changed); err != nil {
+			return result, err
+		}
+	}
+	// Packages that were only added to devbox.json weren't installed.
+	mode := install
+	if opts.SkipInstall || opts.DeferValidation || len(result.Unverified) > 0 {
+		mode = ""
+	}
+	d.writeAuditLog(append(auditEntries, d.addAuditEntries(result, mode)...))
+	return result, nil
+}
+
+func (d *Devbox) addWithResult(ctx context.Context, pkgsNames []string, opts devopt.AddOpts) (AddResult, error) {
+	pkgsNames, opts, err := d.prepareAdd(ctx, pkgsNames, opts)
+	if err != nil {
+		return AddResult{}, err
+	}
+	if len(opts.Alternatives) > 0 {
+		return d.addAlternatives(ctx, pkgsNames, opts)
+	}
+
+	// Validate the platforms before changing anything so that a typo doesn't
+	// leave devbox.json half updated.
+	if opts.Platforms, err = nix.NormalizePlatforms(opts.Platforms); err != nil {
+		return AddResult{}, err
+	}
+	if opts.ExcludePlatforms, err = nix.NormalizePlatforms(opts.ExcludePlatforms); err != nil {
+		return AddResult{}, err
+	}
 
 	// Only add packages that are not already in config. If same canonical exists,
 	// replace it.
-	pkgs := devpkg.PackagesFromStringsWithOptions(lo.Uniq(pkgsNames), d.lockfile, opts)
+	pkgsNames = lo.Uniq(pkgsNames)
+	pkgs := devpkg.PackagesFromStringsWithOptions(d.preferSources(ctx, pkgsNames, opts), d.lockfile, opts)
+	result := AddResult{packages: pkgs}
 
 	// addedPackageNames keeps track of the possibly transformed (versioned)
 	// names of added packages (even if they are already in config). We use this
 	// to know the exact name to mark as allowed insecure later on.
 	addedPackageNames := []string{}
+	// selectedOutputs are the outputs selected with a ^output suffix, keyed
+	// by the package's name in the config.
+	selectedOutputs := map[string][]string{}
 	existingPackageNames := lo.Map(
 		d.cfg.Root.TopLevelPackages(), func(p configfile.Package, _ int) string {
 			return p.VersionedName()
 		})
-	for _, pkg := range pkgs {
+	for i, pkg := range pkgs {
+		rawName, outputs := devpkg.SplitOutputs(pkgsNames[i])
+		pkgOpts := opts
+		if pkg.IsDevboxPackage {
+			pkgOpts.Outputs = lo.Uniq(slices.Concat(opts.Outputs, outputs))
+		}
+
 		// If exact versioned package is already in the config, we can skip the
 		// next loop that only deals with newPackages.
 		if slices.Contains(existingPackageNames, pkg.Versioned()) {
-			// But we still need to add to addedPackageNames. See its comment.
-			addedPackageNames = append(addedPackageNames, pkg.Versioned())
-			unchangedPackageNames = append(unchangedPackageNames, pkg.Versioned())
-			ux.Finfo(d.stderr, "Package %q already in devbox.json\n", pkg.Versioned())
+			if d.addExistingPackage(pkg, pkgOpts, &result) {
+				// But we still need to add to addedPackageNames. See its comment.
+				addedPackageNames = append(addedPackageNames, pkg.Versioned())
+				selectedOutputs[pkg.Versioned()] = pkgOpts.Outputs
+			}
 			continue
 		}
 
-		// On the other hand, if there's a package with same canonical name, replace
-		// it. Ignore error (which is either missing or more than one). We search by
-		// CanonicalName so any legacy or versioned packages will be removed if they
-		// match.
-		found, _ := d.findPackageByName(pkg.CanonicalName())
-		if found != nil {
-			ux.Finfo(d.stderr, "Replacing package %q in devbox.json\n", found.Raw)
-			if err := d.Remove(ctx, found.Raw); err != nil {
-				return err
-			}
+		replaced, skip, err := d.findPackageToReplace(pkg, rawName, opts, &result)
+		if err != nil {
+			return result, err
+		} else if skip {
+			continue
 		}
 
-		// validate that the versioned package exists in the search endpoint.
-		// if not, fallback to legacy vanilla nix.
-		versionedPkg := devpkg.PackageFromStringWithOptions(pkg.Versioned(), d.lockfile, opts)
+		packageNameForConfig, err := d.validatePackageToAdd(ctx, pkg, pkgOpts, &result)
+		if err != nil {
+			return result, err
+		}
 
-		packageNameForConfig := pkg.Raw
-		ok, err := versionedPkg.ValidateExists(ctx)
-		if (err == nil && ok) || errors.Is(err, devpkg.ErrCannotBuildPackageOnSystem) {
-			// Only use versioned if it exists in search. We can disregard the error
-			// about not building on the current system, since user's can continue
-			// via --exclude-platform flag.
-			packageNameForConfig = pkg.Versioned()
-		} else if !versionedPkg.IsDevboxPackage {
-			// This means it didn't validate and we don't want to fallback to legacy
-			// Just propagate the error.
-			return err
-		} else if _, err := nix.Search(d.lockfile.LegacyNixpkgsPath(pkg.Raw)); err != nil {
-			// This means it looked like a devbox package or attribute path, but we
-			// could not find it in search or in the legacy nixpkgs path.
-			return usererr.New("Package %s not found", pkg.Raw)
+		if replaced != nil && opts.DryRun {
+			ux.Finfo(d.stderr, "Would replace package %q in devbox.json\n", replaced.Raw)
+		} else if replaced != nil {
+			ux.Finfo(d.stderr, "Replacing package %q in devbox.json\n", replaced.Raw)
+			d.replacePackage(replaced, &result)
 		}
 
+		d.warnIfProvidedByPlugin(pkg)
+
+		addedPackageNames = append(addedPackageNames, packageNameForConfig)
+		selectedOutputs[packageNameForConfig] = pkgOpts.Outputs
+		result.Added = append(result.Added, packageNameForConfig)
+		if opts.DryRun {
+			ux.Finfo(d.stderr, "Would add package %q to devbox.json\n", packageNameForConfig)
+			continue
+		}
 		ux.Finfo(d.stderr, "Adding package %q to devbox.json\n", packageNameForConfig)
 		d.cfg.PackageMutator().Add(packageNameForConfig)
-		addedPackageNames = append(addedPackageNames, packageNameForConfig)
 	}
 
+	// In a dry run we stop once every package has been validated, before
+	// anything is written to devbox.json or installed.
+	if opts.DryRun {
+		return result, nil
+	}
+
+	// Names like "go" and "go@latest" refer to the same package in
+	// devbox.json, so only set its options once.
+	addedPackageNames = dedupeByCanonicalName(addedPackageNames, d.lockfile)
+
 	// Options must be set before ensureStateIsUpToDate. See comment in function
-	if err := d.setPackageOptions(addedPackageNames, opts); err != nil {
-		return err
+	for _, name := range addedPackageNames {
+		pkgOpts := opts
+		pkgOpts.Outputs = selectedOutputs[name]
+		if err := d.setPackageOptions([]string{name}, pkgOpts); err != nil {
+			return result, err
+		}
 	}
 
+	// Replaced packages share plugin files with the packages that replaced
+	// them, unless they were pinned to a different canonical name.
+	if err := d.removeUnusedPluginFiles(lo.Map(result.replaced, func(p *devpkg.Package, _ int) string {
+		return p.CanonicalName()
+	})); err != nil {
+		return result, err
+	}
+
+	if opts.SkipInstall || len(result.Unverified) > 0 {
+		return result, d.saveAddWithoutInstall(result, opts)
+	}
+
+	d.warnIfLargeInstall(ctx, lo.Filter(d.InstallablePackages(), func(p *devpkg.Package, _ int) bool {
+		return slices.Contains(addedPackageNames, p.Raw)
+	}))
+
+	// This also removes the replaced packages from the nix profile and the
+	// lockfile.
 	if err := d.ensureStateIsUpToDate(ctx, install); err != nil {
-		return usererr.WithUserMessage(err, "There was an error installing nix packages")
+		return result, usererr.WithUserMessage(err, "There was an error installing nix packages")
+	}
+
+	return result, d.saveCfg()
+}
+
+// prepareAdd checks the add options and returns the names of the packages to
+// add, including those read from stdin and expanded from aliases.
+func (d *Devbox) prepareAdd(ctx context.Context, pkgsNames []string, opts devopt.AddOpts) ([]string, devopt.AddOpts, error) {
+	if opts.DeferValidation && opts.DryRun {
+		return nil, opts, usererr.New("Can't defer validation in a dry run, since a dry run only validates the packages.")
+	}
+	opts.SkipInstall = opts.SkipInstall || opts.DeferValidation
+	if opts.ReadFromStdin {
+		stdinNames, err := d.readPackagesFromStdin()
+		if err != nil {
+			return nil, opts, err
+		}
+		pkgsNames = slices.Concat(pkgsNames, stdinNames)
+		if len(pkgsNames) == 0 {
+			return nil, opts, usererr.New("No packages to add: stdin didn't have any package names.")
+		}
+	}
+	pkgsNames = d.expandAliases(ctx, pkgsNames, opts)
+
+	if opts.NixpkgsCommit != "" {
+		if err := validateNixpkgsCommitPin(pkgsNames, opts.NixpkgsCommit); err != nil {
+			return nil, opts, err
+		}
+	}
+
+	if len(opts.SourcePreference) == 0 {
+		opts.SourcePreference = d.cfg.Root.SourcePreference
+	}
+	return pkgsNames, opts, nil
+}
+
+// addExistingPackage handles a requested package whose exact versioned name is
+// already in devbox.json, updating its options if they changed. It returns
+// false if the package is left as it is because of AddOpts.IfMissing.
+func (d *Devbox) addExistingPackage(pkg *devpkg.Package, opts devopt.AddOpts, result *AddResult) bool {
+	if opts.IfMissing {
+		result.Unchanged = append(result.Unchanged, pkg.Versioned())
+		return false
+	}
+	switch {
+	case !d.addChangesOptions(pkg.Versioned(), opts):
+		result.Unchanged = append(result.Unchanged, pkg.Versioned())
+		ux.Finfo(d.stderr, "Package %q already in devbox.json\n", pkg.Versioned())
+	case opts.DryRun:
+		result.Updated = append(result.Updated, pkg.Versioned())
+		ux.Finfo(d.stderr, "Would update the options of package %q in devbox.json\n", pkg.Versioned())
+	default:
+		result.Updated = append(result.Updated, pkg.Versioned())
+		ux.Finfo(d.stderr, "Updating the options of package %q in devbox.json\n", pkg.Versioned())
+	}
+	return true
+}
+
+// findPackageToReplace returns the package in devbox.json with the same
+// canonical name as pkg, if adding pkg should replace it. skip is true if pkg
+// shouldn't be added at all, because the existing package is kept.
+func (d *Devbox) findPackageToReplace(
+	pkg *devpkg.Package, rawName string, opts devopt.AddOpts, result *AddResult,
+) (replaced *devpkg.Package, skip bool, err error) {
+	// Ignore error (which is either missing or more than one). We search by
+	// CanonicalName so any legacy or versioned packages will be replaced if
+	// they match.
+	canonicalName := pkg.CanonicalName()
+	if opts.NixpkgsCommit != "" {
+		// Pinned packages are flakes, so look up the unpinned name
+		// to replace the floating version of the package.
+		canonicalName, _, _ = strings.Cut(rawName, "@")
 	}
+	found, _ := d.findPackageByName(canonicalName)
+	if found == nil {
+		return nil, false, nil
+	}
+	if opts.IfMissing {
+		result.Unchanged = append(result.Unchanged, found.Raw)
+		return nil, true, nil
+	}
+	replace, err := shouldReplaceConflict(opts, found.Raw, pkg.Versioned())
+	if err != nil {
+		return nil, false, err
+	}
+	if !replace {
+		result.Kept = append(result.Kept, found.Raw)
+		ux.Finfo(d.stderr, "Keeping package %q in devbox.json\n", found.Raw)
+		return nil, true, nil
+	}
+	result.Replaced = append(result.Replaced, found.Raw)
+	return found, false, nil
+}
+
+// replacePackage removes a package that's being replaced from devbox.json.
+// The nix profile and lockfile are updated with the rest of the add, so a
+// failed add doesn't leave the project without either package.
+func (d *Devbox) replacePackage(pkg *devpkg.Package, result *AddResult) {
+	result.replaced = append(result.replaced, pkg)
+	if d.auditLogEnabled() {
+		// The mode is set once the add knows how the state was updated.
+		result.replacedAudit = append(result.replacedAudit, d.newAuditEntry(auditStatusRemoved, pkg, ""))
+	}
+	d.cfg.PackageMutator().Remove(pkg.Raw)
+}
+
+// validatePackageToAdd checks that pkg exists and returns the name to add it
+// to devbox.json with.
+func (d *Devbox) validatePackageToAdd(
+	ctx context.Context, pkg *devpkg.Package, opts devopt.AddOpts, result *AddResult,
+) (string, error) {
+	switch {
+	case opts.DeferValidation:
+		// Validated when the package is resolved at install time.
+		return pkg.Versioned(), nil
+	case opts.Offline:
+		if !d.validateExistsOffline(pkg) {
+			result.Unverified = append(result.Unverified, pkg.Raw)
+		}
+		return pkg.Raw, nil
+	case !pkg.IsDevboxPackage:
+		// Flake references aren't in the search endpoint, so validate them
+		// by evaluating the flake instead. They're added to the config as-is.
+		return pkg.Raw, pkg.ValidateFlakeEvaluates(ctx)
+	}
+
+	// validate that the versioned package exists in the search endpoint.
+	// if not, fallback to legacy vanilla nix.
+	ok, err := d.validateExistsWithTimeout(ctx, pkg.Versioned(), opts, opts.ValidateTimeout)
+	if errors.Is(err, devpkg.ErrUnknownOutput) {
+		return "", err
+	}
+	if (err == nil && ok) || errors.Is(err, devpkg.ErrCannotBuildPackageOnSystem) {
+		// Only use versioned if it exists in search. We can disregard the error
+		// about not building on the current system, since user's can continue
+		// via --exclude-platform flag.
+		return pkg.Versioned(), nil
+	}
+	timedOut := errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil
+	if timedOut {
+		d.warn(
+			WarningValidateTimeout,
+			"Timed out validating %s with the search service. Falling back to the legacy nixpkgs path.\n",
+			pkg.Versioned(),
+		)
+	}
+	versionedPkg := devpkg.PackageFromStringWithOptions(pkg.Versioned(), d.lockfile, opts)
+	if !versionedPkg.IsDevboxPackage && !timedOut {
+		// This means it didn't validate and we don't want to fallback to legacy
+		// Just propagate the error.
+		if err == nil {
+			err = usererr.New("Package %s not found", pkg.Raw)
+		}
+		return "", err
+	}
+	return d.validateLegacyPackageToAdd(pkg, opts, result)
+}
+
+// validateLegacyPackageToAdd checks that pkg, which isn't in the search index,
+// is in the project's nixpkgs and returns the name to add it with.
+func (d *Devbox) validateLegacyPackageToAdd(
+	pkg *devpkg.Package, opts devopt.AddOpts, result *AddResult,
+) (string, error) {
+	infos, err := nix.Search(d.lockfile.LegacyNixpkgsPath(pkg.CanonicalName()))
+	if err != nil {
+		// This means it looked like a devbox package or attribute path, but we
+		// could not find it in search or in the legacy nixpkgs path.
+		if suggestions := suggestPackageNames(pkg.CanonicalName()); len(suggestions) > 0 {
+			return "", usererr.New(
+				"Package %s not found. Did you mean: %s?",
+				pkg.Raw, strings.Join(suggestions, ", "),
+			)
+		}
+		return "", usererr.New("Package %s not found", pkg.Raw)
+	}
+	if err := d.checkLegacyVersion(pkg, infos, opts.StrictVersion); err != nil {
+		return "", err
+	}
+	// The versioned name isn't in the search index, so it couldn't be
+	// resolved at install time. Add the package by its name in the project's
+	// nixpkgs instead.
+	name := pkg.Raw
+	if canonicalName := pkg.CanonicalName(); canonicalName != pkg.Raw {
+		ux.Finfo(d.stderr, "Adding %s as %q from the project's nixpkgs.\n", pkg.Raw, canonicalName)
+		name = canonicalName
+	}
+	result.FellBackToLegacy = append(result.FellBackToLegacy, name)
+	return name, nil
+}
 
+// saveAddWithoutInstall saves the added packages to devbox.json without
+// installing them, for AddOpts.SkipInstall or packages that couldn't be
+// verified offline.
+func (d *Devbox) saveAddWithoutInstall(result AddResult, opts devopt.AddOpts) error {
 	if err := d.saveCfg(); err != nil {
 		return err
 	}
+	// The profile isn't synced, so drop the replaced packages from the
+	// lockfile here.
+	if len(result.replaced) > 0 {
+		if err := d.lockfile.Remove(lo.Map(result.replaced, func(p *devpkg.Package, _ int) string {
+			return p.Raw
+		})...); err != nil {
+			return err
+		}
+	}
+	if !opts.SkipInstall {
+		d.warn(
+			WarningUnverified,
+			"Could not verify %s offline, so they were only added to devbox.json.\n",
+			strings.Join(result.Unverified, ", "),
+		)
+	}
+	// Mark the state as stale so the packages get installed the next time
+	// the environment is used.
+	if err := lock.InvalidateStateHashFile(d.projectDir); err != nil {
+		return errors.WithStack(err)
+	}
+	ux.Finfo(d.stderr, "Skipped installing packages. Run `devbox install` to install them.\n")
+	return nil
+}
 
-	return d.printPostAddMessage(ctx, pkgs, unchangedPackageNames, opts)
+// addAlternatives adds a package whose alternatives install a different package
+// on each platform. See AddOpts.Alternatives.
+func (d *Devbox) addAlternatives(ctx context.Context, pkgsNames []string, opts devopt.AddOpts) (AddResult, error) {
+	result := AddResult{}
+	if len(pkgsNames) != 1 {
+		return result, usererr.New(
+			"Add exactly one package with platform alternatives, such as `devbox add cc --on linux=gcc --on darwin=clang`.")
+	}
+	name := pkgsNames[0]
+
+	alternatives := map[string]string{}
+	for selector, raw := range opts.Alternatives {
+		if _, err := nix.PlatformsForSelector(selector); err != nil {
+			return result, err
+		}
+		pkg := devpkg.PackageFromStringWithOptions(raw, d.lockfile, opts)
+		if pkg.IsDevboxPackage && !opts.DeferValidation {
+			// The alternative may not build on this system, which is fine
+			// since it's only installed on its own platforms.
+			ok, err := devpkg.PackageFromStringWithOptions(pkg.Versioned(), d.lockfile, opts).ValidateExists(ctx)
+			if err != nil && !errors.Is(err, devpkg.ErrCannotBuildPackageOnSystem) {
+				return result, err
+			} else if err == nil && !ok {
+				return result, usererr.New("Package %s not found", raw)
+			}
+		}
+		alternatives[selector] = pkg.Versioned()
+	}
+
+	selectors := lo.Keys(alternatives)
+	slices.Sort(selectors)
+	described := lo.Map(selectors, func(s string, _ int) string { return s + "=" + alternatives[s] })
+	result.Added = append(result.Added, name)
+	if opts.DryRun {
+		ux.Finfo(d.stderr, "Would add package %q to devbox.json with alternatives %s\n", name, strings.Join(described, ", "))
+		return result, nil
+	}
+	ux.Finfo(d.stderr, "Adding package %q to devbox.json with alternatives %s\n", name, strings.Join(described, ", "))
+	if err := d.cfg.PackageMutator().SetAlternatives(name, alternatives); err != nil {
+		return result, err
+	}
+
+	if opts.SkipInstall {
+		if err := d.saveCfg(); err != nil {
+			return result, err
+		}
+		return result, errors.WithStack(lock.InvalidateStateHashFile(d.projectDir))
+	}
+	if err := d.ensureStateIsUpToDate(ctx, install); err != nil {
+		return result, usererr.WithUserMessage(err, "There was an error installing nix packages")
+	}
+	return result, d.saveCfg()
+}
+
+// addAuditEntries describes the replaced, added and unchanged packages of an
+// add for the audit log.
+func (d *Devbox) addAuditEntries(result AddResult, mode installMode) []auditEntry {
+	if !d.auditLogEnabled() {
+		return nil
+	}
+	entries := []auditEntry{}
+	for _, entry := range result.replacedAudit {
+		entry.Mode = mode
+		entries = append(entries, entry)
+	}
+	for _, name := range result.Added {
+		pkg := devpkg.PackageFromStringWithDefaults(name, d.lockfile)
+		entries = append(entries, d.newAuditEntry(auditStatusAdded, pkg, mode))
+	}
+	for _, name := range result.Updated {
+		pkg := devpkg.PackageFromStringWithDefaults(name, d.lockfile)
+		entries = append(entries, d.newAuditEntry(auditStatusUpdated, pkg, mode))
+	}
+	for _, name := range result.Unchanged {
+		pkg := devpkg.PackageFromStringWithDefaults(name, d.lockfile)
+		entries = append(entries, d.newAuditEntry(auditStatusUnchanged, pkg, mode))
+	}
+	return entries
+}
+
+// largeInstallWarningSize is the estimated install size above which Add warns
+// before installing, so that users on small machines can stop before running
+// out of disk space.
+const largeInstallWarningSize = 2 << 30 // 2 GiB
+
+// warnIfLargeInstall prints a warning if installing pkgs is estimated to take
+// more than largeInstallWarningSize of disk space. The estimate is best-effort,
+// so errors are only logged. Setting DEVBOX_SUPPRESS_LARGE_INSTALL_WARNING
+// turns the warning off and skips the estimate.
+func (d *Devbox) warnIfLargeInstall(ctx context.Context, pkgs []*devpkg.Package) {
+	if suppress, _ := strconv.ParseBool(os.Getenv(envir.DevboxSuppressLargeInstallWarning)); suppress {
+		return
+	}
+	size, err := d.EstimateClosureSize(ctx, pkgs...)
+	if err != nil {
+		slog.Debug("failed to estimate install size", "err", err)
+		return
+	}
+	if size > largeInstallWarningSize {
+		d.warn(
+			WarningLargeInstall,
+			"Installing these packages will use at least %s of disk space.\n",
+			formatBytes(size),
+		)
+	}
+}
+
+// warnIfProvidedByPlugin prints a warning if a plugin already installs a
+// package with the same canonical name as pkg, since adding it to devbox.json
+// is redundant and its version may conflict with the plugin's.
+func (d *Devbox) warnIfProvidedByPlugin(pkg *devpkg.Package) {
+	plugins := []string{}
+	for _, origin := range d.cfg.PackageOrigins(pkg.CanonicalName()) {
+		if len(origin.Plugins) == 0 {
+			continue
+		}
+		// The last plugin in the chain is the one that lists the package.
+		plugins = append(plugins, pluginReason(origin.Plugins[len(origin.Plugins)-1]).Plugin)
+	}
+	if len(plugins) == 0 {
+		return
+	}
+	d.warn(
+		WarningProvidedByPlugin,
+		"Package %s is already installed by the plugin %s. Adding it to devbox.json is redundant, "+
+			"and its version may conflict with the plugin's.\n",
+		pkg.CanonicalName(),
+		strings.Join(lo.Uniq(plugins), ", "),
+	)
+}
+
+// checkLegacyVersion compares the version of a package that fell back to the
+// project's nixpkgs with the version that was requested, since the pinned
+// nixpkgs can have a very different version, such as foo@2 falling back to a
+// foo that's actually 1.x. A mismatch is a warning, or an error if strict is
+// set. Packages without a requested version always match.
+func (d *Devbox) checkLegacyVersion(pkg *devpkg.Package, infos map[string]*nix.Info, strict bool) error {
+	_, requested, _ := strings.Cut(pkg.Raw, "@")
+	if requested == "" || requested == "latest" {
+		return nil
+	}
+	for _, info := range infos {
+		if info.Version == "" || versionMatchesRequested(info.Version, requested) {
+			continue
+		}
+		if strict {
+			return usererr.New(
+				"Package %s isn't in the search index, and the project's nixpkgs has version %s instead. "+
+					"Add a version that's in the search index, or pin a nixpkgs commit with --nixpkgs-commit.",
+				pkg.Raw, info.Version,
+			)
+		}
+		d.warn(
+			WarningLegacyVersion,
+			"Package %s isn't in the search index, so Devbox fell back to the project's nixpkgs, "+
+				"which has version %s instead of %s.\n",
+			pkg.Raw, info.Version, requested,
+		)
+		return nil
+	}
+	return nil
+}
+
+// versionMatchesRequested reports whether version is one of the versions
+// that requested refers to: each dot-separated component of requested must
+// match the same component of version, so "2" and "2.1" match "2.1.3", but
+// "2.2" doesn't.
+func versionMatchesRequested(version, requested string) bool {
+	have := strings.Split(version, ".")
+	want := strings.Split(requested, ".")
+	if len(want) > len(have) {
+		return false
+	}
+	for i := range want {
+		if want[i] != have[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// validateExistsOffline checks that a Devbox package exists in the project's
+// nixpkgs commit using only the local Nix store, and locks it as resolved
+// offline. It returns false for packages that can't be checked without network
+// access, such as flakes, runx packages and packages whose nixpkgs isn't in the
+// store, and for versioned packages whose version doesn't match the one in the
+// project's nixpkgs commit.
+func (d *Devbox) validateExistsOffline(pkg *devpkg.Package) bool {
+	if !pkg.IsDevboxPackage || pkgtype.IsRunX(pkg.Raw) {
+		return false
+	}
+	infos, err := nix.SearchOffline(d.lockfile.LegacyNixpkgsPath(pkg.CanonicalName()))
+	if err != nil {
+		slog.Debug("failed to check package offline", "pkg", pkg.Raw, "err", err)
+		return false
+	}
+	_, requested, _ := strings.Cut(pkg.Raw, "@")
+	version, ok := offlineVersion(infos, requested)
+	if !ok {
+		slog.Debug("package version not in the project's nixpkgs", "pkg", pkg.Raw)
+		return false
+	}
+	d.lockfile.ResolveOffline(pkg.Raw, version)
+	return true
+}
+
+// offlineVersion returns the version of the package found by an offline
+// search, and whether it's the requested version. Any version matches an
+// unversioned or "latest" request.
+func offlineVersion(infos map[string]*nix.Info, requested string) (string, bool) {
+	for _, info := range infos {
+		if requested == "" || requested == "latest" ||
+			versionMatchesRequested(info.Version, requested) {
+			return info.Version, true
+		}
+	}
+	return "", false
+}
+
+// maxPackageSuggestions caps the names suggested for a package that wasn't
+// found.
+const maxPackageSuggestions = 5
+
+// suggestPackageNames queries the search endpoint for packages with names
+// close to name, such as "nodejs_18" for "nodejs18". It's best-effort, so a
+// failing search returns no suggestions.
+func suggestPackageNames(name string) []string {
+	if name == "" {
+		return nil
+	}
+	// Version-like suffixes rarely match as typed, so also search for the
+	// name without them ("nodejs18" -> "nodejs").
+	queries := lo.Uniq(lo.Compact([]string{name, strings.TrimRight(name, "0123456789._-")}))
+	candidates := []string{}
+	for _, query := range queries {
+		results, err := searcher.Client().Search(query)
+		if err != nil {
+			slog.Debug("failed to search for package suggestions", "query", query, "err", err)
+			continue
+		}
+		for _, p := range results.Packages {
+			candidates = append(candidates, p.Name)
+		}
+	}
+	return rankPackageSuggestions(name, candidates)
+}
+
+// rankPackageSuggestions returns up to maxPackageSuggestions of the candidates
+// that are close to name, closest first. Separators are ignored when comparing
+// so that "nodejs18" is an exact match for "nodejs_18".
+func rankPackageSuggestions(name string, candidates []string) []string {
+	normalize := func(s string) string {
+		return strings.Map(func(r rune) rune {
+			if r == '_' || r == '-' || r == '.' {
+				return -1
+			}
+			return unicode.ToLower(r)
+		}, s)
+	}
+	target := normalize(name)
+	distances := map[string]int{}
+	for _, candidate := range lo.Uniq(candidates) {
+		if candidate == name {
+			continue
+		}
+		normalized := normalize(candidate)
+		distance := editDistance(normalized, target)
+		if distance <= max(2, len(target)/3) || strings.HasPrefix(normalized, target) {
+			distances[candidate] = distance
+		}
+	}
+	suggestions := lo.Keys(distances)
+	slices.SortFunc(suggestions, func(a, b string) int {
+		if distances[a] != distances[b] {
+			return distances[a] - distances[b]
+		}
+		return strings.Compare(a, b)
+	})
+	return suggestions[:min(len(suggestions), maxPackageSuggestions)]
+}
+
+// dedupeByCanonicalName drops all but the last of the names that refer to the
+// same package, such as "go" and "go@latest". The last one wins because adding
+// a package replaces any package with the same canonical name in devbox.json.
+// Names without a canonical name (flakes) are only deduped if they're equal.
+func dedupeByCanonicalName(names []string, l lock.Locker) []string {
+	key := func(name string) string {
+		if canonical := devpkg.PackageFromStringWithDefaults(name, l).CanonicalName(); canonical != "" {
+			return canonical
+		}
+		return name
+	}
+	last := map[string]int{}
+	for i, name := range names {
+		last[key(name)] = i
+	}
+	deduped := []string{}
+	for i, name := range names {
+		if last[key(name)] == i {
+			deduped = append(deduped, name)
+		}
+	}
+	return deduped
+}
+
+var commitHashRegex = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+// validateNixpkgsCommitPin checks that the packages can be pinned to commit.
+// Only nixpkgs packages can be pinned, and their version comes from the commit.
+func validateNixpkgsCommitPin(pkgsNames []string, commit string) error {
+	if !commitHashRegex.MatchString(commit) {
+		return usererr.New("Expected --nixpkgs-commit to be a full 40 character commit hash, got %q", commit)
+	}
+	for _, name := range pkgsNames {
+		if pkgtype.IsFlake(name) || pkgtype.IsRunX(name) {
+			return usererr.New("Cannot pin %s to a nixpkgs commit because it isn't a nixpkgs package", name)
+		}
+		if _, version, _ := searcher.ParseVersionedPackage(name); version != "" && version != "latest" {
+			return usererr.New(
+				"Cannot pin %s to a nixpkgs commit because it has a version. "+
+					"The version is decided by the commit.",
+				name,
+			)
+		}
+	}
+	return nil
+}
+
+// AddFromFile adds the packages listed in a manifest file, one name@version
+// entry per line. Blank lines and lines starting with # are ignored. All
+// valid entries are added in a single Add call, so the environment is only
+// recomputed once. Invalid lines don't stop the valid entries from being added,
+// but they are all reported, with their line numbers, in the returned error.
+func (d *Devbox) AddFromFile(ctx context.Context, path string, opts devopt.AddOpts) error {
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return usererr.New("Package manifest %s does not exist.", path)
+	}
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer f.Close()
+
+	pkgs, lineErrs, err := parsePackageManifest(f)
+	if err != nil {
+		return errors.Wrapf(err, "reading package manifest %s", path)
+	}
+	if len(pkgs) > 0 {
+		if err := d.Add(ctx, pkgs, opts); err != nil {
+			return err
+		}
+	}
+	if len(lineErrs) > 0 {
+		return usererr.New(
+			"Some entries in %s could not be added:\n%s",
+			path,
+			strings.Join(lineErrs, "\n"),
+		)
+	}
+	return nil
+}
+
+// readPackagesFromStdin returns the package names piped to stdin for
+// AddOpts.ReadFromStdin, in the same format as AddFromFile. It fails instead of
+// waiting for input when stdin is a terminal.
+func (d *Devbox) readPackagesFromStdin() ([]string, error) {
+	if d.stdin == nil {
+		return nil, usererr.New("Can't read packages from stdin: no input was provided.")
+	}
+	if f, ok := d.stdin.(*os.File); ok && isatty.IsTerminal(f.Fd()) {
+		return nil, usererr.New(
+			"Can't read packages from stdin because it's a terminal. Pipe the package names " +
+				"to devbox, such as with: echo ripgrep | devbox add --stdin")
+	}
+	pkgs, lineErrs, err := parsePackageManifest(d.stdin)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading packages from stdin")
+	}
+	if len(lineErrs) > 0 {
+		return nil, usererr.New(
+			"Some entries read from stdin are invalid:\n%s",
+			strings.Join(lineErrs, "\n"),
+		)
+	}
+	return pkgs, nil
+}
+
+// AddFromLockfile adds the packages locked in another project's devbox.lock,
+// such as one attached to a bug report, pinned to their locked versions. Their
+// lockfile entries are copied too, so they install the same store paths as in
+// the other project. Plugins in the lockfile aren't added.
+//
+// Legacy packages, which don't have a version, are locked to the nixpkgs
+// commit in devbox.json. They can only be reproduced if the other lockfile
+// used the same commit as this project.
+func (d *Devbox) AddFromLockfile(ctx context.Context, lockPath string, opts devopt.AddOpts) error {
+	locked, err := lock.ReadPackages(lockPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return usererr.New("Lockfile %s does not exist.", lockPath)
+	}
+	if err != nil {
+		return usererr.WithUserMessage(err, "Error reading lockfile %s.", lockPath)
+	}
+	pkgs, err := d.lockPackagesFrom(lockPath, locked)
+	if err != nil {
+		return err
+	}
+	return d.Add(ctx, pkgs, opts)
+}
+
+// lockPackagesFrom copies the package entries of another lockfile into the
+// project's lockfile, keyed by the name that pins the locked version, and
+// returns those names.
+func (d *Devbox) lockPackagesFrom(lockPath string, locked map[string]*lock.Package) ([]string, error) {
+	keys := lo.Keys(locked)
+	slices.Sort(keys)
+
+	entries := map[string]*lock.Package{}
+	pkgs := []string{}
+	incompatible := []string{}
+	otherCommit := ""
+	for _, key := range keys {
+		entry := locked[key]
+		if entry.PluginVersion != "" {
+			continue
+		}
+		name := key
+		if lock.IsLegacyPackage(key) {
+			if commit := nix.HashFromNixPkgsURL(entry.Resolved); commit != "" && commit != d.cfg.NixPkgsCommitHash() {
+				incompatible = append(incompatible, key)
+				otherCommit = commit
+			}
+		} else if pkgName, _, versioned := searcher.ParseVersionedPackage(key); versioned && entry.Version != "" {
+			name = pkgName + "@" + entry.Version
+		}
+		entries[name] = entry
+		pkgs = append(pkgs, name)
+	}
+	if len(incompatible) > 0 {
+		return nil, usererr.New(
+			"Packages %s in %s are locked to nixpkgs commit %s, but this project uses %s. "+
+				"Set nixpkgs.commit in devbox.json to %[3]s to reproduce them.",
+			strings.Join(incompatible, ", "), lockPath, otherCommit, d.cfg.NixPkgsCommitHash(),
+		)
+	}
+	if len(pkgs) == 0 {
+		return nil, usererr.New("Lockfile %s doesn't have any packages.", lockPath)
+	}
+	maps.Copy(d.lockfile.Packages, entries)
+	return pkgs, nil
+}
+
+// parsePackageManifest returns the package entries in a manifest and a
+// description of every invalid line.
+func parsePackageManifest(r io.Reader) (pkgs, lineErrs []string, err error) {
+	scanner := bufio.NewScanner(r)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.ContainsAny(line, " \t") {
+			lineErrs = append(lineErrs, fmt.Sprintf("line %d: %q: expected a single name@version entry", lineNum, line))
+			continue
+		}
+		if strings.HasPrefix(line, "@") || strings.HasSuffix(line, "@") {
+			lineErrs = append(lineErrs, fmt.Sprintf("line %d: %q: expected name@version", lineNum, line))
+			continue
+		}
+		pkgs = append(pkgs, line)
+	}
+	return pkgs, lineErrs, errors.WithStack(scanner.Err())
+}
+
+// addChangesOptions reports whether setPackageOptions would change the
+// options of the package with versionedName in devbox.json, such as when it's
+// added again with different platforms.
+func (d *Devbox) addChangesOptions(versionedName string, opts devopt.AddOpts) bool {
+	idx := slices.IndexFunc(d.cfg.Root.TopLevelPackages(), func(p configfile.Package) bool {
+		return p.VersionedName() == versionedName
+	})
+	if idx == -1 {
+		return false
+	}
+	cfgPkg := d.cfg.Root.TopLevelPackages()[idx]
+	notIn := func(want, have []string) bool {
+		return lo.SomeBy(want, func(v string) bool { return !slices.Contains(have, v) })
+	}
+	for k, v := range opts.BuildEnv {
+		if old, ok := cfgPkg.BuildEnv[k]; !ok || old != v {
+			return true
+		}
+	}
+	return notIn(opts.Platforms, cfgPkg.Platforms) ||
+		notIn(opts.ExcludePlatforms, cfgPkg.ExcludedPlatforms) ||
+		opts.DisablePlugin != cfgPkg.DisablePlugin ||
+		opts.PatchGlibc != cfgPkg.PatchGlibc ||
+		notIn(opts.Outputs, cfgPkg.Outputs) ||
+		notIn(opts.AllowInsecure, cfgPkg.AllowInsecure) ||
+		(opts.Group != "" && !slices.Contains(cfgPkg.Groups, opts.Group)) ||
+		(opts.Reason != "" && opts.Reason != cfgPkg.Reason)
 }
 
 func (d *Devbox) setPackageOptions(pkgs []string, opts devopt.AddOpts) error {
@@ -154,76 +1079,218 @@ func (d *Devbox) setPackageOptions(pkgs []string, opts devopt.AddOpts) error {
 			d.stderr, pkg, opts.AllowInsecure); err != nil {
 			return err
 		}
+		if err := d.cfg.PackageMutator().AddGroup(
+			d.stderr, pkg, opts.Group); err != nil {
+			return err
+		}
+		if err := d.cfg.PackageMutator().SetBuildEnv(
+			d.stderr, pkg, opts.BuildEnv); err != nil {
+			return err
+		}
+		if err := d.cfg.PackageMutator().SetReason(
+			pkg, opts.Reason); err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
-func (d *Devbox) printPostAddMessage(
-	ctx context.Context,
-	pkgs []*devpkg.Package,
-	unchangedPackageNames []string,
-	opts devopt.AddOpts,
-) error {
-	for _, input := range pkgs {
-		if readme, err := plugin.Readme(
-			ctx,
-			input,
-			d.projectDir,
-			false /*markdown*/); err != nil {
+// expandRemovePatterns replaces the glob patterns in names, such as
+// "python*", with the packages in devbox.json whose canonical or versioned name
+// matches them. A pattern that doesn't match any package is an error, so that
+// a typo isn't silently a no-op. Other names are returned unchanged.
+func (d *Devbox) expandRemovePatterns(names []string) ([]string, error) {
+	expanded := []string{}
+	for _, name := range names {
+		if !strings.ContainsAny(name, "*?[") {
+			expanded = append(expanded, name)
+			continue
+		}
+		matches := []string{}
+		for _, pkg := range d.TopLevelPackages() {
+			matchesName, err := path.Match(name, pkg.CanonicalName())
+			if err != nil {
+				return nil, usererr.New("Invalid package pattern %q: %v", name, err)
+			}
+			matchesRaw, _ := path.Match(name, pkg.Raw)
+			if matchesName || matchesRaw {
+				matches = append(matches, pkg.Raw)
+			}
+		}
+		if len(matches) == 0 {
+			return nil, usererr.New("No packages in devbox.json match %q.", name)
+		}
+		ux.Finfo(d.stderr, "Packages matching %q: %s\n", name, strings.Join(matches, ", "))
+		expanded = append(expanded, matches...)
+	}
+	return expanded, nil
+}
+
+// Remove removes the `pkgs` from the config (i.e. devbox.json) and nix profile
+// for this devbox project. With opts.Force, packages that aren't in the config
+// are still removed from the nix profile if an entry with a matching store path
+// is found. This is best-effort, since store path names don't always match the
+// package name.
+//
+// Names can be glob patterns, such as "python*", to remove every matching
+// package. See expandRemovePatterns.
+func (d *Devbox) Remove(ctx context.Context, opts devopt.RemoveOpts, pkgs ...string) error {
+	ctx, task := trace.NewTask(ctx, "devboxRemove")
+	defer task.End()
+
+	pkgs, err := d.expandRemovePatterns(pkgs)
+	if err != nil {
+		return err
+	}
+
+	// Resolve all names against the config as it was before removing
+	// anything, so that passing both "go" and "go@1.21" removes it once.
+	byName := d.topLevelPackagesByName()
+	packagesToUninstall := []string{}
+	pluginNames := []string{}
+	missingPkgs := []string{}
+	orphanPkgs := []string{}
+	auditEntries := []auditEntry{}
+	for _, pkg := range lo.Uniq(pkgs) {
+		found := byName[pkg]
+		if len(found) != 1 {
+			// Ambiguous names (more than one match) are treated as missing.
+			missingPkgs = append(missingPkgs, pkg)
+			if len(found) == 0 {
+				orphanPkgs = append(orphanPkgs, pkg)
+			}
+			continue
+		}
+		if !slices.Contains(packagesToUninstall, found[0].Raw) {
+			packagesToUninstall = append(packagesToUninstall, found[0].Raw)
+			pluginNames = append(pluginNames, found[0].CanonicalName())
+			if d.auditLogEnabled() {
+				auditEntries = append(auditEntries, d.newAuditEntry(auditStatusRemoved, found[0], uninstall))
+			}
+			d.cfg.PackageMutator().Remove(found[0].Raw)
+		}
+	}
+
+	if len(missingPkgs) > 0 {
+		d.warn(
+			WarningPackageNotFound,
+			"the following packages were not found in your devbox.json: %s\n",
+			strings.Join(missingPkgs, ", "),
+		)
+	}
+
+	if opts.Force && len(orphanPkgs) > 0 {
+		purged, err := d.removeOrphansFromProfile(orphanPkgs)
+		if err != nil {
 			return err
-		} else if readme != "" {
-			fmt.Fprintf(d.stderr, "%s\n", readme)
+		}
+		if len(purged) > 0 {
+			ux.Finfo(d.stderr, "Removed orphaned nix profile entries: %s\n", strings.Join(purged, ", "))
+		} else {
+			ux.Finfo(d.stderr, "No nix profile entries found for: %s\n", strings.Join(orphanPkgs, ", "))
 		}
 	}
 
-	if len(opts.Platforms) == 0 && len(opts.ExcludePlatforms) == 0 && len(opts.Outputs) == 0 && len(opts.AllowInsecure) == 0 {
-		if len(unchangedPackageNames) == 1 {
-			ux.Finfo(d.stderr, "Package %q was already in devbox.json and was not modified\n", unchangedPackageNames[0])
-		} else if len(unchangedPackageNames) > 1 {
-			ux.Finfo(d.stderr, "Packages %s were already in devbox.json and were not modified\n",
-				strings.Join(unchangedPackageNames, ", "),
-			)
+	if err := d.removeUnusedPluginFiles(pluginNames); err != nil {
+		return err
+	}
+
+	// The lockfile entries are removed below, so get the versions for the
+	// commit message first.
+	removedNames := d.lockedVersionNames(packagesToUninstall)
+
+	// this will clean up the now-extra package from nix profile and the lockfile
+	if err := d.ensureStateIsUpToDate(ctx, uninstall); err != nil {
+		return err
+	}
+
+	if err := d.saveCfg(); err != nil {
+		return err
+	}
+	if opts.GitCommit {
+		if err := d.commitConfigChanges(ctx, opts.CommitMessage, "remove", removedNames); err != nil {
+			return err
 		}
 	}
+	d.writeAuditLog(auditEntries)
 	return nil
 }
 
-// Remove removes the `pkgs` from the config (i.e. devbox.json) and nix profile
-// for this devbox project
-func (d *Devbox) Remove(ctx context.Context, pkgs ...string) error {
-	ctx, task := trace.NewTask(ctx, "devboxRemove")
+// removeUnusedPluginFiles removes the plugin files of the packages with the
+// given canonical names. Plugin files are shared by all versions of a package,
+// so they're kept if another version is still in the config.
+func (d *Devbox) removeUnusedPluginFiles(canonicalNames []string) error {
+	for _, pkg := range d.TopLevelPackages() {
+		canonicalNames = lo.Without(canonicalNames, pkg.CanonicalName())
+	}
+	canonicalNames = lo.Compact(lo.Uniq(canonicalNames))
+	if len(canonicalNames) == 0 {
+		return nil
+	}
+
+	removedPluginFiles, err := plugin.Remove(d.projectDir, canonicalNames)
+	if len(removedPluginFiles) > 0 {
+		ux.Finfo(d.stderr, "Removed plugin files: %s\n", strings.Join(removedPluginFiles, ", "))
+	}
+	return err
+}
+
+// Disable keeps the named packages in devbox.json, along with their settings,
+// but marks them as disabled so that they're uninstalled from the project's
+// profile and skipped by later installs. Use Enable to install them again.
+func (d *Devbox) Disable(ctx context.Context, names ...string) error {
+	ctx, task := trace.NewTask(ctx, "devboxDisable")
 	defer task.End()
+	return d.setPackagesDisabled(ctx, names, true)
+}
+
+// Enable reverses Disable for the named packages and installs them.
+func (d *Devbox) Enable(ctx context.Context, names ...string) error {
+	ctx, task := trace.NewTask(ctx, "devboxEnable")
+	defer task.End()
+	return d.setPackagesDisabled(ctx, names, false)
+}
 
-	packagesToUninstall := []string{}
-	missingPkgs := []string{}
-	for _, pkg := range lo.Uniq(pkgs) {
-		found, _ := d.findPackageByName(pkg)
-		if found != nil {
-			packagesToUninstall = append(packagesToUninstall, found.Raw)
-			d.cfg.PackageMutator().Remove(found.Raw)
-		} else {
-			missingPkgs = append(missingPkgs, pkg)
+func (d *Devbox) setPackagesDisabled(ctx context.Context, names []string, disabled bool) error {
+	byName := d.topLevelPackagesByName()
+	changed := []string{}
+	for _, name := range lo.Uniq(names) {
+		found := byName[name]
+		if len(found) == 0 {
+			return usererr.New("Package %s not found in devbox.json", name)
+		}
+		if len(found) > 1 {
+			return usererr.New(
+				"Package %s matches more than one package in devbox.json. Use one of: %s",
+				name,
+				strings.Join(lo.Map(found, func(p *devpkg.Package, _ int) string { return p.Raw }), ", "),
+			)
+		}
+		if found[0].Disabled == disabled || slices.Contains(changed, found[0].Raw) {
+			continue
+		}
+		if err := d.cfg.PackageMutator().SetDisabled(found[0].Raw, disabled); err != nil {
+			return err
 		}
+		changed = append(changed, found[0].Raw)
 	}
-
-	if len(missingPkgs) > 0 {
-		ux.Fwarning(
-			d.stderr,
-			"the following packages were not found in your devbox.json: %s\n",
-			strings.Join(missingPkgs, ", "),
-		)
+	if len(changed) == 0 {
+		return nil
 	}
 
-	if err := plugin.Remove(d.projectDir, packagesToUninstall); err != nil {
-		return err
+	mode := install
+	if disabled {
+		// Disabled packages are no longer installable, so syncing the
+		// profile purges them, the same as when they're removed.
+		mode = uninstall
+		ux.Finfo(d.stderr, "Disabling packages: %s\n", strings.Join(changed, ", "))
+	} else {
+		ux.Finfo(d.stderr, "Enabling packages: %s\n", strings.Join(changed, ", "))
 	}
-
-	// this will clean up the now-extra package from nix profile and the lockfile
-	if err := d.ensureStateIsUpToDate(ctx, uninstall); err != nil {
+	if err := d.ensureStateIsUpToDate(ctx, mode); err != nil {
 		return err
 	}
-
 	return d.saveCfg()
 }
 
@@ -236,24 +1303,43 @@ const (
 	// update is both install new package version and uninstall old package version
 	update installMode = "update"
 	ensure installMode = "ensure"
+	// skipPluginGen skips creating plugin files, installing packages and
+	// generating the environment, and only syncs the lockfile. It's for config
+	// edits that provably don't affect the current platform, such as
+	// excluding another platform. See SetExcludedPlatforms.
+	skipPluginGen installMode = "skip-plugin-gen"
 )
 
 // ensureStateIsUpToDate ensures the Devbox project state is up to date.
 // Namely:
-//  1. Packages are installed, in nix-profile or runx.
-//     Extraneous packages are removed (references purged, not uninstalled).
+//  1. The state is validated against the lockfile, and the config's includes
+//     are reloaded
 //  2. Plugins are installed
-//  3. Files for devbox shellenv are generated
-//  4. The Devbox environment is re-computed, if necessary, and cached
-//  5. Lockfile is synced
+//  3. Packages are installed in the nix store, or with runx
+//  4. Files for devbox shellenv are generated
+//  5. The Devbox environment is re-computed, if necessary, and cached
+//  6. The nix-profile is synced with the environment. Extraneous packages are
+//     removed (references purged, not uninstalled).
+//  7. The packages' verify commands are run in the environment
+//  8. Lockfile is synced
+//
+// Each step is reported to the Devbox's progress reporter (see the
+// ProgressStep constants). Plugins are installed before packages because
+// packages might need the plugin directories.
 //
 // The `mode` is used for:
 // 1. Skipping certain operations that may not apply.
 // 2. User messaging to explain what operations are happening, because this function may take time to execute.
+//
+// With skipPluginGen only steps 1 and 8 run, and the state is marked as up to
+// date.
+// Callers must only use it when the state was up to date before their edit
+// and the edit doesn't change pluginGenInputs.
 func (d *Devbox) ensureStateIsUpToDate(ctx context.Context, mode installMode) error {
 	defer trace.StartRegion(ctx, "devboxEnsureStateIsUpToDate").End()
 	defer debug.FunctionTimer().End()
 
+	done := d.startStep(ProgressStepValidate)
 	upToDate, err := d.lockfile.IsUpToDateAndInstalled(isFishShell())
 	if err != nil {
 		return err
@@ -265,12 +1351,18 @@ func (d *Devbox) ensureStateIsUpToDate(ctx context.Context, mode installMode) er
 	if mode == ensure {
 		// if mode is ensure and we are up to date, then we can skip the rest
 		if upToDate {
+			done()
 			return nil
 		}
 		ux.Finfo(d.stderr, "Ensuring packages are installed.\n")
 	}
 
-	if mode != ensure {
+	if d.keepGoing {
+		d.failures = newPackageFailures(d.lockfile)
+		defer func() { d.failures = nil }()
+	}
+
+	if mode != ensure && mode != skipPluginGen {
 		// Reload includes because added/removed packages might change plugins. Cases:
 		// * New package adds built-in plugin. We wanna make sure the plugin is in config.
 		// * Remove built-in plugin that installs multiple packages (e.g. nginx). We wanna clear them
@@ -279,6 +1371,7 @@ func (d *Devbox) ensureStateIsUpToDate(ctx context.Context, mode installMode) er
 			return err
 		}
 	}
+	done()
 
 	if mode == install || mode == update || mode == ensure {
 		if err := d.installPackages(ctx, mode); err != nil {
@@ -286,7 +1379,10 @@ func (d *Devbox) ensureStateIsUpToDate(ctx context.Context, mode installMode) er
 		}
 	}
 
-	recomputeState := mode == ensure || d.IsEnvEnabled()
+	// InstallGroups and InstallSubset exist to make the selected packages
+	// usable quickly, so they sync the profile even outside a devbox shell.
+	recomputeState := mode == ensure || (d.IsEnvEnabled() && mode != skipPluginGen) ||
+		d.isSubsetInstall()
 	if recomputeState {
 		if err := d.recomputeState(ctx); err != nil {
 			return err
@@ -295,8 +1391,10 @@ func (d *Devbox) ensureStateIsUpToDate(ctx context.Context, mode installMode) er
 
 	// If we're in a devbox shell (global or project), then the environment might
 	// be out of date after the user installs something. If have direnv active
-	// it should reload automatically so we don't need to refresh.
-	if d.IsEnvEnabled() && !upToDate && !d.IsDirenvActive() {
+	// it should reload automatically so we don't need to refresh. Users can
+	// also turn the warning off. See refreshWarningSuppressed.
+	if d.IsEnvEnabled() && !upToDate && mode != skipPluginGen &&
+		!d.IsDirenvActive() && !d.refreshWarningSuppressed() {
 		ux.FHidableWarning(
 			ctx,
 			d.stderr,
@@ -305,7 +1403,23 @@ func (d *Devbox) ensureStateIsUpToDate(ctx context.Context, mode installMode) er
 		)
 	}
 
-	return d.updateLockfile(recomputeState)
+	// The state isn't marked as up to date when packages failed, so that
+	// the next run tries to install them again.
+	failed := d.failures.any()
+	if failed {
+		d.failures.restoreLockfile(d.lockfile)
+	}
+	done = d.startStep(ProgressStepLockfile)
+	// The environment that skipPluginGen leaves alone is still current, so
+	// its state hash is updated too.
+	if err := d.updateLockfile((recomputeState || mode == skipPluginGen) && !failed); err != nil {
+		return err
+	}
+	done()
+	if err := d.clearInstallCheckpoint(); err != nil {
+		return err
+	}
+	return d.failures.err()
 }
 
 // updateLockfile will ensure devbox.lock is up to date with the current state of the project.update
@@ -321,6 +1435,18 @@ func (d *Devbox) updateLockfile(recomputeState bool) error {
 		}
 	}
 
+	// Lock the alternatives for the other platforms too, so that the project
+	// resolves the same way on every machine.
+	for _, cfgPkg := range d.cfg.Root.AlternativePackages() {
+		pkg := devpkg.PackageFromStringWithDefaults(cfgPkg.VersionedName(), d.lockfile)
+		if !pkg.IsDevboxPackage {
+			continue
+		}
+		if _, err := d.lockfile.Resolve(pkg.Raw); err != nil {
+			return err
+		}
+	}
+
 	// Update plugin versions in lockfile.
 	for _, pluginConfig := range d.Config().IncludedPluginConfigs() {
 		if err := d.PluginManager().UpdateLockfileVersion(pluginConfig); err != nil {
@@ -355,8 +1481,11 @@ func (d *Devbox) updateLockfile(recomputeState bool) error {
 // - devbox.lock file
 // - the generated flake
 // - the nix-profile
+//
+// and then runs the packages' verify commands.
 func (d *Devbox) recomputeState(ctx context.Context) error {
 	defer debug.FunctionTimer().End()
+	done := d.startStep(ProgressStepGenerate)
 	if err := shellgen.GenerateForPrintEnv(ctx, d); err != nil {
 		return err
 	}
@@ -366,75 +1495,116 @@ func (d *Devbox) recomputeState(ctx context.Context) error {
 	if err := plugin.RemoveInvalidSymlinks(d.projectDir); err != nil {
 		return err
 	}
+	done()
+
+	if err := d.syncNixProfileFromFlake(ctx); err != nil {
+		return err
+	}
 
-	return d.syncNixProfileFromFlake(ctx)
+	done = d.startStep(ProgressStepVerify)
+	if err := d.verifyPackages(ctx); err != nil {
+		return err
+	}
+	done()
+	return nil
 }
 
-func (d *Devbox) profilePath() (string, error) {
+// profilePath returns the absolute path of the Nix profile of group, which is
+// the project's default profile if group is empty. It creates the profile's
+// parent directory.
+func (d *Devbox) profilePath(group string) (string, error) {
 	absPath := filepath.Join(d.projectDir, nix.ProfilePath)
+	if group != "" {
+		var err error
+		if absPath, err = d.groupProfilePath(group); err != nil {
+			return "", err
+		}
+	}
 
-	if err := resetProfileDirForFlakes(absPath); err != nil {
+	if reset, err := resetProfileDirForFlakes(absPath); err != nil {
 		slog.Error("resetProfileDirForFlakes error", "err", err)
+	} else if reset {
+		d.profileItems = nil
 	}
 
 	return absPath, errors.WithStack(os.MkdirAll(filepath.Dir(absPath), 0o755))
 }
 
-var resetCheckDone = false
+var (
+	// resetCheckDone records the profile directories that
+	// resetProfileDirForFlakes already checked. It's keyed by path so that
+	// several projects can be used from the same process.
+	resetCheckDone   = map[string]bool{}
+	resetCheckDoneMu sync.Mutex
+)
 
 // resetProfileDirForFlakes ensures the profileDir directory is cleared of old
 // state if the Flakes feature has been changed, from the previous execution of a devbox command.
-func resetProfileDirForFlakes(profileDir string) (err error) {
-	if resetCheckDone {
-		return nil
+// It returns true if the profile was removed. It's safe to call concurrently.
+func resetProfileDirForFlakes(profileDir string) (reset bool, err error) {
+	resetCheckDoneMu.Lock()
+	defer resetCheckDoneMu.Unlock()
+	if resetCheckDone[profileDir] {
+		return false, nil
 	}
 	defer func() {
 		if err == nil {
-			resetCheckDone = true
+			resetCheckDone[profileDir] = true
 		}
 	}()
 
 	dir, err := filepath.EvalSymlinks(profileDir)
 	if errors.Is(err, fs.ErrNotExist) {
-		return nil
+		return false, nil
 	}
 	if err != nil {
-		return errors.WithStack(err)
+		return false, errors.WithStack(err)
 	}
 
 	// older nix profiles have a manifest.nix file present
 	_, err = os.Stat(filepath.Join(dir, "manifest.nix"))
 	if errors.Is(err, fs.ErrNotExist) {
-		return nil
+		return false, nil
 	}
 	if err != nil {
-		return errors.WithStack(err)
+		return false, errors.WithStack(err)
 	}
 
-	return errors.WithStack(os.Remove(profileDir))
+	if err := os.Remove(profileDir); err != nil {
+		return false, errors.WithStack(err)
+	}
+	return true, nil
 }
 
 func (d *Devbox) installPackages(ctx context.Context, mode installMode) error {
 	defer debug.FunctionTimer().End()
 	// Create plugin directories first because packages might need them
+	done := d.startStep(ProgressStepPlugins)
 	for _, pluginConfig := range d.Config().IncludedPluginConfigs() {
 		if err := d.PluginManager().CreateFilesForConfig(pluginConfig); err != nil {
 			return err
 		}
 	}
+	done()
 
+	done = d.startStep(ProgressStepInstall)
 	if err := d.installNixPackagesToStore(ctx, mode); err != nil {
 		if caches, _ := nixcache.CachedReadCaches(ctx); len(caches) > 0 {
 			err = d.handleInstallFailure(ctx, mode)
 		}
+		if err != nil {
+			return err
+		}
+	}
+	if err := d.InstallRunXPackages(ctx); err != nil {
 		return err
 	}
-
-	return d.InstallRunXPackages(ctx)
+	done()
+	return nil
 }
 
 func (d *Devbox) handleInstallFailure(ctx context.Context, mode installMode) error {
-	ux.Fwarning(d.stderr, "Failed to build from cache, building from source.\n")
+	d.warn(WarningBuildFromSource, "Failed to build from cache, building from source.\n")
 	telemetry.Event(telemetry.EventNixBuildWithSubstitutersFailed, telemetry.Metadata{
 		Packages: lo.Map(
 			d.InstallablePackages(), func(p *devpkg.Package, _ int) string { return p.Raw }),
@@ -444,20 +1614,175 @@ func (d *Devbox) handleInstallFailure(ctx context.Context, mode installMode) err
 	return d.installNixPackagesToStore(ctx, mode)
 }
 
+// InstallRunXPackages installs the project's runx packages. The packages are
+// resolved one at a time, since resolving may update the lockfile, and then
+// installed concurrently. An install failure doesn't stop the other installs;
+// all failures are returned together.
+//
+// The installed files are checked against the sha256 in the lockfile. Packages
+// that don't have one yet get it recorded.
+//
+// With keepGoing, the failed packages are recorded instead of returned.
 func (d *Devbox) InstallRunXPackages(ctx context.Context) error {
-	for _, pkg := range lo.Filter(d.InstallablePackages(), devpkg.IsRunX) {
+	pkgs := []*devpkg.Package{}
+	locked := []*lock.Package{}
+	for _, pkg := range d.PackagesByType(pkgtype.RunX) {
 		lockedPkg, err := d.lockfile.Resolve(pkg.Raw)
 		if err != nil {
+			if err := d.keepGoingOnError(pkg, err); err != nil {
+				return err
+			}
+			continue
+		}
+		pkgs = append(pkgs, pkg)
+		locked = append(locked, lockedPkg)
+	}
+
+	var mu sync.Mutex
+	var errs []error
+	digests := make([]string, len(locked))
+	group := errgroup.Group{}
+	group.SetLimit(runtime.GOMAXPROCS(0))
+	for i, lockedPkg := range locked {
+		group.Go(func() error {
+			paths, err := installRunXPackage(ctx, lockedPkg.Resolved)
+			if err == nil {
+				digests[i], err = verifyRunXPackage(lockedPkg.Resolved, lockedPkg.Sha256, paths)
+			}
+			if err != nil {
+				mu.Lock()
+				if d.failures != nil {
+					d.failures.add(pkgs[i], err)
+				} else {
+					errs = append(errs, fmt.Errorf("error installing runx package %s: %w", lockedPkg.Resolved, err))
+				}
+				mu.Unlock()
+			}
+			return nil
+		})
+	}
+	_ = group.Wait()
+
+	// Record the digests here rather than in the goroutines, since the
+	// lockfile isn't safe for concurrent use.
+	for i, lockedPkg := range locked {
+		if lockedPkg.Sha256 == "" && digests[i] != "" {
+			lockedPkg.Sha256 = digests[i]
+		}
+	}
+	return stderrors.Join(errs...)
+}
+
+// verifyRunXPackage checks the files that a runx package installed to paths
+// against the expected sha256 digest, and returns their digest. If the digest
+// doesn't match, the files are deleted so that a tampered release isn't used.
+// An empty expected digest always matches.
+func verifyRunXPackage(ref, expected string, paths []string) (string, error) {
+	digest, err := runxDigest(paths)
+	if err != nil {
+		return "", err
+	}
+	if expected == "" || digest == expected {
+		return digest, nil
+	}
+	for _, path := range paths {
+		if err := os.RemoveAll(path); err != nil {
+			slog.Error("failed to remove runx package with a checksum mismatch", "path", path, "err", err)
+		}
+	}
+	return "", usererr.New(
+		"The files of runx package %s don't match the sha256 in devbox.lock (expected %s, got %s), so they were deleted. "+
+			"If the release was changed on purpose, remove its sha256 from devbox.lock and install again.",
+		ref, expected, digest,
+	)
+}
+
+// runxDigest returns the sha256 of the regular files in the given directories,
+// including their paths relative to the directory.
+func runxDigest(paths []string) (string, error) {
+	paths = slices.Clone(paths)
+	slices.Sort(paths)
+	h := sha256.New()
+	for _, root := range paths {
+		err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil || !entry.Type().IsRegular() {
+				return err
+			}
+			rel, err := filepath.Rel(root, path)
+			if err != nil {
+				return err
+			}
+			f, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			fmt.Fprintf(h, "%s\x00", filepath.ToSlash(rel))
+			_, err = io.Copy(h, f)
 			return err
+		})
+		if err != nil {
+			return "", errors.WithStack(err)
 		}
-		if _, err := pkgtype.RunXClient().Install(
-			ctx,
-			lockedPkg.Resolved,
-		); err != nil {
-			return fmt.Errorf("error installing runx package %s: %w", pkg, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// runxInstallAttempts is the number of times a runx package install is
+// attempted when it fails with a transient network error.
+const runxInstallAttempts = 3
+
+// installRunXPackage installs a resolved runx package, retrying with an
+// exponential backoff if the install fails because of a network or server
+// error. Other errors, such as a missing release, aren't retried.
+func installRunXPackage(ctx context.Context, ref string) ([]string, error) {
+	if err := pkgtype.CheckRunXNetworkPolicy(); err != nil {
+		return nil, err
+	}
+	var err error
+	attempt := 1
+	for ; ; attempt++ {
+		var paths []string
+		if paths, err = pkgtype.RunXClient().Install(ctx, ref); err == nil {
+			return paths, nil
+		}
+		if attempt == runxInstallAttempts || !isTransientNetworkError(err) {
+			break
+		}
+		wait := time.Duration(1<<(attempt-1)) * time.Second
+		slog.Debug("retrying runx install", "pkg", ref, "err", err, "wait", wait)
+		select {
+		case <-ctx.Done():
+			return nil, errors.WithStack(ctx.Err())
+		case <-time.After(wait):
 		}
 	}
-	return nil
+	if attempt > 1 {
+		return nil, fmt.Errorf("failed after %d attempts: %w", attempt, err)
+	}
+	return nil, err
+}
+
+var serverErrorRegex = regexp.MustCompile(`\b5\d\d\b`)
+
+// isTransientNetworkError returns true if err looks like a network failure or
+// an HTTP 5xx response, which may succeed if retried.
+func isTransientNetworkError(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	msg := strings.ToLower(err.Error())
+	if strings.Contains(msg, "404") || strings.Contains(msg, "not found") {
+		return false
+	}
+	return serverErrorRegex.MatchString(msg) ||
+		strings.Contains(msg, "connection reset") ||
+		strings.Contains(msg, "connection refused") ||
+		strings.Contains(msg, "timeout")
 }
 
 // installNixPackagesToStore will install all the packages in the nix store, if
@@ -467,10 +1792,25 @@ func (d *Devbox) InstallRunXPackages(ctx context.Context) error {
 // and installing in the nix profile (even if offline).
 func (d *Devbox) installNixPackagesToStore(ctx context.Context, mode installMode) error {
 	defer debug.FunctionTimer().End()
-	packages, err := d.packagesToInstallInStore(ctx, mode)
+	if d.storeRoot != "" {
+		if err := nix.ValidateStoreRoot(ctx, d.storeRoot); err != nil {
+			return err
+		}
+	}
+	crossSystem := d.buildSystem != "" && d.buildSystem != nix.System()
+	var packages []*devpkg.Package
+	var err error
+	if crossSystem {
+		packages = d.packagesForSystem(d.buildSystem)
+	} else {
+		packages, err = d.packagesToInstallInStore(ctx, mode)
+	}
 	if err != nil || len(packages) == 0 {
 		return err
 	}
+	if err := d.checkBuildNetworkPolicy(packages); err != nil {
+		return err
+	}
 
 	// --no-link to avoid generating the result objects
 	flags := []string{"--no-link"}
@@ -479,8 +1819,14 @@ func (d *Devbox) installNixPackagesToStore(ctx context.Context, mode installMode
 	}
 
 	args := &nix.BuildArgs{
-		Flags:  flags,
-		Writer: d.stderr,
+		Flags:        flags,
+		Store:        d.storeRoot,
+		System:       d.buildSystem,
+		Verbosity:    d.buildVerbosity,
+		Writer:       d.stderr,
+		LogTailLines: d.buildLogLines,
+		MaxJobs:      d.maxJobs,
+		Cores:        d.cores,
 	}
 	err = d.appendExtraSubstituters(ctx, args)
 	if err != nil {
@@ -491,50 +1837,214 @@ func (d *Devbox) installNixPackagesToStore(ctx context.Context, mode installMode
 		packages,
 		func(p *devpkg.Package, _ int) string { return p.Raw },
 	)
-	ux.Finfo(
-		d.stderr,
-		"Installing the following packages to the nix store: %s\n",
-		strings.Join(packageNames, ", "),
-	)
+	if d.buildVerbosity != nix.BuildVerbosityQuiet {
+		ux.Finfo(
+			d.stderr,
+			"Installing the following packages to the nix store: %s\n",
+			strings.Join(packageNames, ", "),
+		)
+	}
 
-	installables := map[bool][]string{false: {}, true: {}}
+	// Only the insecure packages that were explicitly allowed with
+	// allow_insecure are permitted; nix rejects any other insecure package.
+	// Packages with a build_env or build_flags are built on their own so
+	// that the variables and flags only apply to them.
+	installablesByPkg := map[*devpkg.Package][]string{}
+	buildEnvByPkg := map[*devpkg.Package]map[string]string{}
 	for _, pkg := range packages {
-		pkgInstallables, err := pkg.Installables()
+		buildEnv, err := d.expandBuildEnv(pkg)
 		if err != nil {
+			if err := d.keepGoingOnError(pkg, err); err != nil {
+				return err
+			}
+			continue
+		}
+		buildEnvByPkg[pkg] = buildEnv
+
+		var pkgInstallables []string
+		if crossSystem {
+			installable, err := pkg.InstallableForSystem(d.buildSystem)
+			if err != nil {
+				return err
+			}
+			pkgInstallables = []string{installable}
+		} else if pkgInstallables, err = pkg.Installables(); err != nil {
+			if err := d.keepGoingOnError(pkg, err); err != nil {
+				return err
+			}
+			continue
+		}
+		installablesByPkg[pkg] = pkgInstallables
+		args.AllowInsecure = append(args.AllowInsecure, pkg.AllowInsecure...)
+	}
+	args.AllowInsecure = lo.Uniq(args.AllowInsecure)
+
+	// Evaluate local flakes first so that errors in them point at their
+	// directory instead of failing the whole build.
+	for _, pkg := range packages {
+		if d.failures.has(pkg) {
+			continue
+		}
+		if err := d.keepGoingOnError(pkg, pkg.ValidateLocalFlake(ctx)); err != nil {
 			return err
 		}
-		installables[pkg.HasAllowInsecure()] = append(
-			installables[pkg.HasAllowInsecure()],
-			pkgInstallables...,
-		)
 	}
+	packages = lo.Filter(packages, func(pkg *devpkg.Package, _ int) bool {
+		return !d.failures.has(pkg)
+	})
 
-	for allowInsecure, installables := range installables {
-		if len(installables) == 0 {
+	installables := []string{}
+	for _, pkg := range packages {
+		if _, ok := packageBuildArgs(args, pkg, buildEnvByPkg[pkg]); !ok {
+			installables = append(installables, installablesByPkg[pkg]...)
+		}
+	}
+	eventStart := time.Now()
+	batchFailed := false
+	if len(installables) > 0 {
+		err := nix.Build(ctx, args, installables...)
+		if err != nil && d.failures == nil {
+			return err
+		}
+		batchFailed = err != nil
+	}
+	for _, pkg := range packages {
+		pkgArgs, ok := packageBuildArgs(args, pkg, buildEnvByPkg[pkg])
+		if !ok && !batchFailed {
 			continue
 		}
-		eventStart := time.Now()
-		args.AllowInsecure = allowInsecure
-		err = nix.Build(ctx, args, installables...)
-		if err != nil {
+		// With keepGoing, a failed batch is built again one package at a
+		// time to find out which packages fail.
+		err := nix.Build(ctx, pkgArgs, installablesByPkg[pkg]...)
+		if err := d.keepGoingOnError(pkg, err); err != nil {
 			return err
 		}
-		telemetry.Event(telemetry.EventNixBuildSuccess, telemetry.Metadata{
-			EventStart: eventStart,
-			Packages:   packageNames,
+	}
+	packages = lo.Filter(packages, func(pkg *devpkg.Package, _ int) bool {
+		return !d.failures.has(pkg)
+	})
+	if !crossSystem {
+		if err := d.checkpointStoredPackages(packages); err != nil {
+			slog.Debug("failed to write install checkpoint", "err", err)
+		}
+		if d.pushToCache != nil {
+			d.pushBuiltPackagesToCache(ctx, packages)
+		}
+	}
+	telemetry.Event(telemetry.EventNixBuildSuccess, telemetry.Metadata{
+		EventStart: eventStart,
+		Packages:   packageNames,
+	})
+	return nil
+}
+
+// defaultBuildLogLines is how many lines of a failed build's log are included
+// in the error by default.
+const defaultBuildLogLines = 25
+
+// packageBuildArgs returns the nix build args for pkg, with its expanded
+// buildEnv. It returns true if pkg has a build_env or build_flags, in which case
+// it's built on its own so that they only apply to it.
+func packageBuildArgs(args *nix.BuildArgs, pkg *devpkg.Package, buildEnv map[string]string) (*nix.BuildArgs, bool) {
+	pkgArgs := *args
+	if len(buildEnv) == 0 && len(pkg.BuildFlags) == 0 {
+		return &pkgArgs, false
+	}
+	pkgArgs.Env = append(slices.Clone(args.Env), envir.MapToPairs(buildEnv)...)
+	pkgArgs.Flags = append(slices.Clone(args.Flags), pkg.BuildFlags...)
+	return &pkgArgs, true
+}
+
+// expandBuildEnv returns pkg's build_env with the ${VAR} and $VAR references in
+// its values replaced. A variable is looked up in order in:
+//
+//  1. DEVBOX_PROJECT_ROOT, which is always the project directory.
+//  2. The env field of devbox.json and the env of its plugins, expanded the
+//     same way as in a devbox shell.
+//  3. The environment that devbox runs in.
+//
+// A variable that isn't set in any of them is an error instead of expanding
+// to an empty string, since that could silently build the package with a
+// wrong path.
+func (d *Devbox) expandBuildEnv(pkg *devpkg.Package) (map[string]string, error) {
+	if len(pkg.BuildEnv) == 0 {
+		return nil, nil
+	}
+	osEnv := envir.PairsToMap(os.Environ())
+	projectEnv := conf.OSExpandEnvMap(d.cfg.Env(), osEnv, d.projectDir)
+	lookup := func(name string) (string, bool) {
+		if name == "DEVBOX_PROJECT_ROOT" {
+			return d.projectDir, true
+		}
+		if v, ok := projectEnv[name]; ok {
+			return v, true
+		}
+		v, ok := osEnv[name]
+		return v, ok
+	}
+
+	keys := lo.Keys(pkg.BuildEnv)
+	slices.Sort(keys)
+	expanded := make(map[string]string, len(keys))
+	for _, k := range keys {
+		var missing []string
+		expanded[k] = os.Expand(pkg.BuildEnv[k], func(name string) string {
+			v, ok := lookup(name)
+			if !ok {
+				missing = append(missing, name)
+			}
+			return v
 		})
+		if len(missing) > 0 {
+			return nil, usererr.New(
+				"The build_env of package %s sets %s=%q, but %s isn't set in the env of "+
+					"devbox.json or in the environment.",
+				pkg.Raw, k, pkg.BuildEnv[k], strings.Join(lo.Uniq(missing), ", "),
+			)
+		}
 	}
+	return expanded, nil
+}
 
-	return nil
+// flakeBuildEnv returns the expanded build_env of all installable packages, as
+// KEY=VALUE pairs for evaluating the generated flake. The flake evaluates every
+// package at once, so packages can't set the same variable to different values.
+func (d *Devbox) flakeBuildEnv() ([]string, error) {
+	env := map[string]string{}
+	setBy := map[string]string{}
+	for _, pkg := range d.InstallablePackages() {
+		buildEnv, err := d.expandBuildEnv(pkg)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range buildEnv {
+			if other, ok := setBy[k]; ok && env[k] != v {
+				return nil, usererr.New(
+					"Packages %s and %s set %s to different values in their build_env. "+
+						"The environment is evaluated with every package's build_env, so they must match.",
+					other, pkg.Raw, k,
+				)
+			}
+			env[k] = v
+			setBy[k] = pkg.Raw
+		}
+	}
+	pairs := envir.MapToPairs(env)
+	slices.Sort(pairs)
+	return pairs, nil
 }
 
 func (d *Devbox) appendExtraSubstituters(ctx context.Context, args *nix.BuildArgs) error {
+	// Listing the Jetify caches calls the Jetify API.
+	if netpolicy.IsStrict() {
+		return nil
+	}
 	creds, err := nixcache.CachedCredentials(ctx)
 	if errors.Is(err, auth.ErrNotLoggedIn) {
 		return nil
 	}
 	if err != nil {
-		ux.Fwarning(d.stderr, "Devbox was unable to authenticate with the Jetify Nix cache. Some packages might be built from source.\n")
+		d.warn(WarningCacheUnavailable, "Devbox was unable to authenticate with the Jetify Nix cache. Some packages might be built from source.\n")
 		return nil //nolint:nilerr
 	}
 
@@ -565,7 +2075,7 @@ func (d *Devbox) appendExtraSubstituters(ctx context.Context, args *nix.BuildArg
 	// continue by building from source if necessary.
 	if err != nil {
 		slog.Error("error configuring nix cache", "err", err)
-		ux.Fwarning(d.stderr, "Devbox was unable to configure Nix to use the Jetify Nix cache. Some packages might be built from source.\n")
+		d.warn(WarningCacheUnavailable, "Devbox was unable to configure Nix to use the Jetify Nix cache. Some packages might be built from source.\n")
 		return nil
 	}
 
@@ -576,46 +2086,155 @@ func (d *Devbox) appendExtraSubstituters(ctx context.Context, args *nix.BuildArg
 	return nil
 }
 
+// configPackagesForSystem returns the packages in the config that are
+// installed on system, and the versioned names of the ones that platforms or
+// excluded_platforms leave out. Disabled packages are in neither. Packages
+// with alternatives are already replaced by the alternative for each
+// platform.
+func (d *Devbox) configPackagesForSystem(system string) (enabled []configfile.Package, excluded []string) {
+	for _, pkg := range d.cfg.Packages(false /*includeRemovedTriggerPackages*/) {
+		switch {
+		case pkg.Disabled:
+		case pkg.IsEnabledOnSystem(system):
+			enabled = append(enabled, pkg)
+		default:
+			excluded = append(excluded, pkg.VersionedName())
+		}
+	}
+	return enabled, excluded
+}
+
+// packagesForSystem returns the nix packages to build for system, which is
+// usually not the current system. Packages that are excluded on system with
+// platforms or excluded_platforms are skipped and reported. See
+// configPackagesForSystem.
+func (d *Devbox) packagesForSystem(system string) []*devpkg.Package {
+	enabled, skipped := d.configPackagesForSystem(system)
+	if len(skipped) > 0 {
+		ux.Finfo(
+			d.stderr,
+			"Skipping packages that are not enabled on %s: %s\n",
+			system, strings.Join(skipped, ", "),
+		)
+	}
+	return lo.Filter(devpkg.PackagesFromConfig(enabled, d.lockfile), devpkg.IsNix)
+}
+
+// BuildForSystem builds the project's nix packages for system (such as
+// "aarch64-linux") into the nix store without changing the project's
+// environment. It's meant to warm a binary cache for another platform, so
+// system may differ from the current system as long as nix can build for it
+// (for example with a remote builder or binfmt emulation).
+func (d *Devbox) BuildForSystem(ctx context.Context, system string) error {
+	ctx, task := trace.NewTask(ctx, "devboxBuildForSystem")
+	defer task.End()
+
+	systems, err := nix.NormalizePlatforms([]string{system})
+	if err != nil {
+		return err
+	}
+	d.buildSystem = systems[0]
+	defer func() { d.buildSystem = "" }()
+	return d.installNixPackagesToStore(ctx, install)
+}
+
 func (d *Devbox) packagesToInstallInStore(ctx context.Context, mode installMode) ([]*devpkg.Package, error) {
 	defer debug.FunctionTimer().End()
-	// First, get and prepare all the packages that must be installed in this project
-	// and remove non-nix packages from the list
-	packages := lo.Filter(d.InstallablePackages(), devpkg.IsNix)
-	if err := devpkg.FillNarInfoCache(ctx, packages...); err != nil {
-		return nil, err
+	// First, get all the packages that must be installed in this project
+	// and remove non-nix packages from the list. Lazy packages are left to be
+	// built when the environment is computed.
+	packages := lo.Filter(d.InstallablePackages(), func(p *devpkg.Package, i int) bool {
+		return devpkg.IsNix(p, i) && !p.Lazy
+	})
+	if mode == update {
+		return packages, devpkg.FillNarInfoCache(ctx, packages...)
 	}
 
-	// Second, check which packages are not in the nix store
+	// Restore the lockfile entries of the packages that an earlier run
+	// installed before failing. See installCheckpoint.
+	checkpointed := d.restoreCheckpointedPackages(packages)
+
+	// Second, check which packages with locked store paths are not in the nix
+	// store. Packages that are already installed don't need the binary cache,
+	// so their narinfos aren't fetched.
 	packagesToInstall := []*devpkg.Package{}
-	storePathsForPackage := map[*devpkg.Package][]string{}
-	for _, pkg := range packages {
-		if mode == update {
-			packagesToInstall = append(packagesToInstall, pkg)
-			continue
+	lockedStorePaths, unlocked, err := lockedStorePathsToCheck(packages, checkpointed)
+	if err != nil {
+		return nil, err
+	}
+	notInStore, err := d.packagesNotInStore(ctx, lockedStorePaths)
+	if err != nil {
+		return nil, err
+	}
+	packagesToInstall = append(packagesToInstall, notInStore...)
+
+	// Finally, the binary cache is needed to find the store paths of the
+	// unlocked packages and to install the missing ones.
+	if err := devpkg.FillNarInfoCache(ctx, append(unlocked, packagesToInstall...)...); err != nil {
+		return nil, err
+	}
+	unlockedStorePaths := map[*devpkg.Package][]string{}
+	for _, pkg := range unlocked {
+		if unlockedStorePaths[pkg], err = pkg.GetStorePaths(ctx, d.stderr, d.storeRoot); err != nil {
+			return nil, err
 		}
-		var err error
-		storePathsForPackage[pkg], err = pkg.GetStorePaths(ctx, d.stderr)
+	}
+	notInStore, err = d.packagesNotInStore(ctx, unlockedStorePaths)
+	if err != nil {
+		return nil, err
+	}
+	packagesToInstall = append(packagesToInstall, notInStore...)
+
+	return lo.Uniq(packagesToInstall), nil
+}
+
+// lockedStorePathsToCheck returns the locked store paths of pkgs, and the
+// packages that have none. Checkpointed packages are checked like the others,
+// since their store paths may have been garbage collected, but the ones
+// without locked store paths can only be checked with the binary cache, which
+// the checkpoint is meant to save, so they're left out.
+func lockedStorePathsToCheck(
+	pkgs []*devpkg.Package,
+	checkpointed map[*devpkg.Package]bool,
+) (map[*devpkg.Package][]string, []*devpkg.Package, error) {
+	locked := map[*devpkg.Package][]string{}
+	unlocked := []*devpkg.Package{}
+	for _, pkg := range pkgs {
+		storePaths, err := pkg.GetResolvedStorePaths()
 		if err != nil {
-			return nil, err
+			return nil, nil, err
+		}
+		if len(storePaths) > 0 {
+			locked[pkg] = storePaths
+		} else if !checkpointed[pkg] {
+			unlocked = append(unlocked, pkg)
 		}
 	}
+	return locked, unlocked, nil
+}
 
+// packagesNotInStore returns the packages that have at least one of their
+// store paths missing from the nix store.
+func (d *Devbox) packagesNotInStore(
+	ctx context.Context,
+	storePathsForPackage map[*devpkg.Package][]string,
+) ([]*devpkg.Package, error) {
 	// Batch this for perf
-	storePathMap, err := nix.StorePathsAreInStore(ctx, lo.Flatten(lo.Values(storePathsForPackage)))
+	storePathMap, err := nix.StorePathsAreInStore(ctx, d.storeRoot, lo.Flatten(lo.Values(storePathsForPackage)))
 	if err != nil {
 		return nil, err
 	}
 
+	missing := []*devpkg.Package{}
 	for pkg, storePaths := range storePathsForPackage {
 		for _, storePath := range storePaths {
 			if !storePathMap[storePath] {
-				packagesToInstall = append(packagesToInstall, pkg)
+				missing = append(missing, pkg)
 				break
 			}
 		}
 	}
-
-	return lo.Uniq(packagesToInstall), nil
+	return missing, nil
 }
 
 // moveAllowInsecureFromLockfile will modernize a Devbox project by moving the allow_insecure: boolean
@@ -630,7 +2249,10 @@ func (d *Devbox) moveAllowInsecureFromLockfile(writer io.Writer, lockfile *lock.
 
 	insecurePackages := []string{}
 	for name, pkg := range lockfile.Packages {
-		if pkg.AllowInsecure {
+		// Packages that are no longer in devbox.json (or come from an
+		// included plugin) have no config entry to move the setting to, so
+		// the setting is dropped.
+		if _, inConfig := cfg.Root.GetPackage(name); pkg.AllowInsecure && inConfig {
 			insecurePackages = append(insecurePackages, name)
 		}
 		pkg.AllowInsecure = false
@@ -657,11 +2279,13 @@ func (d *Devbox) moveAllowInsecureFromLockfile(writer io.Writer, lockfile *lock.
 		return err
 	}
 
-	ux.Finfo(
-		writer,
-		"Modernized the allow_insecure setting for package %q by moving it from devbox.lock to devbox.json. Please commit the changes.\n",
-		strings.Join(insecurePackages, ", "),
-	)
+	if len(insecurePackages) > 0 {
+		ux.Finfo(
+			writer,
+			"Modernized the allow_insecure setting for package %q by moving it from devbox.lock to devbox.json. Please commit the changes.\n",
+			strings.Join(insecurePackages, ", "),
+		)
+	}
 
 	return nil
 }
@@ -688,7 +2312,7 @@ func (d *Devbox) FixMissingStorePaths(ctx context.Context) error {
 
 		outputs := []lock.Output{}
 		for _, installable := range installables {
-			storePaths, err := nix.StorePathsFromInstallable(ctx, installable, pkg.HasAllowInsecure())
+			storePaths, err := nix.StorePathsFromInstallable(ctx, d.storeRoot, installable, pkg.AllowInsecure)
 			if err != nil {
 				return err
 			}
@@ -711,3 +2335,74 @@ func (d *Devbox) FixMissingStorePaths(ctx context.Context) error {
 	}
 	return d.lockfile.Save()
 }
+
+// ListByPlatform returns, for each platform supported by nix, the versioned
+// names of the packages that would be installed on that platform. Packages are
+// filtered the same way as when they're built for a system (see
+// configPackagesForSystem), which lets users verify a multi-platform config
+// without entering a shell on each one.
+func (d *Devbox) ListByPlatform(ctx context.Context) (map[string][]string, error) {
+	defer trace.StartRegion(ctx, "devboxListByPlatform").End()
+
+	result := map[string][]string{}
+	for _, platform := range nix.Platforms() {
+		enabled, _ := d.configPackagesForSystem(platform)
+		result[platform] = lo.Map(enabled, func(pkg configfile.Package, _ int) string {
+			return pkg.VersionedName()
+		})
+	}
+	return result, nil
+}
+
+// ValidateLockfileMatchesConfig returns an error if devbox.lock is out of sync
+// with devbox.json. Every package in the config (including those added by
+// plugins) must have a resolved lockfile entry, and every lockfile entry must
+// belong to a package in the config. Unlike IsUpToDateAndInstalled, it doesn't
+// check what is installed, and it never resolves packages or touches the
+// network, so it's cheap enough to run as a CI check.
+func (d *Devbox) ValidateLockfileMatchesConfig(ctx context.Context) error {
+	defer trace.StartRegion(ctx, "devboxValidateLockfileMatchesConfig").End()
+
+	names := d.AllPackageNamesIncludingRemovedTriggerPackages()
+	missing := []string{}
+	for _, name := range names {
+		entry, ok := d.lockfile.Packages[name]
+		if !ok || !isLockEntryCurrent(d.lockfile, name, entry) {
+			missing = append(missing, name)
+		}
+	}
+	orphaned := []string{}
+	for name := range d.lockfile.Packages {
+		if !slices.Contains(names, name) {
+			orphaned = append(orphaned, name)
+		}
+	}
+	slices.Sort(orphaned)
+
+	if len(missing) == 0 && len(orphaned) == 0 {
+		return nil
+	}
+	msg := "devbox.lock is out of sync with devbox.json."
+	if len(missing) > 0 {
+		msg += "\nPackages missing from devbox.lock: " + strings.Join(missing, ", ")
+	}
+	if len(orphaned) > 0 {
+		msg += "\nPackages in devbox.lock but not in devbox.json: " + strings.Join(orphaned, ", ")
+	}
+	return usererr.New("%s\nRun `devbox install` to update the lockfile.", msg)
+}
+
+// isLockEntryCurrent reports whether a lockfile entry is complete for the
+// package name it's keyed by. Flakes aren't locked, so any entry will do.
+func isLockEntryCurrent(lockfile *lock.File, name string, entry *lock.Package) bool {
+	if entry == nil {
+		return false
+	}
+	if pkgtype.IsFlake(name) {
+		return true
+	}
+	if lock.IsLegacyPackage(name) {
+		return entry.Resolved == lockfile.LegacyNixpkgsPath(name)
+	}
+	return entry.Resolved != ""
+}
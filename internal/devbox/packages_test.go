@@ -0,0 +1,543 @@
+package devbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.jetpack.io/devbox/internal/devbox/devopt"
+	"go.jetpack.io/devbox/internal/devconfig/configfile"
+	"go.jetpack.io/devbox/internal/devpkg"
+	"go.jetpack.io/devbox/internal/lock"
+	"go.jetpack.io/devbox/internal/nix"
+)
+
+func TestValidateLockfileMatchesConfig(t *testing.T) {
+	devbox := devboxForTesting(t)
+	devbox.cfg.PackageMutator().Add("hello@1.2.3")
+
+	devbox.lockfile.Packages = map[string]*lock.Package{}
+	err := devbox.ValidateLockfileMatchesConfig(context.Background())
+	require.ErrorContains(t, err, "Packages missing from devbox.lock: hello@1.2.3")
+
+	devbox.lockfile.Packages["hello@1.2.3"] = &lock.Package{Resolved: "resolved-flake-reference"}
+	require.NoError(t, devbox.ValidateLockfileMatchesConfig(context.Background()))
+
+	devbox.lockfile.Packages["cowsay@latest"] = &lock.Package{Resolved: "resolved-flake-reference"}
+	err = devbox.ValidateLockfileMatchesConfig(context.Background())
+	require.ErrorContains(t, err, "Packages in devbox.lock but not in devbox.json: cowsay@latest")
+}
+
+func TestPackagesForSystem(t *testing.T) {
+	devbox := devboxForTesting(t)
+	devbox.stderr = io.Discard
+	devbox.cfg.PackageMutator().Add("hello@1.2.3")
+	devbox.cfg.PackageMutator().Add("utm@latest")
+	err := devbox.cfg.PackageMutator().ExcludePlatforms(io.Discard, "utm@latest", []string{"aarch64-linux"})
+	require.NoError(t, err)
+
+	packages := devbox.packagesForSystem("aarch64-linux")
+	require.Len(t, packages, 1)
+	require.Equal(t, "hello@1.2.3", packages[0].Raw)
+
+	require.Len(t, devbox.packagesForSystem("aarch64-darwin"), 2)
+}
+
+func TestListByPlatform(t *testing.T) {
+	devbox := devboxForTesting(t)
+	mutator := devbox.cfg.PackageMutator()
+	mutator.Add("hello@1.2.3")
+	mutator.Add("utm@latest")
+	require.NoError(t, mutator.ExcludePlatforms(io.Discard, "utm@latest", []string{"aarch64-linux"}))
+	mutator.Add("cowsay@latest")
+	require.NoError(t, mutator.SetDisabled("cowsay@latest", true))
+	mutator.Add("cc")
+	require.NoError(t, mutator.SetAlternatives("cc", map[string]string{"linux": "gcc@13", "darwin": "clang@17"}))
+
+	byPlatform, err := devbox.ListByPlatform(context.Background())
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"hello@1.2.3", "gcc@13"}, byPlatform["aarch64-linux"])
+	require.ElementsMatch(t, []string{"hello@1.2.3", "utm@latest", "clang@17"}, byPlatform["aarch64-darwin"])
+}
+
+func TestPackageBuildArgs(t *testing.T) {
+	args := &nix.BuildArgs{Flags: []string{"--no-link"}, Env: []string{"A=1"}}
+	pkg := devpkg.PackageFromStringWithDefaults("hello@latest", nil)
+
+	pkgArgs, ok := packageBuildArgs(args, pkg, pkg.BuildEnv)
+	require.False(t, ok)
+	require.Equal(t, args, pkgArgs)
+
+	pkg.BuildFlags = []string{"--option", "sandbox", "false"}
+	pkgArgs, ok = packageBuildArgs(args, pkg, pkg.BuildEnv)
+	require.True(t, ok)
+	require.Equal(t, []string{"--no-link", "--option", "sandbox", "false"}, pkgArgs.Flags)
+	require.Equal(t, []string{"A=1"}, pkgArgs.Env)
+	// The shared args aren't changed.
+	require.Equal(t, []string{"--no-link"}, args.Flags)
+
+	pkg.BuildFlags = nil
+	pkg.BuildEnv = map[string]string{"B": "2"}
+	pkgArgs, ok = packageBuildArgs(args, pkg, pkg.BuildEnv)
+	require.True(t, ok)
+	require.Equal(t, []string{"--no-link"}, pkgArgs.Flags)
+	require.Equal(t, []string{"A=1", "B=2"}, pkgArgs.Env)
+}
+
+func TestExpandBuildEnv(t *testing.T) {
+	d := devboxForTesting(t)
+	t.Setenv("DEVBOX_TEST_OS_VAR", "from-os")
+	t.Setenv("DEVBOX_TEST_SHADOWED", "from-os")
+	d.cfg.Root.Env = map[string]string{
+		"DEVBOX_TEST_SHADOWED": "from-project",
+		"DEVBOX_TEST_NESTED":   "$DEVBOX_TEST_OS_VAR/nested",
+	}
+
+	pkg := devpkg.PackageFromStringWithDefaults("hello@latest", nil)
+	pkg.BuildEnv = map[string]string{
+		"CARGO_HOME": "${DEVBOX_PROJECT_ROOT}/.cargo",
+		"A":          "$DEVBOX_TEST_OS_VAR",
+		"B":          "${DEVBOX_TEST_SHADOWED}",
+		"C":          "${DEVBOX_TEST_NESTED}",
+		"D":          "plain",
+	}
+	got, err := d.expandBuildEnv(pkg)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{
+		"CARGO_HOME": d.projectDir + "/.cargo",
+		"A":          "from-os",
+		"B":          "from-project",
+		"C":          "from-os/nested",
+		"D":          "plain",
+	}, got)
+	// The package's own build_env isn't changed.
+	require.Equal(t, "${DEVBOX_PROJECT_ROOT}/.cargo", pkg.BuildEnv["CARGO_HOME"])
+
+	pkg.BuildEnv = map[string]string{"A": "${DEVBOX_TEST_NOT_SET}/bin"}
+	_, err = d.expandBuildEnv(pkg)
+	require.ErrorContains(t, err, "DEVBOX_TEST_NOT_SET")
+}
+
+func TestDedupeByCanonicalName(t *testing.T) {
+	devbox := devboxForTesting(t)
+	got := dedupeByCanonicalName([]string{
+		"go", "hello@1.2.3", "go@latest", "github:nixos/nixpkgs#go", "go@1.21", "github:nixos/nixpkgs#go",
+	}, devbox.lockfile)
+	require.Equal(t, []string{"hello@1.2.3", "go@1.21", "github:nixos/nixpkgs#go"}, got)
+
+	got = dedupeByCanonicalName([]string{"go", "go@latest"}, devbox.lockfile)
+	require.Equal(t, []string{"go@latest"}, got)
+}
+
+type fakeConflictPrompter struct {
+	replace bool
+	asked   []string
+	// failOn is an existing package whose prompt fails.
+	failOn string
+}
+
+func (p *fakeConflictPrompter) ConfirmReplace(existing, added string) (bool, error) {
+	p.asked = append(p.asked, existing+" -> "+added)
+	if existing == p.failOn {
+		return false, errors.New("prompt failed")
+	}
+	return p.replace, nil
+}
+
+func TestShouldReplaceConflict(t *testing.T) {
+	replace, err := shouldReplaceConflict(devopt.AddOpts{}, "go@1.21", "go@1.22")
+	require.NoError(t, err)
+	require.True(t, replace)
+
+	replace, err = shouldReplaceConflict(
+		devopt.AddOpts{ConflictResolution: devopt.ConflictKeep}, "go@1.21", "go@1.22")
+	require.NoError(t, err)
+	require.False(t, replace)
+
+	prompter := &fakeConflictPrompter{replace: true}
+	opts := devopt.AddOpts{ConflictResolution: devopt.ConflictPrompt, ConflictPrompter: prompter}
+	replace, err = shouldReplaceConflict(opts, "go@1.21", "go@1.22")
+	require.NoError(t, err)
+	require.True(t, replace)
+	require.Equal(t, []string{"go@1.21 -> go@1.22"}, prompter.asked)
+
+	_, err = shouldReplaceConflict(
+		devopt.AddOpts{ConflictResolution: devopt.ConflictPrompt}, "go@1.21", "go@1.22")
+	require.Error(t, err)
+}
+
+func TestRankPackageSuggestions(t *testing.T) {
+	candidates := []string{
+		"nodejs", "nodejs_18", "nodejs_20", "nodejs-slim_18", "python3", "nodejs_18", "nodejs18",
+		"nodejs_16", "nodejs_14", "nodejs_22", "nodejs_21",
+	}
+	got := rankPackageSuggestions("nodejs18", candidates)
+	require.Equal(t, []string{"nodejs_18", "nodejs_14", "nodejs_16", "nodejs", "nodejs_20"}, got)
+
+	require.Empty(t, rankPackageSuggestions("ripgrep", []string{"python3", "go"}))
+}
+
+func TestParsePackageManifest(t *testing.T) {
+	manifest := `# Tools
+go@1.21
+
+hello@latest
+  cowsay
+bad entry@1
+@1.2.3
+ripgrep@
+`
+	pkgs, lineErrs, err := parsePackageManifest(strings.NewReader(manifest))
+	require.NoError(t, err)
+	require.Equal(t, []string{"go@1.21", "hello@latest", "cowsay"}, pkgs)
+	require.Len(t, lineErrs, 3)
+	require.Contains(t, lineErrs[0], "line 6:")
+	require.Contains(t, lineErrs[1], "line 7:")
+	require.Contains(t, lineErrs[2], "line 8:")
+}
+
+func TestStorePathMatchesName(t *testing.T) {
+	path := "/nix/store/0a2xcqgwdgwhsy2b5idgxlfbjxgyv0x6-go-1.21.6"
+	cases := map[string]bool{
+		"go":        true,
+		"go@latest": true,
+		"go@1.21":   true,
+		"go@1.22":   false,
+		"golang":    false,
+		"hello":     false,
+	}
+	for name, want := range cases {
+		if got := storePathMatchesName(path, name); got != want {
+			t.Errorf("storePathMatchesName(%q, %q) = %v, want %v", path, name, got, want)
+		}
+	}
+	require.False(t, storePathMatchesName("/nix/store/short", "go"))
+}
+
+func TestIsTransientNetworkError(t *testing.T) {
+	cases := map[string]bool{
+		"GET https://api.github.com/repos/a/b/releases: 502 Bad Gateway": true,
+		"read tcp 10.0.0.1:443: connection reset by peer":                true,
+		"GET https://api.github.com/repos/a/b/releases: 404 Not Found":   false,
+		"release v1.2.3 not found":                                       false,
+		"invalid package reference":                                      false,
+	}
+	for msg, want := range cases {
+		if got := isTransientNetworkError(errors.New(msg)); got != want {
+			t.Errorf("isTransientNetworkError(%q) = %v, want %v", msg, got, want)
+		}
+	}
+	require.False(t, isTransientNetworkError(context.Canceled))
+}
+
+func TestResetProfileDirForFlakesConcurrent(t *testing.T) {
+	// Old (non-flake) profiles are a symlink to a directory with a
+	// manifest.nix file.
+	dir := t.TempDir()
+	oldProfile := filepath.Join(dir, "profile-1-link")
+	require.NoError(t, os.Mkdir(oldProfile, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(oldProfile, "manifest.nix"), nil, 0o644))
+	profileDir := filepath.Join(dir, "profile")
+	require.NoError(t, os.Symlink(oldProfile, profileDir))
+
+	var resets atomic.Int32
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			reset, err := resetProfileDirForFlakes(profileDir)
+			if err != nil {
+				t.Error(err)
+			}
+			if reset {
+				resets.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	require.Equal(t, int32(1), resets.Load(), "profile should be reset exactly once")
+	_, err := os.Lstat(profileDir)
+	require.ErrorIs(t, err, fs.ErrNotExist)
+}
+
+func TestVerifyRunXPackage(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "bin"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "bin", "tool"), []byte("v1"), 0o755))
+
+	// Without an expected digest, the files' digest is returned.
+	digest, err := verifyRunXPackage("owner/tool@v1", "", []string{dir})
+	require.NoError(t, err)
+	require.Len(t, digest, 64)
+
+	got, err := verifyRunXPackage("owner/tool@v1", digest, []string{dir})
+	require.NoError(t, err)
+	require.Equal(t, digest, got)
+
+	// A changed file doesn't match, and the files are deleted.
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "bin", "tool"), []byte("v2"), 0o755))
+	_, err = verifyRunXPackage("owner/tool@v1", digest, []string{dir})
+	require.ErrorContains(t, err, "don't match the sha256")
+	require.NoDirExists(t, dir)
+}
+
+func TestVersionMatchesRequested(t *testing.T) {
+	cases := []struct {
+		version, requested string
+		want               bool
+	}{
+		{"2.1.3", "2", true},
+		{"2.1.3", "2.1", true},
+		{"2.1.3", "2.1.3", true},
+		{"2.1.3", "2.2", false},
+		{"1.9.0", "2", false},
+		{"2.10.0", "2.1", false},
+		{"2.1", "2.1.3", false},
+	}
+	for _, c := range cases {
+		got := versionMatchesRequested(c.version, c.requested)
+		require.Equal(t, c.want, got, "versionMatchesRequested(%q, %q)", c.version, c.requested)
+	}
+}
+
+func TestAddExistingPackageWithChangedPlatforms(t *testing.T) {
+	d := devboxForTesting(t)
+	d.stderr = io.Discard
+	d.cfg.PackageMutator().Add("hello@1.2.3")
+	ctx := context.Background()
+
+	// Re-adding with a new platform updates the existing entry.
+	opts := devopt.AddOpts{SkipInstall: true, Platforms: []string{"x86_64-linux"}}
+	result, err := d.AddWithResult(ctx, []string{"hello@1.2.3"}, opts)
+	require.NoError(t, err)
+	require.Equal(t, []string{"hello@1.2.3"}, result.Updated)
+	require.Empty(t, result.Unchanged)
+	require.Equal(t, []string{"x86_64-linux"}, d.cfg.Root.TopLevelPackages()[0].Platforms)
+
+	// Adding it again with the same platform doesn't change anything.
+	result, err = d.AddWithResult(ctx, []string{"hello@1.2.3"}, opts)
+	require.NoError(t, err)
+	require.Empty(t, result.Updated)
+	require.Equal(t, []string{"hello@1.2.3"}, result.Unchanged)
+
+	// Another platform is an update again.
+	opts.Platforms = []string{"aarch64-darwin"}
+	result, err = d.AddWithResult(ctx, []string{"hello@1.2.3"}, opts)
+	require.NoError(t, err)
+	require.Equal(t, []string{"hello@1.2.3"}, result.Updated)
+	require.Equal(t, []string{"x86_64-linux", "aarch64-darwin"}, d.cfg.Root.TopLevelPackages()[0].Platforms)
+}
+
+func TestAddDeferValidation(t *testing.T) {
+	d := devboxForTesting(t)
+	d.stderr = io.Discard
+	ctx := context.Background()
+
+	// Neither package is looked up, so the made up one is added too.
+	opts := devopt.AddOpts{DeferValidation: true}
+	result, err := d.AddWithResult(ctx, []string{"not-a-real-package", "jq@1.7"}, opts)
+	require.NoError(t, err)
+	require.Equal(t, []string{"not-a-real-package@latest", "jq@1.7"}, result.Added)
+	for _, name := range result.Added {
+		_, ok := d.cfg.Root.GetPackage(name)
+		require.True(t, ok, "%s is not in devbox.json", name)
+	}
+
+	opts.DryRun = true
+	_, err = d.AddWithResult(ctx, []string{"hello"}, opts)
+	require.Error(t, err)
+}
+
+func TestExpandRemovePatterns(t *testing.T) {
+	d := devboxForTesting(t)
+	d.stderr = io.Discard
+	d.cfg.PackageMutator().Add("python@3.11")
+	d.cfg.PackageMutator().Add("python3Packages.pip@latest")
+	d.cfg.PackageMutator().Add("go@1.22")
+
+	got, err := d.expandRemovePatterns([]string{"python*", "go"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"python@3.11", "python3Packages.pip@latest", "go"}, got)
+
+	got, err = d.expandRemovePatterns([]string{"go@1.2?"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"go@1.22"}, got)
+
+	_, err = d.expandRemovePatterns([]string{"ruby*"})
+	require.ErrorContains(t, err, `No packages in devbox.json match "ruby*"`)
+
+	_, err = d.expandRemovePatterns([]string{"[python"})
+	require.ErrorContains(t, err, "Invalid package pattern")
+}
+
+func TestLockPackagesFrom(t *testing.T) {
+	d := devboxForTesting(t)
+	commit := d.cfg.NixPkgsCommitHash()
+	path := filepath.Join(t.TempDir(), "devbox.lock")
+	require.NoError(t, os.WriteFile(path, []byte(`{
+  "lockfile_version": "1",
+  "packages": {
+    "go@latest": {"resolved": "github:NixOS/nixpkgs/abc#go", "version": "1.22.1"},
+    "hello": {"resolved": "github:NixOS/nixpkgs/`+commit+`#hello"},
+    "github:jetify-com/devbox-plugins?dir=mongodb": {"plugin_version": "0.0.1"}
+  }
+}`), 0o644))
+
+	locked, err := lock.ReadPackages(path)
+	require.NoError(t, err)
+	pkgs, err := d.lockPackagesFrom(path, locked)
+	require.NoError(t, err)
+	require.Equal(t, []string{"go@1.22.1", "hello"}, pkgs)
+	require.Equal(t, locked["go@latest"], d.lockfile.Get("go@1.22.1"))
+
+	// Legacy packages locked to another nixpkgs commit can't be reproduced.
+	locked["hello"].Resolved = "github:NixOS/nixpkgs/def#hello"
+	delete(d.lockfile.Packages, "go@1.22.1")
+	_, err = d.lockPackagesFrom(path, locked)
+	require.ErrorContains(t, err, "locked to nixpkgs commit def")
+	require.Nil(t, d.lockfile.Get("go@1.22.1"))
+}
+
+func TestAddIfMissing(t *testing.T) {
+	d := devboxForTesting(t)
+	d.cfg.PackageMutator().Add("go@1.21")
+
+	opts := devopt.AddOpts{IfMissing: true, DryRun: true}
+	result, err := d.AddWithResult(context.Background(), []string{"go@1.22"}, opts)
+	require.NoError(t, err)
+	require.Equal(t, []string{"go@1.21"}, result.Unchanged)
+	require.Empty(t, result.Added)
+	require.Empty(t, result.Replaced)
+
+	// The exact package is left alone too, even with options that would
+	// otherwise change it.
+	opts.Platforms = []string{"x86_64-linux"}
+	result, err = d.AddWithResult(context.Background(), []string{"go@1.21"}, opts)
+	require.NoError(t, err)
+	require.Equal(t, []string{"go@1.21"}, result.Unchanged)
+	require.Empty(t, result.Updated)
+}
+
+func TestAddReplacesPackage(t *testing.T) {
+	d := devboxForTesting(t)
+	d.cfg.Root.AuditLog = &configfile.AuditLogConfig{Enabled: true}
+	d.cfg.PackageMutator().Add("go@1.21")
+	d.lockfile.Packages["go@1.21"] = &lock.Package{Resolved: "github:NixOS/nixpkgs/abc#go", Version: "1.21.5"}
+
+	opts := devopt.AddOpts{DeferValidation: true}
+	result, err := d.AddWithResult(context.Background(), []string{"go@1.22"}, opts)
+	require.NoError(t, err)
+	require.Equal(t, []string{"go@1.21"}, result.Replaced)
+	require.Equal(t, []string{"go@1.22"}, result.Added)
+	pkgs := d.TopLevelPackages()
+	require.Len(t, pkgs, 1)
+	require.Equal(t, "go@1.22", pkgs[0].Raw)
+	require.Nil(t, d.lockfile.Get("go@1.21"))
+
+	got, err := os.ReadFile(filepath.Join(d.projectDir, ".devbox", "audit.log"))
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(got)), "\n")
+	require.Len(t, lines, 2)
+	var removed auditEntry
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &removed))
+	require.Equal(t, auditStatusRemoved, removed.Status)
+	require.Equal(t, "go@1.21", removed.Package)
+	require.Equal(t, "1.21.5", removed.Version)
+	require.Empty(t, removed.Mode, "the replacement wasn't installed")
+}
+
+func TestAddReplacementWaitsForAdd(t *testing.T) {
+	nix.FakeForTest(t, "exit 0\n")
+	d := devboxForTesting(t)
+	d.cfg.PackageMutator().Add("go@1.21")
+	d.cfg.PackageMutator().Add("hello@1")
+	d.lockfile.Packages["go@1.21"] = &lock.Package{Resolved: "github:NixOS/nixpkgs/abc#go", Version: "1.21.5"}
+	d.lockfile.Packages["hello@1"] = &lock.Package{Resolved: "github:NixOS/nixpkgs/abc#hello", Version: "1.0"}
+	require.NoError(t, d.saveCfg())
+	configPath := filepath.Join(d.projectDir, "devbox.json")
+	before, err := os.ReadFile(configPath)
+	require.NoError(t, err)
+
+	// The second conflict fails after go@1.21 was already replaced, which
+	// must not have been written to devbox.json yet.
+	opts := devopt.AddOpts{
+		DeferValidation:    true,
+		ConflictResolution: devopt.ConflictPrompt,
+		ConflictPrompter:   &fakeConflictPrompter{replace: true, failOn: "hello@1"},
+	}
+	_, err = d.addWithResult(context.Background(), []string{"go@1.22", "hello@2"}, opts)
+	require.Error(t, err)
+	after, err := os.ReadFile(configPath)
+	require.NoError(t, err)
+	require.Equal(t, string(before), string(after))
+}
+
+func TestReadPackagesFromStdin(t *testing.T) {
+	d := devboxForTesting(t)
+	d.stdin = strings.NewReader("ripgrep\n\n  fd@latest  \n# a comment\n")
+	pkgs, err := d.readPackagesFromStdin()
+	require.NoError(t, err)
+	require.Equal(t, []string{"ripgrep", "fd@latest"}, pkgs)
+
+	d.stdin = strings.NewReader("ripgrep fd\n")
+	_, err = d.readPackagesFromStdin()
+	require.Error(t, err)
+
+	d.stdin = nil
+	_, err = d.readPackagesFromStdin()
+	require.Error(t, err)
+}
+
+func TestOfflineVersion(t *testing.T) {
+	infos := map[string]*nix.Info{
+		"legacyPackages.x86_64-linux.go": {AttributeKey: "legacyPackages.x86_64-linux.go", PName: "go", Version: "1.22.5"},
+	}
+	cases := []struct {
+		requested   string
+		wantVersion string
+		wantOK      bool
+	}{
+		{"", "1.22.5", true},
+		{"latest", "1.22.5", true},
+		{"1.22", "1.22.5", true},
+		{"1.21", "", false},
+	}
+	for _, c := range cases {
+		version, ok := offlineVersion(infos, c.requested)
+		require.Equal(t, c.wantOK, ok, "offlineVersion(%q)", c.requested)
+		require.Equal(t, c.wantVersion, version, "offlineVersion(%q)", c.requested)
+	}
+}
+
+func TestFlakeBuildEnv(t *testing.T) {
+	d := devboxForTesting(t)
+	d.cfg.PackageMutator().Add("mytool@latest")
+	d.cfg.PackageMutator().Add("other@latest")
+	require.NoError(t, d.cfg.PackageMutator().SetBuildEnv(
+		io.Discard, "mytool@latest", map[string]string{"CARGO_HOME": "${DEVBOX_PROJECT_ROOT}/.cargo"}))
+
+	env, err := d.flakeBuildEnv()
+	require.NoError(t, err)
+	require.Equal(t, []string{"CARGO_HOME=" + d.projectDir + "/.cargo"}, env)
+
+	// The same value from two packages is fine, but not different ones.
+	require.NoError(t, d.cfg.PackageMutator().SetBuildEnv(
+		io.Discard, "other@latest", map[string]string{"CARGO_HOME": "${DEVBOX_PROJECT_ROOT}/.cargo"}))
+	_, err = d.flakeBuildEnv()
+	require.NoError(t, err)
+	require.NoError(t, d.cfg.PackageMutator().SetBuildEnv(
+		io.Discard, "other@latest", map[string]string{"CARGO_HOME": "/tmp/cargo"}))
+	_, err = d.flakeBuildEnv()
+	require.Error(t, err)
+}
@@ -0,0 +1,92 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"context"
+	"runtime/trace"
+
+	"go.jetpack.io/devbox/internal/devpkg"
+	"go.jetpack.io/devbox/internal/nix"
+)
+
+// PackageDetails describes a package in devbox.json, combining what's in the
+// config, the lockfile and the binary cache.
+type PackageDetails struct {
+	// Name is the package as it appears in devbox.json.
+	Name string `json:"name"`
+	// CanonicalName is the package name without a version. It's empty for
+	// flakes.
+	CanonicalName string `json:"canonical_name,omitempty"`
+	// Version is the version the package is locked to.
+	Version string `json:"version,omitempty"`
+	// StorePaths are the locked store paths of the package's outputs on
+	// the current system.
+	StorePaths []string `json:"store_paths,omitempty"`
+	// InBinaryCache is true if the package's outputs can be downloaded
+	// from a binary cache instead of being built.
+	InBinaryCache bool `json:"in_binary_cache"`
+	// Plugin is the name of the plugin that the package activates, if any.
+	Plugin string `json:"plugin,omitempty"`
+
+	Platforms         []string `json:"platforms,omitempty"`
+	ExcludedPlatforms []string `json:"excluded_platforms,omitempty"`
+	// Installable is false if the package is disabled or isn't enabled on
+	// the current platform.
+	Installable bool `json:"installable"`
+	// AllowInsecure are the insecure packages that this package is
+	// permitted to install.
+	AllowInsecure []string `json:"allow_insecure,omitempty"`
+	// Insecure is true if nixpkgs marks the locked package as insecure,
+	// and KnownVulnerabilities are the reasons it gives.
+	Insecure             bool     `json:"insecure"`
+	KnownVulnerabilities []string `json:"known_vulnerabilities,omitempty"`
+}
+
+// PackageInfo returns the details of a package in devbox.json. The name may
+// be the package's versioned or canonical name.
+func (d *Devbox) PackageInfo(ctx context.Context, name string) (PackageDetails, error) {
+	defer trace.StartRegion(ctx, "devboxPackageInfo").End()
+
+	pkg, err := d.findPackageByName(name)
+	if err != nil {
+		return PackageDetails{}, err
+	}
+	details := PackageDetails{
+		Name:          pkg.Raw,
+		CanonicalName: pkg.CanonicalName(),
+		Installable:   pkg.IsInstallable() && !pkg.Disabled,
+		AllowInsecure: pkg.AllowInsecure,
+	}
+	if cfgPkg, ok := d.cfg.Root.GetPackage(pkg.Raw); ok {
+		details.Platforms = cfgPkg.Platforms
+		details.ExcludedPlatforms = cfgPkg.ExcludedPlatforms
+	}
+	if locked := d.lockfile.Get(pkg.Raw); locked != nil {
+		details.Version = locked.Version
+		if devpkg.IsNix(pkg, 0) && locked.Resolved != "" {
+			details.Insecure = nix.PackageIsInsecure(locked.Resolved)
+			details.KnownVulnerabilities = nix.PackageKnownVulnerabilities(locked.Resolved)
+		}
+	}
+	if details.StorePaths, err = pkg.GetResolvedStorePaths(); err != nil {
+		return PackageDetails{}, err
+	}
+	if devpkg.IsNix(pkg, 0) {
+		if err := devpkg.FillNarInfoCache(ctx, pkg); err != nil {
+			return PackageDetails{}, err
+		}
+		if details.InBinaryCache, err = pkg.IsInBinaryCache(); err != nil {
+			return PackageDetails{}, err
+		}
+	}
+	for _, pluginConfig := range d.cfg.IncludedPluginConfigs() {
+		source, ok := pluginConfig.Source.(*devpkg.Package)
+		if ok && source.CanonicalName() != "" && source.CanonicalName() == pkg.CanonicalName() {
+			details.Plugin = pluginConfig.Name
+			break
+		}
+	}
+	return details, nil
+}
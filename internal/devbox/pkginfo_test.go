@@ -0,0 +1,54 @@
+package devbox
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.jetpack.io/devbox/internal/lock"
+	"go.jetpack.io/devbox/internal/nix"
+)
+
+func TestPackageInfo(t *testing.T) {
+	// A fake nix that reports the locked openssl as insecure. It's too old
+	// to use the binary cache, so the test doesn't need the network.
+	nix.FakeForTest(t, `case "$*" in
+  *--version*) echo "nix (Nix) 2.16.0";;
+  *"#openssl.meta.insecure"*) echo 'true';;
+  *"#openssl.meta.knownVulnerabilities"*) echo '["CVE-2024-0001"]';;
+  *meta.insecure*) echo 'false';;
+  *meta.knownVulnerabilities*) echo '[]';;
+  *) exit 1;;
+esac
+`)
+
+	d := devboxForTesting(t)
+	d.cfg.PackageMutator().Add("openssl@1.1.1")
+	d.cfg.PackageMutator().Add("hello@2.12")
+	d.lockfile.Packages["openssl@1.1.1"] = &lock.Package{
+		Resolved: "github:NixOS/nixpkgs/abc#openssl",
+		Version:  "1.1.1w",
+	}
+	d.lockfile.Packages["hello@2.12"] = &lock.Package{
+		Resolved: "github:NixOS/nixpkgs/abc#hello",
+		Version:  "2.12",
+	}
+
+	details, err := d.PackageInfo(context.Background(), "openssl")
+	require.NoError(t, err)
+	require.Equal(t, "openssl@1.1.1", details.Name)
+	require.Equal(t, "openssl", details.CanonicalName)
+	require.Equal(t, "1.1.1w", details.Version)
+	require.True(t, details.Installable)
+	require.True(t, details.Insecure)
+	require.Equal(t, []string{"CVE-2024-0001"}, details.KnownVulnerabilities)
+	require.False(t, details.InBinaryCache)
+
+	details, err = d.PackageInfo(context.Background(), "hello@2.12")
+	require.NoError(t, err)
+	require.False(t, details.Insecure)
+	require.Empty(t, details.KnownVulnerabilities)
+
+	_, err = d.PackageInfo(context.Background(), "jq")
+	require.Error(t, err)
+}
@@ -0,0 +1,274 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"path/filepath"
+	"runtime/trace"
+	"slices"
+
+	"github.com/samber/lo"
+	"go.jetpack.io/devbox/internal/devpkg"
+	"go.jetpack.io/devbox/internal/devpkg/pkgtype"
+	"go.jetpack.io/devbox/internal/fileutil"
+	"go.jetpack.io/devbox/internal/nix"
+)
+
+// ChangePlan describes what ensureStateIsUpToDate would do for a given mode.
+type ChangePlan struct {
+	// UpToDate is true if the lockfile and local state are already up to
+	// date. In ensure mode, nothing else happens when this is true.
+	UpToDate bool
+
+	// StorePackages are the Nix packages that will be built or fetched into
+	// the Nix store.
+	StorePackages []string
+
+	// RunXPackages are the runx packages that will be installed.
+	RunXPackages []string
+
+	// ProfileAdd and ProfileRemove are the store paths that will be added to
+	// and removed from the project's Nix profile. They're based on the store
+	// paths in the lockfile, so ProfileRemove is left empty if any package
+	// (such as a flake) doesn't have its store paths locked.
+	ProfileAdd    []string
+	ProfileRemove []string
+
+	// Plugins are the plugins whose files will be created.
+	Plugins []string
+
+	// RecomputeEnv is true if the Devbox environment will be recomputed,
+	// which regenerates the flake and syncs the Nix profile.
+	RecomputeEnv bool
+}
+
+// IsEmpty returns true if the plan doesn't change anything.
+func (p *ChangePlan) IsEmpty() bool {
+	return len(p.StorePackages) == 0 &&
+		len(p.RunXPackages) == 0 &&
+		len(p.ProfileAdd) == 0 &&
+		len(p.ProfileRemove) == 0 &&
+		len(p.Plugins) == 0 &&
+		!p.RecomputeEnv
+}
+
+// DescribeChanges returns the changes ensureStateIsUpToDate would make in the
+// given mode, without making them. It may query the Nix store and the binary
+// caches to decide which packages need to be installed, but it doesn't build
+// anything or write any project state.
+//
+// Packages are resolved to describe the changes, which fills in the lockfile
+// in memory. The lockfile is put back afterwards, so that a later save or
+// install in the same process doesn't write the entries resolved for the
+// plan.
+func (d *Devbox) DescribeChanges(ctx context.Context, mode installMode) (*ChangePlan, error) {
+	defer trace.StartRegion(ctx, "devboxDescribeChanges").End()
+
+	locked := maps.Clone(d.lockfile.Packages)
+	defer func() { d.lockfile.Packages = locked }()
+
+	upToDate, err := d.lockfile.IsUpToDateAndInstalled(isFishShell())
+	if err != nil {
+		return nil, err
+	}
+	plan := &ChangePlan{UpToDate: upToDate}
+	if mode == ensure && upToDate {
+		return plan, nil
+	}
+
+	if mode == install || mode == update || mode == ensure {
+		for _, pluginConfig := range d.Config().IncludedPluginConfigs() {
+			plan.Plugins = append(plan.Plugins, pluginConfig.Source.CanonicalName())
+		}
+
+		packages, err := d.packagesToInstallInStore(ctx, mode)
+		if err != nil {
+			return nil, err
+		}
+		plan.StorePackages = lo.Map(packages, func(p *devpkg.Package, _ int) string { return p.Raw })
+		slices.Sort(plan.StorePackages)
+
+		plan.RunXPackages = lo.Map(
+			d.PackagesByType(pkgtype.RunX),
+			func(p *devpkg.Package, _ int) string { return p.Raw },
+		)
+	}
+
+	plan.RecomputeEnv = mode == ensure || (d.IsEnvEnabled() && mode != skipPluginGen)
+	if plan.RecomputeEnv {
+		plan.ProfileAdd, plan.ProfileRemove, err = d.profileChanges()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return plan, nil
+}
+
+// profileChanges diffs the store paths in the project's Nix profile against
+// the store paths recorded in the lockfile for the installable Nix packages.
+func (d *Devbox) profileChanges() (add, remove []string, err error) {
+	want := []string{}
+	complete := true
+	for _, pkg := range d.PackagesByType(pkgtype.Nix) {
+		storePaths, err := pkg.GetResolvedStorePaths()
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(storePaths) == 0 {
+			complete = false
+		}
+		want = append(want, storePaths...)
+	}
+
+	got, err := d.installedProfileStorePaths()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	remove, add = lo.Difference(got, want)
+	if !complete {
+		// We can't tell which profile entries belong to the packages
+		// without locked store paths.
+		remove = nil
+	}
+	slices.Sort(add)
+	slices.Sort(remove)
+	return add, remove, nil
+}
+
+// PackagePlan describes how a package will be installed.
+type PackagePlan struct {
+	// Package is the package name as it appears in devbox.json.
+	Package string
+
+	// FromCache is true if all of the package's default outputs will be
+	// downloaded from a binary cache. Otherwise the package is built from
+	// source.
+	FromCache bool
+
+	// DownloadSize is the compressed size in bytes of the outputs that will
+	// be downloaded, or 0 if unknown.
+	DownloadSize int64
+}
+
+// PackageBuildPlan reports, for each installable Nix package, whether it will
+// be fetched from a binary cache or built locally.
+func (d *Devbox) PackageBuildPlan(ctx context.Context) ([]PackagePlan, error) {
+	defer trace.StartRegion(ctx, "devboxPackageBuildPlan").End()
+
+	packages := d.PackagesByType(pkgtype.Nix)
+	if err := devpkg.FillNarInfoCache(ctx, packages...); err != nil {
+		return nil, err
+	}
+
+	plans := make([]PackagePlan, 0, len(packages))
+	for _, pkg := range packages {
+		inCache, err := pkg.IsInBinaryCache()
+		if err != nil {
+			return nil, err
+		}
+		plan := PackagePlan{Package: pkg.Raw, FromCache: inCache}
+		if inCache {
+			if plan.DownloadSize, err = pkg.NarDownloadSize(); err != nil {
+				return nil, err
+			}
+		}
+		plans = append(plans, plan)
+	}
+	return plans, nil
+}
+
+// ExtraneousPackages returns the store paths in the project's Nix profile that
+// don't belong to any installable package. These are the entries that are
+// purged the next time the profile is synced. A profile entry is kept if any of
+// its store paths is locked for a package, or if it was installed from the
+// package's flake reference.
+func (d *Devbox) ExtraneousPackages(ctx context.Context) ([]string, error) {
+	defer trace.StartRegion(ctx, "devboxExtraneousPackages").End()
+
+	profilePath := filepath.Join(d.projectDir, nix.ProfilePath)
+	if !fileutil.Exists(profilePath) {
+		return []string{}, nil
+	}
+	items, err := d.profileListItems(profilePath)
+	if err != nil {
+		return nil, err
+	}
+
+	packages := d.PackagesByType(pkgtype.Nix)
+	locked := map[string]bool{}
+	for _, pkg := range packages {
+		storePaths, err := pkg.GetResolvedStorePaths()
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range storePaths {
+			locked[p] = true
+		}
+	}
+
+	extraneous := []string{}
+	for _, item := range items {
+		if lo.SomeBy(item.StorePaths(), func(p string) bool { return locked[p] }) {
+			continue
+		}
+		if lo.SomeBy(packages, func(pkg *devpkg.Package) bool { return item.Matches(pkg, d.lockfile) }) {
+			continue
+		}
+		extraneous = append(extraneous, item.StorePaths()...)
+	}
+	slices.Sort(extraneous)
+	return extraneous, nil
+}
+
+// EstimateClosureSize returns roughly how many bytes installing pkgs will add
+// to the Nix store. It sums the uncompressed NAR sizes, as reported by the
+// binary cache, of the packages' outputs that aren't in the store yet. It's an
+// underestimate: outputs that aren't in a binary cache and the packages'
+// runtime dependencies aren't counted.
+func (d *Devbox) EstimateClosureSize(ctx context.Context, pkgs ...*devpkg.Package) (int64, error) {
+	defer trace.StartRegion(ctx, "devboxEstimateClosureSize").End()
+
+	pkgs = lo.Filter(pkgs, devpkg.IsNix)
+	if err := devpkg.FillNarInfoCache(ctx, pkgs...); err != nil {
+		return 0, err
+	}
+	sizes := map[string]int64{}
+	for _, pkg := range pkgs {
+		pkgSizes, err := pkg.NarSizes()
+		if err != nil {
+			return 0, err
+		}
+		maps.Copy(sizes, pkgSizes)
+	}
+	inStore, err := nix.StorePathsAreInStore(ctx, d.storeRoot, lo.Keys(sizes))
+	if err != nil {
+		return 0, err
+	}
+
+	var total int64
+	for path, size := range sizes {
+		if !inStore[path] {
+			total += size
+		}
+	}
+	return total, nil
+}
+
+// formatBytes formats a size in bytes for humans, such as "1.5 GiB".
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit && exp < 4; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTP"[exp])
+}
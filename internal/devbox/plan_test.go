@@ -0,0 +1,133 @@
+package devbox
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.jetpack.io/devbox/internal/lock"
+	"go.jetpack.io/devbox/internal/nix"
+)
+
+func TestFormatBytes(t *testing.T) {
+	testCases := map[int64]string{
+		0:                       "0 B",
+		1023:                    "1023 B",
+		1024:                    "1.0 KiB",
+		1536:                    "1.5 KiB",
+		5 << 20:                 "5.0 MiB",
+		largeInstallWarningSize: "2.0 GiB",
+	}
+	for n, want := range testCases {
+		if got := formatBytes(n); got != want {
+			t.Errorf("formatBytes(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
+
+func TestDescribeChanges(t *testing.T) {
+	storePath := "/nix/store/aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa-hello-2.12"
+	// A fake nix that reports the package as already in the store, so
+	// nothing needs to be installed and no binary cache is queried.
+	nix.FakeForTest(t, `case "$*" in
+  *--version*) echo "nix (Nix) 2.24.0";;
+  *path-info*) echo '{"`+storePath+`": {"narHash": "sha256-abc"}}';;
+  *) exit 1;;
+esac
+`)
+
+	d := devboxForTesting(t)
+	d.cfg.PackageMutator().Add("hello@2.12")
+	d.lockfile.Packages["hello@2.12"] = &lock.Package{
+		Resolved: "github:NixOS/nixpkgs/abc#hello",
+		Version:  "2.12",
+		Systems: map[string]*lock.SystemInfo{
+			nix.System(): {Outputs: []lock.Output{{Name: "out", Path: storePath, Default: true}}},
+		},
+	}
+
+	// The profile doesn't exist yet, so the package's store path is added
+	// to it when the environment is recomputed.
+	plan, err := d.DescribeChanges(context.Background(), ensure)
+	require.NoError(t, err)
+	require.False(t, plan.UpToDate)
+	require.Empty(t, plan.StorePackages)
+	require.Empty(t, plan.RunXPackages)
+	require.Empty(t, plan.Plugins)
+	require.True(t, plan.RecomputeEnv)
+	require.Equal(t, []string{storePath}, plan.ProfileAdd)
+	require.Empty(t, plan.ProfileRemove)
+	require.False(t, plan.IsEmpty())
+
+	// skipPluginGen only syncs the lockfile.
+	plan, err = d.DescribeChanges(context.Background(), skipPluginGen)
+	require.NoError(t, err)
+	require.True(t, plan.IsEmpty())
+}
+
+func TestDescribeChangesKeepsLockfile(t *testing.T) {
+	storePath := "/nix/store/aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa-hello-2.12"
+	nix.FakeForTest(t, `case "$*" in
+  *--version*) echo "nix (Nix) 2.24.0";;
+  *path-info*) echo '{"`+storePath+`": {"narHash": "sha256-abc"}}';;
+  *) exit 1;;
+esac
+`)
+
+	// A failed install left hello in the install checkpoint, but not in
+	// the lockfile. Describing the changes restores it in order to check
+	// the store, which must not leak into the lockfile.
+	d := devboxForTesting(t)
+	d.cfg.PackageMutator().Add("hello@2.12")
+	d.lockfile.Packages["hello@2.12"] = &lock.Package{
+		Resolved: "github:NixOS/nixpkgs/abc#hello",
+		Version:  "2.12",
+		Systems: map[string]*lock.SystemInfo{
+			nix.System(): {Outputs: []lock.Output{{Name: "out", Path: storePath, Default: true}}},
+		},
+	}
+	require.NoError(t, d.checkpointStoredPackages(d.AllPackages()))
+	delete(d.lockfile.Packages, "hello@2.12")
+
+	plan, err := d.DescribeChanges(context.Background(), ensure)
+	require.NoError(t, err)
+	require.Equal(t, []string{storePath}, plan.ProfileAdd)
+	require.NotContains(t, d.lockfile.Packages, "hello@2.12")
+}
+
+func TestExtraneousPackages(t *testing.T) {
+	hello := "/nix/store/aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa-hello-2.12"
+	cowsay := "/nix/store/bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb-cowsay-3.7.0"
+	// A fake nix with a profile that has the locked hello package and a
+	// cowsay package that isn't in the config.
+	nix.FakeForTest(t, `case "$*" in
+  *--version*) echo "nix (Nix) 2.24.0";;
+  *"profile list"*) echo '{"elements": {
+    "hello": {"storePaths": ["`+hello+`"]},
+    "cowsay": {"originalUrl": "flake:nixpkgs", "attrPath": "legacyPackages.x86_64-linux.cowsay", "storePaths": ["`+cowsay+`"]}
+  }, "version": 3}';;
+  *) exit 1;;
+esac
+`)
+
+	d := devboxForTesting(t)
+	got, err := d.ExtraneousPackages(context.Background())
+	require.NoError(t, err)
+	require.Empty(t, got, "a project without a profile has nothing to purge")
+
+	d.cfg.PackageMutator().Add("hello@2.12")
+	d.lockfile.Packages["hello@2.12"] = &lock.Package{
+		Resolved: "github:NixOS/nixpkgs/abc#hello",
+		Version:  "2.12",
+		Systems: map[string]*lock.SystemInfo{
+			nix.System(): {Outputs: []lock.Output{{Name: "out", Path: hello, Default: true}}},
+		},
+	}
+	require.NoError(t, os.MkdirAll(filepath.Join(d.projectDir, nix.ProfilePath), 0o755))
+
+	got, err = d.ExtraneousPackages(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, []string{cowsay}, got)
+}
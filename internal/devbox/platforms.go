@@ -0,0 +1,87 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"bytes"
+	"context"
+	"runtime/trace"
+	"strings"
+
+	"github.com/samber/lo"
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+	"go.jetpack.io/devbox/internal/cachehash"
+	"go.jetpack.io/devbox/internal/devpkg"
+)
+
+// SetExcludedPlatforms replaces the platforms that the named package is
+// excluded from. An empty list clears them.
+//
+// Excluding or including other platforms than the current one doesn't change
+// the project's plugins or its environment on this machine, so in that case
+// only devbox.lock and the state hash are updated. Otherwise the project is
+// installed again, the same as after an update.
+func (d *Devbox) SetExcludedPlatforms(ctx context.Context, name string, platforms []string) error {
+	ctx, task := trace.NewTask(ctx, "devboxSetExcludedPlatforms")
+	defer task.End()
+
+	found := d.topLevelPackagesByName()[name]
+	if len(found) == 0 {
+		return usererr.New("Package %s not found in devbox.json", name)
+	}
+	if len(found) > 1 {
+		return usererr.New(
+			"Package %s matches more than one package in devbox.json. Use one of: %s",
+			name,
+			strings.Join(lo.Map(found, func(p *devpkg.Package, _ int) string { return p.Raw }), ", "),
+		)
+	}
+
+	// The shortcut is only safe if the state was up to date before the edit,
+	// since it marks the state as up to date afterwards.
+	upToDate, err := d.lockfile.IsUpToDateAndInstalled(isFishShell())
+	if err != nil {
+		return err
+	}
+	before, err := d.pluginGenInputs()
+	if err != nil {
+		return err
+	}
+	if err := d.cfg.PackageMutator().SetExcludedPlatforms(d.stderr, found[0].Raw, platforms); err != nil {
+		return err
+	}
+	after, err := d.pluginGenInputs()
+	if err != nil {
+		return err
+	}
+
+	mode := update
+	if upToDate && before == after {
+		mode = skipPluginGen
+	}
+	if err := d.ensureStateIsUpToDate(ctx, mode); err != nil {
+		return err
+	}
+	return d.saveCfg()
+}
+
+// pluginGenInputs returns a hash of everything on the current platform that
+// plugin files and the generated environment depend on: the installable
+// packages and the included plugin configs. An edit that doesn't change it can
+// use skipPluginGen.
+func (d *Devbox) pluginGenInputs() (string, error) {
+	buf := bytes.Buffer{}
+	for _, pkg := range d.InstallablePackages() {
+		buf.WriteString(pkg.Raw)
+		buf.WriteString(pkg.Hash())
+	}
+	for _, pluginConfig := range d.cfg.IncludedPluginConfigs() {
+		h, err := pluginConfig.Hash()
+		if err != nil {
+			return "", err
+		}
+		buf.WriteString(h)
+	}
+	return cachehash.Bytes(buf.Bytes()), nil
+}
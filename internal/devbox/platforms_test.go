@@ -0,0 +1,33 @@
+package devbox
+
+import (
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.jetpack.io/devbox/internal/nix"
+)
+
+func TestPluginGenInputs(t *testing.T) {
+	d := devboxForTesting(t)
+	d.cfg.PackageMutator().Add("hello@2.12")
+	d.cfg.PackageMutator().Add("jq@1.7")
+	before, err := d.pluginGenInputs()
+	require.NoError(t, err)
+
+	// Excluding another platform doesn't change anything on this one.
+	other := "aarch64-darwin"
+	if nix.System() == other {
+		other = "x86_64-linux"
+	}
+	require.NoError(t, d.cfg.PackageMutator().SetExcludedPlatforms(io.Discard, "jq@1.7", []string{other}))
+	after, err := d.pluginGenInputs()
+	require.NoError(t, err)
+	require.Equal(t, before, after)
+
+	// Excluding this platform removes jq from the environment.
+	require.NoError(t, d.cfg.PackageMutator().SetExcludedPlatforms(io.Discard, "jq@1.7", []string{nix.System()}))
+	after, err = d.pluginGenInputs()
+	require.NoError(t, err)
+	require.NotEqual(t, before, after)
+}
@@ -0,0 +1,41 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"log/slog"
+
+	"go.jetpack.io/devbox/internal/devbox/devopt"
+)
+
+// Steps reported to a devopt.ProgressReporter by ensureStateIsUpToDate, in
+// the order they run.
+const (
+	ProgressStepValidate    = "validate"
+	ProgressStepPlugins     = "plugins"
+	ProgressStepInstall     = "install"
+	ProgressStepGenerate    = "generate"
+	ProgressStepComputeEnv  = "computeEnv"
+	ProgressStepSyncProfile = "syncNixProfile"
+	ProgressStepVerify      = "verify"
+	ProgressStepLockfile    = "lockfile"
+)
+
+// logProgress is the default devopt.ProgressReporter. Devbox already prints
+// messages for the steps that take a noticeable amount of time, so it only
+// logs the steps for debugging.
+type logProgress struct{}
+
+func (logProgress) Step(name string) { slog.Debug("devbox step started", "step", name) }
+func (logProgress) Done(name string) { slog.Debug("devbox step done", "step", name) }
+
+// startStep reports that the step name started and returns a function that
+// reports it's done. The returned function should only be called if the step
+// succeeds.
+func (d *Devbox) startStep(name string) func() {
+	d.progress.Step(name)
+	return func() { d.progress.Done(name) }
+}
+
+var _ devopt.ProgressReporter = logProgress{}
@@ -0,0 +1,32 @@
+package devbox
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type recordingProgress struct {
+	events []string
+}
+
+func (r *recordingProgress) Step(name string) { r.events = append(r.events, "step "+name) }
+func (r *recordingProgress) Done(name string) { r.events = append(r.events, "done "+name) }
+
+func TestEnsureStateReportsSteps(t *testing.T) {
+	d := devboxForTesting(t)
+	reporter := &recordingProgress{}
+	d.progress = reporter
+	require.NoError(t, os.MkdirAll(filepath.Join(d.projectDir, ".devbox"), 0o755))
+
+	require.NoError(t, d.ensureStateIsUpToDate(context.Background(), skipPluginGen))
+	require.Equal(t, []string{
+		"step " + ProgressStepValidate,
+		"done " + ProgressStepValidate,
+		"step " + ProgressStepLockfile,
+		"done " + ProgressStepLockfile,
+	}, reporter.events)
+}
@@ -0,0 +1,79 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"context"
+	"runtime/trace"
+
+	"github.com/samber/lo"
+	"go.jetpack.io/devbox/internal/nix"
+	"go.jetpack.io/devbox/internal/ux"
+)
+
+// PruneStore reclaims the space used by the old generations of the project's
+// Nix profiles, including the profiles of groups, without a global garbage
+// collection, which would also delete the unused paths of every other project.
+//
+// It deletes the profiles' old generations and then the store paths that only
+// they referenced. Paths in the closure of a current generation are never
+// deleted, and neither are paths that are still alive because another profile
+// or garbage collector root needs them.
+func (d *Devbox) PruneStore(ctx context.Context) error {
+	defer trace.StartRegion(ctx, "devboxPruneStore").End()
+
+	profilePaths, err := d.projectProfilePaths()
+	if err != nil {
+		return err
+	}
+	var current, old, pruned []string
+	for _, profilePath := range profilePaths {
+		profileCurrent, profileOld, err := nix.ProfileGenerations(profilePath)
+		if err != nil {
+			return err
+		}
+		if profileCurrent != "" {
+			current = append(current, profileCurrent)
+		}
+		if profileCurrent != "" && len(profileOld) > 0 {
+			old = append(old, profileOld...)
+			pruned = append(pruned, profilePath)
+		}
+	}
+	if len(old) == 0 {
+		ux.Finfo(d.stderr, "The Nix profiles have no old generations to prune.\n")
+		return nil
+	}
+
+	// The closures of every profile's generations are computed together, so
+	// that a path an old generation of one profile shares with the current
+	// generation of another isn't a candidate.
+	oldClosure, err := nix.StorePathClosure(ctx, d.storeRoot, old)
+	if err != nil {
+		return err
+	}
+	currentClosure, err := nix.StorePathClosure(ctx, d.storeRoot, current)
+	if err != nil {
+		return err
+	}
+	candidates := lo.Without(oldClosure, currentClosure...)
+
+	// The old generations are garbage collector roots, so they must be gone
+	// before the paths they reference can be deleted.
+	for _, profilePath := range pruned {
+		if err := nix.ProfileWipeHistory(ctx, d.storeRoot, profilePath); err != nil {
+			return err
+		}
+	}
+	deleted, err := nix.DeleteDeadStorePaths(ctx, d.storeRoot, candidates)
+	if err != nil {
+		return err
+	}
+	ux.Finfo(
+		d.stderr,
+		"Removed %d old Nix profile generations and deleted %d store paths.\n",
+		len(old), len(deleted),
+	)
+	return nil
+}
@@ -0,0 +1,67 @@
+package devbox
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.jetpack.io/devbox/internal/nix"
+)
+
+// writeProfileGenerations creates a fake Nix profile whose generations link
+// to the given store paths. The last one is the current generation.
+func writeProfileGenerations(t *testing.T, profilePath string, storePaths ...string) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(filepath.Dir(profilePath), 0o755))
+	name := filepath.Base(profilePath)
+	for i, storePath := range storePaths {
+		link := filepath.Join(filepath.Dir(profilePath), fmt.Sprintf("%s-%d-link", name, i+1))
+		require.NoError(t, os.Symlink(storePath, link))
+	}
+	current := fmt.Sprintf("%s-%d-link", name, len(storePaths))
+	require.NoError(t, os.Symlink(current, profilePath))
+}
+
+func TestPruneStoreIncludesGroupProfiles(t *testing.T) {
+	log := filepath.Join(t.TempDir(), "calls")
+	// path-info treats every path as its own closure, and nix-store reports
+	// the old dev generation as still alive.
+	bin := nix.FakeForTest(t, `echo "nix $*" >> `+log+`
+case "$*" in
+*path-info*) for arg in "$@"; do case "$arg" in /nix/store/*) echo "$arg";; esac; done ;;
+esac
+`)
+	nixStoreScript := `#!/bin/sh
+echo "nix-store $*" >> ` + log + `
+echo /nix/store/dev-old
+`
+	require.NoError(t, os.WriteFile(filepath.Join(bin, "nix-store"), []byte(nixStoreScript), 0o755))
+
+	d := devboxForTesting(t)
+	d.stderr = io.Discard
+	writeProfileGenerations(t, filepath.Join(d.projectDir, nix.ProfilePath), "/nix/store/default-old", "/nix/store/default-cur")
+	devProfile, err := d.groupProfilePath("dev")
+	require.NoError(t, err)
+	writeProfileGenerations(t, devProfile, "/nix/store/dev-old", "/nix/store/dev-cur")
+
+	require.NoError(t, d.PruneStore(context.Background()))
+
+	data, err := os.ReadFile(log)
+	require.NoError(t, err)
+	var wiped, deletes []string
+	for _, call := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		if _, profile, ok := strings.Cut(call, "wipe-history --profile "); ok {
+			wiped = append(wiped, profile)
+		}
+		if _, paths, ok := strings.Cut(call, "store delete "); ok {
+			deletes = append(deletes, paths)
+		}
+	}
+	require.ElementsMatch(t, []string{filepath.Join(d.projectDir, nix.ProfilePath), devProfile}, wiped)
+	require.Equal(t, []string{"/nix/store/default-old"}, deletes)
+}
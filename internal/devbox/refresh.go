@@ -3,13 +3,44 @@ package devbox
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
+
+	"go.jetpack.io/devbox/internal/devconfig/configfile"
+	"go.jetpack.io/devbox/internal/envir"
 )
 
 func (d *Devbox) IsDirenvActive() bool {
 	return strings.TrimPrefix(os.Getenv("DIRENV_DIR"), "-") == d.projectDir
 }
 
+// refreshWarningSuppressed returns true if the warning that the shell
+// environment is out of date is turned off. DEVBOX_SUPPRESS_REFRESH_WARNING
+// takes precedence over suppress_refresh_warning in the project's devbox.json,
+// which takes precedence over the global devbox.json.
+func (d *Devbox) refreshWarningSuppressed() bool {
+	if suppress, err := strconv.ParseBool(os.Getenv(envir.DevboxSuppressRefreshWarning)); err == nil {
+		return suppress
+	}
+	if suppress := d.cfg.Root.SuppressRefreshWarning; suppress != nil {
+		return *suppress
+	}
+	if d.isGlobal() {
+		return false
+	}
+	globalPath, err := GlobalDataPath()
+	if err != nil {
+		return false
+	}
+	data, err := os.ReadFile(filepath.Join(globalPath, configfile.DefaultName))
+	if err != nil {
+		return false
+	}
+	global, err := configfile.LoadBytes(data)
+	return err == nil && global.SuppressRefreshWarning != nil && *global.SuppressRefreshWarning
+}
+
 func (d *Devbox) isRefreshAliasSet() bool {
 	return os.Getenv(d.refreshAliasEnvVar()) == d.refreshCmd()
 }
@@ -23,6 +54,21 @@ func (d *Devbox) isGlobal() bool {
 	return d.projectDir == globalPath
 }
 
+// RefreshCommand returns the command to run in a devbox shell to refresh it
+// after the environment changes. That's the refresh alias if the shell has it
+// set, or otherwise the full refresh command for the current shell. It returns
+// an empty string if direnv is active for the project, since direnv reloads
+// the environment itself.
+func (d *Devbox) RefreshCommand() string {
+	if d.IsDirenvActive() {
+		return ""
+	}
+	if d.isRefreshAliasSet() {
+		return d.refreshAliasName()
+	}
+	return d.refreshCmd()
+}
+
 // In some cases (e.g. 2 non-global projects somehow active at the same time),
 // refresh might not match. This is a tiny edge case, so no need to make UX
 // great, we just print out the entire command.
@@ -0,0 +1,44 @@
+package devbox
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+	"go.jetpack.io/devbox/internal/envir"
+)
+
+func TestRefreshWarningSuppressed(t *testing.T) {
+	t.Setenv(envir.XDGDataHome, t.TempDir())
+	d := devboxForTesting(t)
+	require.False(t, d.refreshWarningSuppressed())
+
+	globalPath, err := GlobalDataPath()
+	require.NoError(t, err)
+	err = os.WriteFile(filepath.Join(globalPath, "devbox.json"), []byte(`{"suppress_refresh_warning": true}`), 0o644)
+	require.NoError(t, err)
+	require.True(t, d.refreshWarningSuppressed())
+
+	// The project's devbox.json overrides the global one.
+	d.cfg.Root.SuppressRefreshWarning = lo.ToPtr(false)
+	require.False(t, d.refreshWarningSuppressed())
+
+	d.cfg.Root.SuppressRefreshWarning = nil
+	t.Setenv(envir.DevboxSuppressRefreshWarning, "false")
+	require.False(t, d.refreshWarningSuppressed())
+}
+
+func TestRefreshCommand(t *testing.T) {
+	d := devboxForTesting(t)
+	t.Setenv("DIRENV_DIR", "")
+	t.Setenv(d.refreshAliasEnvVar(), "")
+	require.Equal(t, d.refreshCmd(), d.RefreshCommand())
+
+	t.Setenv(d.refreshAliasEnvVar(), d.refreshCmd())
+	require.Equal(t, "refresh", d.RefreshCommand())
+
+	t.Setenv("DIRENV_DIR", "-"+d.projectDir)
+	require.Empty(t, d.RefreshCommand())
+}
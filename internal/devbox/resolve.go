@@ -0,0 +1,30 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"context"
+	"runtime/trace"
+)
+
+// ResolveLock locks every package in the project, including the ones that
+// aren't installed on this platform, and saves devbox.lock. Unlike Install,
+// nothing is built, installed or added to the environment, so it's a quick way
+// to pin the project before committing it.
+//
+// Packages that are already locked keep their entries, so it works offline
+// unless a new versioned package has to be looked up in the search service.
+// The environment is brought up to date the next time it's used.
+func (d *Devbox) ResolveLock(ctx context.Context) error {
+	defer trace.StartRegion(ctx, "devboxResolveLock").End()
+
+	for _, pkg := range d.AllPackages() {
+		if _, err := d.lockfile.Resolve(pkg.Raw); err != nil {
+			return err
+		}
+	}
+	// updateLockfile also removes stale entries and locks the platform
+	// alternatives and plugin versions.
+	return d.updateLockfile(false /*recomputeState*/)
+}
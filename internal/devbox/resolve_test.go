@@ -0,0 +1,30 @@
+package devbox
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.jetpack.io/devbox/internal/devbox/devopt"
+	"go.jetpack.io/devbox/internal/lock"
+)
+
+func TestResolveLock(t *testing.T) {
+	d := devboxForTesting(t)
+	d.cfg.PackageMutator().Add("hello")
+	locked := &lock.Package{Resolved: "github:NixOS/nixpkgs/abc#go", Version: "1.22.5"}
+	d.lockfile.Packages["go@1.22"] = locked
+	d.cfg.PackageMutator().Add("go@1.22")
+	d.lockfile.Packages["stale@1.0"] = &lock.Package{Resolved: "github:NixOS/nixpkgs/abc#stale"}
+
+	require.NoError(t, d.ResolveLock(context.Background()))
+
+	// The lockfile on disk has the new and existing packages, without the
+	// stale one.
+	d, err := Open(&devopt.Opts{Dir: d.projectDir, Stderr: os.Stderr})
+	require.NoError(t, err)
+	require.Equal(t, d.lockfile.LegacyNixpkgsPath("hello"), d.lockfile.Get("hello").Resolved)
+	require.Equal(t, locked.Resolved, d.lockfile.Get("go@1.22").Resolved)
+	require.Nil(t, d.lockfile.Get("stale@1.0"))
+}
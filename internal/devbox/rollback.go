@@ -0,0 +1,97 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	stderrors "errors"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"go.jetpack.io/devbox/internal/devconfig/configfile"
+	"go.jetpack.io/devbox/internal/lock"
+)
+
+// configSnapshot is a copy of devbox.json and devbox.lock, both in memory and
+// on disk, taken before an operation that changes them so that they can be
+// restored if it fails. See snapshotConfig.
+type configSnapshot struct {
+	// cfg is devbox.json as it's in memory, including changes that haven't
+	// been saved yet.
+	cfg []byte
+	// cfgFile and lockFile are the files' contents on disk. lockFile is nil
+	// if the project didn't have a devbox.lock.
+	cfgFile  []byte
+	lockFile []byte
+	// locked is the lockfile's packages in memory.
+	locked map[string]*lock.Package
+}
+
+func (d *Devbox) snapshotConfig() (*configSnapshot, error) {
+	cfgFile, err := os.ReadFile(d.cfg.Root.AbsRootPath)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	lockFile, err := os.ReadFile(d.lockfilePath())
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, errors.WithStack(err)
+	}
+	return &configSnapshot{
+		cfg:      d.cfg.Root.Bytes(),
+		cfgFile:  cfgFile,
+		lockFile: lockFile,
+		locked:   maps.Clone(d.lockfile.Packages),
+	}, nil
+}
+
+// restoreConfig undoes the changes to devbox.json and devbox.lock since
+// snapshot was taken, in memory and on disk. The Nix profile may already have
+// been changed, so the state is marked as stale and the next command that
+// uses the environment installs the restored packages again.
+func (d *Devbox) restoreConfig(snapshot *configSnapshot) error {
+	root, err := configfile.LoadBytes(snapshot.cfg)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	root.AbsRootPath = d.cfg.Root.AbsRootPath
+	d.cfg.Root = *root
+	d.lockfile.Packages = maps.Clone(snapshot.locked)
+	d.profileItems = nil
+
+	errs := []error{
+		os.WriteFile(d.cfg.Root.AbsRootPath, snapshot.cfgFile, 0o644),
+		lock.InvalidateStateHashFile(d.projectDir),
+		d.cfg.LoadRecursive(d.lockfile),
+	}
+	if snapshot.lockFile != nil {
+		errs = append(errs, os.WriteFile(d.lockfilePath(), snapshot.lockFile, 0o644))
+	} else if err := os.Remove(d.lockfilePath()); !errors.Is(err, fs.ErrNotExist) {
+		errs = append(errs, err)
+	}
+	return errors.WithStack(stderrors.Join(errs...))
+}
+
+// withConfigRollback runs fn and restores devbox.json and devbox.lock to how
+// they were before it if it fails, so that a failed operation doesn't leave
+// them partially changed.
+func (d *Devbox) withConfigRollback(fn func() error) error {
+	snapshot, err := d.snapshotConfig()
+	if err != nil {
+		return err
+	}
+	err = fn()
+	if err == nil {
+		return nil
+	}
+	if restoreErr := d.restoreConfig(snapshot); restoreErr != nil {
+		d.warn(WarningRollback, "Failed to restore devbox.json and devbox.lock after an error: %v\n", restoreErr)
+	}
+	return err
+}
+
+func (d *Devbox) lockfilePath() string {
+	return filepath.Join(d.projectDir, "devbox.lock")
+}
@@ -0,0 +1,74 @@
+package devbox
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.jetpack.io/devbox/internal/devbox/devopt"
+	"go.jetpack.io/devbox/internal/lock"
+)
+
+func TestAddRestoresConfigOnFailure(t *testing.T) {
+	d := devboxForTesting(t)
+	d.stderr = io.Discard
+	d.cfg.PackageMutator().Add("hello@1.2.3")
+	d.lockfile.Packages["hello@1.2.3"] = &lock.Package{
+		Resolved: "github:NixOS/nixpkgs/aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa#hello",
+		Source:   "devbox-search",
+		Version:  "1.2.3",
+	}
+	require.NoError(t, d.saveCfg())
+	require.NoError(t, d.lockfile.Save())
+
+	cfgPath := filepath.Join(d.projectDir, "devbox.json")
+	lockPath := filepath.Join(d.projectDir, "devbox.lock")
+	wantCfg, err := os.ReadFile(cfgPath)
+	require.NoError(t, err)
+	wantLock, err := os.ReadFile(lockPath)
+	require.NoError(t, err)
+
+	// A directory where the state file should be makes syncing the state
+	// fail after the package's options were changed.
+	require.NoError(t, os.MkdirAll(filepath.Join(d.projectDir, ".devbox", "state.json"), 0o755))
+
+	_, err = d.AddWithResult(context.Background(), []string{"hello@1.2.3"}, devopt.AddOpts{
+		Platforms: []string{"x86_64-linux"},
+	})
+	require.Error(t, err)
+
+	gotCfg, err := os.ReadFile(cfgPath)
+	require.NoError(t, err)
+	require.Equal(t, string(wantCfg), string(gotCfg))
+	gotLock, err := os.ReadFile(lockPath)
+	require.NoError(t, err)
+	require.Equal(t, string(wantLock), string(gotLock))
+
+	// The in-memory config is restored too.
+	require.Empty(t, d.cfg.Root.TopLevelPackages()[0].Platforms)
+	require.Equal(t, string(wantCfg), string(d.cfg.Root.Bytes()))
+}
+
+func TestAddKeepsConfigWhenGitCommitFails(t *testing.T) {
+	d := devboxForTesting(t)
+	d.stderr = io.Discard
+	d.cfg.PackageMutator().Add("hello@1.2.3")
+	require.NoError(t, d.saveCfg())
+
+	// An invalid message template makes the commit fail after the package
+	// was updated.
+	_, err := d.AddWithResult(context.Background(), []string{"hello@1.2.3"}, devopt.AddOpts{
+		SkipInstall:   true,
+		Platforms:     []string{"x86_64-linux"},
+		GitCommit:     true,
+		CommitMessage: "{{.Action",
+	})
+	require.Error(t, err)
+	require.Equal(t, []string{"x86_64-linux"}, d.cfg.Root.TopLevelPackages()[0].Platforms)
+	cfg, err := os.ReadFile(filepath.Join(d.projectDir, "devbox.json"))
+	require.NoError(t, err)
+	require.Contains(t, string(cfg), "x86_64-linux")
+}
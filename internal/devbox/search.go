@@ -0,0 +1,102 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"context"
+	"runtime/trace"
+	"strings"
+
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+	"go.jetpack.io/devbox/internal/nix"
+	"go.jetpack.io/devbox/internal/searcher"
+)
+
+// SearchResult is a package found by Devbox.Search.
+type SearchResult struct {
+	// Name is the package's name, which can be added as name@version.
+	Name string `json:"name"`
+
+	// Versions are the versions that can be installed on the current
+	// system, in the order of the search index (newest first).
+	Versions []string `json:"versions"`
+
+	// Description is the summary of the newest version.
+	Description string `json:"description,omitempty"`
+
+	// NixpkgsVersion is the version in the project's nixpkgs commit, if
+	// the search index has it. It's the version that adding the package
+	// resolves to when it falls back to the project's nixpkgs.
+	NixpkgsVersion string `json:"nixpkgs_version,omitempty"`
+
+	// LockedVersion is the version that devbox.lock has for the package if
+	// it's already in devbox.json.
+	LockedVersion string `json:"locked_version,omitempty"`
+}
+
+// Search queries the search index for packages matching query, such as "go"
+// or "python3". Unlike searching the index directly, the results are for this
+// project: versions that can't be installed on the current system are left
+// out, since Add would reject them, and each result notes the version in the
+// project's nixpkgs commit and in devbox.lock.
+func (d *Devbox) Search(ctx context.Context, query string) ([]SearchResult, error) {
+	defer trace.StartRegion(ctx, "devboxSearch").End()
+
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil, usererr.New("The search query can't be empty.")
+	}
+	found, err := searcher.Client().Search(query)
+	if err != nil {
+		return nil, usererr.WithUserMessage(err, "Failed to search for %q.", query)
+	}
+
+	results := projectSearchResults(found, nix.System(), d.lockfile.NixPkgsCommitHash())
+	for i := range results {
+		pkg, err := d.findPackageByName(results[i].Name)
+		if err != nil {
+			continue
+		}
+		if entry := d.lockfile.Get(pkg.Raw); entry != nil {
+			results[i].LockedVersion = entry.Version
+		}
+	}
+	return results, nil
+}
+
+// projectSearchResults converts the search index's results to SearchResults
+// for a project on system with the given nixpkgs commit. Packages without any
+// version for system are left out.
+func projectSearchResults(found *searcher.SearchResults, system, nixpkgsCommit string) []SearchResult {
+	results := []SearchResult{}
+	for _, pkg := range found.Packages {
+		result := SearchResult{Name: pkg.Name, Versions: []string{}}
+		for _, v := range pkg.Versions {
+			if v.Version == "" {
+				continue
+			}
+			// Versions without per-system info are assumed to be available
+			// everywhere.
+			info, ok := v.Systems[system]
+			if len(v.Systems) > 0 && !ok {
+				continue
+			}
+			commit := v.CommitHash
+			if info.CommitHash != "" {
+				commit = info.CommitHash
+			}
+			if len(result.Versions) == 0 {
+				result.Description = v.Summary
+			}
+			result.Versions = append(result.Versions, v.Version)
+			if result.NixpkgsVersion == "" && commit != "" && commit == nixpkgsCommit {
+				result.NixpkgsVersion = v.Version
+			}
+		}
+		if len(result.Versions) > 0 {
+			results = append(results, result)
+		}
+	}
+	return results
+}
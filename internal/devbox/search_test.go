@@ -0,0 +1,42 @@
+package devbox
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.jetpack.io/devbox/internal/searcher"
+)
+
+func TestProjectSearchResults(t *testing.T) {
+	version := func(v, commit, summary string, systems ...string) searcher.PackageVersion {
+		pv := searcher.PackageVersion{
+			PackageInfo: searcher.PackageInfo{Version: v, CommitHash: commit, Summary: summary},
+		}
+		if len(systems) > 0 {
+			pv.Systems = map[string]searcher.PackageInfo{}
+			for _, s := range systems {
+				pv.Systems[s] = searcher.PackageInfo{}
+			}
+		}
+		return pv
+	}
+	found := &searcher.SearchResults{Packages: []searcher.Package{
+		{Name: "go", Versions: []searcher.PackageVersion{
+			version("1.23.0", "c3", "Go 1.23", "aarch64-darwin"),
+			version("1.22.5", "c2", "Go 1.22", "x86_64-linux", "aarch64-darwin"),
+			version("1.21.6", "c1", "Go 1.21"),
+			version("", "c0", ""),
+		}},
+		{Name: "darwin-only", Versions: []searcher.PackageVersion{
+			version("1.0", "c1", "", "aarch64-darwin"),
+		}},
+	}}
+
+	results := projectSearchResults(found, "x86_64-linux", "c1")
+	require.Equal(t, []SearchResult{{
+		Name:           "go",
+		Versions:       []string{"1.22.5", "1.21.6"},
+		Description:    "Go 1.22",
+		NixpkgsVersion: "1.21.6",
+	}}, results)
+}
@@ -0,0 +1,100 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"context"
+	"fmt"
+	"runtime/trace"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/samber/lo"
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+	"go.jetpack.io/devbox/internal/devpkg"
+	"go.jetpack.io/devbox/internal/lock"
+	"go.jetpack.io/devbox/internal/nix"
+)
+
+// SetNixpkgs pins the whole project to a nixpkgs commit, such as to roll back
+// to an older, known-good commit. ref is a commit hash or a nixpkgs flake
+// reference with a commit, like "github:NixOS/nixpkgs/<commit>".
+//
+// Every nixpkgs package in the project is checked against the commit first.
+// If any of them doesn't exist there, or has a version there that doesn't
+// match the one requested in devbox.json, nothing is changed and the error
+// lists them. Otherwise, nixpkgs.commit is set in devbox.json, the packages
+// are locked to the commit, and the project is updated like Update does.
+// Flakes and runx packages aren't affected.
+func (d *Devbox) SetNixpkgs(ctx context.Context, ref string) error {
+	ctx, task := trace.NewTask(ctx, "devboxSetNixpkgs")
+	defer task.End()
+
+	commit, err := parseNixpkgsRef(ref)
+	if err != nil {
+		return err
+	}
+
+	pkgs := lo.Filter(d.AllPackages(), func(pkg *devpkg.Package, _ int) bool {
+		return pkg.IsDevboxPackage && !pkg.IsRunX()
+	})
+	versions := map[string]string{}
+	missing := []string{}
+	mismatched := []string{}
+	for _, pkg := range pkgs {
+		infos, err := nix.Search(lock.NixpkgsInstallable(commit, pkg.CanonicalName()))
+		if err != nil && !errors.Is(err, nix.ErrPackageNotFound) {
+			return usererr.WithUserMessage(err, "Unable to look up %s in nixpkgs commit %s.", pkg.Raw, commit)
+		}
+		if len(infos) == 0 {
+			missing = append(missing, pkg.Raw)
+			continue
+		}
+		for _, info := range infos {
+			versions[pkg.Raw] = info.Version
+			break
+		}
+		version := versions[pkg.Raw]
+		_, requested, _ := strings.Cut(pkg.Raw, "@")
+		if requested != "" && requested != "latest" && version != "" && !versionMatchesRequested(version, requested) {
+			mismatched = append(mismatched, fmt.Sprintf("%s (%s)", pkg.Raw, version))
+		}
+	}
+	if len(missing) > 0 {
+		return usererr.New(
+			"Packages %s don't exist in nixpkgs commit %s. Remove them or choose another commit.",
+			strings.Join(missing, ", "), commit,
+		)
+	}
+	if len(mismatched) > 0 {
+		return usererr.New(
+			"Packages %s have other versions in nixpkgs commit %s. Change their versions or choose another commit.",
+			strings.Join(mismatched, ", "), commit,
+		)
+	}
+
+	d.cfg.Root.SetNixpkgsCommit(commit)
+	for _, pkg := range pkgs {
+		d.lockfile.LockToNixpkgs(pkg.Raw, versions[pkg.Raw])
+	}
+
+	if err := d.ensureStateIsUpToDate(ctx, update); err != nil {
+		return err
+	}
+	return d.saveCfg()
+}
+
+// parseNixpkgsRef returns the commit of a nixpkgs commit hash or flake
+// reference.
+func parseNixpkgsRef(ref string) (string, error) {
+	commit := ref
+	if hash := nix.HashFromNixPkgsURL(ref); hash != "" {
+		commit = hash
+	}
+	if !commitHashRegex.MatchString(commit) {
+		return "", usererr.New(
+			"Expected a 40 character nixpkgs commit hash or a github:NixOS/nixpkgs/<commit> reference, got %q", ref)
+	}
+	return commit, nil
+}
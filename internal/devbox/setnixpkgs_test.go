@@ -0,0 +1,68 @@
+package devbox
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.jetpack.io/devbox/internal/nix"
+)
+
+func TestParseNixpkgsRef(t *testing.T) {
+	const commit = "af9e00071d0971eb292fd5abef334e66eda3cb69"
+	for _, ref := range []string{commit, "github:NixOS/nixpkgs/" + commit, "github:nixos/nixpkgs/" + commit + "#hello"} {
+		got, err := parseNixpkgsRef(ref)
+		require.NoError(t, err, ref)
+		require.Equal(t, commit, got)
+	}
+	for _, ref := range []string{"", "af9e000", "github:NixOS/nixpkgs/nixos-unstable"} {
+		_, err := parseNixpkgsRef(ref)
+		require.Error(t, err, ref)
+	}
+}
+
+func TestLockToNixpkgs(t *testing.T) {
+	d := devboxForTesting(t)
+	d.cfg.Root.SetNixpkgsCommit("af9e00071d0971eb292fd5abef334e66eda3cb69")
+	entry := d.lockfile.LockToNixpkgs("go@1.21", "1.21.4")
+	require.Equal(t, "github:NixOS/nixpkgs/af9e00071d0971eb292fd5abef334e66eda3cb69#go", entry.Resolved)
+	require.Equal(t, "1.21.4", entry.Version)
+
+	locked, err := d.lockfile.Resolve("go@1.21")
+	require.NoError(t, err)
+	require.Equal(t, entry, locked)
+}
+
+func TestSetNixpkgsChecksPackages(t *testing.T) {
+	const commit = "af9e00071d0971eb292fd5abef334e66eda3cb69"
+	// A fake nix that has go 1.22.5 in the commit, doesn't have missing,
+	// and can't download nixpkgs for unreachable.
+	nix.FakeForTest(t, `case "$*" in
+  *--version*) echo "nix (Nix) 2.24.0";;
+  *search*#missing*) echo "error: flake 'github:NixOS/nixpkgs/x' does not provide attribute 'missing'" >&2; exit 1;;
+  *search*#unreachable*) echo "error: unable to download 'https://github.com/NixOS/nixpkgs': Could not resolve host" >&2; exit 1;;
+  *search*) echo '{"legacyPackages.x86_64-linux.go":{"pname":"go","version":"1.22.5","description":""}}';;
+  *) exit 1;;
+esac
+`)
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	for _, c := range []struct {
+		pkgs    []string
+		wantErr string
+	}{
+		{[]string{"go@1.22", "missing@1.0"}, "Packages missing@1.0 don't exist"},
+		{[]string{"go@1.21"}, "Packages go@1.21 (1.22.5) have other versions"},
+		{[]string{"unreachable@latest"}, "Unable to look up unreachable@latest"},
+	} {
+		d := devboxForTesting(t)
+		d.stderr = io.Discard
+		for _, pkg := range c.pkgs {
+			d.cfg.PackageMutator().Add(pkg)
+		}
+		err := d.SetNixpkgs(context.Background(), commit)
+		require.ErrorContains(t, err, c.wantErr)
+		require.NotEqual(t, commit, d.cfg.NixPkgsCommitHash(), "the commit mustn't change on errors")
+	}
+}
@@ -0,0 +1,199 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"runtime/trace"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/samber/lo"
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+	"go.jetpack.io/devbox/internal/devbox/devopt"
+	"go.jetpack.io/devbox/internal/lock"
+)
+
+// envSnapshot is the file format written by SnapshotEnv.
+type envSnapshot struct {
+	CreatedAt time.Time                `json:"created_at"`
+	Env       map[string]string        `json:"env"`
+	Packages  []string                 `json:"packages"`
+	Lockfile  map[string]*lock.Package `json:"lockfile"`
+}
+
+// snapshotIgnoredEnv lists variables that change on every invocation and
+// would only add noise to a comparison.
+var snapshotIgnoredEnv = map[string]bool{
+	"DEVBOX_WD": true,
+	"PWD":       true,
+	"OLDPWD":    true,
+	"SHLVL":     true,
+	"_":         true,
+}
+
+// sensitiveEnvNameParts are parts of variable names that usually hold
+// credentials. See isSensitiveEnv.
+var sensitiveEnvNameParts = []string{
+	"TOKEN", "SECRET", "PASSWORD", "PASSWD", "CREDENTIAL", "API_KEY", "PRIVATE_KEY", "ACCESS_KEY",
+}
+
+// EnvVarChange is an environment variable whose value differs from the
+// snapshot.
+type EnvVarChange struct {
+	Old string
+	New string
+}
+
+// EnvDrift describes how the current environment differs from a snapshot
+// saved with SnapshotEnv.
+type EnvDrift struct {
+	// SnapshotTime is when the snapshot was taken.
+	SnapshotTime time.Time
+
+	AddedEnv   map[string]string
+	RemovedEnv map[string]string
+	ChangedEnv map[string]EnvVarChange
+
+	AddedPackages   []string
+	RemovedPackages []string
+
+	// ChangedLocks are the packages whose lockfile entry resolves to a
+	// different version or reference than in the snapshot.
+	ChangedLocks []string
+}
+
+// IsEmpty returns true if the environment hasn't changed since the snapshot.
+func (e *EnvDrift) IsEmpty() bool {
+	return len(e.AddedEnv) == 0 &&
+		len(e.RemovedEnv) == 0 &&
+		len(e.ChangedEnv) == 0 &&
+		len(e.AddedPackages) == 0 &&
+		len(e.RemovedPackages) == 0 &&
+		len(e.ChangedLocks) == 0
+}
+
+// SnapshotEnv writes the computed environment, the resolved package set and
+// the lockfile state to path, so that it can later be compared with
+// CompareEnvSnapshot. The environment is computed as for a --pure shell so
+// that unrelated variables from the caller's shell don't end up in the
+// snapshot.
+//
+// Variables that may hold secrets are left out (see isSensitiveEnv), and the
+// file is only readable by the user.
+func (d *Devbox) SnapshotEnv(ctx context.Context, path string) error {
+	defer trace.StartRegion(ctx, "devboxSnapshotEnv").End()
+
+	snapshot, err := d.takeEnvSnapshot(ctx)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o600); err != nil {
+		return errors.WithStack(err)
+	}
+	// WriteFile keeps the permissions of an existing file.
+	return errors.WithStack(os.Chmod(path, 0o600))
+}
+
+// CompareEnvSnapshot computes the current environment and reports how it
+// differs from the snapshot saved at path.
+func (d *Devbox) CompareEnvSnapshot(ctx context.Context, path string) (*EnvDrift, error) {
+	defer trace.StartRegion(ctx, "devboxCompareEnvSnapshot").End()
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, usererr.New("Environment snapshot %s does not exist.", path)
+	}
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	old := &envSnapshot{}
+	if err := json.Unmarshal(data, old); err != nil {
+		return nil, usererr.WithUserMessage(err, "Unable to parse environment snapshot %s.", path)
+	}
+
+	current, err := d.takeEnvSnapshot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return diffEnvSnapshots(old, current), nil
+}
+
+func (d *Devbox) takeEnvSnapshot(ctx context.Context) (*envSnapshot, error) {
+	env, err := d.computeEnv(ctx, true /*usePrintDevEnvCache*/, devopt.EnvOptions{Pure: true})
+	if err != nil {
+		return nil, err
+	}
+	env = lo.OmitBy(env, func(k, _ string) bool { return snapshotIgnoredEnv[k] || d.isSensitiveEnv(k) })
+
+	packages := d.AllPackageNamesIncludingRemovedTriggerPackages()
+	slices.Sort(packages)
+
+	return &envSnapshot{
+		CreatedAt: time.Now().UTC(),
+		Env:       env,
+		Packages:  packages,
+		Lockfile:  d.lockfile.Packages,
+	}, nil
+}
+
+// isSensitiveEnv returns true if the variable name may hold a secret: it was
+// read from env_from or passed with --env or --env-file, or its name looks
+// like a credential, which covers the variables that plugins set.
+func (d *Devbox) isSensitiveEnv(name string) bool {
+	if d.envFromNames[name] {
+		return true
+	}
+	if _, ok := d.env[name]; ok {
+		return true
+	}
+	upper := strings.ToUpper(name)
+	return lo.SomeBy(sensitiveEnvNameParts, func(part string) bool { return strings.Contains(upper, part) })
+}
+
+func diffEnvSnapshots(old, current *envSnapshot) *EnvDrift {
+	drift := &EnvDrift{
+		SnapshotTime: old.CreatedAt,
+		AddedEnv:     map[string]string{},
+		RemovedEnv:   map[string]string{},
+		ChangedEnv:   map[string]EnvVarChange{},
+	}
+	for k, v := range current.Env {
+		oldValue, ok := old.Env[k]
+		if !ok {
+			drift.AddedEnv[k] = v
+		} else if oldValue != v {
+			drift.ChangedEnv[k] = EnvVarChange{Old: oldValue, New: v}
+		}
+	}
+	for k, v := range old.Env {
+		if _, ok := current.Env[k]; !ok {
+			drift.RemovedEnv[k] = v
+		}
+	}
+
+	drift.RemovedPackages, drift.AddedPackages = lo.Difference(old.Packages, current.Packages)
+
+	for name, pkg := range current.Lockfile {
+		oldPkg, ok := old.Lockfile[name]
+		if !ok || oldPkg == nil || pkg == nil {
+			continue
+		}
+		if oldPkg.GetSource() != pkg.GetSource() ||
+			oldPkg.Resolved != pkg.Resolved ||
+			oldPkg.Version != pkg.Version {
+			drift.ChangedLocks = append(drift.ChangedLocks, name)
+		}
+	}
+	slices.Sort(drift.ChangedLocks)
+	return drift
+}
@@ -0,0 +1,75 @@
+package devbox
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.jetpack.io/devbox/internal/devbox/devopt"
+	"go.jetpack.io/devbox/internal/lock"
+	"go.jetpack.io/devbox/internal/nix"
+)
+
+func TestDiffEnvSnapshots(t *testing.T) {
+	old := &envSnapshot{
+		Env:      map[string]string{"KEEP": "1", "CHANGE": "old", "REMOVE": "x"},
+		Packages: []string{"go@1.21", "hello@latest"},
+		Lockfile: map[string]*lock.Package{
+			"go@1.21":      {Resolved: "github:NixOS/nixpkgs/aaa#go", Version: "1.21.1"},
+			"hello@latest": {Resolved: "github:NixOS/nixpkgs/aaa#hello", Version: "2.12"},
+		},
+	}
+	current := &envSnapshot{
+		Env:      map[string]string{"KEEP": "1", "CHANGE": "new", "ADD": "y"},
+		Packages: []string{"go@1.21", "cowsay@latest"},
+		Lockfile: map[string]*lock.Package{
+			"go@1.21":       {Resolved: "github:NixOS/nixpkgs/bbb#go", Version: "1.21.2"},
+			"cowsay@latest": {Resolved: "github:NixOS/nixpkgs/bbb#cowsay", Version: "3.7"},
+		},
+	}
+
+	drift := diffEnvSnapshots(old, current)
+	require.Equal(t, map[string]string{"ADD": "y"}, drift.AddedEnv)
+	require.Equal(t, map[string]string{"REMOVE": "x"}, drift.RemovedEnv)
+	require.Equal(t, map[string]EnvVarChange{"CHANGE": {Old: "old", New: "new"}}, drift.ChangedEnv)
+	require.Equal(t, []string{"cowsay@latest"}, drift.AddedPackages)
+	require.Equal(t, []string{"hello@latest"}, drift.RemovedPackages)
+	require.Equal(t, []string{"go@1.21"}, drift.ChangedLocks)
+	require.False(t, drift.IsEmpty())
+
+	require.True(t, diffEnvSnapshots(current, current).IsEmpty())
+}
+
+func TestSnapshotEnvLeavesOutSecrets(t *testing.T) {
+	d := devboxForTesting(t)
+	dotenv := filepath.Join(t.TempDir(), ".env")
+	require.NoError(t, os.WriteFile(dotenv, []byte("DB_URL=postgres://me:pw@db\n"), 0o644))
+	cfg := `{"env": {"API_TOKEN": "secret", "GREETING": "hello"}, "env_from": "` + dotenv + `"}`
+	require.NoError(t, os.WriteFile(filepath.Join(d.projectDir, "devbox.json"), []byte(cfg), 0o644))
+	d, err := Open(&devopt.Opts{Dir: d.projectDir, Stderr: os.Stderr})
+	require.NoError(t, err)
+	// The environment comes from the print-dev-env cache, so nix doesn't run,
+	// but a --pure environment still needs to find it on the PATH.
+	nix.FakeForTest(t, "exit 1\n")
+	require.NoError(t, os.MkdirAll(d.flakeDir(), 0o755))
+	require.NoError(t, os.WriteFile(d.nixPrintDevEnvCachePath(), []byte(`{"Variables": {}}`), 0o644))
+
+	path := filepath.Join(t.TempDir(), "snapshot.json")
+	require.NoError(t, os.WriteFile(path, nil, 0o644))
+	require.NoError(t, d.SnapshotEnv(context.Background(), path))
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	snapshot := &envSnapshot{}
+	require.NoError(t, json.Unmarshal(data, snapshot))
+	require.Equal(t, "hello", snapshot.Env["GREETING"])
+	require.NotContains(t, snapshot.Env, "API_TOKEN")
+	require.NotContains(t, snapshot.Env, "DB_URL")
+	require.NotContains(t, string(data), "secret")
+}
@@ -6,9 +6,14 @@ package devbox
 import (
 	"context"
 	"fmt"
+	"runtime/trace"
+	"slices"
+	"strings"
 
 	"github.com/pkg/errors"
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
 	"go.jetpack.io/devbox/internal/devbox/devopt"
+	"go.jetpack.io/devbox/internal/devconfig/configfile"
 	"go.jetpack.io/devbox/internal/devpkg"
 	"go.jetpack.io/devbox/internal/lock"
 	"go.jetpack.io/devbox/internal/nix"
@@ -36,7 +41,7 @@ func (d *Devbox) Update(ctx context.Context, opts devopt.UpdateOpts) error {
 				return fmt.Errorf("package %s not found in config", pkg.Raw)
 			}
 
-			if err := d.Remove(ctx, pkg.Raw); err != nil {
+			if err := d.Remove(ctx, devopt.RemoveOpts{}, pkg.Raw); err != nil {
 				return err
 			}
 			// Calling Add function with the original package names, since
@@ -83,6 +88,146 @@ func (d *Devbox) Update(ctx context.Context, opts devopt.UpdateOpts) error {
 	return plugin.Update()
 }
 
+// Upgrade re-resolves each of the named packages to the newest version
+// available in search. Packages pinned to a version other than "latest" are
+// rewritten in devbox.json to pin the new version. Flakes and legacy packages
+// don't have versions to upgrade and should be updated with Update instead.
+func (d *Devbox) Upgrade(ctx context.Context, names ...string) error {
+	ctx, task := trace.NewTask(ctx, "devboxUpgrade")
+	defer task.End()
+
+	for _, name := range names {
+		pkg, err := d.findPackageByName(name)
+		if errors.Is(err, searcher.ErrNotFound) {
+			if matches := d.closePackageNames(name); len(matches) > 0 {
+				return usererr.New(
+					"Package %s is not in devbox.json. Did you mean: %s?",
+					name, strings.Join(matches, ", "),
+				)
+			}
+			return usererr.New("Package %s is not in devbox.json.", name)
+		} else if err != nil {
+			return err
+		}
+
+		pkgName, version, isVersioned := searcher.ParseVersionedPackage(pkg.Raw)
+		if !isVersioned {
+			return usererr.New(
+				"Package %s doesn't have a version to upgrade. Use `devbox update %[1]s` instead.",
+				pkg.Raw,
+			)
+		}
+		if version == "latest" {
+			if err := d.updateDevboxPackage(pkg); err != nil {
+				return err
+			}
+			continue
+		}
+
+		resolved, err := d.lockfile.FetchResolvedPackage(pkgName + "@latest")
+		if err != nil {
+			return err
+		}
+		if resolved == nil || resolved.Version == "" || resolved.Version == version {
+			ux.Finfo(d.stderr, "Already up-to-date %s\n", pkg.Raw)
+			continue
+		}
+
+		upgraded := pkgName + "@" + resolved.Version
+		ux.Finfo(d.stderr, "Upgrading %s -> %s\n", pkg.Raw, upgraded)
+		if err := d.cfg.PackageMutator().SetVersion(pkg.Raw, resolved.Version); err != nil {
+			return err
+		}
+		delete(d.lockfile.Packages, pkg.Raw)
+		d.lockfile.Packages[upgraded] = resolved
+	}
+
+	if err := d.ensureStateIsUpToDate(ctx, update); err != nil {
+		return err
+	}
+	return d.saveCfg()
+}
+
+// RenamePackage changes the name or version of a package in devbox.json while
+// keeping its other options, such as its platforms and plugin settings. Unlike
+// removing and adding the package, the config entry stays where it is. The new
+// name is validated and resolved before the config is changed.
+func (d *Devbox) RenamePackage(ctx context.Context, oldName, newName string) error {
+	ctx, task := trace.NewTask(ctx, "devboxRenamePackage")
+	defer task.End()
+
+	pkg, err := d.findPackageByName(oldName)
+	if err != nil {
+		return err
+	}
+	renamed := devpkg.PackageFromStringWithDefaults(newName, d.lockfile).Versioned()
+	if renamed == pkg.Raw {
+		ux.Finfo(d.stderr, "Package %s is already in devbox.json\n", renamed)
+		return nil
+	}
+	if _, err := d.findPackageByName(renamed); err == nil {
+		return usererr.New("Package %s is already in devbox.json.", renamed)
+	}
+
+	newPkg := devpkg.PackageFromStringWithDefaults(renamed, d.lockfile)
+	if ok, err := newPkg.ValidateExists(ctx); err != nil {
+		return err
+	} else if !ok {
+		return usererr.New("Package %s not found", renamed)
+	}
+
+	if err := d.cfg.PackageMutator().Rename(pkg.Raw, renamed); err != nil {
+		return err
+	}
+	delete(d.lockfile.Packages, pkg.Raw)
+	if newPkg.IsDevboxPackage {
+		if _, err := d.lockfile.Resolve(renamed); err != nil {
+			return err
+		}
+	}
+	ux.Finfo(d.stderr, "Renamed %s -> %s\n", pkg.Raw, renamed)
+
+	if err := d.ensureStateIsUpToDate(ctx, update); err != nil {
+		return err
+	}
+	return d.saveCfg()
+}
+
+// closePackageNames returns the names of packages in devbox.json that look
+// like a misspelling of name.
+func (d *Devbox) closePackageNames(name string) []string {
+	matches := []string{}
+	for candidate := range d.topLevelPackagesByName() {
+		if strings.Contains(candidate, name) || strings.Contains(name, candidate) ||
+			editDistance(candidate, name) <= 2 {
+			matches = append(matches, candidate)
+		}
+	}
+	slices.Sort(matches)
+	return matches
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
 func (d *Devbox) inputsToUpdate(
 	opts devopt.UpdateOpts,
 ) ([]*devpkg.Package, error) {
@@ -129,8 +274,8 @@ func (d *Devbox) mergeResolvedPackageToLockfile(
 
 	if existing.Version != resolved.Version {
 		if existing.LastModified > resolved.LastModified {
-			ux.Fwarning(
-				d.stderr,
+			d.warn(
+				WarningOlderVersion,
 				"Resolved version for %s has older last_modified time. Not updating\n",
 				pkg,
 			)
@@ -181,9 +326,10 @@ func (d *Devbox) mergeResolvedPackageToLockfile(
 // attemptToUpgradeFlake attempts to upgrade a flake using `nix profile upgrade`
 // and prints an error if it fails, but does not propagate upgrade errors.
 func (d *Devbox) attemptToUpgradeFlake(pkg *devpkg.Package) error {
-	profilePath, err := d.profilePath()
-	if err != nil {
-		return err
+	// With group_profiles, a flake in groups is in each group's profile.
+	groups := []string{""}
+	if d.cfg.Root.GroupProfiles && len(pkg.Groups) > 0 {
+		groups = pkg.Groups
 	}
 
 	ux.Finfo(
@@ -192,14 +338,21 @@ func (d *Devbox) attemptToUpgradeFlake(pkg *devpkg.Package) error {
 		pkg.Raw,
 	)
 
-	err = nixprofile.ProfileUpgrade(profilePath, pkg, d.lockfile)
-	if err != nil {
-		ux.Fwarning(
-			d.stderr,
-			"Failed to upgrade %s using `nix profile upgrade`: %s\n",
-			pkg.Raw,
-			err,
-		)
+	d.profileItems = nil
+	for _, group := range groups {
+		profilePath, err := d.profilePath(group)
+		if err != nil {
+			return err
+		}
+		err = nixprofile.ProfileUpgrade(d.storeRoot, profilePath, pkg, d.lockfile)
+		if err != nil {
+			d.warn(
+				WarningProfileUpgrade,
+				"Failed to upgrade %s using `nix profile upgrade`: %s\n",
+				pkg.Raw,
+				err,
+			)
+		}
 	}
 
 	return nil
@@ -214,3 +367,56 @@ func useResolvedPackageInLockfile(
 	lockfile.Packages[pkg.Raw] = resolved
 	lockfile.Packages[pkg.Raw].AllowInsecure = existing.AllowInsecure
 }
+
+// ApplySecurityUpdates updates the packages marked with
+// `"auto_update": "security"` whose locked version has known vulnerabilities.
+// Each one is re-resolved to the newest version that matches its version
+// constraint in devbox.json and then reinstalled. All other packages stay
+// pinned to their locked versions.
+func (d *Devbox) ApplySecurityUpdates(ctx context.Context) error {
+	toUpdate := []*devpkg.Package{}
+	for _, cfgPkg := range d.cfg.Root.TopLevelPackages() {
+		if cfgPkg.AutoUpdate != configfile.AutoUpdateSecurity {
+			continue
+		}
+		pkg, err := d.findPackageByName(cfgPkg.VersionedName())
+		if err != nil {
+			return err
+		}
+		if _, _, isVersioned := searcher.ParseVersionedPackage(pkg.Raw); !isVersioned {
+			d.warn(WarningNotVersioned, "Skipping security updates for %s because it isn't a versioned package\n", pkg.Raw)
+			continue
+		}
+		locked := d.lockfile.Get(pkg.Raw)
+		if locked == nil {
+			// Not locked yet, so installing will resolve the newest version.
+			continue
+		}
+		if vulns := nix.PackageKnownVulnerabilities(locked.Resolved); len(vulns) > 0 {
+			ux.Finfo(d.stderr, "%s %s has known vulnerabilities:\n%s\n", pkg.Raw, locked.Version, strings.Join(vulns, "\n"))
+			toUpdate = append(toUpdate, pkg)
+		}
+	}
+	if len(toUpdate) == 0 {
+		ux.Finfo(d.stderr, "No security updates to apply\n")
+		return nil
+	}
+
+	for _, pkg := range toUpdate {
+		if err := d.updateDevboxPackage(pkg); err != nil {
+			return err
+		}
+		updated := d.lockfile.Get(pkg.Raw)
+		if vulns := nix.PackageKnownVulnerabilities(updated.Resolved); len(vulns) > 0 {
+			d.warn(
+				WarningStillVulnerable,
+				"The newest version of %s (%s) still has known vulnerabilities. "+
+					"Consider changing its version in devbox.json.\n",
+				pkg.Raw,
+				updated.Version,
+			)
+		}
+	}
+
+	return d.ensureStateIsUpToDate(ctx, update)
+}
@@ -1,9 +1,13 @@
 package devbox
 
 import (
+	"context"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/require"
+	"go.jetpack.io/devbox/internal/devbox/devopt"
 	"go.jetpack.io/devbox/internal/devpkg"
 	"go.jetpack.io/devbox/internal/lock"
 	"go.jetpack.io/devbox/internal/nix"
@@ -27,6 +31,27 @@ func TestUpdateNewPackageIsAdded(t *testing.T) {
 	require.Contains(t, lockfile.Packages, raw)
 }
 
+func TestUpdateOlderVersionIsReported(t *testing.T) {
+	devbox := devboxForTesting(t)
+	reporter := &recordingWarnings{}
+	devbox.warnings = reporter
+
+	raw := "hello@latest"
+	devPkg := devpkg.PackageFromStringWithDefaults(raw, nil)
+	existing := &lock.Package{Version: "2.12", LastModified: "2024-02-01T00:00:00Z"}
+	lockfile := &lock.File{
+		Packages: map[string]*lock.Package{raw: existing},
+	}
+	resolved := &lock.Package{Version: "2.10", LastModified: "2023-01-01T00:00:00Z"}
+
+	err := devbox.mergeResolvedPackageToLockfile(devPkg, resolved, lockfile)
+	require.NoError(t, err, "update failed")
+
+	require.Same(t, existing, lockfile.Packages[raw])
+	require.Len(t, reporter.warnings, 1)
+	require.Equal(t, WarningOlderVersion, reporter.warnings[0].Code)
+}
+
 func TestUpdateNewCurrentSysInfoIsAdded(t *testing.T) {
 	devbox := devboxForTesting(t)
 
@@ -195,3 +220,44 @@ func currentSystem(*testing.T) string {
 	sys := nix.System() // NOTE: we could mock this too, if it helps.
 	return sys
 }
+
+func TestEditDistance(t *testing.T) {
+	require.Equal(t, 0, editDistance("python", "python"))
+	require.Equal(t, 2, editDistance("pyhton", "python"))
+	require.Equal(t, 1, editDistance("nodejs", "nodej"))
+	require.Equal(t, 3, editDistance("", "abc"))
+}
+
+func TestApplySecurityUpdatesChecksMarkedPackages(t *testing.T) {
+	// A fake nix that reports no known vulnerabilities and logs the
+	// packages it's asked about.
+	calls := filepath.Join(t.TempDir(), "calls")
+	nix.FakeForTest(t, `echo "$*" >> `+calls+`
+case "$*" in
+  *--version*) echo "nix (Nix) 2.24.0";;
+  *knownVulnerabilities*) echo '[]';;
+  *) exit 1;;
+esac
+`)
+
+	devbox := devboxForTesting(t)
+	cfg := `{"packages": {"hello": {"version": "2.12", "auto_update": "security"}, "jq": "1.7"}}`
+	require.NoError(t, os.WriteFile(filepath.Join(devbox.projectDir, "devbox.json"), []byte(cfg), 0o644))
+	devbox, err := Open(&devopt.Opts{Dir: devbox.projectDir, Stderr: os.Stderr})
+	require.NoError(t, err)
+	hello := &lock.Package{Resolved: "github:NixOS/nixpkgs/abc#hello", Version: "2.12"}
+	jq := &lock.Package{Resolved: "github:NixOS/nixpkgs/abc#jq", Version: "1.7"}
+	devbox.lockfile.Packages["hello@2.12"] = hello
+	devbox.lockfile.Packages["jq@1.7"] = jq
+
+	require.NoError(t, devbox.ApplySecurityUpdates(context.Background()))
+
+	// Only the package marked with auto_update is checked, and neither
+	// package is re-resolved since hello has no known vulnerabilities.
+	log, err := os.ReadFile(calls)
+	require.NoError(t, err)
+	require.Contains(t, string(log), "github:NixOS/nixpkgs/abc#hello.meta.knownVulnerabilities")
+	require.NotContains(t, string(log), "#jq")
+	require.Same(t, hello, devbox.lockfile.Get("hello@2.12"))
+	require.Same(t, jq, devbox.lockfile.Get("jq@1.7"))
+}
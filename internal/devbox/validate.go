@@ -0,0 +1,142 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/samber/lo"
+	"go.jetpack.io/devbox/internal/devbox/devopt"
+	"go.jetpack.io/devbox/internal/devpkg"
+	"go.jetpack.io/devbox/internal/devpkg/pkgtype"
+	"go.jetpack.io/devbox/internal/lock"
+)
+
+// defaultValidateTimeout is how long Add waits for the search endpoint to
+// validate a package before falling back to the legacy nixpkgs path.
+const defaultValidateTimeout = 15 * time.Second
+
+// validateExistsWithTimeout checks that the versioned package exists, giving
+// up with context.DeadlineExceeded after timeout. The search client doesn't
+// honor contexts everywhere, so the validation runs in its own goroutine
+// against a scratch lockfile. If it times out, the goroutine is left to finish
+// without touching the project's lockfile. Otherwise, the packages it
+// resolved are copied into the project's lockfile.
+func (d *Devbox) validateExistsWithTimeout(
+	ctx context.Context,
+	name string,
+	opts devopt.AddOpts,
+	timeout time.Duration,
+) (bool, error) {
+	if timeout <= 0 {
+		timeout = defaultValidateTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	locker := newScratchLocker(d.lockfile, name)
+	pkg := devpkg.PackageFromStringWithOptions(name, locker, opts)
+
+	type result struct {
+		ok  bool
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		ok, err := pkg.ValidateExists(ctx)
+		done <- result{ok, err}
+	}()
+
+	select {
+	case r := <-done:
+		for key, entry := range locker.resolved {
+			d.lockfile.Packages[key] = entry
+		}
+		return r.ok, r.err
+	case <-ctx.Done():
+		return false, errors.WithStack(ctx.Err())
+	}
+}
+
+// scratchLocker is a lock.Locker that resolves packages without writing to
+// the project's lockfile, so that it can be used from another goroutine.
+type scratchLocker struct {
+	lockfile *lock.File
+	resolved map[string]*lock.Package
+}
+
+// newScratchLocker returns a scratchLocker seeded with the project's lockfile
+// entry for pkg, if there is one and it wasn't resolved offline. It must be
+// called from the goroutine that owns lockfile.
+func newScratchLocker(lockfile *lock.File, pkg string) *scratchLocker {
+	l := &scratchLocker{lockfile: lockfile, resolved: map[string]*lock.Package{}}
+	if entry := lockfile.Get(pkg); entry != nil && !entry.ResolvedOffline {
+		l.resolved[pkg] = entry
+	}
+	return l
+}
+
+func (l *scratchLocker) Get(pkg string) *lock.Package {
+	return l.resolved[pkg]
+}
+
+func (l *scratchLocker) LegacyNixpkgsPath(pkg string) string {
+	return l.lockfile.LegacyNixpkgsPath(pkg)
+}
+
+func (l *scratchLocker) ProjectDir() string {
+	return l.lockfile.ProjectDir()
+}
+
+func (l *scratchLocker) Resolve(pkg string) (*lock.Package, error) {
+	if entry, ok := l.resolved[pkg]; ok {
+		return entry, nil
+	}
+	entry, err := l.lockfile.FetchResolvedPackage(pkg)
+	if err != nil || entry == nil {
+		return entry, err
+	}
+	l.resolved[pkg] = entry
+	return entry, nil
+}
+
+// preferSources rewrites the ambiguous package names in names to the first
+// source in opts.SourcePreference that has the package. Names that no
+// preferred source has are left as they are, so that validating them reports
+// the usual error.
+//
+// Packages that aren't validated, because validation is deferred or we're
+// offline, and packages pinned to a nixpkgs commit are left as they are too,
+// since we can't tell which source has them.
+func (d *Devbox) preferSources(ctx context.Context, names []string, opts devopt.AddOpts) []string {
+	if len(opts.SourcePreference) == 0 || opts.DeferValidation || opts.Offline || opts.NixpkgsCommit != "" {
+		return names
+	}
+	return lo.Map(names, func(name string, _ int) string {
+		candidates := pkgtype.SourceCandidates(name, opts.SourcePreference)
+		// The last candidate is the name as-is, which is validated by
+		// the caller, so it isn't checked here.
+		for _, candidate := range candidates[:len(candidates)-1] {
+			if d.sourceHasPackage(ctx, candidate, opts) {
+				return candidate
+			}
+			slog.Debug("package not found in preferred source", "package", candidate)
+		}
+		return name
+	})
+}
+
+// sourceHasPackage returns true if name resolves in the source that its
+// scheme names. Errors mean that it doesn't.
+func (d *Devbox) sourceHasPackage(ctx context.Context, name string, opts devopt.AddOpts) bool {
+	pkg := devpkg.PackageFromStringWithOptions(name, d.lockfile, opts)
+	if !pkg.IsDevboxPackage && !pkg.IsRunX() {
+		return pkg.ValidateFlakeEvaluates(ctx) == nil
+	}
+	ok, err := d.validateExistsWithTimeout(ctx, pkg.Versioned(), opts, opts.ValidateTimeout)
+	return ok && err == nil
+}
@@ -0,0 +1,51 @@
+package devbox
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.jetpack.io/devbox/internal/devbox/devopt"
+	"go.jetpack.io/devbox/internal/devpkg/pkgtype"
+	"go.jetpack.io/devbox/internal/lock"
+	"go.jetpack.io/devbox/internal/nix"
+)
+
+func TestScratchLocker(t *testing.T) {
+	lockfile := &lock.File{Packages: map[string]*lock.Package{
+		"go@1.22":      {Resolved: "github:NixOS/nixpkgs/abc#go"},
+		"hello@latest": {Resolved: "github:NixOS/nixpkgs/def#hello", ResolvedOffline: true},
+	}}
+
+	locker := newScratchLocker(lockfile, "go@1.22")
+	require.Equal(t, lockfile.Packages["go@1.22"], locker.Get("go@1.22"))
+	entry, err := locker.Resolve("go@1.22")
+	require.NoError(t, err)
+	require.Equal(t, lockfile.Packages["go@1.22"], entry)
+
+	// Offline entries are re-resolved, so they aren't copied.
+	locker = newScratchLocker(lockfile, "hello@latest")
+	require.Nil(t, locker.Get("hello@latest"))
+	require.Len(t, lockfile.Packages, 2)
+}
+
+func TestPreferSources(t *testing.T) {
+	d := devboxForTesting(t)
+	ctx := context.Background()
+	opts := devopt.AddOpts{SourcePreference: []string{pkgtype.SourceFlake, pkgtype.SourceNixpkgs}}
+
+	// The flake registry only has hello, so only hello comes from a flake.
+	nix.FakeForTest(t, `case "$*" in
+*"path-info --derivation --impure flake:hello") echo /nix/store/abc-hello.drv;;
+*) exit 1;;
+esac
+`)
+	require.Equal(t,
+		[]string{"flake:hello", "cowsay", "cowsay@1.0"},
+		d.preferSources(ctx, []string{"hello", "cowsay", "cowsay@1.0"}, opts),
+	)
+
+	// Without validation, we can't tell which source has the package.
+	opts.DeferValidation = true
+	require.Equal(t, []string{"hello"}, d.preferSources(ctx, []string{"hello"}, opts))
+}
@@ -0,0 +1,70 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"context"
+	"os/exec"
+	"strings"
+
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+	"go.jetpack.io/devbox/internal/cmdutil"
+	"go.jetpack.io/devbox/internal/devbox/devopt"
+	"go.jetpack.io/devbox/internal/devpkg"
+	"go.jetpack.io/devbox/internal/envir"
+	"go.jetpack.io/devbox/internal/ux"
+)
+
+// verifyPackages runs the verify command of every installed package that has
+// one, in the project's environment. It catches broken installs, such as a
+// binary for the wrong architecture, right away instead of at first use.
+//
+// A failing command fails the install, or is recorded as the package's
+// failure with keepGoing. It does nothing if the project was opened with
+// SkipVerify.
+func (d *Devbox) verifyPackages(ctx context.Context) error {
+	if d.skipVerify {
+		return nil
+	}
+	pkgs := []*devpkg.Package{}
+	for _, pkg := range d.InstallablePackages() {
+		if pkg.Verify != "" && !d.failures.has(pkg) {
+			pkgs = append(pkgs, pkg)
+		}
+	}
+	if len(pkgs) == 0 {
+		return nil
+	}
+
+	// The environment was just computed, so the print-dev-env cache is
+	// current.
+	env, err := d.computeEnv(ctx, true /*usePrintDevEnvCache*/, devopt.EnvOptions{})
+	if err != nil {
+		return err
+	}
+	for _, pkg := range pkgs {
+		ux.Finfo(d.stderr, "Verifying %s: %s\n", pkg.Raw, pkg.Verify)
+		if err := d.keepGoingOnError(pkg, runVerifyCommand(ctx, d.projectDir, pkg, env)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func runVerifyCommand(ctx context.Context, dir string, pkg *devpkg.Package, env map[string]string) error {
+	shPath := cmdutil.GetPathOrDefault("sh", "/bin/sh")
+	cmd := exec.CommandContext(ctx, shPath, "-c", pkg.Verify)
+	cmd.Env = envir.MapToPairs(env)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return usererr.WithUserMessage(
+			err,
+			"Package %s failed verification: `%s` failed with %v.\n%s\n"+
+				"Use --skip-verify to install it without verifying it.",
+			pkg.Raw, pkg.Verify, err, strings.TrimSpace(string(out)),
+		)
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package devbox
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.jetpack.io/devbox/internal/devpkg"
+)
+
+func TestRunVerifyCommand(t *testing.T) {
+	d := devboxForTesting(t)
+	pkg := devpkg.PackageFromStringWithDefaults("hello@2.12", d.lockfile)
+	env := map[string]string{"GREETING": "hello"}
+
+	pkg.Verify = `test "$GREETING" = hello`
+	require.NoError(t, runVerifyCommand(context.Background(), d.projectDir, pkg, env))
+
+	pkg.Verify = "echo broken binary; exit 3"
+	err := runVerifyCommand(context.Background(), d.projectDir, pkg, env)
+	require.ErrorContains(t, err, "exit status 3")
+}
+
+func TestVerifyPackagesSkipped(t *testing.T) {
+	d := devboxForTesting(t)
+	d.cfg.PackageMutator().Add("hello@2.12")
+
+	// No package has a verify command, so the environment isn't computed.
+	require.NoError(t, d.verifyPackages(context.Background()))
+
+	d.skipVerify = true
+	require.NoError(t, d.verifyPackages(context.Background()))
+}
@@ -0,0 +1,97 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"go.jetpack.io/devbox/internal/devbox/devopt"
+	"go.jetpack.io/devbox/internal/ux"
+)
+
+// Codes of the warnings reported to a devopt.WarningReporter.
+const (
+	// WarningValidateTimeout is reported when Add times out validating a
+	// package with the search service.
+	WarningValidateTimeout = "validate-timeout"
+	// WarningUnverified is reported when an offline Add can't verify the
+	// packages, so they're only added to devbox.json.
+	WarningUnverified = "unverified"
+	// WarningLargeInstall is reported when the added packages need a lot of
+	// disk space.
+	WarningLargeInstall = "large-install"
+	// WarningProvidedByPlugin is reported when an added package is already
+	// installed by a plugin.
+	WarningProvidedByPlugin = "provided-by-plugin"
+	// WarningLegacyVersion is reported when an added package falls back to
+	// a project nixpkgs version that isn't the requested one.
+	WarningLegacyVersion = "legacy-version"
+	// WarningPackageNotFound is reported when Remove is given packages that
+	// aren't in devbox.json.
+	WarningPackageNotFound = "package-not-found"
+	// WarningBuildFromSource is reported when installing from the binary
+	// cache fails and the packages are built from source instead.
+	WarningBuildFromSource = "build-from-source"
+	// WarningCacheUnavailable is reported when the Jetify Nix cache can't
+	// be used, so some packages might be built from source.
+	WarningCacheUnavailable = "cache-unavailable"
+	// WarningCachePush is reported when a package can't be pushed to the
+	// devopt.PushToCache cache.
+	WarningCachePush = "cache-push"
+	// WarningNotGitRepository is reported when a git commit was requested,
+	// but the project isn't in a git repository.
+	WarningNotGitRepository = "not-git-repository"
+	// WarningAuditLog is reported when the audit log can't be written.
+	WarningAuditLog = "audit-log"
+	// WarningNotLocked is reported when Freeze can't pin a package because
+	// it isn't locked to a version.
+	WarningNotLocked = "not-locked"
+	// WarningNotVersioned is reported when Freeze can't pin a package, or a
+	// security update skips one, because it has no version and uses the
+	// project's nixpkgs commit.
+	WarningNotVersioned = "not-versioned"
+	// WarningAliasShadowsPackage is reported when Add expands an alias that
+	// has the same name as a package.
+	WarningAliasShadowsPackage = "alias-shadows-package"
+	// WarningRollback is reported when devbox.json and devbox.lock can't be
+	// restored after a failed Add.
+	WarningRollback = "rollback"
+	// WarningNetworkDenied is reported when the strict network policy
+	// blocks a request.
+	WarningNetworkDenied = "network-denied"
+	// WarningOlderVersion is reported when Update doesn't update a package
+	// because the newly resolved version is older than the locked one.
+	WarningOlderVersion = "older-version"
+	// WarningProfileUpgrade is reported when Update can't upgrade a package
+	// in a Nix profile.
+	WarningProfileUpgrade = "profile-upgrade"
+	// WarningStillVulnerable is reported when a security update leaves a
+	// package at a version that still has known vulnerabilities.
+	WarningStillVulnerable = "still-vulnerable"
+	// WarningPluginEnvConflict is reported when plugins set an environment
+	// variable to different values.
+	WarningPluginEnvConflict = "plugin-env-conflict"
+)
+
+// stderrWarnings is the default devopt.WarningReporter, which prints the
+// warnings like the rest of Devbox's messages.
+type stderrWarnings struct {
+	w io.Writer
+}
+
+func (s stderrWarnings) Warn(w devopt.Warning) {
+	ux.Fwarning(s.w, "%s\n", w.Message)
+}
+
+// warn reports a warning with the given code to the devopt.WarningReporter.
+func (d *Devbox) warn(code, format string, a ...any) {
+	d.warnings.Warn(devopt.Warning{
+		Code:    code,
+		Message: strings.TrimSuffix(fmt.Sprintf(format, a...), "\n"),
+	})
+}
+
+var _ devopt.WarningReporter = stderrWarnings{}
@@ -0,0 +1,68 @@
+package devbox
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/fatih/color"
+	"github.com/stretchr/testify/require"
+	"go.jetpack.io/devbox/internal/devbox/devopt"
+)
+
+type recordingWarnings struct {
+	warnings []devopt.Warning
+}
+
+func (r *recordingWarnings) Warn(w devopt.Warning) {
+	r.warnings = append(r.warnings, w)
+}
+
+func TestWarningReporter(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git isn't installed")
+	}
+	d := devboxForTesting(t)
+	reporter := &recordingWarnings{}
+	d.warnings = reporter
+
+	require.NoError(t, d.commitConfigChanges(context.Background(), "", "add", []string{"hello@2.12"}))
+	require.Equal(t, []devopt.Warning{{
+		Code:    WarningNotGitRepository,
+		Message: d.projectDir + " isn't in a git repository, so the changes weren't committed.",
+	}}, reporter.warnings)
+
+	// The default reporter prints the same message as before.
+	defer func(noColor bool) { color.NoColor = noColor }(color.NoColor)
+	color.NoColor = true
+	var buf bytes.Buffer
+	stderrWarnings{&buf}.Warn(reporter.warnings[0])
+	require.Equal(t, "Warning: "+d.projectDir+" isn't in a git repository, so the changes weren't committed.\n", buf.String())
+}
+
+func TestPluginEnvConflictWarnedOnce(t *testing.T) {
+	dir := devboxForTesting(t).projectDir
+	for name, port := range map[string]string{"a": "1", "b": "2"} {
+		plugin := `{"name": "` + name + `", "version": "0.0.1", "env": {"PORT": "` + port + `"}}`
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, name), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name, "plugin.json"), []byte(plugin), 0o644))
+	}
+	cfg := `{"include": ["path:a/plugin.json", "path:b/plugin.json"]}`
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "devbox.json"), []byte(cfg), 0o644))
+	d, err := Open(&devopt.Opts{Dir: dir, Stderr: os.Stderr})
+	require.NoError(t, err)
+	reporter := &recordingWarnings{}
+	d.warnings = reporter
+
+	// The environment is computed more than once in a command.
+	for range 2 {
+		env, err := d.configEnvs(context.Background(), map[string]string{})
+		require.NoError(t, err)
+		require.Equal(t, "2", env["PORT"])
+	}
+	require.Len(t, reporter.warnings, 1)
+	require.Equal(t, WarningPluginEnvConflict, reporter.warnings[0].Code)
+}
@@ -0,0 +1,176 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package devbox
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"runtime/trace"
+	"strings"
+
+	"github.com/samber/lo"
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+	"go.jetpack.io/devbox/internal/devpkg"
+	"go.jetpack.io/devbox/internal/nix"
+	"go.jetpack.io/devbox/internal/plugin"
+)
+
+// InstallReason is one reason a package is part of the project. See
+// Devbox.InstallReasons.
+type InstallReason struct {
+	// Package is the package's versioned name.
+	Package string `json:"package"`
+
+	// Plugins is the chain of plugins that includes the package, starting
+	// with the plugin included by devbox.json. It's empty if the package is
+	// in devbox.json or is a dependency.
+	Plugins []PluginReason `json:"plugins,omitempty"`
+
+	// DependencyOf is the installed package whose runtime closure has the
+	// package, if it isn't in the project itself. Package is then the
+	// dependency's name and version from its store path, such as
+	// "glibc@2.39".
+	DependencyOf string `json:"dependency_of,omitempty"`
+
+	// Installed is false if the package isn't installed even though it's in
+	// the project, such as when it's disabled, not for this platform, or
+	// overridden by another package with the same name.
+	Installed bool `json:"installed"`
+}
+
+// PluginReason is a plugin in an InstallReason's chain.
+type PluginReason struct {
+	// Plugin is the plugin's name.
+	Plugin string `json:"plugin"`
+
+	// TriggeredBy is the package that enabled the plugin if it's a built-in
+	// plugin, or empty if the plugin was included.
+	TriggeredBy string `json:"triggered_by,omitempty"`
+}
+
+// String describes the reason in a sentence, such as "php@8.1 is required by
+// the built-in plugin php (for package php@8.1)".
+func (r InstallReason) String() string {
+	var b strings.Builder
+	b.WriteString(r.Package)
+	if r.DependencyOf != "" {
+		fmt.Fprintf(&b, " is a dependency of %s", r.DependencyOf)
+		return b.String()
+	}
+	if len(r.Plugins) == 0 {
+		b.WriteString(" is in devbox.json")
+	}
+	// The chain starts at devbox.json, so describe it from the package back
+	// up to devbox.json.
+	for i := len(r.Plugins) - 1; i >= 0; i-- {
+		p := r.Plugins[i]
+		if i == len(r.Plugins)-1 {
+			b.WriteString(" is required by ")
+		} else {
+			b.WriteString(", which is required by ")
+		}
+		if p.TriggeredBy != "" {
+			fmt.Fprintf(&b, "the built-in plugin %s (for package %s)", p.Plugin, p.TriggeredBy)
+		} else {
+			fmt.Fprintf(&b, "the plugin %s", p.Plugin)
+		}
+	}
+	if !r.Installed {
+		b.WriteString(", but it isn't installed")
+	}
+	return b.String()
+}
+
+// InstallReasons explains why the packages with the given name or versioned
+// name are part of the project: because they're in devbox.json, because a
+// plugin requires them, or because they're a runtime dependency of an
+// installed package. Built-in plugins are enabled by packages, so their chain
+// also names the package that enabled them.
+func (d *Devbox) InstallReasons(ctx context.Context, name string) ([]InstallReason, error) {
+	defer trace.StartRegion(ctx, "devboxInstallReasons").End()
+
+	origins := d.cfg.PackageOrigins(name)
+	dependencies := d.dependencyReasons(ctx, name)
+	if len(origins) == 0 && len(dependencies) == 0 {
+		return nil, usererr.New(
+			"Package %s is not in devbox.json, any of its plugins, or the dependencies of its installed packages.",
+			name,
+		)
+	}
+
+	// InstallablePackages leaves out packages that are disabled, overridden,
+	// or removed by their built-in plugin.
+	installed := map[string]bool{}
+	for _, pkg := range d.InstallablePackages() {
+		installed[pkg.Raw] = true
+	}
+
+	reasons := make([]InstallReason, 0, len(origins))
+	for _, origin := range origins {
+		versionedName := origin.Package.VersionedName()
+		reason := InstallReason{
+			Package:   versionedName,
+			Plugins:   make([]PluginReason, 0, len(origin.Plugins)),
+			Installed: installed[versionedName],
+		}
+		for _, source := range origin.Plugins {
+			reason.Plugins = append(reason.Plugins, pluginReason(source))
+		}
+		reasons = append(reasons, reason)
+	}
+	return append(reasons, dependencies...), nil
+}
+
+// dependencyReasons returns a reason for each installed package whose runtime
+// closure has a store path matching name, other than the package's own
+// outputs. Packages that aren't in the Nix store are skipped.
+func (d *Devbox) dependencyReasons(ctx context.Context, name string) []InstallReason {
+	reasons := []InstallReason{}
+	for _, pkg := range d.InstallablePackages() {
+		outputs, err := pkg.GetResolvedStorePaths()
+		if err != nil || len(outputs) == 0 {
+			continue
+		}
+		closure, err := nix.StorePathClosure(ctx, d.storeRoot, outputs)
+		if err != nil {
+			slog.Debug("skipping dependencies of package", "pkg", pkg.Raw, "err", err)
+			continue
+		}
+		for _, path := range lo.Without(closure, outputs...) {
+			if !storePathMatchesName(path, name) {
+				continue
+			}
+			parts := nix.NewStorePathParts(path)
+			dependency := parts.Name
+			if parts.Version != "" {
+				dependency += "@" + parts.Version
+			}
+			reasons = append(reasons, InstallReason{
+				Package:      dependency,
+				DependencyOf: pkg.Raw,
+				Installed:    true,
+			})
+		}
+	}
+	return lo.UniqBy(reasons, func(r InstallReason) string { return r.Package + " " + r.DependencyOf })
+}
+
+// WhyInstalled is like InstallReasons, but describes each reason in a
+// sentence.
+func (d *Devbox) WhyInstalled(ctx context.Context, name string) ([]string, error) {
+	reasons, err := d.InstallReasons(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return lo.Map(reasons, func(r InstallReason, _ int) string { return r.String() }), nil
+}
+
+func pluginReason(source plugin.Includable) PluginReason {
+	// Built-in plugins are sourced from the package that enables them.
+	if pkg, ok := source.(*devpkg.Package); ok {
+		return PluginReason{Plugin: pkg.CanonicalName(), TriggeredBy: pkg.Raw}
+	}
+	return PluginReason{Plugin: source.CanonicalName()}
+}
@@ -0,0 +1,80 @@
+package devbox
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.jetpack.io/devbox/internal/lock"
+	"go.jetpack.io/devbox/internal/nix"
+)
+
+func TestInstallReasonString(t *testing.T) {
+	testCases := []struct {
+		reason InstallReason
+		want   string
+	}{
+		{
+			reason: InstallReason{Package: "go@1.22", Installed: true},
+			want:   "go@1.22 is in devbox.json",
+		},
+		{
+			reason: InstallReason{
+				Package: "php81Extensions.apcu@latest",
+				Plugins: []PluginReason{
+					{Plugin: "github:org/php-tools"},
+					{Plugin: "php", TriggeredBy: "php@8.1"},
+				},
+				Installed: true,
+			},
+			want: "php81Extensions.apcu@latest is required by the built-in plugin php (for package php@8.1), " +
+				"which is required by the plugin github:org/php-tools",
+		},
+		{
+			reason: InstallReason{Package: "glibc@2.39", DependencyOf: "hello@2.12", Installed: true},
+			want:   "glibc@2.39 is a dependency of hello@2.12",
+		},
+		{
+			reason: InstallReason{Package: "hello@latest", Plugins: []PluginReason{{Plugin: "./plugins/hello"}}},
+			want:   "hello@latest is required by the plugin ./plugins/hello, but it isn't installed",
+		},
+	}
+	for _, tc := range testCases {
+		if got := tc.reason.String(); got != tc.want {
+			t.Errorf("got %q, want %q", got, tc.want)
+		}
+	}
+}
+
+func TestInstallReasonsDependency(t *testing.T) {
+	helloPath := "/nix/store/0a2xcqgwdgwhsy2b5idgxlfbjxgyv0x6-hello-2.12.1"
+	glibcPath := "/nix/store/1b2xcqgwdgwhsy2b5idgxlfbjxgyv0x6-glibc-2.39"
+	nix.FakeForTest(t, `case "$*" in
+*--version*) echo "nix (Nix) 2.21.2" ;;
+*path-info*) echo `+helloPath+" "+glibcPath+` ;;
+esac
+`)
+
+	d := devboxForTesting(t)
+	d.cfg.PackageMutator().Add("hello@2.12")
+	d.lockfile.Packages["hello@2.12"] = &lock.Package{
+		Resolved: "github:NixOS/nixpkgs/0000000000000000000000000000000000000000#hello",
+		Version:  "2.12.1",
+		Systems: map[string]*lock.SystemInfo{nix.System(): {
+			Outputs: []lock.Output{{Name: "out", Path: helloPath, Default: true}},
+		}},
+	}
+
+	reasons, err := d.InstallReasons(context.Background(), "glibc")
+	require.NoError(t, err)
+	require.Equal(t, []InstallReason{{Package: "glibc@2.39", DependencyOf: "hello@2.12", Installed: true}}, reasons)
+
+	// A package's own outputs aren't its dependencies.
+	reasons, err = d.InstallReasons(context.Background(), "hello")
+	require.NoError(t, err)
+	require.Len(t, reasons, 1)
+	require.Empty(t, reasons[0].DependencyOf)
+
+	_, err = d.InstallReasons(context.Background(), "zlib")
+	require.Error(t, err)
+}
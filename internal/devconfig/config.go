@@ -1,6 +1,7 @@
 package devconfig
 
 import (
+	"cmp"
 	"context"
 	"fmt"
 	"io"
@@ -9,6 +10,7 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"slices"
 	"syscall"
 	"time"
 
@@ -19,6 +21,7 @@ import (
 	"go.jetpack.io/devbox/internal/devbox/shellcmd"
 	"go.jetpack.io/devbox/internal/devconfig/configfile"
 	"go.jetpack.io/devbox/internal/lock"
+	"go.jetpack.io/devbox/internal/netpolicy"
 	"go.jetpack.io/devbox/internal/plugin"
 )
 
@@ -198,7 +201,7 @@ func LoadConfigFromURL(ctx context.Context, url string) (*Config, error) {
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
-	res, err := http.DefaultClient.Do(req)
+	res, err := netpolicy.Client.Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -339,17 +342,131 @@ func (c *Config) Packages(
 	))
 }
 
+// PackageOrigin is one way a package ends up in a project: either it's in
+// devbox.json, or it's in an included plugin.
+type PackageOrigin struct {
+	Package configfile.Package
+
+	// Plugins is the chain of plugins that includes the package, starting
+	// with the plugin included by devbox.json and ending with the plugin
+	// that lists the package. It's empty if the package is in devbox.json.
+	Plugins []plugin.Includable
+}
+
+// PackageOrigins returns where the packages with the given name or versioned
+// name come from. A package can have more than one origin. Unlike Packages,
+// it also returns packages that are overridden by another package of the same
+// name.
+func (c *Config) PackageOrigins(name string) []PackageOrigin {
+	return c.packageOrigins(name, nil)
+}
+
+func (c *Config) packageOrigins(name string, plugins []plugin.Includable) []PackageOrigin {
+	origins := []PackageOrigin{}
+	for _, i := range c.included {
+		chain := append(slices.Clone(plugins), i.pluginData.Source)
+		origins = append(origins, i.packageOrigins(name, chain)...)
+	}
+	for _, pkg := range c.Root.TopLevelPackages() {
+		if pkg.Name == name || pkg.VersionedName() == name {
+			origins = append(origins, PackageOrigin{Package: pkg, Plugins: plugins})
+		}
+	}
+	return origins
+}
+
 func (c *Config) NixPkgsCommitHash() string {
 	return c.Root.NixPkgsCommitHash()
 }
 
+// Env returns the environment variables defined by the config and its
+// included plugins. When more than one plugin sets the same variable, the
+// plugin with the highest env priority wins, and plugins with equal priority
+// fall back to include order. Variables set in the config itself always take
+// precedence over plugins.
 func (c *Config) Env() map[string]string {
+	env, _ := c.envWithConflicts()
+	return env
+}
+
+// EnvConflict describes an environment variable that more than one plugin sets
+// to different values.
+type EnvConflict struct {
+	Name string
+	// Plugins lists the conflicting plugins in ascending precedence. The
+	// value of the last plugin is the one used in the environment.
+	Plugins []string
+}
+
+// EnvConflicts returns the environment variables that plugins set to
+// conflicting values. Variables that are also set by the config itself are
+// not considered conflicts because the config overrides all plugins.
+func (c *Config) EnvConflicts() []EnvConflict {
+	_, conflicts := c.envWithConflicts()
+	return conflicts
+}
+
+// pluginEnv is the set of environment variables contributed by a single
+// plugin.
+type pluginEnv struct {
+	name     string
+	priority int
+	env      map[string]string
+}
+
+func (c *Config) envWithConflicts() (map[string]string, []EnvConflict) {
+	plugins := c.pluginEnvs()
+	for i := range plugins {
+		if p, ok := c.Root.PluginEnvPriority[plugins[i].name]; ok {
+			plugins[i].priority = p
+		}
+	}
+	slices.SortStableFunc(plugins, func(a, b pluginEnv) int {
+		return cmp.Compare(a.priority, b.priority)
+	})
+
 	env := map[string]string{}
-	for _, i := range c.included {
-		maps.Copy(env, i.Env())
+	setBy := map[string][]string{}
+	conflicting := map[string]bool{}
+	for _, p := range plugins {
+		for k, v := range p.env {
+			if old, ok := env[k]; ok && old != v {
+				conflicting[k] = true
+			}
+			env[k] = v
+			setBy[k] = append(setBy[k], p.name)
+		}
 	}
+
+	names := lo.Keys(conflicting)
+	slices.Sort(names)
+	conflicts := []EnvConflict{}
+	for _, k := range names {
+		if _, ok := c.Root.Env[k]; ok {
+			continue
+		}
+		conflicts = append(conflicts, EnvConflict{Name: k, Plugins: setBy[k]})
+	}
+
 	maps.Copy(env, c.Root.Env)
-	return env
+	return env, conflicts
+}
+
+// pluginEnvs returns the environment variables of every included plugin,
+// in include order.
+func (c *Config) pluginEnvs() []pluginEnv {
+	envs := []pluginEnv{}
+	for _, i := range c.included {
+		envs = append(envs, i.pluginEnvs()...)
+	}
+	if c.pluginData != nil && len(c.Root.Env) > 0 {
+		envs = append(envs, pluginEnv{
+			name:     c.pluginData.Source.CanonicalName(),
+			priority: c.pluginData.EnvPriority,
+			env:      c.Root.Env,
+		})
+	}
+	return envs
 }
 
 func (c *Config) InitHook() *shellcmd.Commands {
@@ -11,6 +11,7 @@ import (
 	"github.com/pkg/errors"
 	"github.com/tailscale/hujson"
 	"go.jetpack.io/devbox/internal/devconfig/configfile"
+	"go.jetpack.io/devbox/internal/plugin"
 )
 
 func TestOpen(t *testing.T) {
@@ -327,3 +328,106 @@ func TestDefault(t *testing.T) {
 		t.Errorf("got different JSON after load/save/load:\ninput:\n%s\noutput:\n%s", inBytes, outBytes)
 	}
 }
+
+type fakeIncludable struct{ name string }
+
+func (f fakeIncludable) CanonicalName() string              { return f.name }
+func (f fakeIncludable) FileContent(string) ([]byte, error) { return nil, nil }
+func (f fakeIncludable) Hash() string                       { return f.name }
+func (f fakeIncludable) LockfileKey() string                { return f.name }
+
+func TestPackageOrigins(t *testing.T) {
+	root, err := configfile.LoadBytes([]byte(`{"packages": {"go": "1.22", "jq": "latest"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pluginRoot, err := configfile.LoadBytes([]byte(`{"packages": {"go": "1.21"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	source := fakeIncludable{name: "github:org/go-tools"}
+	cfg := &Config{
+		Root: *root,
+		included: []*Config{{
+			Root:       *pluginRoot,
+			pluginData: &plugin.PluginOnlyData{Source: source},
+		}},
+	}
+
+	origins := cfg.PackageOrigins("go")
+	if len(origins) != 2 {
+		t.Fatalf("got %d origins for go, want 2", len(origins))
+	}
+	if got := origins[0].Package.VersionedName(); got != "go@1.21" {
+		t.Errorf("got first origin %s, want go@1.21", got)
+	}
+	if plugins := origins[0].Plugins; len(plugins) != 1 || plugins[0] != source {
+		t.Errorf("got plugins %v for go@1.21, want [%v]", plugins, source)
+	}
+	if got := origins[1].Package.VersionedName(); got != "go@1.22" || len(origins[1].Plugins) != 0 {
+		t.Errorf("got second origin %s from %v, want go@1.22 from devbox.json", got, origins[1].Plugins)
+	}
+
+	if origins := cfg.PackageOrigins("jq@latest"); len(origins) != 1 {
+		t.Errorf("got %d origins for jq@latest, want 1", len(origins))
+	}
+	if origins := cfg.PackageOrigins("ripgrep"); len(origins) != 0 {
+		t.Errorf("got %d origins for ripgrep, want 0", len(origins))
+	}
+}
+
+func TestEnvPluginPriority(t *testing.T) {
+	pluginConfig := func(name, env string, priority int) *Config {
+		root, err := configfile.LoadBytes([]byte(env))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &Config{
+			Root: *root,
+			pluginData: &plugin.PluginOnlyData{
+				Source:      fakeIncludable{name: name},
+				EnvPriority: priority,
+			},
+		}
+	}
+	newConfig := func(rootJSON string) *Config {
+		root, err := configfile.LoadBytes([]byte(rootJSON))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &Config{
+			Root: *root,
+			included: []*Config{
+				pluginConfig("high", `{"env": {"PORT": "1", "HOST": "a"}}`, 10),
+				pluginConfig("low", `{"env": {"PORT": "2", "HOST": "a"}}`, 0),
+			},
+		}
+	}
+
+	// The plugin with the highest priority wins, even though it's
+	// included first.
+	cfg := newConfig(`{}`)
+	if got := cfg.Env()["PORT"]; got != "1" {
+		t.Errorf("got PORT=%s, want the value of the high priority plugin", got)
+	}
+	wantConflicts := []EnvConflict{{Name: "PORT", Plugins: []string{"low", "high"}}}
+	if diff := cmp.Diff(wantConflicts, cfg.EnvConflicts()); diff != "" {
+		t.Errorf("wrong conflicts (-want +got):\n%s", diff)
+	}
+
+	// plugin_env_priority overrides the priority declared by the plugin.
+	cfg = newConfig(`{"plugin_env_priority": {"low": 20}}`)
+	if got := cfg.Env()["PORT"]; got != "2" {
+		t.Errorf("got PORT=%s, want the value of the overridden plugin", got)
+	}
+
+	// Variables set in devbox.json win over every plugin, so they
+	// aren't conflicts.
+	cfg = newConfig(`{"env": {"PORT": "3"}}`)
+	if got := cfg.Env()["PORT"]; got != "3" {
+		t.Errorf("got PORT=%s, want the value from devbox.json", got)
+	}
+	if conflicts := cfg.EnvConflicts(); len(conflicts) != 0 {
+		t.Errorf("got conflicts %v, want none", conflicts)
+	}
+}
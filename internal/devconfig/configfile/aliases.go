@@ -0,0 +1,50 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package configfile
+
+import (
+	"strings"
+
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+)
+
+// ExpandAlias returns the package that name is an alias for in the aliases
+// field, and true. A version on the alias, such as "node@18", replaces the
+// version of the package it expands to. Names that aren't aliases are returned
+// unchanged with false.
+func (c *ConfigFile) ExpandAlias(name string) (string, bool) {
+	alias, version, hasVersion := strings.Cut(name, "@")
+	target, ok := c.Aliases[alias]
+	if !ok {
+		return name, false
+	}
+	if !hasVersion {
+		return target, true
+	}
+	targetName, _, _ := strings.Cut(target, "@")
+	return targetName + "@" + version, true
+}
+
+func validateAliases(cfg *ConfigFile) error {
+	for alias, target := range cfg.Aliases {
+		if alias == "" || whitespace.MatchString(alias) || strings.ContainsAny(alias, "@^#:") {
+			return usererr.New(
+				"Invalid alias %q in devbox.json. Aliases must be plain names without spaces, versions or outputs.",
+				alias,
+			)
+		}
+		if strings.TrimSpace(target) == "" {
+			return usererr.New("The alias %q in devbox.json doesn't name a package.", alias)
+		}
+		// Aliases are expanded once, so an alias of an alias wouldn't
+		// resolve to a real package.
+		if targetName, _, _ := strings.Cut(target, "@"); cfg.Aliases[targetName] != "" {
+			return usererr.New(
+				"The alias %q in devbox.json refers to the alias %q. Aliases must refer to packages.",
+				alias, targetName,
+			)
+		}
+	}
+	return nil
+}
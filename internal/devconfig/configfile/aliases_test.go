@@ -0,0 +1,43 @@
+package configfile
+
+import "testing"
+
+func TestExpandAlias(t *testing.T) {
+	cfg, err := LoadBytes([]byte(`{
+  "packages": {},
+  "aliases": {"node": "nodejs_20", "pg": "postgresql@16"}
+}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		in, want string
+		ok       bool
+	}{
+		{"node", "nodejs_20", true},
+		{"node@18", "nodejs_20@18", true},
+		{"pg", "postgresql@16", true},
+		{"pg@15", "postgresql@15", true},
+		{"nodejs", "nodejs", false},
+	}
+	for _, tc := range cases {
+		got, ok := cfg.ExpandAlias(tc.in)
+		if got != tc.want || ok != tc.ok {
+			t.Errorf("ExpandAlias(%q) = %q, %v, want %q, %v", tc.in, got, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestInvalidAliases(t *testing.T) {
+	for _, aliases := range []string{
+		`{"node@18": "nodejs_18"}`,
+		`{"node": ""}`,
+		`{"node": "js", "js": "nodejs_20"}`,
+	} {
+		_, err := LoadBytes([]byte(`{"packages": {}, "aliases": ` + aliases + `}`))
+		if err == nil {
+			t.Errorf("got nil error for aliases %s", aliases)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package configfile
+
+import (
+	"maps"
+	"slices"
+
+	"github.com/pkg/errors"
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+	"go.jetpack.io/devbox/internal/nix"
+)
+
+// expandAlternatives replaces each package that has alternatives with one
+// package per alternative, restricted to the alternative's platforms. The
+// alternatives keep the other settings of the package, such as its groups.
+// Packages without alternatives are returned as they are.
+func expandAlternatives(pkgs []Package) []Package {
+	if !slices.ContainsFunc(pkgs, func(p Package) bool { return len(p.Alternatives) > 0 }) {
+		return pkgs
+	}
+
+	expanded := make([]Package, 0, len(pkgs))
+	for _, pkg := range pkgs {
+		if len(pkg.Alternatives) == 0 {
+			expanded = append(expanded, pkg)
+			continue
+		}
+		selectors := make([]string, 0, len(pkg.Alternatives))
+		for selector := range pkg.Alternatives {
+			selectors = append(selectors, selector)
+		}
+		slices.Sort(selectors)
+		for _, selector := range selectors {
+			platforms, err := nix.PlatformsForSelector(selector)
+			if err != nil {
+				// Reported by validateAlternatives.
+				continue
+			}
+			alt := pkg
+			alt.Name, alt.Version = parseVersionedName(pkg.Alternatives[selector])
+			alt.Platforms = platforms
+			alt.Alternatives = nil
+			expanded = append(expanded, alt)
+		}
+	}
+	return expanded
+}
+
+// AlternativePackages returns the alternatives of the packages in the config,
+// for every platform.
+func (c *ConfigFile) AlternativePackages() []Package {
+	return expandAlternatives(slices.DeleteFunc(
+		slices.Clone(c.PackagesMutator.collection),
+		func(p Package) bool { return len(p.Alternatives) == 0 },
+	))
+}
+
+func validateAlternatives(cfg *ConfigFile) error {
+	for _, pkg := range cfg.PackagesMutator.collection {
+		if len(pkg.Alternatives) == 0 {
+			continue
+		}
+		if len(pkg.Platforms) > 0 {
+			return usererr.New(
+				"Package %s can't have both platforms and alternatives. Use the alternatives' platforms instead.",
+				pkg.VersionedName(),
+			)
+		}
+		for selector, alt := range pkg.Alternatives {
+			if _, err := nix.PlatformsForSelector(selector); err != nil {
+				return usererr.WithUserMessage(
+					err, "Invalid platform %q in the alternatives of package %s.", selector, pkg.VersionedName())
+			}
+			if alt == "" {
+				return usererr.New(
+					"The alternative for %q of package %s is empty.", selector, pkg.VersionedName())
+			}
+		}
+	}
+	return nil
+}
+
+// SetAlternatives sets the platform-specific alternatives of a package, adding
+// the package if it isn't in the config. The keys of alternatives are platform
+// selectors (see nix.PlatformsForSelector) and the values are the package to
+// install on those platforms.
+func (pkgs *PackagesMutator) SetAlternatives(versionedName string, alternatives map[string]string) error {
+	for selector := range alternatives {
+		if _, err := nix.PlatformsForSelector(selector); err != nil {
+			return err
+		}
+	}
+	name, version := parseVersionedName(versionedName)
+	i := pkgs.index(name, version)
+	if i == -1 {
+		pkgs.Add(versionedName)
+		i = len(pkgs.collection) - 1
+	}
+
+	pkg := &pkgs.collection[i]
+	if len(pkg.Platforms) > 0 {
+		return errors.Errorf("package %s can't have both platforms and alternatives", versionedName)
+	}
+	pkgs.ast.setPackageStringMap(pkg.Name, "alternatives", alternatives)
+	if pkg.Alternatives == nil {
+		pkg.Alternatives = map[string]string{}
+	}
+	maps.Copy(pkg.Alternatives, alternatives)
+	return nil
+}
+
+// removeAlternative removes versionedName from the alternatives of the package
+// that has it, and removes that package once it has no alternatives left. It
+// returns false if no package has versionedName as an alternative.
+func (pkgs *PackagesMutator) removeAlternative(versionedName string) bool {
+	for i, pkg := range pkgs.collection {
+		for selector, alt := range pkg.Alternatives {
+			if alt != versionedName {
+				continue
+			}
+			if len(pkg.Alternatives) == 1 {
+				pkgs.collection = slices.Delete(pkgs.collection, i, i+1)
+				pkgs.ast.removePackage(pkg.Name)
+				return true
+			}
+			delete(pkgs.collection[i].Alternatives, selector)
+			pkgs.ast.removePackageMapKey(pkg.Name, "alternatives", selector)
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,86 @@
+package configfile
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestTopLevelPackagesWithAlternatives(t *testing.T) {
+	cfg, err := LoadBytes([]byte(`{
+  "packages": {
+    "go": "1.22",
+    "cc": {
+      "groups": ["build"],
+      "alternatives": {"linux": "gcc@latest", "aarch64-darwin": "clang@17"}
+    }
+  }
+}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := cfg.TopLevelPackages()
+	want := []Package{
+		{Name: "go", Version: "1.22"},
+		{Name: "clang", Version: "17", Groups: []string{"build"}, Platforms: []string{"aarch64-darwin"}},
+		{Name: "gcc", Version: "latest", Groups: []string{"build"}, Platforms: []string{"aarch64-linux", "i686-linux", "x86_64-linux", "armv7l-linux"}},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("wrong packages (-want +got):\n%s", diff)
+	}
+	if got[2].IsEnabledOnSystem("x86_64-darwin") || !got[2].IsEnabledOnSystem("x86_64-linux") {
+		t.Errorf("got gcc platforms %v, want only linux", got[2].Platforms)
+	}
+}
+
+func TestInvalidAlternativePlatform(t *testing.T) {
+	_, err := LoadBytes([]byte(`{"packages": {"cc": {"alternatives": {"windows": "msvc"}}}}`))
+	if err == nil {
+		t.Error("got nil error for an alternative on an unsupported platform")
+	}
+}
+
+func TestSetAndRemoveAlternatives(t *testing.T) {
+	in, want := parseConfigTxtarTest(t, `
+-- in --
+{
+  "packages": {
+    "go": "1.22"
+  }
+}
+-- want --
+{
+  "packages": {
+    "go": "1.22",
+    "cc": {
+      "alternatives": {
+        "linux": "gcc@latest"
+      }
+    }
+  }
+}`)
+
+	err := in.PackagesMutator.SetAlternatives("cc", map[string]string{
+		"darwin": "clang@latest",
+		"linux":  "gcc@latest",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := len(in.TopLevelPackages()); got != 3 {
+		t.Errorf("got %d packages, want 3", got)
+	}
+
+	// Removing an alternative keeps the others.
+	in.PackagesMutator.Remove("clang@latest")
+	if diff := cmp.Diff(want, in.Bytes()); diff != "" {
+		t.Errorf("wrong raw config hujson (-want +got):\n%s", diff)
+	}
+
+	// Removing the last alternative removes the package.
+	in.PackagesMutator.Remove("gcc@latest")
+	if got := len(in.TopLevelPackages()); got != 1 {
+		t.Errorf("got %d packages, want 1", got)
+	}
+}
@@ -171,6 +171,82 @@ func (c *configAST) removePackageElement(arr *hujson.Array, name string) {
 	arr.Elements = slices.Delete(arr.Elements, i, i+1)
 }
 
+// setPackageVersion changes the version of a package, keeping any other
+// fields the package has.
+func (c *configAST) setPackageVersion(name, version string) {
+	switch val := c.packagesField(false).Value.Value.(type) {
+	case *hujson.Object:
+		i := c.memberIndex(val, name)
+		if i == -1 {
+			return
+		}
+		pkg := &val.Members[i].Value
+		obj, ok := pkg.Value.(*hujson.Object)
+		if !ok {
+			pkg.Value = hujson.String(version)
+			break
+		}
+		if j := c.memberIndex(obj, "version"); j != -1 {
+			obj.Members[j].Value.Value = hujson.String(version)
+		} else {
+			obj.Members = append(obj.Members, hujson.ObjectMember{
+				Name: hujson.Value{
+					Value:       hujson.String("version"),
+					BeforeExtra: []byte{'\n'},
+				},
+				Value: hujson.Value{Value: hujson.String(version)},
+			})
+		}
+	case *hujson.Array:
+		i := c.packageElementIndex(val, name)
+		if i == -1 {
+			return
+		}
+		val.Elements[i].Value = hujson.String(joinNameVersion(name, version))
+	default:
+		panic("packages field must be an object or array")
+	}
+	c.root.Format()
+}
+
+// setPackage sets the value of a package in the packages field, adding the
+// package if it isn't there. A legacy packages array is migrated to an object.
+func (c *configAST) setPackage(name string, val hujson.Value) {
+	pkgs := c.packagesField(true).Value.Value.(*hujson.Object)
+	if i := c.memberIndex(pkgs, name); i != -1 {
+		pkgs.Members[i].Value.Value = val.Value
+	} else {
+		pkgs.Members = append(pkgs.Members, hujson.ObjectMember{
+			Name:  hujson.Value{Value: hujson.String(name), BeforeExtra: []byte{'\n'}},
+			Value: val,
+		})
+	}
+	c.root.Format()
+}
+
+// renamePackage changes the name of a package, keeping its position and
+// fields, and sets its version.
+func (c *configAST) renamePackage(name, newName, version string) {
+	switch val := c.packagesField(false).Value.Value.(type) {
+	case *hujson.Object:
+		i := c.memberIndex(val, name)
+		if i == -1 {
+			return
+		}
+		val.Members[i].Name.Value = hujson.String(newName)
+		c.setPackageVersion(newName, version)
+	case *hujson.Array:
+		i := c.packageElementIndex(val, name)
+		if i == -1 {
+			return
+		}
+		val.Elements[i].Value = hujson.String(joinNameVersion(newName, version))
+		c.root.Format()
+	default:
+		panic("packages field must be an object or array")
+	}
+}
+
 // setPackageBool sets a bool field on a package.
 func (c *configAST) setPackageBool(name, fieldName string, val bool) {
 	pkgObject := c.FindPkgObject(name)
@@ -192,6 +268,112 @@ func (c *configAST) setPackageBool(name, fieldName string, val bool) {
 	c.root.Format()
 }
 
+// setPackageString sets a string field on a package.
+func (c *configAST) setPackageString(name, fieldName, val string) {
+	pkgObject := c.FindPkgObject(name)
+	if pkgObject == nil {
+		return
+	}
+	if i := c.memberIndex(pkgObject, fieldName); i == -1 {
+		pkgObject.Members = append(pkgObject.Members, hujson.ObjectMember{
+			Name: hujson.Value{
+				Value:       hujson.String(fieldName),
+				BeforeExtra: []byte{'\n'},
+			},
+			Value: hujson.Value{Value: hujson.String(val)},
+		})
+	} else {
+		pkgObject.Members[i].Value.Value = hujson.String(val)
+	}
+
+	c.root.Format()
+}
+
+// removePackageField removes a field from a package, if the package is an
+// object that has it.
+func (c *configAST) removePackageField(name, fieldName string) {
+	pkgs, ok := c.packagesField(false).Value.Value.(*hujson.Object)
+	if !ok {
+		return
+	}
+	i := c.memberIndex(pkgs, name)
+	if i == -1 {
+		return
+	}
+	pkgObject, ok := pkgs.Members[i].Value.Value.(*hujson.Object)
+	if !ok {
+		return
+	}
+	if j := c.memberIndex(pkgObject, fieldName); j != -1 {
+		pkgObject.Members = slices.Delete(pkgObject.Members, j, j+1)
+		c.root.Format()
+	}
+}
+
+// removePackageMapKey removes a key from a map field of a package, such as
+// alternatives.
+func (c *configAST) removePackageMapKey(name, fieldName, key string) {
+	pkgObject := c.FindPkgObject(name)
+	if pkgObject == nil {
+		return
+	}
+	i := c.memberIndex(pkgObject, fieldName)
+	if i == -1 {
+		return
+	}
+	obj, ok := pkgObject.Members[i].Value.Value.(*hujson.Object)
+	if !ok {
+		return
+	}
+	if j := c.memberIndex(obj, key); j != -1 {
+		obj.Members = slices.Delete(obj.Members, j, j+1)
+		c.root.Format()
+	}
+}
+
+// setPackageStringMap sets keys in a string map field of a package, such as
+// build_env, adding the field if the package doesn't have it.
+func (c *configAST) setPackageStringMap(name, fieldName string, values map[string]string) {
+	pkgObject := c.FindPkgObject(name)
+	if pkgObject == nil {
+		return
+	}
+
+	var obj *hujson.Object
+	if i := c.memberIndex(pkgObject, fieldName); i == -1 {
+		obj = &hujson.Object{}
+		pkgObject.Members = append(pkgObject.Members, hujson.ObjectMember{
+			Name: hujson.Value{
+				Value:       hujson.String(fieldName),
+				BeforeExtra: []byte{'\n'},
+			},
+			Value: hujson.Value{Value: obj},
+		})
+	} else {
+		obj = pkgObject.Members[i].Value.Value.(*hujson.Object)
+	}
+
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	for _, k := range keys {
+		if j := c.memberIndex(obj, k); j != -1 {
+			obj.Members[j].Value.Value = hujson.String(values[k])
+			continue
+		}
+		obj.Members = append(obj.Members, hujson.ObjectMember{
+			Name: hujson.Value{
+				Value:       hujson.String(k),
+				BeforeExtra: []byte{'\n'},
+			},
+			Value: hujson.Value{Value: hujson.String(values[k])},
+		})
+	}
+	c.root.Format()
+}
+
 func (c *configAST) appendPlatforms(name, fieldName string, platforms []string) {
 	if len(platforms) == 0 {
 		return
@@ -54,3 +54,40 @@ func (c *configAST) setStringField(key, val string) {
 
 	c.root.Format()
 }
+
+// SetNixpkgsCommit sets nixpkgs.commit, which is the nixpkgs commit that
+// legacy packages and the project's stdenv come from.
+func (c *ConfigFile) SetNixpkgsCommit(commit string) {
+	if c.Nixpkgs == nil {
+		c.Nixpkgs = &NixpkgsConfig{}
+	}
+	c.Nixpkgs.Commit = commit
+	c.ast.setNixpkgsCommit(commit)
+}
+
+func (c *configAST) setNixpkgsCommit(commit string) {
+	rootObject := c.root.Value.(*hujson.Object)
+	i := c.memberIndex(rootObject, "nixpkgs")
+	if i == -1 {
+		rootObject.Members = append(rootObject.Members, hujson.ObjectMember{
+			Name:  hujson.Value{Value: hujson.String("nixpkgs")},
+			Value: hujson.Value{Value: &hujson.Object{}},
+		})
+		i = len(rootObject.Members) - 1
+	}
+	nixpkgs, ok := rootObject.Members[i].Value.Value.(*hujson.Object)
+	if !ok {
+		nixpkgs = &hujson.Object{}
+		rootObject.Members[i].Value.Value = nixpkgs
+	}
+	if j := c.memberIndex(nixpkgs, "commit"); j == -1 {
+		nixpkgs.Members = append(nixpkgs.Members, hujson.ObjectMember{
+			Name:  hujson.Value{Value: hujson.String("commit")},
+			Value: hujson.Value{Value: hujson.String(commit)},
+		})
+	} else {
+		nixpkgs.Members[j].Value.Value = hujson.String(commit)
+	}
+
+	c.root.Format()
+}
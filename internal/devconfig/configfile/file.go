@@ -17,12 +17,20 @@ import (
 	"go.jetpack.io/devbox/internal/boxcli/usererr"
 	"go.jetpack.io/devbox/internal/cachehash"
 	"go.jetpack.io/devbox/internal/devbox/shellcmd"
+	"go.jetpack.io/devbox/internal/devpkg/pkgtype"
 )
 
 const (
 	DefaultName = "devbox.json"
 )
 
+// Values for the plugin_failure_policy field.
+const (
+	PluginFailurePolicyFail = "fail"
+	PluginFailurePolicyWarn = "warn"
+	PluginFailurePolicySkip = "skip"
+)
+
 // ConfigFile defines a devbox environment as JSON.
 type ConfigFile struct {
 	// AbsRootPath is the absolute path to the devbox.json or plugin.json file
@@ -42,6 +50,56 @@ type ConfigFile struct {
 	// Only allows "envsec" for now
 	EnvFrom string `json:"env_from,omitempty"`
 
+	// PluginEnvPriority overrides the env priority declared by plugins, keyed
+	// by plugin name. When several plugins set the same env variable, the one
+	// with the highest priority wins.
+	PluginEnvPriority map[string]int `json:"plugin_env_priority,omitempty"`
+
+	// SourcePreference orders the package sources ("nixpkgs", "flake",
+	// "runx") to use when a package name added to the project could come
+	// from more than one of them. The first source that has the package is
+	// used.
+	SourcePreference []string `json:"source_preference,omitempty"`
+
+	// Aliases are shorthand names for packages, such as {"node":
+	// "nodejs_20"}, that devbox add expands before resolving the packages.
+	// See ExpandAlias.
+	Aliases map[string]string `json:"aliases,omitempty"`
+
+	// PluginFailurePolicy controls what happens when a plugin fails to
+	// create one of its files. It is one of "fail" (the default), "warn"
+	// (create the plugin's other files) or "skip" (skip the plugin's
+	// remaining files).
+	PluginFailurePolicy string `json:"plugin_failure_policy,omitempty"`
+
+	// Proxy is the URL of an HTTP proxy for Devbox's network requests. It's
+	// only used when the HTTP_PROXY and HTTPS_PROXY environment variables
+	// aren't set.
+	Proxy string `json:"proxy,omitempty"`
+
+	// MaxJobs is the number of nix builds that may run in parallel when
+	// installing packages, passed to nix as --max-jobs. Nil uses nix's
+	// setting.
+	MaxJobs *int `json:"max_jobs,omitempty"`
+
+	// Cores is the number of CPU cores that each nix build may use, passed to
+	// nix as --cores. Zero means all cores, and nil uses nix's setting.
+	Cores *int `json:"cores,omitempty"`
+
+	// GroupProfiles installs the packages of each group into a Nix profile
+	// of its own, so that shells can put only some groups on the PATH.
+	// Packages without a group stay in the default profile.
+	GroupProfiles bool `json:"group_profiles,omitempty"`
+
+	// AuditLog configures the log of package changes made by devbox add and
+	// devbox rm. It's disabled unless enabled is set.
+	AuditLog *AuditLogConfig `json:"audit_log,omitempty"`
+
+	// SuppressRefreshWarning turns off the warning that a devbox shell's
+	// environment is out of date after packages change. It's a pointer so
+	// that a project can set it to false to override a global true.
+	SuppressRefreshWarning *bool `json:"suppress_refresh_warning,omitempty"`
+
 	// Shell configures the devbox shell environment.
 	Shell *shellConfig `json:"shell,omitempty"`
 	// Nixpkgs specifies the repository to pull packages from
@@ -62,6 +120,16 @@ type shellConfig struct {
 	// InitHook contains commands that will run at shell startup.
 	InitHook *shellcmd.Commands            `json:"init_hook,omitempty"`
 	Scripts  map[string]*shellcmd.Commands `json:"scripts,omitempty"`
+	// AfterAdd contains commands that will run after `devbox add` changes
+	// the packages in devbox.json.
+	AfterAdd *shellcmd.Commands `json:"after_add,omitempty"`
+}
+
+type AuditLogConfig struct {
+	Enabled bool `json:"enabled,omitempty"`
+	// Path is where the log is written, relative to the project directory.
+	// Defaults to .devbox/audit.log.
+	Path string `json:"path,omitempty"`
 }
 
 type NixpkgsConfig struct {
@@ -110,6 +178,25 @@ func (c *ConfigFile) InitHook() *shellcmd.Commands {
 	return c.Shell.InitHook
 }
 
+func (c *ConfigFile) AfterAddHook() *shellcmd.Commands {
+	if c == nil || c.Shell == nil || c.Shell.AfterAdd == nil {
+		return &shellcmd.Commands{}
+	}
+	return c.Shell.AfterAdd
+}
+
+// AuditLogPath returns the path of the audit log relative to the project
+// directory, or "" if the audit log is disabled.
+func (c *ConfigFile) AuditLogPath() string {
+	if c == nil || c.AuditLog == nil || !c.AuditLog.Enabled {
+		return ""
+	}
+	if c.AuditLog.Path == "" {
+		return filepath.Join(".devbox", "audit.log")
+	}
+	return c.AuditLog.Path
+}
+
 // SaveTo writes the config to a file.
 func (c *ConfigFile) SaveTo(path string) error {
 	return os.WriteFile(filepath.Join(path, DefaultName), c.Bytes(), 0o644)
@@ -125,10 +212,19 @@ func (c *ConfigFile) GetPackage(versionedName string) (*Package, bool) {
 	return &c.PackagesMutator.collection[i], true
 }
 
+// ConfiguredPackages returns the packages as they're written in the config
+// file. Unlike TopLevelPackages, a package with alternatives is returned as a
+// single package.
+func (c *ConfigFile) ConfiguredPackages() []Package {
+	return slices.Clone(c.PackagesMutator.collection)
+}
+
 // TopLevelPackages returns the packages in the config file, but not the included ones.
 // Semi-awkwardly named to avoid confusion with the Packages method on Config.
+// A package with alternatives is replaced by its alternatives, each limited to
+// the platforms it's for.
 func (c *ConfigFile) TopLevelPackages() []Package {
-	return c.PackagesMutator.collection
+	return expandAlternatives(c.PackagesMutator.collection)
 }
 
 func LoadBytes(b []byte) (*ConfigFile, error) {
@@ -155,6 +251,11 @@ func validateConfig(cfg *ConfigFile) error {
 	fns := []func(cfg *ConfigFile) error{
 		ValidateNixpkg,
 		validateScripts,
+		validateSourcePreference,
+		validateAutoUpdate,
+		validatePluginFailurePolicy,
+		validateAlternatives,
+		validateAliases,
 	}
 
 	for _, fn := range fns {
@@ -185,6 +286,50 @@ func validateScripts(cfg *ConfigFile) error {
 	return nil
 }
 
+func validateSourcePreference(cfg *ConfigFile) error {
+	for i, source := range cfg.SourcePreference {
+		if !slices.Contains(pkgtype.Sources, source) {
+			return usererr.New(
+				"Invalid source %q in source_preference. Valid sources are: %s",
+				source,
+				strings.Join(pkgtype.Sources, ", "),
+			)
+		}
+		if slices.Contains(cfg.SourcePreference[:i], source) {
+			return usererr.New("Source %q is listed more than once in source_preference", source)
+		}
+	}
+	return nil
+}
+
+func validateAutoUpdate(cfg *ConfigFile) error {
+	for _, pkg := range cfg.TopLevelPackages() {
+		if pkg.AutoUpdate != "" && pkg.AutoUpdate != AutoUpdateSecurity {
+			return usererr.New(
+				"Invalid auto_update value %q for package %s. The only supported value is %q",
+				pkg.AutoUpdate,
+				pkg.VersionedName(),
+				AutoUpdateSecurity,
+			)
+		}
+	}
+	return nil
+}
+
+func validatePluginFailurePolicy(cfg *ConfigFile) error {
+	switch cfg.PluginFailurePolicy {
+	case "", PluginFailurePolicyFail, PluginFailurePolicyWarn, PluginFailurePolicySkip:
+		return nil
+	}
+	return usererr.New(
+		"Invalid plugin_failure_policy %q. Valid values are: %s, %s, %s",
+		cfg.PluginFailurePolicy,
+		PluginFailurePolicyFail,
+		PluginFailurePolicyWarn,
+		PluginFailurePolicySkip,
+	)
+}
+
 func ValidateNixpkg(cfg *ConfigFile) error {
 	hash := cfg.NixPkgsCommitHash()
 	if hash == "" {
@@ -4,6 +4,7 @@ package configfile
 import (
 	"encoding/json"
 	"io"
+	"slices"
 	"strings"
 	"testing"
 
@@ -590,6 +591,47 @@ func TestExcludePlatforms(t *testing.T) {
 	}
 }
 
+func TestSetExcludedPlatforms(t *testing.T) {
+	in, want := parseConfigTxtarTest(t, `
+-- in --
+{
+  "packages": {
+    "go": {
+      "version":            "1.20",
+      "excluded_platforms": ["aarch64-darwin", "x86_64-darwin"]
+    }
+  }
+}
+-- want --
+{
+  "packages": {
+    "go": {
+      "version":            "1.20",
+      "excluded_platforms": ["x86_64-linux"]
+    }
+  }
+}`)
+
+	err := in.PackagesMutator.SetExcludedPlatforms(io.Discard, "go@1.20", []string{"x86_64-linux"})
+	if err != nil {
+		t.Error(err)
+	}
+	if diff := cmp.Diff(want, in.Bytes(), optParseHujson()); diff != "" {
+		t.Errorf("wrong parsed config json (-want +got):\n%s", diff)
+	}
+
+	err = in.PackagesMutator.SetExcludedPlatforms(io.Discard, "go@1.20", nil)
+	if err != nil {
+		t.Error(err)
+	}
+	if pkgs := in.TopLevelPackages(); len(pkgs[0].ExcludedPlatforms) != 0 {
+		t.Errorf("got excluded platforms %v, want none", pkgs[0].ExcludedPlatforms)
+	}
+	if strings.Contains(string(in.Bytes()), "excluded_platforms") {
+		t.Errorf("got excluded_platforms field in config after clearing it:\n%s", in.Bytes())
+	}
+}
+
 func TestSetOutputs(t *testing.T) {
 	in, want := parseConfigTxtarTest(t, `
 -- in --
@@ -652,6 +694,130 @@ func TestSetOutputsMigrateArray(t *testing.T) {
 	}
 }
 
+func TestSetVersion(t *testing.T) {
+	in, want := parseConfigTxtarTest(t, `
+-- in --
+{
+  "packages": {
+    "go": "1.21",
+    "prometheus": {
+      "version": "2.40",
+      "outputs": ["cli"]
+    }
+  }
+}
+-- want --
+{
+  "packages": {
+    "go": "1.22.5",
+    "prometheus": {
+      "version": "2.53.0",
+      "outputs": ["cli"]
+    }
+  }
+}`)
+
+	if err := in.PackagesMutator.SetVersion("go@1.21", "1.22.5"); err != nil {
+		t.Error(err)
+	}
+	if err := in.PackagesMutator.SetVersion("prometheus@2.40", "2.53.0"); err != nil {
+		t.Error(err)
+	}
+	if diff := cmp.Diff(want, in.Bytes(), optParseHujson()); diff != "" {
+		t.Errorf("wrong parsed config json (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(want, in.Bytes()); diff != "" {
+		t.Errorf("wrong raw config hujson (-want +got):\n%s", diff)
+	}
+}
+
+func TestSetVersionArray(t *testing.T) {
+	in, want := parseConfigTxtarTest(t, `
+-- in --
+{
+  "packages": ["hello", "go@1.21"]
+}
+-- want --
+{
+  "packages": ["hello", "go@1.22.5"]
+}`)
+
+	if err := in.PackagesMutator.SetVersion("go@1.21", "1.22.5"); err != nil {
+		t.Error(err)
+	}
+	if diff := cmp.Diff(want, in.Bytes()); diff != "" {
+		t.Errorf("wrong raw config hujson (-want +got):\n%s", diff)
+	}
+}
+
+func TestRename(t *testing.T) {
+	in, want := parseConfigTxtarTest(t, `
+-- in --
+{
+  "packages": {
+    "go": "1.21",
+    "python": {
+      "version": "3.11",
+      "platforms": ["x86_64-linux"],
+      "patch_glibc": true
+    }
+  }
+}
+-- want --
+{
+  "packages": {
+    "go": "1.22",
+    "python3": {
+      "version":     "3.12",
+      "platforms":   ["x86_64-linux"],
+      "patch_glibc": true
+    }
+  }
+}`)
+
+	if err := in.PackagesMutator.Rename("go@1.21", "go@1.22"); err != nil {
+		t.Error(err)
+	}
+	if err := in.PackagesMutator.Rename("python@3.11", "python3@3.12"); err != nil {
+		t.Error(err)
+	}
+	if err := in.PackagesMutator.Rename("python3@3.12", "go@1.22"); err == nil {
+		t.Error("got nil error renaming to a package that's already in the config")
+	}
+	if diff := cmp.Diff(want, in.Bytes()); diff != "" {
+		t.Errorf("wrong raw config hujson (-want +got):\n%s", diff)
+	}
+}
+
+func TestSetReason(t *testing.T) {
+	in, want := parseConfigTxtarTest(t, `
+-- in --
+{
+  "packages": {
+    "jq": "latest"
+  }
+}
+-- want --
+{
+  "packages": {
+    "jq": {
+      "version": "latest",
+      "reason":  "needed for the release script"
+    }
+  }
+}`)
+
+	if err := in.PackagesMutator.SetReason("jq@latest", "needed for the release script"); err != nil {
+		t.Error(err)
+	}
+	if diff := cmp.Diff(want, in.Bytes()); diff != "" {
+		t.Errorf("wrong raw config hujson (-want +got):\n%s", diff)
+	}
+	if got := in.TopLevelPackages()[0].Reason; got != "needed for the release script" {
+		t.Errorf("got Reason %q, want the new reason", got)
+	}
+}
+
 func TestSetAllowInsecure(t *testing.T) {
 	in, want := parseConfigTxtarTest(t, `
 -- in --
@@ -684,6 +850,125 @@ func TestSetAllowInsecure(t *testing.T) {
 	}
 }
 
+func TestAddGroup(t *testing.T) {
+	in, want := parseConfigTxtarTest(t, `
+-- in --
+{
+  "packages": {
+    "go": {
+      "version": "1.22"
+    }
+  }
+}
+-- want --
+{
+  "packages": {
+    "go": {
+      "version": "1.22",
+      "groups":  ["backend", "tools"]
+    }
+  }
+}`)
+
+	for _, group := range []string{"backend", "tools", "backend"} {
+		if err := in.PackagesMutator.AddGroup(io.Discard, "go@1.22", group); err != nil {
+			t.Error(err)
+		}
+	}
+	if diff := cmp.Diff(want, in.Bytes(), optParseHujson()); diff != "" {
+		t.Errorf("wrong parsed config json (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(want, in.Bytes()); diff != "" {
+		t.Errorf("wrong raw config hujson (-want +got):\n%s", diff)
+	}
+	if got := in.PackagesMutator.collection[0].Groups; !slices.Equal(got, []string{"backend", "tools"}) {
+		t.Errorf("got groups %v, want [backend tools]", got)
+	}
+}
+
+func TestSetDisabled(t *testing.T) {
+	in, want := parseConfigTxtarTest(t, `
+-- in --
+{
+  "packages": {
+    "go": {
+      "version":   "1.22",
+      "platforms": ["x86_64-linux"]
+    }
+  }
+}
+-- want --
+{
+  "packages": {
+    "go": {
+      "version":   "1.22",
+      "platforms": ["x86_64-linux"],
+      "disabled":  true
+    }
+  }
+}`)
+
+	if err := in.PackagesMutator.SetDisabled("go@1.22", true); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(want, in.Bytes()); diff != "" {
+		t.Errorf("wrong raw config hujson after disabling (-want +got):\n%s", diff)
+	}
+
+	if err := in.PackagesMutator.SetDisabled("go@1.22", false); err != nil {
+		t.Fatal(err)
+	}
+	want = []byte(`{
+  "packages": {
+    "go": {
+      "version":   "1.22",
+      "platforms": ["x86_64-linux"]
+    }
+  }
+}
+`)
+	if diff := cmp.Diff(want, in.Bytes()); diff != "" {
+		t.Errorf("wrong raw config hujson after enabling (-want +got):\n%s", diff)
+	}
+}
+
+func TestSetBuildEnv(t *testing.T) {
+	in, want := parseConfigTxtarTest(t, `
+-- in --
+{
+  "packages": {
+    "go": "1.22"
+  }
+}
+-- want --
+{
+  "packages": {
+    "go": {
+      "version": "1.22",
+      "build_env": {
+        "GOFLAGS":     "-trimpath",
+        "CGO_ENABLED": "0"
+      }
+    }
+  }
+}`)
+
+	err := in.PackagesMutator.SetBuildEnv(io.Discard, "go@1.22", map[string]string{"GOFLAGS": "-trimpath"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = in.PackagesMutator.SetBuildEnv(io.Discard, "go@1.22", map[string]string{"CGO_ENABLED": "0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(want, in.Bytes()); diff != "" {
+		t.Errorf("wrong raw config hujson after setting build_env (-want +got):\n%s", diff)
+	}
+	if got := in.PackagesMutator.collection[0].BuildEnv; len(got) != 2 || got["GOFLAGS"] != "-trimpath" {
+		t.Errorf("got BuildEnv %v, want GOFLAGS and CGO_ENABLED", got)
+	}
+}
+
 func TestNixpkgsValidation(t *testing.T) {
 	testCases := map[string]struct {
 		commit   string
@@ -710,3 +995,81 @@ func TestNixpkgsValidation(t *testing.T) {
 		})
 	}
 }
+
+func TestSetNixpkgsCommit(t *testing.T) {
+	in, want := parseConfigTxtarTest(t, `
+-- in --
+{
+  // Pinned for the release.
+  "nixpkgs": {"commit": "75a52265bda7fd25e06e3a67dee3f0354e73243c"},
+  "packages": ["go@latest"]
+}
+-- want --
+{
+  // Pinned for the release.
+  "nixpkgs":  {"commit": "af9e00071d0971eb292fd5abef334e66eda3cb69"},
+  "packages": ["go@latest"],
+}`)
+
+	in.SetNixpkgsCommit("af9e00071d0971eb292fd5abef334e66eda3cb69")
+	if diff := cmp.Diff(want, in.Bytes()); diff != "" {
+		t.Errorf("wrong raw config hujson (-want +got):\n%s", diff)
+	}
+	if got := in.NixPkgsCommitHash(); got != "af9e00071d0971eb292fd5abef334e66eda3cb69" {
+		t.Errorf("got NixPkgsCommitHash %q", got)
+	}
+
+	in, want = parseConfigTxtarTest(t, `
+-- in --
+{}
+-- want --
+{
+  "nixpkgs": {"commit": "af9e00071d0971eb292fd5abef334e66eda3cb69"}
+}`)
+	in.SetNixpkgsCommit("af9e00071d0971eb292fd5abef334e66eda3cb69")
+	if diff := cmp.Diff(want, in.Bytes(), optParseHujson()); diff != "" {
+		t.Errorf("wrong parsed config json (-want +got):\n%s", diff)
+	}
+}
+
+func TestPut(t *testing.T) {
+	in, want := parseConfigTxtarTest(t, `
+-- in --
+{
+  "packages": {
+    "go":    "1.20",
+    "hello": "latest"
+  }
+}
+-- want --
+{
+  "packages": {
+    "go": {
+      "version":            "1.22",
+      "excluded_platforms": ["aarch64-darwin"],
+      "patch_glibc":        true
+    },
+    "hello": "latest",
+    "jq":    "1.7"
+  }
+}`)
+
+	err := in.PackagesMutator.Put(Package{
+		Name:              "go",
+		Version:           "1.22",
+		ExcludedPlatforms: []string{"aarch64-darwin"},
+		PatchGlibc:        true,
+	})
+	if err != nil {
+		t.Error(err)
+	}
+	if err := in.PackagesMutator.Put(Package{Name: "jq", Version: "1.7"}); err != nil {
+		t.Error(err)
+	}
+	if diff := cmp.Diff(want, in.Bytes(), optParseHujson()); diff != "" {
+		t.Errorf("wrong parsed config json (-want +got):\n%s", diff)
+	}
+	if got, ok := in.GetPackage("go@1.22"); !ok || !got.PatchGlibc {
+		t.Errorf("got package %+v, want go@1.22 with patch_glibc", got)
+	}
+}
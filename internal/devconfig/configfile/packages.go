@@ -4,10 +4,12 @@ package configfile
 import (
 	"encoding/json"
 	"io"
+	"maps"
 	"slices"
 	"strings"
 
 	"github.com/pkg/errors"
+	"github.com/tailscale/hujson"
 	orderedmap "github.com/wk8/go-ordered-map/v2"
 	"go.jetpack.io/devbox/internal/boxcli/usererr"
 	"go.jetpack.io/devbox/internal/nix"
@@ -37,12 +39,87 @@ func (pkgs *PackagesMutator) Remove(versionedName string) {
 	name, version := parseVersionedName(versionedName)
 	i := pkgs.index(name, version)
 	if i == -1 {
+		pkgs.removeAlternative(versionedName)
 		return
 	}
 	pkgs.collection = slices.Delete(pkgs.collection, i, i+1)
 	pkgs.ast.removePackage(name)
 }
 
+// SetVersion changes the version of a package without changing its other
+// options.
+func (pkgs *PackagesMutator) SetVersion(versionedName, newVersion string) error {
+	name, version := parseVersionedName(versionedName)
+	i := pkgs.index(name, version)
+	if i == -1 {
+		return errors.Errorf("package %s not found", versionedName)
+	}
+	if version != newVersion {
+		pkgs.collection[i].Version = newVersion
+		pkgs.ast.setPackageVersion(name, newVersion)
+	}
+	return nil
+}
+
+// Rename changes the name and version of a package without changing its other
+// options. It's an error if a package with the new name is already in the
+// config.
+func (pkgs *PackagesMutator) Rename(versionedName, newVersionedName string) error {
+	name, version := parseVersionedName(versionedName)
+	i := pkgs.index(name, version)
+	if i == -1 {
+		return errors.Errorf("package %s not found", versionedName)
+	}
+	newName, newVersion := parseVersionedName(newVersionedName)
+	if newName == name {
+		return pkgs.SetVersion(versionedName, newVersion)
+	}
+	if slices.ContainsFunc(pkgs.collection, func(p Package) bool { return p.Name == newName }) {
+		return errors.Errorf("package %s is already in the config", newName)
+	}
+	pkgs.collection[i].Name = newName
+	pkgs.collection[i].Version = newVersion
+	pkgs.ast.renamePackage(name, newName, newVersion)
+	return nil
+}
+
+// Put adds pkg with all of its settings, replacing the package with the same
+// name if there is one. A package without settings other than its version is
+// written as a version string.
+func (pkgs *PackagesMutator) Put(pkg Package) error {
+	b, err := json.Marshal(pkg)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	// Decode the copy that's kept in the collection from the JSON too, so
+	// that it doesn't share slices and maps with pkg.
+	pkg = Package{}
+	if err := json.Unmarshal(b, &pkg); err != nil {
+		return errors.WithStack(err)
+	}
+	val, err := hujson.Parse(b)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	obj := val.Value.(*hujson.Object)
+	// The name is the package's key in devbox.json, not one of its fields.
+	obj.Members = slices.DeleteFunc(obj.Members, func(m hujson.ObjectMember) bool {
+		return m.Name.Value.(hujson.Literal).String() == "Name"
+	})
+	if len(obj.Members) == 0 || (len(obj.Members) == 1 && obj.Members[0].Name.Value.(hujson.Literal).String() == "version") {
+		val = hujson.Value{Value: hujson.String(pkg.Version)}
+	}
+
+	i := slices.IndexFunc(pkgs.collection, func(p Package) bool { return p.Name == pkg.Name })
+	if i == -1 {
+		pkgs.collection = append(pkgs.collection, pkg)
+	} else {
+		pkgs.collection[i] = pkg
+	}
+	pkgs.ast.setPackage(pkg.Name, val)
+	return nil
+}
+
 // AddPlatforms adds a platform to the list of platforms for a given package
 func (pkgs *PackagesMutator) AddPlatforms(writer io.Writer, versionedname string, platforms []string) error {
 	if len(platforms) == 0 {
@@ -124,6 +201,50 @@ func (pkgs *PackagesMutator) ExcludePlatforms(writer io.Writer, versionedName st
 	return nil
 }
 
+// SetExcludedPlatforms replaces the list of excluded platforms for a given
+// package. An empty list removes the field from devbox.json.
+func (pkgs *PackagesMutator) SetExcludedPlatforms(writer io.Writer, versionedName string, platforms []string) error {
+	if err := nix.EnsureValidPlatform(platforms...); err != nil {
+		return errors.WithStack(err)
+	}
+
+	name, version := parseVersionedName(versionedName)
+	i := pkgs.index(name, version)
+	if i == -1 {
+		return errors.Errorf("package %s not found", versionedName)
+	}
+
+	pkg := &pkgs.collection[i]
+	if len(platforms) > 0 && len(pkg.Platforms) > 0 {
+		return usererr.New(
+			"cannot exclude any platform for package %s because it already has `platforms` defined. "+
+				"Please delete the `platforms` for this package from devbox.json and re-try.",
+			pkg.VersionedName(),
+		)
+	}
+	unique := []string{}
+	for _, p := range platforms {
+		if !slices.Contains(unique, p) {
+			unique = append(unique, p)
+		}
+	}
+	platforms = unique
+	if slices.Equal(pkg.ExcludedPlatforms, platforms) {
+		return nil
+	}
+
+	pkg.ExcludedPlatforms = platforms
+	pkgs.ast.removePackageField(pkg.Name, "excluded_platforms")
+	pkgs.ast.appendPlatforms(pkg.Name, "excluded_platforms", platforms)
+	if len(platforms) == 0 {
+		ux.Finfo(writer, "Cleared the excluded platforms for package %s\n", pkg.VersionedName())
+	} else {
+		ux.Finfo(writer, "Set the excluded platforms for package %s to %s\n",
+			pkg.VersionedName(), strings.Join(platforms, ", "))
+	}
+	return nil
+}
+
 func (pkgs *PackagesMutator) UnmarshalJSON(data []byte) error {
 	// First, attempt to unmarshal as a list of strings (legacy format)
 	var packages []string
@@ -180,6 +301,26 @@ func (pkgs *PackagesMutator) SetDisablePlugin(versionedName string, v bool) erro
 	return nil
 }
 
+// SetDisabled sets or clears the disabled field of a package. Clearing it
+// removes the field from devbox.json.
+func (pkgs *PackagesMutator) SetDisabled(versionedName string, v bool) error {
+	name, version := parseVersionedName(versionedName)
+	i := pkgs.index(name, version)
+	if i == -1 {
+		return errors.Errorf("package %s not found", versionedName)
+	}
+	if pkgs.collection[i].Disabled == v {
+		return nil
+	}
+	pkgs.collection[i].Disabled = v
+	if v {
+		pkgs.ast.setPackageBool(name, "disabled", true)
+	} else {
+		pkgs.ast.removePackageField(name, "disabled")
+	}
+	return nil
+}
+
 func (pkgs *PackagesMutator) SetOutputs(writer io.Writer, versionedName string, outputs []string) error {
 	name, version := parseVersionedName(versionedName)
 	i := pkgs.index(name, version)
@@ -225,6 +366,79 @@ func (pkgs *PackagesMutator) SetAllowInsecure(writer io.Writer, versionedName st
 	return nil
 }
 
+// AddGroup adds a package to a named group.
+func (pkgs *PackagesMutator) AddGroup(writer io.Writer, versionedName, group string) error {
+	if group == "" {
+		return nil
+	}
+	name, version := parseVersionedName(versionedName)
+	i := pkgs.index(name, version)
+	if i == -1 {
+		return errors.Errorf("package %s not found", versionedName)
+	}
+
+	pkg := &pkgs.collection[i]
+	if !slices.Contains(pkg.Groups, group) {
+		pkgs.ast.appendStringSliceField(pkg.Name, "groups", []string{group})
+		pkg.Groups = append(pkg.Groups, group)
+		ux.Finfo(writer, "Added package %s to group %s\n", versionedName, group)
+	}
+	return nil
+}
+
+// SetBuildEnv sets environment variables that are only used when building the
+// package. Variables that the package already has are overwritten.
+func (pkgs *PackagesMutator) SetBuildEnv(writer io.Writer, versionedName string, env map[string]string) error {
+	if len(env) == 0 {
+		return nil
+	}
+	name, version := parseVersionedName(versionedName)
+	i := pkgs.index(name, version)
+	if i == -1 {
+		return errors.Errorf("package %s not found", versionedName)
+	}
+
+	pkg := &pkgs.collection[i]
+	toSet := map[string]string{}
+	names := []string{}
+	for k, v := range env {
+		if old, ok := pkg.BuildEnv[k]; !ok || old != v {
+			toSet[k] = v
+			names = append(names, k)
+		}
+	}
+	if len(toSet) == 0 {
+		return nil
+	}
+	pkgs.ast.setPackageStringMap(pkg.Name, "build_env", toSet)
+	if pkg.BuildEnv == nil {
+		pkg.BuildEnv = map[string]string{}
+	}
+	maps.Copy(pkg.BuildEnv, toSet)
+
+	slices.Sort(names)
+	ux.Finfo(writer, "Set build environment %s for package %s\n", strings.Join(names, ", "), versionedName)
+	return nil
+}
+
+// SetReason sets the note about why the package was added. An empty reason
+// leaves the package unchanged.
+func (pkgs *PackagesMutator) SetReason(versionedName, reason string) error {
+	if reason == "" {
+		return nil
+	}
+	name, version := parseVersionedName(versionedName)
+	i := pkgs.index(name, version)
+	if i == -1 {
+		return errors.Errorf("package %s not found", versionedName)
+	}
+	if pkgs.collection[i].Reason != reason {
+		pkgs.collection[i].Reason = reason
+		pkgs.ast.setPackageString(name, "reason", reason)
+	}
+	return nil
+}
+
 func (pkgs *PackagesMutator) index(name, version string) int {
 	return slices.IndexFunc(pkgs.collection, func(p Package) bool {
 		return p.Name == name && p.Version == version
@@ -250,8 +464,55 @@ type Package struct {
 	// AllowInsecure is a whitelist of packages that may be marked insecure
 	// in nixpkgs, but are allowed by the user to be installed.
 	AllowInsecure []string `json:"allow_insecure,omitempty"`
+
+	// AutoUpdate opts the package into automatic updates. The only
+	// supported value is "security", which updates the package when its
+	// locked version has known vulnerabilities.
+	AutoUpdate string `json:"auto_update,omitempty"`
+
+	// Groups are the named groups the package belongs to, such as
+	// "frontend" or "backend". Installing a subset of groups installs the
+	// packages in any of those groups plus the packages without a group.
+	Groups []string `json:"groups,omitempty"`
+
+	// Disabled keeps the package in devbox.json, along with its other
+	// settings, without installing it.
+	Disabled bool `json:"disabled,omitempty"`
+
+	// BuildEnv are environment variables that are set when Nix evaluates and
+	// builds the package, but not in the devbox shell.
+	BuildEnv map[string]string `json:"build_env,omitempty"`
+
+	// BuildFlags are extra arguments to nix build when Devbox installs the
+	// package to the Nix store, such as ["--option", "sandbox", "false"].
+	// They're passed after Devbox's own flags.
+	BuildFlags []string `json:"build_flags,omitempty"`
+
+	// Lazy skips building or downloading the package when Devbox installs
+	// packages to the Nix store. It's still part of the environment, so it's
+	// built the first time the environment is computed instead.
+	Lazy bool `json:"lazy,omitempty"`
+
+	// Reason is a short note about why the package was added. It's only
+	// metadata, and doesn't affect how the package is resolved or installed.
+	Reason string `json:"reason,omitempty"`
+
+	// Verify is a shell command that checks the package works, such as
+	// "node --version". Devbox runs it in the project's environment after
+	// installing packages, and fails the install if it exits non-zero.
+	Verify string `json:"verify,omitempty"`
+
+	// Alternatives are the packages to install instead of this one on some
+	// platforms, such as {"linux": "gcc@latest", "darwin": "clang@latest"}.
+	// The keys are "linux", "darwin" or a single platform. See
+	// ConfigFile.TopLevelPackages.
+	Alternatives map[string]string `json:"alternatives,omitempty"`
 }
 
+// AutoUpdateSecurity is the auto_update value for packages that should be
+// updated when their locked version has known vulnerabilities.
+const AutoUpdateSecurity = "security"
+
 func NewVersionOnlyPackage(name, version string) Package {
 	return Package{
 		Name:    name,
@@ -264,7 +525,12 @@ func NewVersionOnlyPackage(name, version string) Package {
 // If the package has a list of excluded platforms, it is enabled on all platforms
 // except those.
 func (p *Package) IsEnabledOnPlatform() bool {
-	platform := nix.System()
+	return p.IsEnabledOnSystem(nix.System())
+}
+
+// IsEnabledOnSystem is like IsEnabledOnPlatform, but checks against the given
+// system instead of the current one.
+func (p *Package) IsEnabledOnSystem(platform string) bool {
 	if len(p.Platforms) > 0 {
 		for _, plt := range p.Platforms {
 			if plt == platform {
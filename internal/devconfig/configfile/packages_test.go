@@ -302,3 +302,71 @@ func TestParseVersionedName(t *testing.T) {
 		})
 	}
 }
+
+func TestIsEnabledOnSystem(t *testing.T) {
+	testCases := []struct {
+		name     string
+		pkg      Package
+		system   string
+		expected bool
+	}{
+		{
+			name:     "no-platforms",
+			pkg:      Package{Name: "hello"},
+			system:   "x86_64-linux",
+			expected: true,
+		},
+		{
+			name:     "in-platforms",
+			pkg:      Package{Name: "hello", Platforms: []string{"x86_64-linux"}},
+			system:   "x86_64-linux",
+			expected: true,
+		},
+		{
+			name:     "not-in-platforms",
+			pkg:      Package{Name: "hello", Platforms: []string{"x86_64-linux"}},
+			system:   "aarch64-darwin",
+			expected: false,
+		},
+		{
+			name:     "excluded-platform",
+			pkg:      Package{Name: "hello", ExcludedPlatforms: []string{"aarch64-darwin"}},
+			system:   "aarch64-darwin",
+			expected: false,
+		},
+		{
+			name:     "not-excluded-platform",
+			pkg:      Package{Name: "hello", ExcludedPlatforms: []string{"aarch64-darwin"}},
+			system:   "x86_64-linux",
+			expected: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if got := testCase.pkg.IsEnabledOnSystem(testCase.system); got != testCase.expected {
+				t.Errorf("expected: %v, got: %v", testCase.expected, got)
+			}
+		})
+	}
+}
+
+func TestAutoUpdate(t *testing.T) {
+	cfg, err := LoadBytes([]byte(`{"packages": {"hello": {"version": "2.12", "auto_update": "security"}, "jq": "1.7"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, pkg := range cfg.TopLevelPackages() {
+		want := ""
+		if pkg.Name == "hello" {
+			want = AutoUpdateSecurity
+		}
+		if pkg.AutoUpdate != want {
+			t.Errorf("got auto_update %q for %s, want %q", pkg.AutoUpdate, pkg.Name, want)
+		}
+	}
+
+	if _, err := LoadBytes([]byte(`{"packages": {"hello": {"auto_update": "always"}}}`)); err == nil {
+		t.Error("got nil error for an unsupported auto_update value")
+	}
+}
@@ -1,11 +1,13 @@
 package devpkg
 
 import (
+	"bufio"
 	"context"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -17,6 +19,7 @@ import (
 	"go.jetpack.io/devbox/internal/devbox/providers/nixcache"
 	"go.jetpack.io/devbox/internal/goutil"
 	"go.jetpack.io/devbox/internal/lock"
+	"go.jetpack.io/devbox/internal/netpolicy"
 	"go.jetpack.io/devbox/internal/nix"
 	"golang.org/x/sync/errgroup"
 )
@@ -173,7 +176,7 @@ func (p *Package) fetchNarInfoStatusOnce(
 func (p *Package) AreAllOutputsInCache(
 	ctx context.Context, w io.Writer, cacheURI string,
 ) (bool, error) {
-	storePaths, err := p.GetStorePaths(ctx, w)
+	storePaths, err := p.GetStorePaths(ctx, w, "" /*store*/)
 	if err != nil {
 		return false, err
 	}
@@ -271,6 +274,99 @@ func (p *Package) sysInfoIfExists() (*lock.SystemInfo, error) {
 
 var narInfoStatusFnCache = sync.Map{}
 
+// narInfoSizesFnCache holds the narinfo downloads done to learn NAR sizes.
+var narInfoSizesFnCache = sync.Map{}
+
+// narInfoFileSizeCache maps a cache URI and store path hash to the compressed
+// NAR size from its narinfo. Entries only exist for narinfos that were found
+// and had a FileSize field.
+var narInfoFileSizeCache = sync.Map{}
+
+// narInfoNarSizeCache is like narInfoFileSizeCache, but holds the
+// uncompressed NarSize, which is roughly the space the path takes up in the
+// Nix store.
+var narInfoNarSizeCache = sync.Map{}
+
+// NarDownloadSize returns the total compressed size in bytes of the package's
+// default outputs, as reported by the binary cache. It returns 0 if the size
+// isn't known, for example because the package isn't in a binary cache.
+func (p *Package) NarDownloadSize() (int64, error) {
+	sizes, err := p.narSizes(&narInfoFileSizeCache)
+	if err != nil {
+		return 0, err
+	}
+	var total int64
+	for _, size := range sizes {
+		total += size
+	}
+	return total, nil
+}
+
+// NarSizes returns the uncompressed NAR size in bytes of each of the package's
+// default outputs, keyed by store path. Outputs whose size isn't known, for
+// example because they aren't in a binary cache, are omitted.
+func (p *Package) NarSizes() (map[string]int64, error) {
+	return p.narSizes(&narInfoNarSizeCache)
+}
+
+func (p *Package) narSizes(sizeCache *sync.Map) (map[string]int64, error) {
+	sizes := map[string]int64{}
+	if eligible, err := p.isEligibleForBinaryCache(); err != nil || !eligible {
+		return sizes, err
+	}
+	outputToCache, err := p.fetchNarInfoStatusOnce(useDefaultOutputs)
+	if err != nil {
+		return nil, err
+	}
+	outputs, err := p.outputsForOutputName(useDefaultOutputs)
+	if err != nil {
+		return nil, err
+	}
+	for _, output := range outputs {
+		cache, ok := outputToCache[output.Name]
+		if !ok {
+			continue
+		}
+		hash := nix.NewStorePathParts(output.Path).Hash
+		key := fmt.Sprintf("%s/%s", cache, hash)
+		// S3 narinfos are downloaded by the existence check, so their
+		// sizes are already cached.
+		if !strings.HasPrefix(cache, "s3") {
+			if err := fetchNarInfoSizesFromHTTP(context.TODO(), cache, hash); err != nil {
+				return nil, err
+			}
+		}
+		if size, ok := sizeCache.Load(key); ok {
+			sizes[output.Path] = size.(int64)
+		}
+	}
+	return sizes, nil
+}
+
+// storeNarInfoSizes parses the FileSize and NarSize fields of a narinfo and
+// caches them under key.
+func storeNarInfoSizes(key string, narinfo io.Reader) {
+	scanner := bufio.NewScanner(io.LimitReader(narinfo, 64*1024))
+	for scanner.Scan() {
+		field, value, ok := strings.Cut(scanner.Text(), ": ")
+		if !ok {
+			continue
+		}
+		var sizeCache *sync.Map
+		switch field {
+		case "FileSize":
+			sizeCache = &narInfoFileSizeCache
+		case "NarSize":
+			sizeCache = &narInfoNarSizeCache
+		default:
+			continue
+		}
+		if size, err := strconv.ParseInt(value, 10, 64); err == nil {
+			sizeCache.Store(key, size)
+		}
+	}
+}
+
 func fetchNarInfoStatusFromHTTP(
 	ctx context.Context,
 	uri string,
@@ -286,7 +382,7 @@ func fetchNarInfoStatusFromHTTP(
 			if err != nil {
 				return false, err
 			}
-			res, err := http.DefaultClient.Do(req)
+			res, err := netpolicy.Client.Do(req)
 			if err != nil {
 				return false, err
 			}
@@ -297,6 +393,38 @@ func fetchNarInfoStatusFromHTTP(
 	return fetch.(func() (bool, error))()
 }
 
+// fetchNarInfoSizesFromHTTP downloads a narinfo that's known to exist and
+// caches its sizes. Existence checks only need a HEAD request, so the narinfo
+// body is only fetched when a size is asked for.
+func fetchNarInfoSizesFromHTTP(
+	ctx context.Context,
+	uri string,
+	hash string,
+) error {
+	key := fmt.Sprintf("%s/%s", uri, hash)
+	fetch, _ := narInfoSizesFnCache.LoadOrStore(key, sync.OnceValue(
+		func() error {
+			ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+			defer cancel()
+			url := fmt.Sprintf("%s/%s.narinfo", uri, hash)
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+			if err != nil {
+				return err
+			}
+			res, err := netpolicy.Client.Do(req)
+			if err != nil {
+				return err
+			}
+			defer res.Body.Close()
+			if res.StatusCode == http.StatusOK {
+				storeNarInfoSizes(key, res.Body)
+			}
+			return nil
+		},
+	))
+	return fetch.(func() error)()
+}
+
 func fetchNarInfoStatusFromS3(
 	ctx context.Context,
 	uri string,
@@ -315,7 +443,7 @@ func fetchNarInfoStatusFromS3(
 				return false, errors.WithStack(err)
 			}
 
-			_, err = s3Client.GetObject(ctx,
+			out, err := s3Client.GetObject(ctx,
 				&s3.GetObjectInput{
 					Bucket: aws.String(bucketURI.Hostname()),
 					Key:    aws.String(hash + ".narinfo"),
@@ -326,7 +454,12 @@ func fetchNarInfoStatusFromS3(
 					}
 				},
 			)
-			return err == nil, nil
+			if err != nil {
+				return false, nil //nolint:nilerr
+			}
+			defer out.Body.Close()
+			storeNarInfoSizes(key, out.Body)
+			return true, nil
 		},
 	))
 	return fetch.(func() (bool, error))()
@@ -335,6 +468,16 @@ func fetchNarInfoStatusFromS3(
 var nixCacheIsConfigured = goutil.OnceValueWithContext(nixcache.IsConfigured)
 
 func readCaches(ctx context.Context) ([]string, error) {
+	// The Jetify cache can't be listed without calling the Jetify API, so
+	// the strict network policy only checks the public cache, and only if
+	// it's allowed.
+	if netpolicy.IsStrict() {
+		if !netpolicy.Allows(binaryCache) {
+			return nil, nil
+		}
+		return []string{binaryCache}, nil
+	}
+
 	cacheURIs := []string{binaryCache}
 	if !nixCacheIsConfigured.Do(ctx) {
 		return cacheURIs, nil
@@ -352,4 +495,7 @@ func readCaches(ctx context.Context) ([]string, error) {
 
 func ClearNarInfoCache() {
 	narInfoStatusFnCache = sync.Map{}
+	narInfoSizesFnCache = sync.Map{}
+	narInfoFileSizeCache = sync.Map{}
+	narInfoNarSizeCache = sync.Map{}
 }
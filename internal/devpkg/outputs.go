@@ -1,5 +1,16 @@
 package devpkg
 
+import (
+	"errors"
+	"slices"
+	"strings"
+
+	"github.com/samber/lo"
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+	"go.jetpack.io/devbox/internal/devpkg/pkgtype"
+	"go.jetpack.io/devbox/internal/lock"
+)
+
 type Output struct {
 	Name     string
 	CacheURI string
@@ -45,3 +56,46 @@ func (out *outputs) initDefaultNames(pkg *Package) error {
 	}
 	return nil
 }
+
+// SplitOutputs splits the ^output suffix off a Devbox package string, such as
+// "openssl^dev" or "openssl@3^dev,out", and returns the package name and the
+// selected outputs. Flake installables keep their ^output suffix, since it's
+// part of the installable, and runx packages don't have outputs.
+func SplitOutputs(raw string) (string, []string) {
+	if pkgtype.IsRunX(raw) || pkgtype.IsFlake(raw) {
+		return raw, nil
+	}
+	name, suffix, found := strings.Cut(raw, "^")
+	if !found {
+		return raw, nil
+	}
+	outputs := lo.Compact(strings.Split(suffix, ","))
+	return name, outputs
+}
+
+// ErrUnknownOutput is returned when a package doesn't have a selected output.
+var ErrUnknownOutput = errors.New("unknown package output")
+
+// validateOutputs checks that the package has every selected output. Only
+// packages whose lockfile entry lists their outputs can be checked, so the
+// outputs of other packages are assumed to exist.
+func (p *Package) validateOutputs() error {
+	if !p.IsDevboxPackage || len(p.outputs.selectedNames) == 0 {
+		return nil
+	}
+	sysInfo, err := p.sysInfoIfExists()
+	if err != nil || sysInfo == nil || len(sysInfo.Outputs) == 0 {
+		return err
+	}
+	names := lo.Map(sysInfo.Outputs, func(o lock.Output, _ int) string { return o.Name })
+	for _, name := range p.outputs.selectedNames {
+		if !slices.Contains(names, name) {
+			return usererr.WithUserMessage(
+				ErrUnknownOutput,
+				"Package %q doesn't have an output named %q. Its outputs are: %s",
+				p.Raw, name, strings.Join(names, ", "),
+			)
+		}
+	}
+	return nil
+}
@@ -5,10 +5,14 @@ package devpkg
 
 import (
 	"context"
+	stderrors "errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"path/filepath"
 	"regexp"
+	"runtime"
+	"slices"
 	"strings"
 	"sync"
 
@@ -23,9 +27,11 @@ import (
 	"go.jetpack.io/devbox/internal/devpkg/pkgtype"
 	"go.jetpack.io/devbox/internal/lock"
 	"go.jetpack.io/devbox/internal/nix"
+	"go.jetpack.io/devbox/internal/searcher"
 	"go.jetpack.io/devbox/internal/ux"
 	"go.jetpack.io/devbox/nix/flake"
 	"go.jetpack.io/devbox/plugins"
+	"golang.org/x/sync/errgroup"
 )
 
 // Package represents a "package" added to the devbox.json config.
@@ -90,6 +96,31 @@ type Package struct {
 	// installed even if they are marked as insecure.
 	AllowInsecure []string
 
+	// Groups are the named groups the package belongs to in devbox.json.
+	Groups []string
+
+	// Disabled is true if the package is in devbox.json but shouldn't be
+	// installed.
+	Disabled bool
+
+	// BuildEnv are environment variables to set when building the package.
+	BuildEnv map[string]string
+
+	// BuildFlags are extra nix build arguments for the package. See
+	// configfile.Package.BuildFlags.
+	BuildFlags []string
+
+	// Lazy is true if the package shouldn't be installed to the Nix store
+	// ahead of time. See configfile.Package.Lazy.
+	Lazy bool
+
+	// Reason is the note in devbox.json about why the package was added.
+	Reason string
+
+	// Verify is the command that checks the package works after it's
+	// installed. See configfile.Package.Verify.
+	Verify string
+
 	// isInstallable is true if the package may be enabled on the current platform.
 	// It's a function to allow deferring nix System call until it's needed.
 	isInstallable func() bool
@@ -115,6 +146,13 @@ func PackagesFromConfig(packages []configfile.Package, l lock.Locker) []*Package
 		})
 		pkg.outputs.selectedNames = lo.Uniq(append(pkg.outputs.selectedNames, cfgPkg.Outputs...))
 		pkg.AllowInsecure = cfgPkg.AllowInsecure
+		pkg.Groups = cfgPkg.Groups
+		pkg.Disabled = cfgPkg.Disabled
+		pkg.BuildEnv = cfgPkg.BuildEnv
+		pkg.BuildFlags = cfgPkg.BuildFlags
+		pkg.Lazy = cfgPkg.Lazy
+		pkg.Reason = cfgPkg.Reason
+		pkg.Verify = cfgPkg.Verify
 		result = append(result, pkg)
 	}
 	return result
@@ -124,15 +162,42 @@ func PackageFromStringWithDefaults(raw string, locker lock.Locker) *Package {
 	return newPackage(raw, func() bool { return true } /*isInstallable*/, locker)
 }
 
+// PackageFromStringWithOptions returns the package for raw, which may select
+// outputs with a ^output suffix, such as "openssl^dev".
 func PackageFromStringWithOptions(raw string, locker lock.Locker, opts devopt.AddOpts) *Package {
+	raw, outputs := SplitOutputs(raw)
+	if opts.NixpkgsCommit != "" {
+		raw = pinToNixpkgsCommit(raw, opts.NixpkgsCommit)
+		// Pinned packages are flakes, which select outputs in the
+		// installable itself.
+		if len(outputs) > 0 {
+			raw += "^" + strings.Join(outputs, ",")
+			outputs = nil
+		}
+	}
 	pkg := PackageFromStringWithDefaults(raw, locker)
 	pkg.DisablePlugin = opts.DisablePlugin
 	pkg.patchGlibc = sync.OnceValue(func() bool { return opts.PatchGlibc })
-	pkg.outputs.selectedNames = lo.Uniq(append(pkg.outputs.selectedNames, opts.Outputs...))
+	pkg.outputs.selectedNames = lo.Uniq(slices.Concat(pkg.outputs.selectedNames, outputs, opts.Outputs))
 	pkg.AllowInsecure = opts.AllowInsecure
+	pkg.BuildEnv = opts.BuildEnv
 	return pkg
 }
 
+// pinToNixpkgsCommit turns a Devbox package string into a nixpkgs flake
+// installable at commit. The version, if any, is dropped because the commit
+// decides which version is used. Flakes and runx packages are returned as-is.
+func pinToNixpkgsCommit(raw, commit string) string {
+	if pkgtype.IsFlake(raw) || pkgtype.IsRunX(raw) {
+		return raw
+	}
+	name, _, _ := searcher.ParseVersionedPackage(raw)
+	if name == "" {
+		name = raw
+	}
+	return lock.NixpkgsInstallable(commit, name)
+}
+
 func newPackage(raw string, isInstallable func() bool, locker lock.Locker) *Package {
 	pkg := &Package{
 		Raw:           raw,
@@ -237,6 +302,17 @@ func (p *Package) IsInstallable() bool {
 	return p.isInstallable()
 }
 
+// InAnyGroup returns true if the package doesn't belong to a group, or if it
+// belongs to at least one of groups.
+func (p *Package) InAnyGroup(groups []string) bool {
+	if len(p.Groups) == 0 {
+		return true
+	}
+	return slices.ContainsFunc(p.Groups, func(g string) bool {
+		return slices.Contains(groups, g)
+	})
+}
+
 func (p *Package) PatchGlibc() bool {
 	return p.patchGlibc != nil && p.patchGlibc()
 }
@@ -290,6 +366,22 @@ func (p *Package) InstallableForOutput(output string) (string, error) {
 	return installable, nil
 }
 
+// InstallableForSystem returns the flake installable that builds the package
+// for system, which may differ from the current system. Unlike Installables,
+// it never returns the store paths in the lockfile because those are only
+// valid on the system they were locked for.
+func (p *Package) InstallableForSystem(system string) (string, error) {
+	if err := p.resolve(); err != nil {
+		return "", err
+	}
+	if !p.IsDevboxPackage || p.installable.AttrPath == "" {
+		return p.installable.String(), nil
+	}
+	clone := p.installable
+	clone.AttrPath = fmt.Sprintf("legacyPackages.%s.%s", system, clone.AttrPath)
+	return clone.String(), nil
+}
+
 // FlakeInstallable returns a flake installable. The raw string must contain
 // a valid flake reference parsable by ParseFlakeRef, optionally followed by an
 // #attrpath and/or an ^output.
@@ -505,33 +597,59 @@ func (p *Package) LegacyToVersioned() string {
 
 // EnsureNixpkgsPrefetched will prefetch flake for the nixpkgs registry for the package.
 // This is an internal method, and should not be called directly.
+//
+// The packages' binary cache status is checked concurrently, and each nixpkgs
+// commit is prefetched once even if several packages use it. All prefetch
+// errors are returned together.
 func EnsureNixpkgsPrefetched(ctx context.Context, w io.Writer, pkgs []*Package) error {
-	for _, input := range pkgs {
-		if err := input.ensureNixpkgsPrefetched(w); err != nil {
+	// IsInBinaryCache reads from the narinfo cache, so fill it concurrently
+	// up front. If this fails, IsInBinaryCache fetches it again below.
+	if err := FillNarInfoCache(ctx, pkgs...); err != nil {
+		slog.Debug("failed to fill narinfo cache before prefetching nixpkgs", "err", err)
+	}
+
+	hashes := []string{}
+	for _, pkg := range pkgs {
+		hash, err := pkg.nixpkgsHashToPrefetch()
+		if err != nil {
 			return err
 		}
+		if hash != "" {
+			hashes = append(hashes, hash)
+		}
 	}
-	return nil
+
+	var mu sync.Mutex
+	var errs []error
+	group := errgroup.Group{}
+	group.SetLimit(runtime.GOMAXPROCS(0))
+	for _, hash := range lo.Uniq(hashes) {
+		group.Go(func() error {
+			if err := nix.EnsureNixpkgsPrefetched(w, hash); err != nil {
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("error prefetching nixpkgs %s: %w", hash, err))
+				mu.Unlock()
+			}
+			return nil
+		})
+	}
+	_ = group.Wait()
+	return stderrors.Join(errs...)
 }
 
-// ensureNixpkgsPrefetched should be called via the public EnsureNixpkgsPrefetched.
-// See function comment there.
-func (p *Package) ensureNixpkgsPrefetched(w io.Writer) error {
+// nixpkgsHashToPrefetch returns the nixpkgs commit that needs to be prefetched
+// for the package, or an empty string if there's nothing to prefetch.
+func (p *Package) nixpkgsHashToPrefetch() (string, error) {
 	inCache, err := p.IsInBinaryCache()
 	if err != nil {
-		return err
+		return "", err
 	}
 	if inCache {
 		// We can skip prefetching nixpkgs, if this package is in the binary
 		// cache store.
-		return nil
-	}
-
-	hash := p.HashFromNixPkgsURL()
-	if hash == "" {
-		return nil
+		return "", nil
 	}
-	return nix.EnsureNixpkgsPrefetched(w, hash)
+	return p.HashFromNixPkgsURL(), nil
 }
 
 // version returns the version of the package
@@ -641,6 +759,20 @@ func (p *Package) IsNix() bool {
 	return IsNix(p, 0)
 }
 
+// IsType reports if the package is of type t. Flakes are both Nix and flake
+// packages.
+func (p *Package) IsType(t pkgtype.Type) bool {
+	switch t {
+	case pkgtype.Nix:
+		return p.IsNix()
+	case pkgtype.Flake:
+		return p.IsNix() && !p.IsDevboxPackage
+	case pkgtype.RunX:
+		return p.IsRunX()
+	}
+	return false
+}
+
 func (p *Package) RunXPath() string {
 	return strings.TrimPrefix(p.Raw, pkgtype.RunXPrefix)
 }
@@ -741,7 +873,10 @@ func (p *Package) GetResolvedStorePaths() ([]string, error) {
 const MissingStorePathsWarning = "Outputs for %s are not in lockfile. To fix this issue and improve performance, please run " +
 	"`devbox install --tidy-lockfile`\n"
 
-func (p *Package) GetStorePaths(ctx context.Context, w io.Writer) ([]string, error) {
+// GetStorePaths returns the package's store paths from the lockfile or, if
+// they aren't locked, by asking nix to evaluate the package. If store is
+// empty, the default store is queried.
+func (p *Package) GetStorePaths(ctx context.Context, w io.Writer, store string) ([]string, error) {
 	storePathsForPackage, err := p.GetResolvedStorePaths()
 	if err != nil || len(storePathsForPackage) > 0 {
 		return storePathsForPackage, err
@@ -758,7 +893,7 @@ func (p *Package) GetStorePaths(ctx context.Context, w io.Writer) ([]string, err
 	}
 	for _, installable := range installables {
 		storePathsForInstallable, err := nix.StorePathsFromInstallable(
-			ctx, installable, p.HasAllowInsecure())
+			ctx, store, installable, p.AllowInsecure)
 		if err != nil {
 			return nil, packageInstallErrorHandler(err, p, installable)
 		}
@@ -767,6 +902,27 @@ func (p *Package) GetStorePaths(ctx context.Context, w io.Writer) ([]string, err
 	return storePathsForPackage, nil
 }
 
+// PlatformIncompatibleError is returned, wrapped in a user error, when a
+// package can't be installed on a platform. Use errors.As to get it.
+type PlatformIncompatibleError struct {
+	// Package is the versioned name of the package.
+	Package string
+	// Platform is the Nix system the package was installed for, such as
+	// x86_64-darwin.
+	Platform string
+	// CacheMiss is true when Nix couldn't find the package's output for
+	// the platform, which may only mean that this version isn't available
+	// for it yet. It's false when Nix reports that the package doesn't
+	// support the platform.
+	CacheMiss bool
+
+	err error
+}
+
+func (e *PlatformIncompatibleError) Error() string { return e.err.Error() }
+
+func (e *PlatformIncompatibleError) Unwrap() error { return e.err }
+
 // packageInstallErrorHandler checks for two kinds of errors to print custom messages for so that Devbox users
 // can work around them:
 // 1. Packages that cannot be installed on the current system, but may be installable on other systems.packageInstallErrorHandler
@@ -799,7 +955,12 @@ func packageInstallErrorHandler(err error, pkg *Package, installableOrEmpty stri
 	if maybePackageSystemCompatibilityErrorType1 || maybePackageSystemCompatibilityErrorType2 {
 		platform := nix.System()
 		return usererr.WithUserMessage(
-			err,
+			&PlatformIncompatibleError{
+				Package:   pkg.Versioned(),
+				Platform:  platform,
+				CacheMiss: !maybePackageSystemCompatibilityErrorType2,
+				err:       err,
+			},
 			"package %s cannot be installed on your platform %s.\n"+
 				"If you know this package is incompatible with %[2]s, then "+
 				"you could run `devbox add %[1]s --exclude-platform %[2]s` and re-try.\n"+
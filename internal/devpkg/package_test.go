@@ -4,12 +4,19 @@
 package devpkg
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
 
 	"github.com/samber/lo"
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+	"go.jetpack.io/devbox/internal/devbox/devopt"
 	"go.jetpack.io/devbox/internal/lock"
 	"go.jetpack.io/devbox/internal/nix"
 )
@@ -206,3 +213,155 @@ func TestCanonicalName(t *testing.T) {
 		})
 	}
 }
+
+func TestInAnyGroup(t *testing.T) {
+	ungrouped := PackageFromStringWithDefaults("git", &lockfile{})
+	grouped := PackageFromStringWithDefaults("nodejs", &lockfile{})
+	grouped.Groups = []string{"frontend", "e2e"}
+
+	tests := []struct {
+		pkg    *Package
+		groups []string
+		want   bool
+	}{
+		{ungrouped, nil, true},
+		{ungrouped, []string{"backend"}, true},
+		{grouped, nil, false},
+		{grouped, []string{"backend"}, false},
+		{grouped, []string{"e2e"}, true},
+		{grouped, []string{"backend", "frontend"}, true},
+	}
+	for _, tt := range tests {
+		if got := tt.pkg.InAnyGroup(tt.groups); got != tt.want {
+			t.Errorf("%s in groups %v: InAnyGroup(%v) = %v, want %v",
+				tt.pkg.Raw, tt.pkg.Groups, tt.groups, got, tt.want)
+		}
+	}
+}
+
+func TestPinToNixpkgsCommit(t *testing.T) {
+	const commit = "5233fd2ba76a3accb5aaa999c00509a11fd0793c"
+	testCases := map[string]string{
+		"ripgrep":                     "github:NixOS/nixpkgs/" + commit + "#ripgrep",
+		"ripgrep@latest":              "github:NixOS/nixpkgs/" + commit + "#ripgrep",
+		"github:nixos/nixpkgs#hello":  "github:nixos/nixpkgs#hello",
+		"runx:golangci/golangci-lint": "runx:golangci/golangci-lint",
+	}
+	for raw, want := range testCases {
+		if got := pinToNixpkgsCommit(raw, commit); got != want {
+			t.Errorf("pinToNixpkgsCommit(%q) = %q, want %q", raw, got, want)
+		}
+	}
+}
+
+func TestSplitOutputs(t *testing.T) {
+	testCases := map[string]struct {
+		name    string
+		outputs []string
+	}{
+		"openssl":                          {"openssl", nil},
+		"openssl^dev":                      {"openssl", []string{"dev"}},
+		"openssl@3^dev,out":                {"openssl@3", []string{"dev", "out"}},
+		"github:nixos/nixpkgs#openssl^dev": {"github:nixos/nixpkgs#openssl^dev", nil},
+	}
+	for raw, want := range testCases {
+		name, outputs := SplitOutputs(raw)
+		if name != want.name || !slices.Equal(outputs, want.outputs) {
+			t.Errorf("SplitOutputs(%q) = %q, %v, want %q, %v", raw, name, outputs, want.name, want.outputs)
+		}
+	}
+
+	pkg := PackageFromStringWithOptions("openssl@3^dev", &lockfile{}, devopt.AddOpts{Outputs: []string{"out"}})
+	if pkg.Raw != "openssl@3" {
+		t.Errorf("got Raw %q, want %q", pkg.Raw, "openssl@3")
+	}
+	outputs, err := pkg.GetOutputNames()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"dev", "out"}; !slices.Equal(outputs, want) {
+		t.Errorf("got outputs %v, want %v", outputs, want)
+	}
+}
+
+func TestStoreNarInfoSizes(t *testing.T) {
+	narinfo := "StorePath: /nix/store/abc-hello-2.12\nURL: nar/xyz.nar.xz\nCompression: xz\nFileSize: 51234\nNarSize: 226560\n"
+	storeNarInfoSizes("test-cache/abc", strings.NewReader(narinfo))
+	size, ok := narInfoFileSizeCache.Load("test-cache/abc")
+	if !ok || size.(int64) != 51234 {
+		t.Errorf("got FileSize %v, want 51234", size)
+	}
+	size, ok = narInfoNarSizeCache.Load("test-cache/abc")
+	if !ok || size.(int64) != 226560 {
+		t.Errorf("got NarSize %v, want 226560", size)
+	}
+}
+
+func TestNarInfoExistenceUsesHead(t *testing.T) {
+	ClearNarInfoCache()
+	t.Cleanup(ClearNarInfoCache)
+	var methods []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		methods = append(methods, r.Method)
+		fmt.Fprint(w, "StorePath: /nix/store/abc-hello-2.12\nFileSize: 51234\nNarSize: 226560\n")
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	inCache, err := fetchNarInfoStatusFromHTTP(ctx, server.URL, "abc")
+	if err != nil || !inCache {
+		t.Fatalf("got inCache %v, err %v, want true", inCache, err)
+	}
+	if _, ok := narInfoFileSizeCache.Load(server.URL + "/abc"); ok {
+		t.Error("existence check cached a size, want it to skip the narinfo body")
+	}
+	if err := fetchNarInfoSizesFromHTTP(ctx, server.URL, "abc"); err != nil {
+		t.Fatal(err)
+	}
+	if size, ok := narInfoFileSizeCache.Load(server.URL + "/abc"); !ok || size.(int64) != 51234 {
+		t.Errorf("got FileSize %v, want 51234", size)
+	}
+	if want := []string{http.MethodHead, http.MethodGet}; !slices.Equal(methods, want) {
+		t.Errorf("got requests %v, want %v", methods, want)
+	}
+}
+
+func TestValidateLocalFlakeMissingFlakeNix(t *testing.T) {
+	projectDir := t.TempDir()
+	pkg := PackageFromStringWithDefaults("path:./mypkg#hello", &lockfile{projectDir})
+	if got, want := pkg.LocalFlakeDir(), filepath.Join(projectDir, "mypkg"); got != want {
+		t.Errorf("got LocalFlakeDir() = %q, want %q", got, want)
+	}
+	err := pkg.ValidateLocalFlake(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "no flake.nix") {
+		t.Errorf("got error %v, want a missing flake.nix error", err)
+	}
+
+	pkg = PackageFromStringWithDefaults("github:nixos/nixpkgs#hello", &lockfile{projectDir})
+	if dir := pkg.LocalFlakeDir(); dir != "" {
+		t.Errorf("got LocalFlakeDir() = %q for a github flake, want empty", dir)
+	}
+}
+
+func TestPackageInstallErrorHandlerPlatformIncompatible(t *testing.T) {
+	pkg := PackageFromStringWithDefaults("sublime4@4169", &lockfile{t.TempDir()})
+	nixErr := errors.New("error: Package ‘sublimetext4-4169’ is not available on the requested hostPlatform")
+	err := packageInstallErrorHandler(nixErr, pkg, "")
+
+	var platformErr *PlatformIncompatibleError
+	if !errors.As(err, &platformErr) {
+		t.Fatalf("got error %v, want a PlatformIncompatibleError", err)
+	}
+	if platformErr.Package != "sublime4@4169" || platformErr.Platform != nix.System() || platformErr.CacheMiss {
+		t.Errorf("got %+v, want an incompatible sublime4@4169 on %s", platformErr, nix.System())
+	}
+	if userErr, ok := usererr.Extract(err); !ok || !strings.Contains(userErr.Error(), "cannot be installed on your platform") {
+		t.Errorf("got error %v, want the user message", err)
+	}
+
+	nixErr = errors.New("error: flake output attribute 'legacyPackages.x86_64-darwin.glibcLocales' is not a derivation or path")
+	err = packageInstallErrorHandler(nixErr, pkg, "")
+	if !errors.As(err, &platformErr) || !platformErr.CacheMiss {
+		t.Errorf("got error %v, want a PlatformIncompatibleError with CacheMiss", err)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"os"
 	"strings"
 
+	"go.jetpack.io/devbox/internal/netpolicy"
 	"go.jetpack.io/pkg/runx/impl/registry"
 	"go.jetpack.io/pkg/runx/impl/runx"
 )
@@ -15,6 +16,9 @@ const (
 	githubAPITokenVarName = "DEVBOX_GITHUB_API_TOKEN"
 )
 
+// runxHosts are the hosts that runx resolves and downloads packages from.
+var runxHosts = []string{"https://api.github.com", "https://github.com", "https://objects.githubusercontent.com"}
+
 var cachedRegistry *registry.Registry
 
 func IsRunX(s string) bool {
@@ -27,8 +31,24 @@ func RunXClient() *runx.RunX {
 	}
 }
 
+// CheckRunXNetworkPolicy returns an error if the network policy doesn't
+// allow runx to resolve and download packages. runx makes its own requests
+// instead of using netpolicy.Client, so this must be called before
+// RunXClient().Install.
+func CheckRunXNetworkPolicy() error {
+	for _, host := range runxHosts {
+		if err := netpolicy.Check(host); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func RunXRegistry(ctx context.Context) (*registry.Registry, error) {
 	if cachedRegistry == nil {
+		if err := CheckRunXNetworkPolicy(); err != nil {
+			return nil, err
+		}
 		var err error
 		cachedRegistry, err = registry.NewLocalRegistry(ctx, os.Getenv(githubAPITokenVarName))
 		if err != nil {
@@ -0,0 +1,64 @@
+package pkgtype
+
+import (
+	"strings"
+
+	"github.com/samber/lo"
+	"go.jetpack.io/devbox/nix/flake"
+)
+
+// Package sources that can be listed in a project's source_preference.
+const (
+	SourceNixpkgs = "nixpkgs"
+	SourceFlake   = "flake"
+	SourceRunX    = "runx"
+)
+
+// Sources lists the valid package sources.
+var Sources = []string{SourceNixpkgs, SourceFlake, SourceRunX}
+
+// SourceCandidates returns the ways to read a raw package string in the given
+// source preference order, each rewritten with an explicit scheme for its
+// source. Strings that already name their source (such as "runx:owner/repo"
+// or "github:owner/repo") are the only candidate for themselves. Sources that
+// can't provide the string are skipped, and the string as-is is always the
+// last candidate so that it keeps the default interpretation as a Devbox
+// (nixpkgs) package.
+//
+// The candidates aren't checked to exist. Callers should use the first one
+// that resolves.
+func SourceCandidates(raw string, preference []string) []string {
+	if raw == "" || IsRunX(raw) {
+		return []string{raw}
+	}
+	parsed, err := flake.ParseInstallable(raw)
+	if err == nil && !IsAmbiguous(raw, parsed) {
+		return []string{raw}
+	}
+
+	candidates := []string{}
+	name, _, _ := strings.Cut(raw, "@")
+	for _, source := range preference {
+		switch source {
+		case SourceNixpkgs:
+			candidates = append(candidates, raw)
+		case SourceFlake:
+			// Flake references can't carry a devbox version.
+			if err != nil || strings.Contains(raw, "@") {
+				continue
+			}
+			if parsed.Ref.Type == flake.TypePath {
+				candidates = append(candidates, "path:"+raw)
+			} else {
+				candidates = append(candidates, "flake:"+raw)
+			}
+		case SourceRunX:
+			// RunX packages are GitHub repositories in owner/repo form.
+			owner, repo, ok := strings.Cut(name, "/")
+			if ok && owner != "" && repo != "" && !strings.Contains(repo, "/") {
+				candidates = append(candidates, RunXPrefix+raw)
+			}
+		}
+	}
+	return lo.Uniq(append(candidates, raw))
+}
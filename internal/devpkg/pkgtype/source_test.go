@@ -0,0 +1,36 @@
+package pkgtype
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSourceCandidates(t *testing.T) {
+	testCases := []struct {
+		raw        string
+		preference []string
+		expected   []string
+	}{
+		{"hello", nil, []string{"hello"}},
+		{"hello", []string{SourceNixpkgs, SourceFlake}, []string{"hello", "flake:hello"}},
+		{"hello", []string{SourceFlake, SourceNixpkgs}, []string{"flake:hello", "hello"}},
+		{"hello", []string{SourceFlake}, []string{"flake:hello", "hello"}},
+		{"hello@1.2.3", []string{SourceFlake, SourceNixpkgs}, []string{"hello@1.2.3"}},
+		{"owner/repo", []string{SourceRunX, SourceFlake}, []string{"runx:owner/repo", "flake:owner/repo", "owner/repo"}},
+		{"owner/repo@v1.0.0", []string{SourceRunX}, []string{"runx:owner/repo@v1.0.0", "owner/repo@v1.0.0"}},
+		{"owner/repo", []string{SourceFlake, SourceRunX}, []string{"flake:owner/repo", "runx:owner/repo", "owner/repo"}},
+		{"hello", []string{SourceRunX}, []string{"hello"}},
+		{"runx:owner/repo", []string{SourceNixpkgs}, []string{"runx:owner/repo"}},
+		{"github:owner/repo", []string{SourceRunX}, []string{"github:owner/repo"}},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.raw, func(t *testing.T) {
+			got := SourceCandidates(testCase.raw, testCase.preference)
+			if !slices.Equal(got, testCase.expected) {
+				t.Errorf("SourceCandidates(%q, %v) = %q, want %q",
+					testCase.raw, testCase.preference, got, testCase.expected)
+			}
+		})
+	}
+}
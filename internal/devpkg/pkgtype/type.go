@@ -0,0 +1,15 @@
+package pkgtype
+
+// Type is the kind of a package, which decides how Devbox installs it.
+type Type string
+
+const (
+	// Nix packages are installed with Nix. They're the Devbox packages from
+	// the search index, legacy nixpkgs packages and flakes.
+	Nix Type = "nix"
+	// Flake packages are flake installables, such as "github:owner/repo#pkg".
+	// They're also Nix packages.
+	Flake Type = "flake"
+	// RunX packages are installed with runx, such as "runx:owner/repo".
+	RunX Type = "runx"
+)
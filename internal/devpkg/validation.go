@@ -2,10 +2,13 @@ package devpkg
 
 import (
 	"context"
+	"path/filepath"
 	"strings"
 
 	"go.jetpack.io/devbox/internal/boxcli/usererr"
+	"go.jetpack.io/devbox/internal/fileutil"
 	"go.jetpack.io/devbox/internal/nix"
+	"go.jetpack.io/devbox/nix/flake"
 )
 
 func (p *Package) ValidateExists(ctx context.Context) (bool, error) {
@@ -22,11 +25,68 @@ func (p *Package) ValidateExists(ctx context.Context) (bool, error) {
 		return false, err
 	}
 	if inCache {
-		return true, nil
+		return true, p.validateOutputs()
+	}
+	if hash := p.HashFromNixPkgsURL(); hash != "" {
+		if err := nix.CheckNixpkgsNetworkPolicy(hash); err != nil {
+			return false, err
+		}
 	}
 
 	info, err := p.NormalizedPackageAttributePath()
-	return info != "", err
+	if info == "" || err != nil {
+		return false, err
+	}
+	return true, p.validateOutputs()
+}
+
+// ValidateFlakeEvaluates checks that a flake package evaluates to a
+// derivation, without building it. Flakes aren't in the Devbox search index,
+// so ValidateExists can't tell whether they exist.
+func (p *Package) ValidateFlakeEvaluates(ctx context.Context) error {
+	installable, err := p.urlForInstall()
+	if err != nil {
+		return err
+	}
+	if _, err := nix.InstantiateInstallable(ctx, installable, p.AllowInsecure); err != nil {
+		return usererr.WithUserMessage(err, "Unable to evaluate flake %s.", p.Raw)
+	}
+	return nil
+}
+
+// LocalFlakeDir returns the absolute directory of a local path flake, such as
+// path:./mypkg, or an empty string if the package isn't a local flake.
+func (p *Package) LocalFlakeDir() string {
+	if p.installable.Ref.Type != flake.TypePath {
+		return ""
+	}
+	return p.installable.Ref.Path
+}
+
+// ValidateLocalFlake checks that a local path flake has a flake.nix and that
+// its package evaluates. Local flakes are never in a binary cache, so without
+// this a mistake in the flake only shows up as a failed build. It does nothing
+// for other packages.
+func (p *Package) ValidateLocalFlake(ctx context.Context) error {
+	dir := p.LocalFlakeDir()
+	if dir == "" {
+		return nil
+	}
+	if !fileutil.Exists(filepath.Join(dir, "flake.nix")) {
+		return usererr.New("Package %s refers to the local directory %s, which has no flake.nix.", p.Raw, dir)
+	}
+	installable, err := p.urlForInstall()
+	if err != nil {
+		return err
+	}
+	if _, err := nix.InstantiateInstallable(ctx, installable, p.AllowInsecure); err != nil {
+		return usererr.WithUserMessage(
+			err,
+			"Unable to evaluate the local flake for package %s in %s. Run `nix flake check %s` to see what's wrong.",
+			p.Raw, dir, dir,
+		)
+	}
+	return nil
 }
 
 func (p *Package) ValidateInstallsOnSystem() (bool, error) {
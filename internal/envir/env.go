@@ -10,12 +10,25 @@ const (
 	// DevboxLatestVersion is the latest version available of the devbox CLI binary.
 	// NOTE: it should NOT start with v (like 0.4.8)
 	DevboxLatestVersion  = "DEVBOX_LATEST_VERSION"
+	DevboxNetworkPolicy  = "DEVBOX_NETWORK_POLICY"
 	DevboxRegion         = "DEVBOX_REGION"
 	DevboxSearchHost     = "DEVBOX_SEARCH_HOST"
 	DevboxShellEnabled   = "DEVBOX_SHELL_ENABLED"
 	DevboxShellStartTime = "DEVBOX_SHELL_START_TIME"
 	DevboxVM             = "DEVBOX_VM"
 
+	// DevboxSuppressRefreshWarning overrides the suppress_refresh_warning
+	// setting in devbox.json.
+	DevboxSuppressRefreshWarning = "DEVBOX_SUPPRESS_REFRESH_WARNING"
+
+	// DevboxSuppressLargeInstallWarning turns off the warning, and the size
+	// estimate behind it, that devbox add prints before a large install.
+	DevboxSuppressLargeInstallWarning = "DEVBOX_SUPPRESS_LARGE_INSTALL_WARNING"
+
+	// DevboxNetworkAllowedHosts is a comma-separated list of extra hosts that
+	// the strict network policy allows.
+	DevboxNetworkAllowedHosts = "DEVBOX_NETWORK_ALLOWED_HOSTS"
+
 	LauncherVersion = "LAUNCHER_VERSION"
 	LauncherPath    = "LAUNCHER_PATH"
 
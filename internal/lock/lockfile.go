@@ -7,6 +7,7 @@ import (
 	"context"
 	"fmt"
 	"io/fs"
+	"log/slog"
 	"path/filepath"
 	"strings"
 
@@ -31,6 +32,11 @@ type File struct {
 
 	// Packages is keyed by "canonicalName@version"
 	Packages map[string]*Package `json:"packages"`
+
+	// retryOffline is set by RetryOfflineResolutions. offlineRetried has
+	// the packages whose offline entries Resolve already tried to verify.
+	retryOffline   bool
+	offlineRetried map[string]bool
 }
 
 func GetFile(project devboxProject) (*File, error) {
@@ -75,6 +81,10 @@ func (f *File) Remove(pkgs ...string) error {
 func (f *File) Resolve(pkg string) (*Package, error) {
 	entry, hasEntry := f.Packages[pkg]
 
+	if hasEntry && entry.ResolvedOffline {
+		return f.reresolveOffline(pkg, entry), nil
+	}
+
 	if !hasEntry || entry.Resolved == "" {
 		locked := &Package{}
 		var err error
@@ -97,6 +107,78 @@ func (f *File) Resolve(pkg string) (*Package, error) {
 	return f.Packages[pkg], nil
 }
 
+// RetryOfflineResolutions makes Resolve try to verify entries locked by
+// ResolveOffline with the search endpoint, once per package. It's meant for
+// explicit installs, so that other commands don't wait on an unreachable
+// search endpoint every time they resolve a package.
+func (f *File) RetryOfflineResolutions() {
+	f.retryOffline = true
+}
+
+// ResolveOffline locks pkg to the project's nixpkgs commit without querying
+// the search endpoint, for adding packages without network access. version is
+// the package's version in that commit, if known. The entry is flagged as
+// ResolvedOffline so that a later Resolve can verify it. Existing entries are
+// returned unchanged.
+func (f *File) ResolveOffline(pkg, version string) *Package {
+	if entry, ok := f.Packages[pkg]; ok && entry.Resolved != "" {
+		return entry
+	}
+	name, _, versioned := searcher.ParseVersionedPackage(pkg)
+	if !versioned {
+		name = pkg
+	}
+	f.Packages[pkg] = &Package{
+		Resolved:        f.LegacyNixpkgsPath(name),
+		Version:         version,
+		Source:          nixpkgSource,
+		ResolvedOffline: true,
+	}
+	return f.Packages[pkg]
+}
+
+// LockToNixpkgs replaces pkg's entry with one locked to the project's nixpkgs
+// commit, such as after the commit is changed. version is the package's
+// version in that commit, if known.
+func (f *File) LockToNixpkgs(pkg, version string) *Package {
+	name, _, versioned := searcher.ParseVersionedPackage(pkg)
+	if !versioned {
+		name = pkg
+	}
+	f.Packages[pkg] = &Package{
+		Resolved: f.LegacyNixpkgsPath(name),
+		Version:  version,
+		Source:   nixpkgSource,
+	}
+	return f.Packages[pkg]
+}
+
+// reresolveOffline tries to replace an entry locked by ResolveOffline with the
+// search endpoint's resolution. Unversioned packages resolve to the same
+// nixpkgs commit online, so only the flag is cleared. Versioned packages are
+// only looked up after RetryOfflineResolutions, and only once. If the search
+// endpoint is still unreachable, the offline entry is kept.
+func (f *File) reresolveOffline(pkg string, entry *Package) *Package {
+	if _, _, versioned := searcher.ParseVersionedPackage(pkg); !versioned || pkgtype.IsRunX(pkg) {
+		entry.ResolvedOffline = false
+		return entry
+	}
+	if !f.retryOffline || f.offlineRetried[pkg] {
+		return entry
+	}
+	if f.offlineRetried == nil {
+		f.offlineRetried = map[string]bool{}
+	}
+	f.offlineRetried[pkg] = true
+	locked, err := f.FetchResolvedPackage(pkg)
+	if err != nil {
+		slog.Debug("keeping offline lockfile entry", "pkg", pkg, "err", err)
+		return entry
+	}
+	f.Packages[pkg] = locked
+	return locked
+}
+
 // TODO:
 // Consider a design change to have the File struct match disk to make this system
 // easier to reason about, and have isDirty() compare the in-memory struct to the
@@ -134,11 +216,13 @@ func (f *File) Save() error {
 }
 
 func (f *File) LegacyNixpkgsPath(pkg string) string {
-	return fmt.Sprintf(
-		"github:NixOS/nixpkgs/%s#%s",
-		f.NixPkgsCommitHash(),
-		pkg,
-	)
+	return NixpkgsInstallable(f.NixPkgsCommitHash(), pkg)
+}
+
+// NixpkgsInstallable returns the flake installable for attrPath in nixpkgs
+// at the given commit.
+func NixpkgsInstallable(commit, attrPath string) string {
+	return fmt.Sprintf("github:NixOS/nixpkgs/%s#%s", commit, attrPath)
 }
 
 func (f *File) Get(pkg string) *Package {
@@ -231,6 +315,17 @@ func (f *File) isDirty() (bool, error) {
 	return currentHash != filesystemHash, nil
 }
 
+// ReadPackages reads the package entries of the lockfile at path, such as
+// another project's devbox.lock.
+func ReadPackages(path string) (map[string]*Package, error) {
+	lockFile := &File{Packages: map[string]*Package{}}
+	if err := cuecfg.ParseFile(path, lockFile); err != nil {
+		return nil, err
+	}
+	ensurePackagesHaveOutputs(lockFile.Packages)
+	return lockFile.Packages, nil
+}
+
 func lockFilePath(projectDir string) string {
 	return filepath.Join(projectDir, "devbox.lock")
 }
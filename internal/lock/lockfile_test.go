@@ -0,0 +1,51 @@
+package lock
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.jetpack.io/devbox/internal/envir"
+)
+
+func TestResolveRetriesOfflineEntriesOnce(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(server.Close)
+	t.Setenv(envir.DevboxSearchHost, server.URL)
+
+	offline := &Package{Resolved: "github:NixOS/nixpkgs/abc#go", ResolvedOffline: true}
+	legacy := &Package{Resolved: "github:NixOS/nixpkgs/abc#hello", ResolvedOffline: true}
+	f := &File{Packages: map[string]*Package{"go@1.21": offline, "hello": legacy}}
+
+	// Without an explicit install, versioned offline entries are kept as-is
+	// without contacting the search endpoint.
+	got, err := f.Resolve("go@1.21")
+	require.NoError(t, err)
+	require.Same(t, offline, got)
+	require.True(t, got.ResolvedOffline)
+	require.Zero(t, requests.Load())
+
+	// Unversioned entries resolve to the same commit online, so they're
+	// verified without the network.
+	got, err = f.Resolve("hello")
+	require.NoError(t, err)
+	require.False(t, got.ResolvedOffline)
+	require.Zero(t, requests.Load())
+
+	f.RetryOfflineResolutions()
+	got, err = f.Resolve("go@1.21")
+	require.NoError(t, err)
+	require.Same(t, offline, got, "an unreachable search endpoint keeps the offline entry")
+	retried := requests.Load()
+	require.NotZero(t, retried)
+
+	_, err = f.Resolve("go@1.21")
+	require.NoError(t, err)
+	require.Equal(t, retried, requests.Load(), "offline entries are only retried once")
+}
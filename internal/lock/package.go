@@ -14,6 +14,9 @@ const (
 )
 
 type Package struct {
+	// AllowInsecure is only read to migrate old lockfiles. The setting now
+	// lives in the package's allow_insecure field in devbox.json, which is
+	// the source of truth when building packages.
 	AllowInsecure bool   `json:"allow_insecure,omitempty"`
 	LastModified  string `json:"last_modified,omitempty"`
 	PluginVersion string `json:"plugin_version,omitempty"`
@@ -22,6 +25,14 @@ type Package struct {
 	Version       string `json:"version,omitempty"`
 	// Systems is keyed by the system name
 	Systems map[string]*SystemInfo `json:"systems,omitempty"`
+	// ResolvedOffline is true if the package was locked to the project's
+	// nixpkgs commit without querying the search endpoint, because it was
+	// added offline. Resolve re-resolves these entries when it can.
+	ResolvedOffline bool `json:"resolved_offline,omitempty"`
+	// Sha256 is the digest of a runx package's installed files. It's
+	// recorded the first time the package is installed, and later installs
+	// fail if the files don't match it.
+	Sha256 string `json:"sha256,omitempty"`
 
 	// NOTE: if you add more fields, please update SyncLockfiles
 }
@@ -6,6 +6,7 @@ package lock
 import (
 	"errors"
 	"io/fs"
+	"os"
 	"path/filepath"
 
 	"go.jetpack.io/devbox/internal/build"
@@ -48,6 +49,16 @@ func UpdateAndSaveStateHashFile(args UpdateStateHashFileArgs) error {
 	return cuecfg.WriteFile(stateHashFilePath(args.ProjectDir), newLock)
 }
 
+// InvalidateStateHashFile removes the state hash file so that the next
+// IsUpToDateAndInstalled check reports the project as out of date.
+func InvalidateStateHashFile(projectDir string) error {
+	err := os.Remove(stateHashFilePath(projectDir))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
 // SetIgnoreShellMismatch is used to disable the shell comparison when checking
 // if the state is up to date. This is useful when we don't load shellrc (e.g. running)
 func SetIgnoreShellMismatch(ignore bool) {
@@ -0,0 +1,226 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+// Package netpolicy restricts the hosts that Devbox connects to.
+//
+// By default Devbox connects to any host it needs to. Under the strict policy,
+// every outbound request goes through Check, which logs it and fails unless its
+// host is allowlisted. Requests made with Client are checked automatically, and
+// go through the proxy configured with SetProxy or the environment.
+//
+// Each project has its own Rules. The rules of every project opened by the
+// process are enforced together, so opening another project never loosens
+// the policy of one that's already open.
+package netpolicy
+
+import (
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+	"go.jetpack.io/devbox/internal/envir"
+)
+
+// Policy is the network policy that applies to the current process.
+type Policy string
+
+const (
+	// Default allows connections to any host.
+	Default Policy = ""
+	// Strict only allows connections to allowlisted hosts.
+	Strict Policy = "strict"
+)
+
+// Parse parses a policy name. An empty name is the default policy.
+func Parse(s string) (Policy, error) {
+	switch p := Policy(strings.ToLower(strings.TrimSpace(s))); p {
+	case Default, "default":
+		return Default, nil
+	case Strict:
+		return Strict, nil
+	}
+	return Default, usererr.New("Invalid network policy %q. Must be %q or %q.", s, "default", Strict)
+}
+
+// FromEnv returns the policy set by the DEVBOX_NETWORK_POLICY environment
+// variable. An invalid value is treated as the strict policy, so that a typo
+// doesn't silently allow every host.
+func FromEnv() Policy {
+	p, err := Parse(os.Getenv(envir.DevboxNetworkPolicy))
+	if err != nil {
+		return Strict
+	}
+	return p
+}
+
+// AllowedHostsFromEnv returns the extra hosts listed in the
+// DEVBOX_NETWORK_ALLOWED_HOSTS environment variable.
+func AllowedHostsFromEnv() []string {
+	hosts := []string{}
+	for _, h := range strings.Split(os.Getenv(envir.DevboxNetworkAllowedHosts), ",") {
+		if h = strings.TrimSpace(h); h != "" {
+			hosts = append(hosts, h)
+		}
+	}
+	return hosts
+}
+
+// Rules is a network policy and the hosts that it allows.
+type Rules struct {
+	policy  Policy
+	hosts   []string
+	allowed map[string]bool
+
+	// OnDeny, if set, is called with each URL that the rules reject, so that
+	// the rejected requests can be reported to the user.
+	OnDeny func(rawURL string)
+}
+
+// NewRules returns the rules for policy p. Under the strict policy, only the
+// given hosts are allowed. Each host may be a bare host name or a URL, such as
+// a substituter URI.
+func NewRules(p Policy, hosts ...string) *Rules {
+	r := &Rules{policy: p, hosts: hosts, allowed: map[string]bool{}}
+	for _, h := range hosts {
+		if h = hostname(h); h != "" {
+			r.allowed[h] = true
+		}
+	}
+	return r
+}
+
+// Policy returns the rules' policy.
+func (r *Rules) Policy() Policy {
+	return r.policy
+}
+
+// Hosts returns the hosts that the rules allow under the strict policy, as
+// they were given to NewRules.
+func (r *Rules) Hosts() []string {
+	return r.hosts
+}
+
+// IsStrict reports if the rules use the strict policy.
+func (r *Rules) IsStrict() bool {
+	return r.policy == Strict
+}
+
+// allows reports if the rules allow connecting to rawURL.
+func (r *Rules) allows(rawURL string) bool {
+	return r.policy != Strict || r.allowed[hostname(rawURL)]
+}
+
+// Check returns an error if the rules don't allow connecting to rawURL.
+// Every checked URL is logged, so that the requests made under the strict
+// policy can be audited.
+func (r *Rules) Check(rawURL string) error {
+	if !r.allows(rawURL) {
+		host := hostname(rawURL)
+		slog.Warn("network policy rejected request", "url", rawURL)
+		if r.OnDeny != nil {
+			r.OnDeny(rawURL)
+		}
+		return usererr.New(
+			"The strict network policy doesn't allow connecting to %q (%s). "+
+				"Add the host to DEVBOX_NETWORK_ALLOWED_HOSTS to allow it.",
+			host, rawURL,
+		)
+	}
+	if r.policy == Strict {
+		slog.Debug("network policy allowed request", "url", rawURL)
+	}
+	return nil
+}
+
+var (
+	mu       sync.RWMutex
+	enforced []*Rules
+)
+
+// Enforce adds r to the rules that apply to every request the process makes,
+// such as the ones made with Client. A request must be allowed by all of
+// them, so enforcing the default policy after a strict one doesn't loosen it.
+func Enforce(r *Rules) {
+	if !r.IsStrict() {
+		return
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	enforced = append(enforced, r)
+	slog.Debug("enforcing strict network policy", "allowed", r.hosts)
+}
+
+// IsStrict reports if the strict policy is in effect. Callers use it to skip
+// optional network calls, such as querying the Jetify cache, that the strict
+// policy would reject anyway.
+func IsStrict() bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	return len(enforced) > 0
+}
+
+// Allows reports if the enforced rules allow connecting to rawURL, without
+// logging or reporting it. It's for deciding whether to make an optional
+// request; use Check for the request itself.
+func Allows(rawURL string) bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	for _, r := range enforced {
+		if !r.allows(rawURL) {
+			return false
+		}
+	}
+	return true
+}
+
+// Check returns an error if the enforced rules don't allow connecting to
+// rawURL.
+func Check(rawURL string) error {
+	mu.RLock()
+	defer mu.RUnlock()
+	for _, r := range enforced {
+		if err := r.Check(rawURL); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Client is the HTTP client to use for requests that the network policy
+// applies to. It uses the proxy set by SetProxy or the environment.
+var Client = &http.Client{Transport: transport{proxyTransport()}}
+
+func proxyTransport() http.RoundTripper {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = proxy
+	return t
+}
+
+type transport struct {
+	base http.RoundTripper
+}
+
+func (t transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := Check(req.URL.String()); err != nil {
+		return nil, err
+	}
+	return t.base.RoundTrip(req)
+}
+
+// hostname returns the lowercase host name, without the port, of a URL or a
+// bare host name.
+func hostname(s string) string {
+	s = strings.TrimSpace(s)
+	if !strings.Contains(s, "://") {
+		s = "//" + s
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Hostname())
+}
@@ -0,0 +1,107 @@
+package netpolicy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// resetEnforced removes the enforced rules when the test ends.
+func resetEnforced(t *testing.T) {
+	t.Cleanup(clearEnforced)
+}
+
+func clearEnforced() {
+	mu.Lock()
+	defer mu.Unlock()
+	enforced = nil
+}
+
+func TestCheck(t *testing.T) {
+	resetEnforced(t)
+
+	Enforce(NewRules(Default))
+	require.False(t, IsStrict())
+	require.NoError(t, Check("https://example.com"))
+
+	rules := NewRules(Strict, "search.devbox.sh", "https://cache.nixos.org/", "s3://My-Bucket?region=us-east-1")
+	denied := []string{}
+	rules.OnDeny = func(rawURL string) { denied = append(denied, rawURL) }
+	Enforce(rules)
+	require.True(t, IsStrict())
+	require.NoError(t, Check("https://search.devbox.sh/v2/resolve?name=go"))
+	require.NoError(t, Check("https://cache.nixos.org:443/abc.narinfo"))
+	require.NoError(t, Check("s3://my-bucket"))
+	require.Error(t, Check("https://github.com"))
+	require.Error(t, Check("https://search.devbox.sh.example.com"))
+	require.Equal(t, []string{"https://github.com", "https://search.devbox.sh.example.com"}, denied)
+
+	// Another project with the default policy doesn't loosen the strict one.
+	Enforce(NewRules(Default))
+	require.True(t, IsStrict())
+	require.Error(t, Check("https://github.com"))
+}
+
+func TestClient(t *testing.T) {
+	resetEnforced(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	Enforce(NewRules(Strict, "search.devbox.sh"))
+	_, err := Client.Get(server.URL)
+	require.ErrorContains(t, err, "doesn't allow connecting to \"127.0.0.1\"")
+
+	clearEnforced()
+	Enforce(NewRules(Strict, server.URL))
+	res, err := Client.Get(server.URL)
+	require.NoError(t, err)
+	res.Body.Close()
+}
+
+func TestParse(t *testing.T) {
+	for in, want := range map[string]Policy{"": Default, "default": Default, " Strict ": Strict} {
+		got, err := Parse(in)
+		require.NoError(t, err)
+		require.Equal(t, want, got)
+	}
+	_, err := Parse("strcit")
+	require.Error(t, err)
+}
+
+func TestSetProxy(t *testing.T) {
+	for _, name := range []string{"HTTP_PROXY", "HTTPS_PROXY", "http_proxy", "https_proxy"} {
+		t.Setenv(name, "")
+	}
+	t.Setenv("NO_PROXY", "localhost,.internal.example.com")
+	t.Cleanup(func() { _ = SetProxy("") })
+
+	require.Error(t, SetProxy("proxy.example.com"))
+
+	require.NoError(t, SetProxy("http://proxy.example.com:8080"))
+	require.Contains(t, ProxyEnv(), "HTTPS_PROXY=http://proxy.example.com:8080")
+	// The proxy is passed to subprocesses, but isn't set in the environment.
+	require.Empty(t, os.Getenv("HTTPS_PROXY"))
+	req := httptest.NewRequest(http.MethodGet, "https://search.devbox.sh/v2/resolve", nil)
+	u, err := proxy(req)
+	require.NoError(t, err)
+	require.Equal(t, "http://proxy.example.com:8080", u.String())
+	req = httptest.NewRequest(http.MethodGet, "https://cache.internal.example.com/abc.narinfo", nil)
+	u, err = proxy(req)
+	require.NoError(t, err)
+	require.Nil(t, u)
+
+	// The environment takes precedence over the configured proxy.
+	t.Setenv("HTTPS_PROXY", "http://other.example.com:3128")
+	require.NoError(t, SetProxy("http://proxy.example.com:8080"))
+	require.Nil(t, proxyURL)
+	require.Nil(t, ProxyEnv())
+
+	// A later project without a proxy clears it.
+	t.Setenv("HTTPS_PROXY", "")
+	require.NoError(t, SetProxy("http://proxy.example.com:8080"))
+	require.NoError(t, SetProxy(""))
+	require.Nil(t, ProxyEnv())
+}
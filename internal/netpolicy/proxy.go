@@ -0,0 +1,106 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package netpolicy
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
+)
+
+// proxyURL is the proxy set by SetProxy. It's only set when the proxy
+// environment variables aren't, so it never overrides them.
+var proxyURL *url.URL
+
+// SetProxy configures the HTTP proxy at rawURL for when the HTTP_PROXY and
+// HTTPS_PROXY environment variables aren't set, such as when Devbox is started
+// by an editor. The variables always take precedence.
+//
+// Requests made with Client or http.DefaultTransport, which runx uses, go
+// through the proxy unless their host is in NO_PROXY. Nix makes its own
+// requests, so the nix commands get the proxy from ProxyEnv. The process
+// environment isn't changed, so the proxy doesn't leak into the devbox shell.
+// An empty rawURL only uses the environment, and clears the proxy set by an
+// earlier call.
+func SetProxy(rawURL string) error {
+	var u *url.URL
+	if rawURL != "" {
+		var err error
+		u, err = url.Parse(rawURL)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return usererr.New("Invalid proxy URL %q. It must be a URL such as http://proxy.example.com:8080.", rawURL)
+		}
+	}
+	if u != nil && (proxyFromEnv("HTTP_PROXY") != "" || proxyFromEnv("HTTPS_PROXY") != "") {
+		u = nil
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	proxyURL = u
+	if t, ok := http.DefaultTransport.(*http.Transport); ok {
+		t.Proxy = proxy
+	}
+	return nil
+}
+
+// ProxyEnv returns the environment variables that make a subprocess, such as
+// nix, use the proxy set by SetProxy. It returns nil if no proxy is set, so
+// the subprocess uses the variables it inherits.
+func ProxyEnv() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	if proxyURL == nil {
+		return nil
+	}
+	env := []string{}
+	for _, name := range []string{"HTTP_PROXY", "HTTPS_PROXY", "http_proxy", "https_proxy"} {
+		env = append(env, name+"="+proxyURL.String())
+	}
+	return env
+}
+
+// proxy returns the proxy to use for req: the one set by SetProxy, or the one
+// in the environment.
+func proxy(req *http.Request) (*url.URL, error) {
+	mu.RLock()
+	u := proxyURL
+	mu.RUnlock()
+	if u == nil {
+		return http.ProxyFromEnvironment(req)
+	}
+	if bypassesProxy(req.URL.Hostname(), proxyFromEnv("NO_PROXY")) {
+		return nil, nil
+	}
+	return u, nil
+}
+
+// bypassesProxy reports if host matches the comma-separated noProxy list.
+// An entry matches the host itself and its subdomains, and "*" matches every
+// host.
+func bypassesProxy(host, noProxy string) bool {
+	host = strings.ToLower(host)
+	for _, entry := range strings.Split(noProxy, ",") {
+		entry = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(entry), "."))
+		if entry == "" {
+			continue
+		}
+		if entry == "*" || host == entry || strings.HasSuffix(host, "."+entry) {
+			return true
+		}
+	}
+	return false
+}
+
+// proxyFromEnv returns the value of a proxy environment variable, which may
+// be upper or lower case.
+func proxyFromEnv(name string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+	return os.Getenv(strings.ToLower(name))
+}
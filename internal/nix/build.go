@@ -1,28 +1,101 @@
 package nix
 
 import (
+	"bytes"
 	"context"
+	"fmt"
 	"io"
 	"log/slog"
 	"os"
+	"slices"
+	"strconv"
 	"strings"
 
 	"go.jetpack.io/devbox/internal/debug"
+	"go.jetpack.io/devbox/internal/redact"
 )
 
+// BuildVerbosity controls how much of the nix build output is shown.
+type BuildVerbosity string
+
+const (
+	// BuildVerbosityDefault shows nix's regular progress output.
+	BuildVerbosityDefault BuildVerbosity = ""
+	// BuildVerbosityVerbose also streams the full build logs of every
+	// derivation that's built.
+	BuildVerbosityVerbose BuildVerbosity = "verbose"
+	// BuildVerbosityQuiet hides the build output unless the build fails.
+	BuildVerbosityQuiet BuildVerbosity = "quiet"
+)
+
+// ParseBuildVerbosity returns the BuildVerbosity named by s.
+func ParseBuildVerbosity(s string) (BuildVerbosity, error) {
+	switch v := BuildVerbosity(s); v {
+	case BuildVerbosityDefault, BuildVerbosityVerbose, BuildVerbosityQuiet:
+		return v, nil
+	case "default":
+		return BuildVerbosityDefault, nil
+	}
+	return "", fmt.Errorf("invalid build verbosity %q, must be one of: default, verbose, quiet", s)
+}
+
 type BuildArgs struct {
-	AllowInsecure     bool
+	// AllowInsecure are the names of insecure packages (such as
+	// "python-2.7.18.1") that may be built. Other insecure packages are
+	// still rejected.
+	AllowInsecure     []string
 	Env               []string
 	ExtraSubstituters []string
 	Flags             []string
-	Writer            io.Writer
+	// Store is the root directory of a non-default Nix store to build
+	// into.
+	Store string
+	// System is the nix system (such as "aarch64-linux") to build for.
+	// Empty means the current system.
+	System    string
+	Verbosity BuildVerbosity
+	Writer    io.Writer
+	// LogTailLines is how many lines at the end of the build log of each
+	// failed derivation to include in the BuildError. Zero doesn't look up
+	// the logs.
+	LogTailLines int
+	// MaxJobs is passed to nix as --max-jobs, the number of builds that
+	// may run in parallel. Nil uses nix's setting.
+	MaxJobs *int
+	// Cores is passed to nix as --cores, the number of CPU cores each build
+	// may use. Zero means all cores, and nil uses nix's setting.
+	Cores *int
+}
+
+// parallelismFlags returns the nix flags for MaxJobs and Cores.
+func (a *BuildArgs) parallelismFlags() []string {
+	flags := []string{}
+	if a.MaxJobs != nil {
+		flags = append(flags, "--max-jobs", strconv.Itoa(*a.MaxJobs))
+	}
+	if a.Cores != nil {
+		flags = append(flags, "--cores", strconv.Itoa(*a.Cores))
+	}
+	return flags
 }
 
 func Build(ctx context.Context, args *BuildArgs, installables ...string) error {
 	defer debug.FunctionTimer().End()
-	// --impure is required for allowUnfreeEnv/allowInsecureEnv to work.
+	// --impure is required for allowUnfreeEnv/permittedInsecureEnv to work.
 	cmd := command("build", "--impure")
+	// The parallelism flags go first so that a package's build_flags can
+	// override them.
+	cmd.Args = appendArgs(cmd.Args, args.parallelismFlags())
 	cmd.Args = appendArgs(cmd.Args, args.Flags)
+	if args.Verbosity == BuildVerbosityVerbose {
+		cmd.Args = append(cmd.Args, "--print-build-logs")
+	}
+	if args.Store != "" {
+		cmd.Args = append(cmd.Args, "--store", args.Store)
+	}
+	if args.System != "" {
+		cmd.Args = append(cmd.Args, "--system", args.System)
+	}
 	cmd.Args = appendArgs(cmd.Args, installables)
 	// Adding extra substituters only here to be conservative, but this could also
 	// be added to ExperimentalFlags() in the future.
@@ -33,9 +106,14 @@ func Build(ctx context.Context, args *BuildArgs, installables ...string) error {
 		)
 	}
 	cmd.Env = append(allowUnfreeEnv(os.Environ()), args.Env...)
-	if args.AllowInsecure {
-		slog.Debug("Setting Allow-insecure env-var\n")
-		cmd.Env = allowInsecureEnv(cmd.Env)
+	if len(args.AllowInsecure) > 0 {
+		slog.Debug("Permitting insecure packages", "pkgs", args.AllowInsecure)
+		env, cleanup, err := permittedInsecureEnv(cmd.Env, args.AllowInsecure)
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+		cmd.Env = env
 	}
 
 	// If nix build runs as tty, the output is much nicer. If we ever
@@ -44,5 +122,114 @@ func Build(ctx context.Context, args *BuildArgs, installables ...string) error {
 	cmd.Stdin = os.Stdin
 	cmd.Stdout = args.Writer
 	cmd.Stderr = args.Writer
-	return cmd.Run(ctx)
+	if args.Verbosity == BuildVerbosityQuiet {
+		// Hold on to the output so that it can still be shown if the build
+		// fails.
+		var output bytes.Buffer
+		cmd.Stdout = &output
+		cmd.Stderr = &output
+		err := cmd.Run(ctx)
+		if err != nil && args.Writer != nil {
+			_, _ = output.WriteTo(args.Writer)
+		}
+		return buildError(ctx, args, err, installables)
+	}
+	return buildError(ctx, args, cmd.Run(ctx), installables)
+}
+
+// BuildError is a failed build. Its message includes the end of the build log
+// of each derivation that failed, since the cause of the failure (such as a
+// missing dependency or a failing test) is usually there.
+type BuildError struct {
+	// Logs maps the store path of each failed derivation to the last
+	// lines of its build log.
+	Logs map[string]string
+	err  error
+}
+
+func (e *BuildError) Error() string {
+	b := &strings.Builder{}
+	b.WriteString(e.err.Error())
+	drvs := make([]string, 0, len(e.Logs))
+	for drv := range e.Logs {
+		drvs = append(drvs, drv)
+	}
+	slices.Sort(drvs)
+	for _, drv := range drvs {
+		fmt.Fprintf(b, "\n\nEnd of the build log of %s:\n%s\n", drv, e.Logs[drv])
+		fmt.Fprintf(b, "Run `nix log %s` to see the full log.", drv)
+	}
+	return b.String()
+}
+
+func (e *BuildError) Unwrap() error {
+	return e.err
+}
+
+func (e *BuildError) Redact() string {
+	// Build logs can contain things like paths and usernames.
+	return redact.Error(e.err).Error()
+}
+
+// buildError adds the build logs of the failed derivations to err, which was
+// returned by building installables. The failed derivations are the ones that
+// still need to be built, but already have a build log.
+func buildError(ctx context.Context, args *BuildArgs, err error, installables []string) error {
+	if err == nil || args.LogTailLines <= 0 || ctx.Err() != nil {
+		return err
+	}
+
+	cmd := command("build", "--impure", "--dry-run")
+	if args.Store != "" {
+		cmd.Args = append(cmd.Args, "--store", args.Store)
+	}
+	if args.System != "" {
+		cmd.Args = append(cmd.Args, "--system", args.System)
+	}
+	cmd.Args = appendArgs(cmd.Args, installables)
+	cmd.Env = append(allowUnfreeEnv(os.Environ()), args.Env...)
+	out, dryRunErr := cmd.CombinedOutput(ctx)
+	if dryRunErr != nil {
+		slog.Debug("failed to find the derivations of a failed build", "err", dryRunErr)
+		return err
+	}
+
+	logs := map[string]string{}
+	for _, drv := range derivationsToBuild(out) {
+		logCmd := command("log", drv)
+		if args.Store != "" {
+			logCmd.Args = append(logCmd.Args, "--store", args.Store)
+		}
+		log, logErr := logCmd.Output(ctx)
+		if logErr != nil || len(bytes.TrimSpace(log)) == 0 {
+			continue
+		}
+		logs[drv] = tailLines(string(log), args.LogTailLines)
+	}
+	if len(logs) == 0 {
+		return err
+	}
+	return &BuildError{Logs: logs, err: err}
+}
+
+// derivationsToBuild returns the derivations listed by nix build --dry-run as
+// the ones that will be built.
+func derivationsToBuild(dryRunOutput []byte) []string {
+	drvs := []string{}
+	for _, line := range strings.Split(string(dryRunOutput), "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "/nix/store/") && strings.HasSuffix(line, ".drv") {
+			drvs = append(drvs, line)
+		}
+	}
+	return drvs
+}
+
+// tailLines returns the last n lines of s.
+func tailLines(s string, n int) string {
+	lines := strings.Split(strings.TrimRight(s, "\n"), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
 }
@@ -0,0 +1,71 @@
+package nix
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseBuildVerbosity(t *testing.T) {
+	cases := map[string]BuildVerbosity{
+		"":        BuildVerbosityDefault,
+		"default": BuildVerbosityDefault,
+		"verbose": BuildVerbosityVerbose,
+		"quiet":   BuildVerbosityQuiet,
+	}
+	for in, want := range cases {
+		got, err := ParseBuildVerbosity(in)
+		if err != nil {
+			t.Errorf("ParseBuildVerbosity(%q) returned error: %v", in, err)
+		}
+		if got != want {
+			t.Errorf("ParseBuildVerbosity(%q) = %q, want %q", in, got, want)
+		}
+	}
+	if _, err := ParseBuildVerbosity("loud"); err == nil {
+		t.Error("ParseBuildVerbosity(\"loud\") returned nil error")
+	}
+}
+
+func TestParallelismFlags(t *testing.T) {
+	args := &BuildArgs{}
+	if got := args.parallelismFlags(); len(got) != 0 {
+		t.Errorf("got flags %v with no settings, want none", got)
+	}
+
+	maxJobs, cores := 0, 2
+	args = &BuildArgs{MaxJobs: &maxJobs, Cores: &cores}
+	want := "--max-jobs 0 --cores 2"
+	if got := strings.Join(args.parallelismFlags(), " "); got != want {
+		t.Errorf("got flags %q, want %q", got, want)
+	}
+}
+
+func TestDerivationsToBuild(t *testing.T) {
+	out := `these 2 derivations will be built:
+  /nix/store/aaa-hello-2.12.drv
+  /nix/store/bbb-env.drv
+these 3 paths will be fetched (1.20 MiB download, 5.10 MiB unpacked):
+  /nix/store/ccc-glibc-2.38
+`
+	got := derivationsToBuild([]byte(out))
+	want := []string{"/nix/store/aaa-hello-2.12.drv", "/nix/store/bbb-env.drv"}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("derivationsToBuild() = %v, want %v", got, want)
+	}
+}
+
+func TestBuildErrorMessage(t *testing.T) {
+	err := &BuildError{
+		Logs: map[string]string{"/nix/store/aaa-hello.drv": tailLines("one\ntwo\nthree\n", 2)},
+		err:  errors.New("exit status 1"),
+	}
+	want := "exit status 1\n\nEnd of the build log of /nix/store/aaa-hello.drv:\ntwo\nthree\n" +
+		"Run `nix log /nix/store/aaa-hello.drv` to see the full log."
+	if got := err.Error(); got != want {
+		t.Errorf("got error message:\n%s\nwant:\n%s", got, want)
+	}
+	if got := err.Redact(); strings.Contains(got, "three") {
+		t.Errorf("redacted message %q contains the build log", got)
+	}
+}
@@ -7,6 +7,9 @@ import (
 	"os"
 )
 
+// CopyInstallableToCache copies installable to the cache at to. The insecure
+// packages named in allowInsecure may be evaluated; any other insecure package
+// is an error.
 func CopyInstallableToCache(
 	ctx context.Context,
 	out io.Writer,
@@ -16,11 +19,12 @@ func CopyInstallableToCache(
 	// TODO: Add support for store paths in flake.Installable
 	to, installable string,
 	env []string,
+	allowInsecure []string,
 ) error {
 	fmt.Fprintf(out, "Copying %s to %s\n", installable, to)
 	cmd := command(
 		"copy", "--to", to,
-		// --impure makes NIXPKGS_ALLOW_* environment variables work.
+		// --impure makes NIXPKGS_ALLOW_UNFREE and NIXPKGS_CONFIG work.
 		"--impure",
 		// --refresh checks the cache to ensure it is up to date. Otherwise if
 		// anything has was copied previously from this machine and then purged
@@ -32,7 +36,49 @@ func CopyInstallableToCache(
 	cmd.Stdin = os.Stdin
 	cmd.Stdout = out
 	cmd.Stderr = out
-	cmd.Env = append(allowUnfreeEnv(allowInsecureEnv(os.Environ())), env...)
+	cmdEnv, cleanup, err := permittedInsecureEnv(append(allowUnfreeEnv(os.Environ()), env...), allowInsecure)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	cmd.Env = cmdEnv
 
 	return cmd.Run(ctx)
 }
+
+// StorePathsInCache returns a map of store paths to whether the binary cache
+// at cacheURI has them, which means they can be substituted from it.
+func StorePathsInCache(ctx context.Context, cacheURI string, storePaths []string) (map[string]bool, error) {
+	if len(storePaths) == 0 {
+		return map[string]bool{}, nil
+	}
+	cmd := command("path-info", "--json", "--store", cacheURI)
+	cmd.Args = appendArgs(cmd.Args, storePaths)
+	output, err := cmd.Output(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return parseStorePathFromInstallableOutput(output)
+}
+
+// CopyStorePathsToCache copies storePaths, but not the rest of their closures,
+// to the cache at to. The paths are copied from the store at from, or from the
+// default store if from is empty.
+func CopyStorePathsToCache(
+	ctx context.Context,
+	out io.Writer,
+	from, to string,
+	storePaths []string,
+	env []string,
+) error {
+	fmt.Fprintf(out, "Copying %d store paths to %s\n", len(storePaths), to)
+	cmd := command("copy", "--to", to, "--no-recursive")
+	if from != "" {
+		cmd.Args = append(cmd.Args, "--from", from)
+	}
+	cmd.Args = appendArgs(cmd.Args, storePaths)
+	cmd.Stdout = out
+	cmd.Stderr = out
+	cmd.Env = append(os.Environ(), env...)
+	return cmd.Run(ctx)
+}
@@ -9,11 +9,14 @@ import (
 	"log/slog"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"slices"
 	"strconv"
 	"strings"
 	"syscall"
 	"time"
+
+	"go.jetpack.io/devbox/internal/netpolicy"
 )
 
 type cmd struct {
@@ -128,6 +131,12 @@ func (c *cmd) initExecCommand(ctx context.Context) *exec.Cmd {
 	args := c.Args.StringSlice()
 	c.execCmd = exec.CommandContext(ctx, args[0], args[1:]...)
 	c.execCmd.Env = c.Env
+	if proxyEnv := netpolicy.ProxyEnv(); len(proxyEnv) > 0 {
+		if c.execCmd.Env == nil {
+			c.execCmd.Env = os.Environ()
+		}
+		c.execCmd.Env = append(c.execCmd.Env, proxyEnv...)
+	}
 	c.execCmd.Stdin = c.Stdin
 	c.execCmd.Stdout = c.Stdout
 	c.execCmd.Stderr = c.Stderr
@@ -331,6 +340,74 @@ func allowUnfreeEnv(curEnv []string) []string {
 	return append(curEnv, "NIXPKGS_ALLOW_UNFREE=1")
 }
 
-func allowInsecureEnv(curEnv []string) []string {
-	return append(curEnv, "NIXPKGS_ALLOW_INSECURE=1")
+// permittedInsecureEnv returns curEnv with NIXPKGS_CONFIG pointing to a
+// nixpkgs config that permits only the named insecure packages (such as
+// "python-2.7.18.1"), instead of allowing all insecure packages. The user's
+// nixpkgs config, if any, is imported and extended so that its other settings
+// still apply. The command must run with --impure for nixpkgs to read it. The
+// caller must call cleanup once the command has finished.
+func permittedInsecureEnv(curEnv, permitted []string) (env []string, cleanup func(), err error) {
+	if len(permitted) == 0 {
+		return curEnv, func() {}, nil
+	}
+	f, err := os.CreateTemp("", "devbox-nixpkgs-config-*.nix")
+	if err != nil {
+		return nil, nil, fmt.Errorf("create nixpkgs config: %w", err)
+	}
+	defer f.Close()
+	cleanup = func() { os.Remove(f.Name()) }
+
+	quoted := make([]string, len(permitted))
+	for i, name := range permitted {
+		quoted[i] = strconv.Quote(name)
+	}
+	config := fmt.Sprintf("{ permittedInsecurePackages = [ %s ]; }\n", strings.Join(quoted, " "))
+	if userConfig := userNixpkgsConfig(curEnv); userConfig != "" {
+		// Like nixpkgs, call the user's config if it's a function.
+		config = fmt.Sprintf(`args:
+let
+  user = import %s;
+  userConfig = if builtins.isFunction user then user args else user;
+in
+userConfig // {
+  permittedInsecurePackages = (userConfig.permittedInsecurePackages or [ ]) ++ [ %s ];
+}
+`, strconv.Quote(userConfig), strings.Join(quoted, " "))
+	}
+	if _, err := f.WriteString(config); err != nil {
+		cleanup()
+		return nil, nil, fmt.Errorf("write nixpkgs config: %w", err)
+	}
+	return append(curEnv, "NIXPKGS_CONFIG="+f.Name()), cleanup, nil
+}
+
+// userNixpkgsConfig returns the path of the nixpkgs config that nixpkgs reads
+// with env, or an empty string if there's none. Like nixpkgs, it looks at
+// NIXPKGS_CONFIG, then ~/.config/nixpkgs/config.nix and ~/.nixpkgs/config.nix.
+func userNixpkgsConfig(env []string) string {
+	lookup := func(name string) string {
+		value := ""
+		for _, kv := range env {
+			if k, v, ok := strings.Cut(kv, "="); ok && k == name {
+				value = v // the last one wins, like in exec.Cmd
+			}
+		}
+		return value
+	}
+	paths := []string{lookup("NIXPKGS_CONFIG")}
+	if home := lookup("HOME"); home != "" {
+		paths = append(paths,
+			filepath.Join(home, ".config", "nixpkgs", "config.nix"),
+			filepath.Join(home, ".nixpkgs", "config.nix"),
+		)
+	}
+	for _, path := range paths {
+		if path == "" {
+			continue
+		}
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path
+		}
+	}
+	return ""
 }
@@ -0,0 +1,103 @@
+package nix
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"go.jetpack.io/devbox/internal/netpolicy"
+)
+
+func TestPermittedInsecureEnv(t *testing.T) {
+	env, cleanup, err := permittedInsecureEnv([]string{"HOME=/home/user"}, []string{"openssl-1.1.1w"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(env) != 2 || !strings.HasPrefix(env[1], "NIXPKGS_CONFIG=") {
+		t.Fatalf("got env %v, want NIXPKGS_CONFIG to be appended", env)
+	}
+	path := strings.TrimPrefix(env[1], "NIXPKGS_CONFIG=")
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "{ permittedInsecurePackages = [ \"openssl-1.1.1w\" ]; }\n"
+	if string(got) != want {
+		t.Errorf("got nixpkgs config %q, want %q", got, want)
+	}
+
+	cleanup()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("got err %v after cleanup, want file to be removed", err)
+	}
+}
+
+func TestCommandProxyEnv(t *testing.T) {
+	for _, name := range []string{"HTTP_PROXY", "HTTPS_PROXY", "http_proxy", "https_proxy"} {
+		t.Setenv(name, "")
+	}
+	t.Cleanup(func() { _ = netpolicy.SetProxy("") })
+
+	if err := netpolicy.SetProxy("http://proxy.example.com:8080"); err != nil {
+		t.Fatal(err)
+	}
+	cmd := command("--version")
+	cmd.Env = []string{"HOME=/home/user"}
+	env := cmd.initExecCommand(context.Background()).Env
+	if !slices.Contains(env, "HOME=/home/user") || !slices.Contains(env, "https_proxy=http://proxy.example.com:8080") {
+		t.Errorf("got env %v, want the command's env and the proxy", env)
+	}
+
+	if err := netpolicy.SetProxy(""); err != nil {
+		t.Fatal(err)
+	}
+	cmd = command("--version")
+	if env := cmd.initExecCommand(context.Background()).Env; env != nil {
+		t.Errorf("got env %v without a proxy, want the inherited environment", env)
+	}
+}
+
+func TestPermittedInsecureEnvMergesUserConfig(t *testing.T) {
+	home := t.TempDir()
+	userConfig := filepath.Join(home, ".config", "nixpkgs", "config.nix")
+	if err := os.MkdirAll(filepath.Dir(userConfig), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(userConfig, []byte("{ allowBroken = true; }\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	env, cleanup, err := permittedInsecureEnv([]string{"HOME=" + home}, []string{"openssl-1.1.1w"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup()
+	got, err := os.ReadFile(strings.TrimPrefix(env[1], "NIXPKGS_CONFIG="))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"import " + strconv.Quote(userConfig),
+		`(userConfig.permittedInsecurePackages or [ ]) ++ [ "openssl-1.1.1w" ]`,
+	} {
+		if !strings.Contains(string(got), want) {
+			t.Errorf("got nixpkgs config %q, want it to contain %q", got, want)
+		}
+	}
+
+	// NIXPKGS_CONFIG takes precedence over the config in the home directory.
+	explicit := filepath.Join(t.TempDir(), "nixpkgs.nix")
+	if err := os.WriteFile(explicit, []byte("{ }\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got := userNixpkgsConfig([]string{"HOME=" + home, "NIXPKGS_CONFIG=" + explicit}); got != explicit {
+		t.Errorf("got user nixpkgs config %q, want %q", got, explicit)
+	}
+	if got := userNixpkgsConfig([]string{"HOME=" + t.TempDir()}); got != "" {
+		t.Errorf("got user nixpkgs config %q without one, want none", got)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"os"
 	"strconv"
+	"strings"
 )
 
 func EvalPackageName(path string) (string, error) {
@@ -59,3 +60,24 @@ func IsInsecureAllowed() bool {
 	allowed, _ := strconv.ParseBool(os.Getenv("NIXPKGS_ALLOW_INSECURE"))
 	return allowed
 }
+
+// InstantiateInstallable evaluates installable and returns the path of its
+// derivation without building it. It's a cheap way to check that a flake
+// installable exists and evaluates on this system. The insecure packages named
+// in allowInsecure may be evaluated.
+func InstantiateInstallable(ctx context.Context, installable string, allowInsecure []string) (string, error) {
+	// --impure for NIXPKGS_ALLOW_UNFREE
+	cmd := command("path-info", "--derivation", "--impure", installable)
+	env, cleanup, err := permittedInsecureEnv(allowUnfreeEnv(os.Environ()), allowInsecure)
+	if err != nil {
+		return "", err
+	}
+	defer cleanup()
+	cmd.Env = env
+
+	out, err := cmd.Output(ctx)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
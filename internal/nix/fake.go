@@ -0,0 +1,34 @@
+// Copyright 2024 Jetify Inc. and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package nix
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// FakeForTest puts a fake nix executable that runs the shell script first on
+// the PATH for the rest of the test, and returns the directory it's in so that
+// tests can add other fake executables to it. The script gets nix's arguments
+// as usual, so it's typically a case statement on "$*".
+//
+// The cached nix version is reset before and after the test, so the version
+// that the script reports for `nix --version` is the one the test sees,
+// regardless of which tests ran before it.
+func FakeForTest(t *testing.T, script string) string {
+	t.Helper()
+	bin := t.TempDir()
+	err := os.WriteFile(filepath.Join(bin, "nix"), []byte("#!/bin/sh\n"+script), 0o755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(filepath.ListSeparator)+os.Getenv("PATH"))
+
+	resetVersion := func() { versionInfo = sync.OnceValues(runNixVersion) }
+	resetVersion()
+	t.Cleanup(resetVersion)
+	return bin
+}
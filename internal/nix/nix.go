@@ -15,6 +15,7 @@ import (
 	"regexp"
 	"runtime"
 	"runtime/trace"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -45,6 +46,16 @@ type PrintDevEnvArgs struct {
 	FlakeDir             string
 	PrintDevEnvCachePath string
 	UsePrintDevEnvCache  bool
+	// Shell is the name of the flake's dev shell to print. The default
+	// shell is used if it's empty.
+	Shell string
+	// Env are KEY=VALUE environment variables to evaluate the flake with,
+	// such as the build_env of packages. Nix only sees them in an impure
+	// evaluation, so they make print-dev-env run with --impure.
+	Env []string
+	// Store is the root of a non-default Nix store to evaluate the flake
+	// against. The default store is used if it's empty.
+	Store string
 }
 
 // PrintDevEnv calls `nix print-dev-env -f <path>` and returns its output. The output contains
@@ -74,9 +85,18 @@ func (*Nix) PrintDevEnv(ctx context.Context, args *PrintDevEnvArgs) (*PrintDevEn
 	}
 
 	if len(data) == 0 {
-		cmd := command("print-dev-env", "--json",
-			"path:"+flakeDirResolved,
-		)
+		installable := "path:" + flakeDirResolved
+		if args.Shell != "" {
+			installable += "#" + args.Shell
+		}
+		cmd := command("print-dev-env", "--json", installable)
+		if args.Store != "" {
+			cmd.Args = append(cmd.Args, "--store", args.Store)
+		}
+		if len(args.Env) > 0 {
+			cmd.Args = append(cmd.Args, "--impure")
+			cmd.Env = append(os.Environ(), args.Env...)
+		}
 		slog.Debug("running print-dev-env cmd", "cmd", cmd)
 		data, err = cmd.Output(ctx)
 		if insecure, insecureErr := IsExitErrorInsecurePackage(err, "" /*pkgName*/, "" /*installable*/); insecure {
@@ -97,6 +117,21 @@ func (*Nix) PrintDevEnv(ctx context.Context, args *PrintDevEnvArgs) (*PrintDevEn
 	return &out, nil
 }
 
+// ReadPrintDevEnvCache returns the output of the last PrintDevEnv that was
+// saved to path, without running nix. The error wraps fs.ErrNotExist if there
+// isn't one.
+func ReadPrintDevEnvCache(path string) (*PrintDevEnvOut, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	var out PrintDevEnvOut
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &out, nil
+}
+
 func savePrintDevEnvCache(path string, out PrintDevEnvOut) error {
 	data, err := json.Marshal(out)
 	if err != nil {
@@ -355,6 +390,11 @@ var nixPlatforms = []string{
 	"armv7l-linux",
 }
 
+// Platforms returns the list of platforms supported by nix.
+func Platforms() []string {
+	return slices.Clone(nixPlatforms)
+}
+
 // EnsureValidPlatform returns an error if the platform is not supported by nix.
 // https://nixos.org/manual/nix/stable/installation/supported-platforms.html
 func EnsureValidPlatform(platforms ...string) error {
@@ -375,6 +415,88 @@ func EnsureValidPlatform(platforms ...string) error {
 	return nil
 }
 
+// platformArchAliases and platformOSAliases map common alternative names, such
+// as the ones used by Go and Docker, to their nix equivalents.
+var (
+	platformArchAliases = map[string]string{
+		"amd64": "x86_64",
+		"x64":   "x86_64",
+		"arm64": "aarch64",
+		"386":   "i686",
+		"armv7": "armv7l",
+	}
+	platformOSAliases = map[string]string{
+		"macos": "darwin",
+		"osx":   "darwin",
+	}
+)
+
+// NormalizePlatforms converts each platform to its nix system name and returns
+// an error listing the valid platforms if one isn't supported. Besides nix
+// system names, it accepts common aliases such as "linux/amd64",
+// "arm64-darwin" or "macos-arm64".
+func NormalizePlatforms(platforms []string) ([]string, error) {
+	normalized := make([]string, 0, len(platforms))
+	for _, p := range platforms {
+		system, ok := normalizePlatform(p)
+		if !ok {
+			return nil, usererr.New(
+				"Unsupported platform: %q. Valid platforms are: %s",
+				p, strings.Join(nixPlatforms, ", "),
+			)
+		}
+		normalized = append(normalized, system)
+	}
+	return normalized, nil
+}
+
+func normalizePlatform(platform string) (string, bool) {
+	platform = strings.ToLower(strings.TrimSpace(platform))
+	if slices.Contains(nixPlatforms, platform) {
+		return platform, true
+	}
+	// The architecture may contain an underscore (x86_64), so only split
+	// on dashes and slashes.
+	first, second, ok := strings.Cut(strings.ReplaceAll(platform, "/", "-"), "-")
+	if !ok {
+		return "", false
+	}
+	for _, parts := range [][2]string{{first, second}, {second, first}} {
+		arch, osName := parts[0], parts[1]
+		if alias, ok := platformArchAliases[arch]; ok {
+			arch = alias
+		}
+		if alias, ok := platformOSAliases[osName]; ok {
+			osName = alias
+		}
+		if system := arch + "-" + osName; slices.Contains(nixPlatforms, system) {
+			return system, true
+		}
+	}
+	return "", false
+}
+
+// PlatformsForSelector returns the nix systems that a platform selector
+// matches. A selector is either an operating system, "linux" or "darwin"
+// ("macos" is an alias), or a single platform in any form that
+// NormalizePlatforms accepts.
+func PlatformsForSelector(selector string) ([]string, error) {
+	osName := strings.ToLower(strings.TrimSpace(selector))
+	if osName == "macos" {
+		osName = "darwin"
+	}
+	if osName == "linux" || osName == "darwin" {
+		platforms := []string{}
+		for _, p := range nixPlatforms {
+			if strings.HasSuffix(p, "-"+osName) {
+				platforms = append(platforms, p)
+			}
+		}
+		return platforms, nil
+	}
+	return NormalizePlatforms([]string{selector})
+}
+
 // Warning: be careful using the bins in default/bin, they won't always match bins
 // produced by the flakes.nix. Use devbox.NixBins() instead.
 func ProfileBinPath(projectDir string) string {
@@ -2,7 +2,10 @@
 package nix
 
 import (
+	"context"
+	"path/filepath"
 	"slices"
+	"strings"
 	"testing"
 )
 
@@ -269,3 +272,47 @@ func TestVersionInfoAtLeast(t *testing.T) {
 		info.AtLeast(v)
 	})
 }
+
+func TestNormalizePlatforms(t *testing.T) {
+	got, err := NormalizePlatforms([]string{
+		"x86_64-linux", "linux/amd64", "arm64-darwin", "macos-arm64", "Linux-ARM64",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"x86_64-linux", "x86_64-linux", "aarch64-darwin", "aarch64-darwin", "aarch64-linux"}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	for _, invalid := range []string{"linux", "x86_64", "x86_64-windows", "linux-x86-64"} {
+		if _, err := NormalizePlatforms([]string{invalid}); err == nil {
+			t.Errorf("NormalizePlatforms(%q) returned no error", invalid)
+		}
+	}
+}
+
+func TestPrintDevEnvPassesEnv(t *testing.T) {
+	// A fake nix that reports its arguments and MYTOOL_ENABLE_GPU as
+	// variables of the environment.
+	FakeForTest(t, `printf '{"variables":{"ARGS":{"type":"exported","value":"%s"},"GPU":{"type":"exported","value":"%s"}}}' "$*" "$MYTOOL_ENABLE_GPU"
+`)
+
+	flakeDir := t.TempDir()
+	out, err := (&Nix{}).PrintDevEnv(context.Background(), &PrintDevEnvArgs{
+		FlakeDir:             flakeDir,
+		PrintDevEnvCachePath: filepath.Join(t.TempDir(), "cache"),
+		Shell:                "ungrouped",
+		Env:                  []string{"MYTOOL_ENABLE_GPU=1"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := out.Variables["GPU"].Value; got != "1" {
+		t.Errorf("got MYTOOL_ENABLE_GPU=%q in print-dev-env, want %q", got, "1")
+	}
+	args, _ := out.Variables["ARGS"].Value.(string)
+	if !strings.Contains(args, "--impure") || !strings.Contains(args, "#ungrouped") {
+		t.Errorf("got print-dev-env args %q, want --impure and the ungrouped shell", args)
+	}
+}
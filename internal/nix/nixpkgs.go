@@ -4,6 +4,7 @@
 package nix
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
@@ -13,46 +14,91 @@ import (
 	"path/filepath"
 	"regexp"
 	"strings"
+	"sync"
 
 	"github.com/fatih/color"
 	"github.com/pkg/errors"
 	"go.jetpack.io/devbox/internal/fileutil"
+	"go.jetpack.io/devbox/internal/netpolicy"
 	"go.jetpack.io/devbox/internal/xdg"
 )
 
+// nixpkgsHost is where Nix downloads nixpkgs from when it isn't in the store.
+const nixpkgsHost = "https://github.com"
+
 // EnsureNixpkgsPrefetched runs the prefetch step to download the flake of the registry
+//
+// It's safe to call concurrently for different commits. Each call writes its
+// messages, which name the commit, and the output of nix to w in one piece, so
+// the output of concurrent prefetches isn't interleaved.
 func EnsureNixpkgsPrefetched(w io.Writer, commit string) error {
-	// Look up the cached map of commitHash:nixStoreLocation
-	commitToLocation, err := nixpkgsCommitFileContents()
-	if err != nil {
+	prefetched, err := nixpkgsIsPrefetched(commit)
+	if err != nil || prefetched {
 		return err
 	}
-
-	// Check if this nixpkgs.Commit is located in the local /nix/store
-	location, isPresent := commitToLocation[commit]
-	if isPresent {
-		if fi, err := os.Stat(location); err == nil && fi.IsDir() {
-			// The nixpkgs for this commit hash is present, so we don't need to prefetch
-			return nil
-		}
+	if err := netpolicy.Check(nixpkgsHost); err != nil {
+		return err
 	}
 
-	fmt.Fprintf(w, "Ensuring nixpkgs registry is downloaded.\n")
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "Ensuring nixpkgs registry %s is downloaded.\n", commit)
 	cmd := command(
 		"flake", "prefetch",
 		FlakeNixpkgs(commit),
 	)
-	cmd.Stdout = w
+	cmd.Stdout = &out
 	cmd.Stderr = cmd.Stdout
-	if err := cmd.Run(context.TODO()); err != nil {
-		fmt.Fprintf(w, "Ensuring nixpkgs registry is downloaded: ")
-		color.New(color.FgRed).Fprintf(w, "Fail\n")
+	err = cmd.Run(context.TODO())
+	fmt.Fprintf(&out, "Ensuring nixpkgs registry %s is downloaded: ", commit)
+	if err != nil {
+		color.New(color.FgRed).Fprintf(&out, "Fail\n")
+	} else {
+		color.New(color.FgGreen).Fprintf(&out, "Success\n")
+	}
+
+	prefetchOutputMu.Lock()
+	_, _ = out.WriteTo(w)
+	prefetchOutputMu.Unlock()
+	if err != nil {
 		return err
 	}
-	fmt.Fprintf(w, "Ensuring nixpkgs registry is downloaded: ")
-	color.New(color.FgGreen).Fprintf(w, "Success\n")
+	return saveToNixpkgsCommitFile(commit)
+}
 
-	return saveToNixpkgsCommitFile(commit, commitToLocation)
+// prefetchOutputMu serializes writing the output of concurrent prefetches.
+var prefetchOutputMu sync.Mutex
+
+// CheckNixpkgsNetworkPolicy returns an error if the nixpkgs commit isn't in
+// the Nix store and the network policy doesn't allow downloading it.
+func CheckNixpkgsNetworkPolicy(commit string) error {
+	if !netpolicy.IsStrict() {
+		return nil
+	}
+	prefetched, err := nixpkgsIsPrefetched(commit)
+	if err != nil || prefetched {
+		return err
+	}
+	return netpolicy.Check(nixpkgsHost)
+}
+
+// nixpkgsIsPrefetched reports if the nixpkgs commit was prefetched and is
+// still in the Nix store.
+func nixpkgsIsPrefetched(commit string) (bool, error) {
+	// Look up the cached map of commitHash:nixStoreLocation
+	nixpkgsCommitFileMu.Lock()
+	commitToLocation, err := nixpkgsCommitFileContents()
+	nixpkgsCommitFileMu.Unlock()
+	if err != nil {
+		return false, err
+	}
+
+	// Check if this nixpkgs.Commit is located in the local /nix/store
+	location, isPresent := commitToLocation[commit]
+	if !isPresent {
+		return false, nil
+	}
+	fi, err := os.Stat(location)
+	return err == nil && fi.IsDir(), nil
 }
 
 func nixpkgsCommitFileContents() (map[string]string, error) {
@@ -70,7 +116,11 @@ func nixpkgsCommitFileContents() (map[string]string, error) {
 	return commitToLocation, errors.WithStack(json.Unmarshal(contents, &commitToLocation))
 }
 
-func saveToNixpkgsCommitFile(commit string, commitToLocation map[string]string) error {
+// nixpkgsCommitFileMu serializes updates to the nixpkgs commit file so that
+// concurrent prefetches of different commits don't overwrite each other.
+var nixpkgsCommitFileMu sync.Mutex
+
+func saveToNixpkgsCommitFile(commit string) error {
 	// Make a query to get the /nix/store path for this commit hash.
 	cmd := command("flake", "prefetch", "--json",
 		FlakeNixpkgs(commit),
@@ -95,6 +145,15 @@ func saveToNixpkgsCommitFile(commit string, commitToLocation map[string]string)
 		return errors.WithStack(err)
 	}
 
+	nixpkgsCommitFileMu.Lock()
+	defer nixpkgsCommitFileMu.Unlock()
+
+	// Re-read the file in case another commit was saved since we checked it.
+	commitToLocation, err := nixpkgsCommitFileContents()
+	if err != nil {
+		return err
+	}
+
 	// write to the map, jsonify it, and write that json to the nixpkgsCommit file
 	commitToLocation[commit] = prefetchData.StorePath
 	serialized, err := json.Marshal(commitToLocation)
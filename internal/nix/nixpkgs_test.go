@@ -0,0 +1,60 @@
+package nix
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/fatih/color"
+)
+
+func TestEnsureNixpkgsPrefetchedOutput(t *testing.T) {
+	defer func(noColor bool) { color.NoColor = noColor }(color.NoColor)
+	color.NoColor = true
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	storePath := t.TempDir()
+	// A fake nix whose prefetch output is split by a pause, so that
+	// concurrent prefetches would interleave if they wrote directly.
+	FakeForTest(t, `for arg; do flake="$arg"; done
+case "$*" in
+*--json*) echo '{"storePath":"`+storePath+`"}' ;;
+*) echo "fetching $flake"; sleep 0.2; echo "fetched $flake" ;;
+esac
+`)
+
+	commits := []string{"aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa", "bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"}
+	var out bytes.Buffer
+	var wg sync.WaitGroup
+	for _, commit := range commits {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := EnsureNixpkgsPrefetched(&out, commit); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 8 {
+		t.Fatalf("got %d lines of output, want 8:\n%s", len(lines), out.String())
+	}
+	// Each prefetch's 4 lines are together and name its commit.
+	for i := 0; i < len(lines); i += 4 {
+		commit := commits[0]
+		if !strings.Contains(lines[i], commit) {
+			commit = commits[1]
+		}
+		for _, line := range lines[i : i+4] {
+			if !strings.Contains(line, commit) {
+				t.Errorf("got line %q in the output of prefetching %s:\n%s", line, commit, out.String())
+			}
+		}
+	}
+	if !strings.HasSuffix(lines[3], "is downloaded: Success") {
+		t.Errorf("got line %q, want the prefetch to succeed", lines[3])
+	}
+}
@@ -19,17 +19,19 @@ import (
 	"go.jetpack.io/devbox/internal/redact"
 )
 
-// ProfileListItems returns a list of the installed packages.
+// ProfileListItems returns a list of the installed packages. If store is
+// empty, the default store is used.
 func ProfileListItems(
 	writer io.Writer,
+	store string,
 	profileDir string,
 ) ([]*NixProfileListItem, error) {
 	defer debug.FunctionTimer().End()
-	output, err := nix.ProfileList(writer, profileDir, true /*useJSON*/)
+	output, err := nix.ProfileList(writer, store, profileDir, true /*useJSON*/)
 	if err != nil {
 		// fallback to legacy profile list
 		// NOTE: maybe we should check the nix version first, instead of falling back on _any_ error.
-		return profileListLegacy(writer, profileDir)
+		return profileListLegacy(writer, store, profileDir)
 	}
 
 	type ProfileListElement struct {
@@ -87,9 +89,10 @@ func ProfileListItems(
 // profileListLegacy lists the items in a nix profile before nix 2.17.0 introduced --json.
 func profileListLegacy(
 	writer io.Writer,
+	store string,
 	profileDir string,
 ) ([]*NixProfileListItem, error) {
-	output, err := nix.ProfileList(writer, profileDir, false /*useJSON*/)
+	output, err := nix.ProfileList(writer, store, profileDir, false /*useJSON*/)
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
@@ -127,6 +130,9 @@ type ProfileListNameOrIndexArgs struct {
 	Writer     io.Writer
 	Package    *devpkg.Package
 	ProfileDir string
+	// Store is the root of a non-default Nix store that the profile's
+	// packages are in. The default store is used if it's empty.
+	Store string
 }
 
 // ProfileListNameOrIndex returns the name or index of args.Package in the nix profile specified by args.ProfileDir,
@@ -135,7 +141,7 @@ func ProfileListNameOrIndex(args *ProfileListNameOrIndexArgs) (string, error) {
 	var err error
 	items := args.Items
 	if items == nil {
-		items, err = ProfileListItems(args.Writer, args.ProfileDir)
+		items, err = ProfileListItems(args.Writer, args.Store, args.ProfileDir)
 		if err != nil {
 			return "", err
 		}
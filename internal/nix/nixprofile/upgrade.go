@@ -11,18 +11,19 @@ import (
 	"go.jetpack.io/devbox/internal/nix"
 )
 
-func ProfileUpgrade(ProfileDir string, pkg *devpkg.Package, lock *lock.File) error {
+func ProfileUpgrade(store, ProfileDir string, pkg *devpkg.Package, lock *lock.File) error {
 	nameOrIndex, err := ProfileListNameOrIndex(
 		&ProfileListNameOrIndexArgs{
 			Lockfile:   lock,
 			Writer:     os.Stderr,
 			Package:    pkg,
 			ProfileDir: ProfileDir,
+			Store:      store,
 		},
 	)
 	if err != nil {
 		return err
 	}
 
-	return nix.ProfileUpgrade(ProfileDir, nameOrIndex)
+	return nix.ProfileUpgrade(store, ProfileDir, nameOrIndex)
 }
@@ -12,17 +12,23 @@ import (
 	"io/fs"
 	"os"
 	"path/filepath"
+	"regexp"
 
 	"github.com/pkg/errors"
 	"go.jetpack.io/devbox/internal/debug"
 	"go.jetpack.io/devbox/internal/redact"
 )
 
-func ProfileList(writer io.Writer, profilePath string, useJSON bool) (string, error) {
+// ProfileList runs `nix profile list` on the profile at profilePath. If store
+// is empty, the default store is used.
+func ProfileList(writer io.Writer, store, profilePath string, useJSON bool) (string, error) {
 	cmd := command("profile", "list", "--profile", profilePath)
 	if useJSON {
 		cmd.Args = append(cmd.Args, "--json")
 	}
+	if store != "" {
+		cmd.Args = append(cmd.Args, "--store", store)
+	}
 	out, err := cmd.Output(context.TODO())
 	if err != nil {
 		return "", redact.Errorf("error running \"nix profile list\": %w", err)
@@ -33,7 +39,10 @@ func ProfileList(writer io.Writer, profilePath string, useJSON bool) (string, er
 type ProfileInstallArgs struct {
 	Installables []string
 	ProfilePath  string
-	Writer       io.Writer
+	// Store is the root of a non-default Nix store that the installables
+	// were built into.
+	Store  string
+	Writer io.Writer
 }
 
 var ErrPriorityConflict = errors.New("priority conflict")
@@ -52,6 +61,9 @@ func ProfileInstall(ctx context.Context, args *ProfileInstallArgs) error {
 		"--priority", nextPriority(args.ProfilePath),
 	)
 
+	if args.Store != "" {
+		cmd.Args = append(cmd.Args, "--store", args.Store)
+	}
 	cmd.Args = appendArgs(cmd.Args, args.Installables)
 	cmd.Env = allowUnfreeEnv(os.Environ())
 
@@ -66,17 +78,23 @@ func ProfileInstall(ctx context.Context, args *ProfileInstallArgs) error {
 	return err
 }
 
-// ProfileRemove removes packages from a profile.
+// ProfileRemove removes packages from a profile. If store is empty, the
+// default store is used.
 // WARNING, don't use indexes, they are not supported by nix 2.20+
-func ProfileRemove(profilePath string, packageNames ...string) error {
+func ProfileRemove(store, profilePath string, packageNames ...string) error {
 	defer debug.FunctionTimer().End()
 	cmd := command(
 		"profile", "remove",
 		"--profile", profilePath,
 		"--impure", // for NIXPKGS_ALLOW_UNFREE
 	)
+	if store != "" {
+		cmd.Args = append(cmd.Args, "--store", store)
+	}
 	cmd.Args = appendArgs(cmd.Args, packageNames)
-	cmd.Env = allowUnfreeEnv(allowInsecureEnv(os.Environ()))
+	// Removing packages doesn't evaluate them, so no insecure packages need
+	// to be permitted.
+	cmd.Env = allowUnfreeEnv(os.Environ())
 	return cmd.Run(context.TODO())
 }
 
@@ -143,3 +161,53 @@ func nextPriority(profilePath string) string {
 	// behaves
 	return fmt.Sprintf("%d", max+1)
 }
+
+// ProfileGenerations returns the store paths of a profile's current generation
+// and of its older generations, which nix keeps as <profile>-<N>-link symlinks
+// next to the profile. It returns an empty current path if the profile doesn't
+// exist.
+func ProfileGenerations(profilePath string) (current string, old []string, err error) {
+	currentLink, err := os.Readlink(profilePath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", nil, nil
+	}
+	if err != nil {
+		return "", nil, errors.WithStack(err)
+	}
+
+	dir := filepath.Dir(profilePath)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", nil, errors.WithStack(err)
+	}
+	generationLink := regexp.MustCompile(`^` + regexp.QuoteMeta(filepath.Base(profilePath)) + `-[0-9]+-link$`)
+	for _, entry := range entries {
+		if !generationLink.MatchString(entry.Name()) {
+			continue
+		}
+		target, err := os.Readlink(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return "", nil, errors.WithStack(err)
+		}
+		if entry.Name() == filepath.Base(currentLink) {
+			current = target
+		} else {
+			old = append(old, target)
+		}
+	}
+	return current, old, nil
+}
+
+// ProfileWipeHistory deletes every generation of a profile except the current
+// one. The store paths of the deleted generations stay in the store until
+// they're garbage collected. If store is empty, the default store is used.
+func ProfileWipeHistory(ctx context.Context, store, profilePath string) error {
+	cmd := command("profile", "wipe-history", "--profile", profilePath)
+	if store != "" {
+		cmd.Args = append(cmd.Args, "--store", store)
+	}
+	if err := cmd.Run(ctx); err != nil {
+		return redact.Errorf("error running \"nix profile wipe-history\": %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package nix
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestProfileGenerations(t *testing.T) {
+	dir := t.TempDir()
+	profilePath := filepath.Join(dir, "default")
+
+	current, old, err := ProfileGenerations(profilePath)
+	if err != nil {
+		t.Fatalf("got error for a missing profile: %v", err)
+	}
+	if current != "" || len(old) != 0 {
+		t.Errorf("got current = %q, old = %v for a missing profile, want none", current, old)
+	}
+
+	links := map[string]string{
+		"default-1-link": "/nix/store/aaa-profile",
+		"default-2-link": "/nix/store/bbb-profile",
+		"default-3-link": "/nix/store/ccc-profile",
+		"other-1-link":   "/nix/store/ddd-profile",
+		"default":        "default-3-link",
+	}
+	for name, target := range links {
+		if err := os.Symlink(target, filepath.Join(dir, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	current, old, err = ProfileGenerations(profilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "/nix/store/ccc-profile"; current != want {
+		t.Errorf("got current = %q, want %q", current, want)
+	}
+	slices.Sort(old)
+	if want := []string{"/nix/store/aaa-profile", "/nix/store/bbb-profile"}; !slices.Equal(old, want) {
+		t.Errorf("got old = %v, want %v", old, want)
+	}
+}
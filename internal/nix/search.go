@@ -41,6 +41,17 @@ func Search(url string) (map[string]*Info, error) {
 	return searchSystem(url, "" /* system */)
 }
 
+// SearchOffline is like Search, but only uses flakes that are already in the
+// local Nix store or cache. It never downloads nixpkgs.
+func SearchOffline(url string) (map[string]*Info, error) {
+	cmd := command("search", url, "^" /*regex*/, "--json", "--offline")
+	out, err := cmd.Output(context.TODO())
+	if err != nil {
+		return nil, fmt.Errorf("error searching for pkg %s offline: %w", url, err)
+	}
+	return parseSearchResults(out), nil
+}
+
 func parseSearchResults(data []byte) map[string]*Info {
 	var results map[string]map[string]any
 	err := json.Unmarshal(data, &results)
@@ -104,9 +115,11 @@ func searchSystem(url, system string) (map[string]*Info, error) {
 	}
 	out, err := cmd.Output(context.TODO())
 	if err != nil {
-		// for now, assume all errors are invalid packages.
-		// TODO: check the error string for "did not find attribute" and
-		// return ErrPackageNotFound only for that case.
+		// Other errors, such as failing to download nixpkgs, don't mean
+		// that the package doesn't exist.
+		if strings.Contains(err.Error(), "does not provide attribute") {
+			return nil, fmt.Errorf("error searching for pkg %s: %w", url, ErrPackageNotFound)
+		}
 		return nil, fmt.Errorf("error searching for pkg %s: %w", url, err)
 	}
 	return parseSearchResults(out), nil
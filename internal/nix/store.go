@@ -8,8 +8,10 @@ import (
 	"log/slog"
 	"os"
 	"os/exec"
+	"slices"
 	"strings"
 
+	"go.jetpack.io/devbox/internal/boxcli/usererr"
 	"go.jetpack.io/devbox/internal/debug"
 	"go.jetpack.io/devbox/internal/redact"
 	"golang.org/x/exp/maps"
@@ -24,15 +26,27 @@ func StorePathFromHashPart(ctx context.Context, hash, storeAddr string) (string,
 	return strings.TrimSpace(string(resultBytes)), nil
 }
 
-func StorePathsFromInstallable(ctx context.Context, installable string, allowInsecure bool) ([]string, error) {
+// StorePathsFromInstallable returns the store paths of installable. The
+// insecure packages named in allowInsecure may be evaluated; any other
+// insecure package is an error. If store is empty, the default store is
+// queried.
+func StorePathsFromInstallable(ctx context.Context, store, installable string, allowInsecure []string) ([]string, error) {
 	defer debug.FunctionTimer().End()
 	// --impure for NIXPKGS_ALLOW_UNFREE
 	cmd := command("path-info", installable, "--json", "--impure")
+	if store != "" {
+		cmd.Args = append(cmd.Args, "--store", store)
+	}
 	cmd.Env = allowUnfreeEnv(os.Environ())
 
-	if allowInsecure {
-		slog.Debug("Setting Allow-insecure env-var\n")
-		cmd.Env = allowInsecureEnv(cmd.Env)
+	if len(allowInsecure) > 0 {
+		slog.Debug("Permitting insecure packages", "pkgs", allowInsecure)
+		env, cleanup, err := permittedInsecureEnv(cmd.Env, allowInsecure)
+		if err != nil {
+			return nil, err
+		}
+		defer cleanup()
+		cmd.Env = env
 	}
 
 	resultBytes, err := cmd.Output(ctx)
@@ -49,12 +63,16 @@ func StorePathsFromInstallable(ctx context.Context, installable string, allowIns
 }
 
 // StorePathsAreInStore a map of store paths to whether they are in the store.
-func StorePathsAreInStore(ctx context.Context, storePaths []string) (map[string]bool, error) {
+// If store is empty, the default store is checked.
+func StorePathsAreInStore(ctx context.Context, store string, storePaths []string) (map[string]bool, error) {
 	defer debug.FunctionTimer().End()
 	if len(storePaths) == 0 {
 		return map[string]bool{}, nil
 	}
 	cmd := command("path-info", "--offline", "--json")
+	if store != "" {
+		cmd.Args = append(cmd.Args, "--store", store)
+	}
 	cmd.Args = appendArgs(cmd.Args, storePaths)
 	output, err := cmd.Output(ctx)
 	if err != nil {
@@ -99,6 +117,41 @@ func parseStorePathFromInstallableOutput(output []byte) (map[string]bool, error)
 	return nil, fmt.Errorf("failed to parse path-info output: %s", output)
 }
 
+// ValidateStoreRoot checks that root can be used as the root of a Nix store.
+// It creates the directory if needed, checks that the current user can write
+// to it, and asks nix to open the store. The root must be a local directory,
+// not a store URL.
+func ValidateStoreRoot(ctx context.Context, root string) error {
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return usererr.WithUserMessage(err, "Unable to create Nix store root %s.", root)
+	}
+	f, err := os.CreateTemp(root, ".devbox-write-check-*")
+	if err != nil {
+		return usererr.WithUserMessage(err, "Nix store root %s is not writable.", root)
+	}
+	f.Close()
+	os.Remove(f.Name())
+
+	cmd := command("store", storeInfoCmd(), "--store", root)
+	if err := cmd.Run(ctx); err != nil {
+		return usererr.WithUserMessage(err, "Nix doesn't support using %s as a store root.", root)
+	}
+	return nil
+}
+
+// storeInfoCmd returns the nix store subcommand that prints store info.
+func storeInfoCmd() string {
+	// We only need the version to decide which CLI flags to use. We can
+	// ignore the error because an empty version assumes nix.MinVersion.
+	cliVersion, _ := Version()
+	if cliVersion.AtLeast(Version2_19) {
+		// "nix store ping" is deprecated as of 2.19 in favor of
+		// "nix store info".
+		return "info"
+	}
+	return "ping"
+}
+
 // DaemonError reports an unsuccessful attempt to connect to the Nix daemon.
 type DaemonError struct {
 	cmd    string
@@ -132,15 +185,9 @@ func DaemonVersion(ctx context.Context) (string, error) {
 	// ignore the error because an empty version assumes nix.MinVersion.
 	cliVersion, _ := Version()
 
-	storeCmd := "ping"
-	if cliVersion.AtLeast(Version2_19) {
-		// "nix store ping" is deprecated as of 2.19 in favor of
-		// "nix store info".
-		storeCmd = "info"
-	}
 	canJSON := cliVersion.AtLeast(Version2_14)
 
-	cmd := command("store", storeCmd, "--store", "daemon")
+	cmd := command("store", storeInfoCmd(), "--store", "daemon")
 	if canJSON {
 		cmd.Args = append(cmd.Args, "--json")
 	}
@@ -186,3 +233,76 @@ func DaemonVersion(ctx context.Context) (string, error) {
 	}
 	return "", redact.Errorf("parse nix daemon version: %s", redact.Safe(lines[0]))
 }
+
+// StorePathClosure returns the store paths in the closure of paths: the paths
+// themselves and everything they reference, directly or indirectly. If store
+// is empty, the default store is used.
+func StorePathClosure(ctx context.Context, store string, paths []string) ([]string, error) {
+	if len(paths) == 0 {
+		return []string{}, nil
+	}
+	cmd := command("path-info", "--offline", "--recursive")
+	if store != "" {
+		cmd.Args = append(cmd.Args, "--store", store)
+	}
+	cmd.Args = appendArgs(cmd.Args, paths)
+	out, err := cmd.Output(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(string(out)), nil
+}
+
+// LiveStorePaths returns every store path that's alive, which means a garbage
+// collector root, such as a profile, still needs it. It finds all of them in
+// one pass over the roots, without deleting anything. If store is empty, the
+// default store is used.
+func LiveStorePaths(ctx context.Context, store string) ([]string, error) {
+	cmd := &cmd{Args: cmdArgs{"nix-store", "--gc", "--print-live"}, logger: slog.Default()}
+	if store != "" {
+		cmd.Args = append(cmd.Args, "--store", store)
+	}
+	out, err := cmd.Output(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(string(out)), nil
+}
+
+// DeleteDeadStorePaths deletes the given store paths that are dead, which
+// means no garbage collector root, such as another profile, still needs them.
+// Live paths and their closures are left in the store. It returns the paths
+// that were deleted. If store is empty, the default store is used.
+//
+// It lists the live paths once and then deletes all of the dead ones with a
+// single command.
+func DeleteDeadStorePaths(ctx context.Context, store string, paths []string) ([]string, error) {
+	defer debug.FunctionTimer().End()
+
+	if len(paths) == 0 {
+		return []string{}, nil
+	}
+	live, err := LiveStorePaths(ctx, store)
+	if err != nil {
+		return nil, err
+	}
+	isLive := make(map[string]bool, len(live))
+	for _, path := range live {
+		isLive[path] = true
+	}
+	dead := slices.DeleteFunc(slices.Clone(paths), func(path string) bool { return isLive[path] })
+	slog.Debug("not deleting live store paths", "count", len(paths)-len(dead))
+	if len(dead) == 0 {
+		return dead, nil
+	}
+
+	cmd := command("store", "delete")
+	if store != "" {
+		cmd.Args = append(cmd.Args, "--store", store)
+	}
+	cmd.Args = appendArgs(cmd.Args, dead)
+	if _, err := cmd.CombinedOutput(ctx); err != nil {
+		return nil, err
+	}
+	return dead, nil
+}
@@ -1,6 +1,11 @@
 package nix
 
 import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"golang.org/x/exp/maps"
@@ -56,3 +61,87 @@ func TestParseStorePathFromInstallableOutput(t *testing.T) {
 		})
 	}
 }
+
+func TestStoreChecksUseStore(t *testing.T) {
+	log := filepath.Join(t.TempDir(), "args")
+	// A fake nix that records its arguments and reports one valid path.
+	FakeForTest(t, `echo "$*" >> `+log+`
+echo '{"/nix/store/fgkl3qk8p5hnd07b0dhzfky3ys5gxjmq-go-1.22.0":{}}'
+`)
+
+	ctx := context.Background()
+	store := "/tmp/ci-store"
+	if _, err := StorePathsFromInstallable(ctx, store, "nixpkgs#go", nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := StorePathsAreInStore(ctx, store, []string{"/nix/store/fgkl3qk8p5hnd07b0dhzfky3ys5gxjmq-go-1.22.0"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := StorePathsAreInStore(ctx, "", []string{"/nix/store/fgkl3qk8p5hnd07b0dhzfky3ys5gxjmq-go-1.22.0"}); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	calls := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(calls) != 3 {
+		t.Fatalf("got %d nix calls, want 3: %q", len(calls), calls)
+	}
+	for _, call := range calls[:2] {
+		if !strings.Contains(call, "--store "+store) {
+			t.Errorf("got nix call %q, want it to use --store %s", call, store)
+		}
+	}
+	if strings.Contains(calls[2], "--store") {
+		t.Errorf("got nix call %q, want the default store", calls[2])
+	}
+}
+
+func TestProfileCommandsUseStore(t *testing.T) {
+	log := filepath.Join(t.TempDir(), "args")
+	FakeForTest(t, `echo "$*" >> `+log+`
+case "$*" in
+*print-dev-env*) echo '{"variables": {}}';;
+esac
+`)
+
+	ctx := context.Background()
+	store := "/tmp/ci-store"
+	profile := filepath.Join(t.TempDir(), "profile")
+	if _, err := ProfileList(io.Discard, store, profile, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := ProfileRemove(store, profile, "/nix/store/fgkl3qk8p5hnd07b0dhzfky3ys5gxjmq-go-1.22.0"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ProfileUpgrade(store, profile, "go"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ProfileWipeHistory(ctx, store, profile); err != nil {
+		t.Fatal(err)
+	}
+	_, err := (&Nix{}).PrintDevEnv(ctx, &PrintDevEnvArgs{
+		FlakeDir:             t.TempDir(),
+		PrintDevEnvCachePath: filepath.Join(t.TempDir(), "cache.json"),
+		Store:                store,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	calls := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(calls) != 5 {
+		t.Fatalf("got %d nix calls, want 5: %q", len(calls), calls)
+	}
+	for _, call := range calls {
+		if !strings.Contains(call, "--store "+store) {
+			t.Errorf("got nix call %q, want it to use --store %s", call, store)
+		}
+	}
+}
@@ -10,12 +10,15 @@ import (
 	"go.jetpack.io/devbox/internal/ux"
 )
 
-func ProfileUpgrade(ProfileDir, indexOrName string) error {
-	return command(
-		"profile", "upgrade",
-		"--profile", ProfileDir,
-		indexOrName,
-	).Run(context.TODO())
+// ProfileUpgrade upgrades a package in a profile. If store is empty, the
+// default store is used.
+func ProfileUpgrade(store, ProfileDir, indexOrName string) error {
+	cmd := command("profile", "upgrade", "--profile", ProfileDir)
+	if store != "" {
+		cmd.Args = append(cmd.Args, "--store", store)
+	}
+	cmd.Args = append(cmd.Args, indexOrName)
+	return cmd.Run(context.TODO())
 }
 
 func FlakeUpdate(ProfileDir string) error {
@@ -38,7 +38,7 @@ func getBuiltinPluginConfigIfExists(
 	pkg *devpkg.Package,
 	projectDir string,
 ) (*Config, error) {
-	if pkg.DisablePlugin {
+	if pkg.DisablePlugin || pkg.Disabled {
 		return nil, nil
 	}
 	content, err := plugins.BuiltInForPackage(pkg.CanonicalName())
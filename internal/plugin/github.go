@@ -15,6 +15,7 @@ import (
 	"github.com/samber/lo"
 	"go.jetpack.io/devbox/internal/boxcli/usererr"
 	"go.jetpack.io/devbox/internal/cachehash"
+	"go.jetpack.io/devbox/internal/netpolicy"
 	"go.jetpack.io/devbox/nix/flake"
 	"go.jetpack.io/pkg/filecache"
 )
@@ -77,8 +78,7 @@ func (p *githubPlugin) FileContent(subpath string) ([]byte, error) {
 				return nil, 0, err
 			}
 
-			client := &http.Client{}
-			res, err := client.Do(req)
+			res, err := netpolicy.Client.Do(req)
 			if err != nil {
 				return nil, 0, err
 			}
@@ -4,6 +4,8 @@
 package plugin
 
 import (
+	"io"
+
 	"go.jetpack.io/devbox/internal/lock"
 )
 
@@ -11,6 +13,11 @@ type Manager struct {
 	devboxProject
 
 	lockfile *lock.File
+
+	// createFailurePolicy is one of the configfile.PluginFailurePolicy
+	// values. Failures are reported to stderr unless the policy is "fail".
+	createFailurePolicy string
+	stderr              io.Writer
 }
 
 type devboxProject interface {
@@ -38,6 +45,13 @@ func WithDevbox(provider devboxProject) managerOption {
 	}
 }
 
+func WithCreateFailurePolicy(policy string, stderr io.Writer) managerOption {
+	return func(m *Manager) {
+		m.createFailurePolicy = policy
+		m.stderr = stderr
+	}
+}
+
 func (m *Manager) ApplyOptions(opts ...managerOption) {
 	for _, opt := range opts {
 		opt(m)
@@ -16,12 +16,14 @@ import (
 	"text/template"
 
 	"github.com/pkg/errors"
+	"github.com/samber/lo"
 	"github.com/tailscale/hujson"
 	"go.jetpack.io/devbox/internal/devconfig/configfile"
 	"go.jetpack.io/devbox/internal/devpkg"
 	"go.jetpack.io/devbox/internal/lock"
 	"go.jetpack.io/devbox/internal/nix"
 	"go.jetpack.io/devbox/internal/services"
+	"go.jetpack.io/devbox/internal/ux"
 )
 
 const (
@@ -48,6 +50,10 @@ type PluginOnlyData struct {
 	// Useful when we want to replace with flake
 	RemoveTriggerPackage bool   `json:"__remove_trigger_package,omitempty"`
 	Version              string `json:"version"`
+	// EnvPriority decides which plugin's value is used when several plugins
+	// set the same env variable. Higher priority wins. Users can override it
+	// with `plugin_env_priority` in devbox.json.
+	EnvPriority int `json:"env_priority,omitempty"`
 	// Source is the includable that triggered this plugin. There are two ways to include a plugin:
 	// 1. Built-in plugins are triggered by packages (See plugins.builtInMap)
 	// 2. Plugins can be added via the "include" field in devbox.json or plugin.json
@@ -70,7 +76,39 @@ func (c *Config) Services() (services.Services, error) {
 	return nil, nil
 }
 
+// CreateFilesForConfig creates the files and directories the plugin
+// declares in create_files. What happens when one of them can't be created
+// depends on the plugin failure policy:
+//
+//   - "fail" (the default) returns the error.
+//   - "warn" warns about the file and goes on to create the plugin's other
+//     files.
+//   - "skip" stops creating the plugin's files and reports that the rest were
+//     skipped.
+//
+// With "warn" and "skip" a broken plugin doesn't prevent the rest of the
+// environment from being set up.
 func (m *Manager) CreateFilesForConfig(cfg *Config) error {
+	name := cfg.Source.CanonicalName()
+	switch m.createFailurePolicy {
+	case configfile.PluginFailurePolicyWarn:
+		return m.createFilesForConfig(cfg, func(path string, err error) {
+			ux.Fwarning(m.stderr, "Failed to create %s for plugin %s: %v\n", path, name, err)
+		})
+	case configfile.PluginFailurePolicySkip:
+		if err := m.createFilesForConfig(cfg, nil); err != nil {
+			ux.Finfo(m.stderr, "Skipping the remaining files for plugin %s because one could not be created: %v\n", name, err)
+		}
+		return nil
+	}
+	return m.createFilesForConfig(cfg, nil)
+}
+
+// createFilesForConfig creates the plugin's files in order of their paths. If
+// onError is nil, it stops at the first file that can't be created and returns
+// the error. Otherwise, it passes the error to onError and moves on to the
+// next file.
+func (m *Manager) createFilesForConfig(cfg *Config, onError func(path string, err error)) error {
 	virtenvPath := filepath.Join(m.ProjectDir(), VirtenvPath)
 	pkg := cfg.Source
 	locked := m.lockfile.Packages[pkg.LockfileKey()]
@@ -83,31 +121,45 @@ func (m *Manager) CreateFilesForConfig(cfg *Config) error {
 	}
 
 	slog.Debug("creating files for package", "pkg", pkg)
-	for filePath, contentPath := range cfg.CreateFiles {
+	filePaths := lo.Keys(cfg.CreateFiles)
+	slices.Sort(filePaths)
+	for _, filePath := range filePaths {
 		if !m.shouldCreateFile(locked, filePath) {
 			continue
 		}
-
-		dirPath := filepath.Dir(filePath)
-		if contentPath == "" {
-			dirPath = filePath
-		}
-		if err := createDir(dirPath); err != nil {
-			return errors.WithStack(err)
-		}
-
-		if contentPath == "" {
+		err := m.createFileOrDir(pkg, filePath, cfg.CreateFiles[filePath], virtenvPath)
+		if err == nil {
 			continue
 		}
-
-		if err := m.createFile(pkg, filePath, contentPath, virtenvPath); err != nil {
+		if onError == nil {
 			return err
 		}
+		onError(filePath, err)
 	}
 
 	return nil
 }
 
+// createFileOrDir creates the directory at filePath if contentPath is empty.
+// Otherwise, it creates the file's directory and then the file.
+func (m *Manager) createFileOrDir(
+	pkg Includable,
+	filePath, contentPath, virtenvPath string,
+) error {
+	dirPath := filepath.Dir(filePath)
+	if contentPath == "" {
+		dirPath = filePath
+	}
+	if err := createDir(dirPath); err != nil {
+		return errors.WithStack(err)
+	}
+
+	if contentPath == "" {
+		return nil
+	}
+	return m.createFile(pkg, filePath, contentPath, virtenvPath)
+}
+
 func (m *Manager) UpdateLockfileVersion(cfg *Config) error {
 	pkg := cfg.Source
 	locked := m.lockfile.Packages[pkg.LockfileKey()]
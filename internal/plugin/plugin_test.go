@@ -0,0 +1,67 @@
+package plugin
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.jetpack.io/devbox/internal/devconfig/configfile"
+	"go.jetpack.io/devbox/internal/lock"
+	"go.jetpack.io/devbox/nix/flake"
+)
+
+type testProject struct{ dir string }
+
+func (p testProject) AllPackageNamesIncludingRemovedTriggerPackages() []string { return nil }
+func (p testProject) ProjectDir() string                                       { return p.dir }
+
+func TestCreateFilesForConfigFailurePolicy(t *testing.T) {
+	testCases := []struct {
+		policy      string
+		wantErr     bool
+		wantCreated bool
+		wantOutput  string
+	}{
+		{policy: "", wantErr: true},
+		{policy: configfile.PluginFailurePolicyFail, wantErr: true},
+		{policy: configfile.PluginFailurePolicyWarn, wantCreated: true, wantOutput: "Failed to create"},
+		{policy: configfile.PluginFailurePolicySkip, wantOutput: "Skipping the remaining files"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.policy, func(t *testing.T) {
+			dir := t.TempDir()
+			pluginDir := filepath.Join(dir, "plugin")
+			require.NoError(t, os.MkdirAll(pluginDir, 0o755))
+			require.NoError(t, os.WriteFile(filepath.Join(pluginDir, "b.txt"), []byte("b"), 0o644))
+
+			var stderr bytes.Buffer
+			m := NewManager(
+				WithDevbox(testProject{dir}),
+				WithLockfile(&lock.File{}),
+				WithCreateFailurePolicy(tc.policy, &stderr),
+			)
+			// a.txt comes first and its content doesn't exist, so it
+			// fails before b.txt is created.
+			out := filepath.Join(dir, "out")
+			cfg := &Config{PluginOnlyData: PluginOnlyData{
+				Source: &LocalPlugin{ref: flake.Ref{Type: flake.TypePath, Path: pluginDir}, name: "test"},
+				CreateFiles: map[string]string{
+					filepath.Join(out, "a.txt"): "missing.txt",
+					filepath.Join(out, "b.txt"): "b.txt",
+				},
+			}}
+
+			err := m.CreateFilesForConfig(cfg)
+			if tc.wantErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+			_, statErr := os.Stat(filepath.Join(out, "b.txt"))
+			require.Equal(t, tc.wantCreated, statErr == nil, "b.txt created")
+			require.Contains(t, stderr.String(), tc.wantOutput)
+		})
+	}
+}
@@ -11,13 +11,22 @@ import (
 	"github.com/pkg/errors"
 )
 
-func Remove(projectDir string, pkgs []string) error {
-	for _, pkg := range pkgs {
-		if err := os.RemoveAll(filepath.Join(projectDir, VirtenvPath, pkg)); err != nil {
-			return errors.WithStack(err)
+// Remove deletes the virtenv directories of the named plugins and returns the
+// paths, relative to projectDir, of the ones that existed.
+func Remove(projectDir string, names []string) ([]string, error) {
+	removed := []string{}
+	for _, name := range names {
+		relPath := filepath.Join(VirtenvPath, name)
+		path := filepath.Join(projectDir, relPath)
+		if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+			continue
 		}
+		if err := os.RemoveAll(path); err != nil {
+			return removed, errors.WithStack(err)
+		}
+		removed = append(removed, relPath)
 	}
-	return nil
+	return removed, nil
 }
 
 func RemoveInvalidSymlinks(projectDir string) error {
@@ -15,6 +15,7 @@ import (
 	"github.com/pkg/errors"
 	"go.jetpack.io/devbox/internal/build"
 	"go.jetpack.io/devbox/internal/envir"
+	"go.jetpack.io/devbox/internal/netpolicy"
 	"go.jetpack.io/devbox/internal/redact"
 )
 
@@ -27,9 +28,12 @@ type client struct {
 }
 
 func Client() *client {
-	return &client{
-		host: envir.GetValueOrDefault(envir.DevboxSearchHost, searchAPIEndpoint),
-	}
+	return &client{host: Host()}
+}
+
+// Host returns the URL of the search service.
+func Host() string {
+	return envir.GetValueOrDefault(envir.DevboxSearchHost, searchAPIEndpoint)
 }
 
 func (c *client) Search(query string) (*SearchResults, error) {
@@ -94,7 +98,7 @@ func execGet[T any](ctx context.Context, url string) (*T, error) {
 	}
 	req.Header.Set("User-Agent", userAgent)
 
-	response, err := http.DefaultClient.Do(req)
+	response, err := netpolicy.Client.Do(req)
 	if err != nil {
 		return nil, redact.Errorf("GET %s: %w", redact.Safe(url), redact.Safe(err))
 	}
@@ -7,6 +7,7 @@ import (
 	"runtime/trace"
 	"strings"
 
+	"github.com/samber/lo"
 	"go.jetpack.io/devbox/internal/devpkg"
 	"go.jetpack.io/devbox/internal/nix"
 )
@@ -18,6 +19,17 @@ type flakePlan struct {
 	Packages    []*devpkg.Package
 	FlakeInputs []flakeInput
 	System      string
+
+	// UngroupedShell is a second dev shell with only the packages that
+	// don't belong to a group. It's generated with group_profiles, so that
+	// the environment can include only the profiles of some groups.
+	UngroupedShell *flakeShell
+}
+
+// flakeShell has the packages of a dev shell in the flake.
+type flakeShell struct {
+	Packages    []*devpkg.Package
+	FlakeInputs []flakeInput
 }
 
 func newFlakePlan(ctx context.Context, devbox devboxer) (*flakePlan, error) {
@@ -38,24 +50,34 @@ func newFlakePlan(ctx context.Context, devbox devboxer) (*flakePlan, error) {
 		return nil, err
 	}
 
-	flakeInputs := flakeInputs(ctx, packages)
+	inputs := flakeInputs(ctx, packages)
 	nixpkgsInfo := getNixpkgsInfo(devbox.Config().NixPkgsCommitHash())
 
 	// This is an optimization. Try to reuse the nixpkgs info from the flake
 	// inputs to avoid introducing a new one.
-	for _, input := range flakeInputs {
+	for _, input := range inputs {
 		if input.IsNixpkgs() {
 			nixpkgsInfo = getNixpkgsInfo(input.HashFromNixPkgsURL())
 			break
 		}
 	}
 
-	return &flakePlan{
-		FlakeInputs: flakeInputs,
+	plan := &flakePlan{
+		FlakeInputs: inputs,
 		NixpkgsInfo: nixpkgsInfo,
 		Packages:    packages,
 		System:      nix.System(),
-	}, nil
+	}
+	if devbox.Config().Root.GroupProfiles {
+		ungrouped := lo.Filter(packages, func(pkg *devpkg.Package, _ int) bool {
+			return len(pkg.Groups) == 0
+		})
+		plan.UngroupedShell = &flakeShell{
+			Packages:    ungrouped,
+			FlakeInputs: flakeInputs(ctx, ungrouped),
+		}
+	}
+	return plan, nil
 }
 
 func (f *flakePlan) needsGlibcPatch() bool {
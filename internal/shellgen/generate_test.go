@@ -55,6 +55,25 @@ func TestWriteFromTemplate(t *testing.T) {
 		}
 		cmpGoldenFile(t, outPath, "testdata/flake-empty.nix.golden")
 	})
+	t.Run("WriteUngroupedShell", func(t *testing.T) {
+		plan := *testFlakeTmplPlan
+		plan.UngroupedShell = &flakeShell{
+			Packages: []*devpkg.Package{},
+			FlakeInputs: []flakeInput{{
+				Name: "nixpkgs",
+				URL:  "github:NixOS/nixpkgs/b9c00c1d41ccd6385da243415299b39aa73357be",
+				Packages: []*devpkg.Package{
+					devpkg.PackageFromStringWithDefaults("git@latest", locker),
+					devpkg.PackageFromStringWithDefaults("jq@latest", locker),
+				},
+			}},
+		}
+		err = writeFromTemplate(dir, &plan, "flake.nix", "flake.nix")
+		if err != nil {
+			t.Fatal("got error writing flake template:", err)
+		}
+		cmpGoldenFile(t, outPath, "testdata/flake-ungrouped.nix.golden")
+	})
 }
 
 func cmpGoldenFile(t *testing.T, gotPath, wantGoldenPath string) {
@@ -4,12 +4,14 @@
 package shellgen
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"os"
 	"time"
 
 	"go.jetpack.io/devbox/internal/envir"
+	"go.jetpack.io/devbox/internal/netpolicy"
 )
 
 // Contains default nixpkgs used for mkShell
@@ -38,10 +40,19 @@ func nixpkgsMirrorURL(commitHash string) string {
 
 	// Check that the mirror is responsive and has the tar file. We can't
 	// leave this up to Nix because fetchTarball will retry indefinitely.
-	client := &http.Client{Timeout: 3 * time.Second}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
 	mirrorURL := fmt.Sprintf("%s/nixos/nixpkgs/archive/%s.tar.gz", baseURL, commitHash)
-	resp, err := client.Head(mirrorURL)
-	if err != nil || resp.StatusCode != http.StatusOK {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, mirrorURL, nil)
+	if err != nil {
+		return ""
+	}
+	resp, err := netpolicy.Client.Do(req)
+	if err != nil {
+		return ""
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
 		return ""
 	}
 	return mirrorURL
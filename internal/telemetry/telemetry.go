@@ -25,6 +25,7 @@ import (
 	"github.com/pkg/errors"
 	segment "github.com/segmentio/analytics-go"
 	"go.jetpack.io/devbox/internal/devbox/providers/identity"
+	"go.jetpack.io/devbox/internal/netpolicy"
 	"go.jetpack.io/devbox/internal/nix"
 
 	"go.jetpack.io/devbox/internal/build"
@@ -56,7 +57,8 @@ var (
 
 // Start enables telemetry for the current program.
 func Start() {
-	if started || envir.DoNotTrack() || build.SentryDSN == "" || build.TelemetryKey == "" {
+	if started || envir.DoNotTrack() || netpolicy.FromEnv() == netpolicy.Strict ||
+		build.SentryDSN == "" || build.TelemetryKey == "" {
 		return
 	}
 
@@ -94,7 +96,7 @@ func orgID() string {
 
 // Stop stops gathering telemetry and flushes buffered events to disk.
 func Stop() {
-	if !started || !needsFlush.Load() {
+	if !started || !needsFlush.Load() || netpolicy.IsStrict() {
 		return
 	}
 
@@ -107,7 +109,7 @@ func Stop() {
 }
 
 func Event(e EventName, meta Metadata) {
-	if !started {
+	if !started || netpolicy.IsStrict() {
 		return
 	}
 
@@ -138,7 +140,7 @@ func commandEvent(meta Metadata) (id string, msg *segment.Track) {
 // Error reports an error to the telemetry server.
 func Error(err error, meta Metadata) {
 	errToLog := err // use errToLog to avoid shadowing err later. Use err to keep API clean.
-	if !started || errToLog == nil {
+	if !started || errToLog == nil || netpolicy.IsStrict() {
 		return
 	}
 